@@ -0,0 +1,15 @@
+package dto
+
+import "go-echo-mongo/internal/model"
+
+// UpdateMetadataRequest represents the request body for merging metadata attributes
+// Keys set to null are removed from the stored metadata
+type UpdateMetadataRequest struct {
+	Metadata model.Metadata `json:"metadata" validate:"required"`
+}
+
+// MetadataResponse represents the metadata attributes of a model
+type MetadataResponse struct {
+	ID       string         `json:"id"`
+	Metadata model.Metadata `json:"metadata"`
+}
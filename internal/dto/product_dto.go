@@ -1,21 +1,59 @@
 package dto
 
 import (
-	"time"
+	"fmt"
 
 	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // ProductResponse represents a product response
 type ProductResponse struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Stock       int32     `json:"stock"`
-	Category    string    `json:"category"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Price       float64        `json:"price"`
+	Stock       int32          `json:"stock"`
+	Category    string         `json:"category"`
+	OwnerID     string         `json:"owner_id,omitempty"`
+	Metadata    model.Metadata `json:"metadata,omitempty"`
+	CreatedAt   Timestamp      `json:"created_at"`
+	UpdatedAt   Timestamp      `json:"updated_at"`
+
+	LastRestockedAt *Timestamp `json:"last_restocked_at,omitempty"`
+	LastRestockedBy string     `json:"last_restocked_by,omitempty"`
+}
+
+// ProductRevisionResponse represents the state of a product before one of its updates
+type ProductRevisionResponse struct {
+	ID        string           `json:"id"`
+	ProductID string           `json:"product_id"`
+	Snapshot  *ProductResponse `json:"snapshot"`
+	EditedBy  string           `json:"edited_by,omitempty"`
+	EditedAt  Timestamp        `json:"edited_at"`
+}
+
+// RestockProductRequest represents the request body for restocking a product
+type RestockProductRequest struct {
+	Quantity int32 `json:"quantity" validate:"required,gt=0"`
+}
+
+// DecrementStockRequest represents the request body for decrementing a product's stock
+type DecrementStockRequest struct {
+	Quantity int32 `json:"quantity" validate:"required,gt=0"`
+}
+
+// ProductOwnerResponse represents the public fields of a product owner
+type ProductOwnerResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProductWithOwnerResponse represents a product response with its owner embedded
+type ProductWithOwnerResponse struct {
+	*ProductResponse
+	Owner *ProductOwnerResponse `json:"owner,omitempty"`
 }
 
 // CreateProductRequest represents the request body for creating a product
@@ -25,73 +63,153 @@ type CreateProductRequest struct {
 	Price       float64 `json:"price" validate:"required,gt=0"`
 	Stock       int32   `json:"stock" validate:"required,gte=0"`
 	Category    string  `json:"category" validate:"required"`
+	OwnerID     string  `json:"owner_id,omitempty" validate:"omitempty,len=24,hexadecimal"`
 }
 
-// UpdateProductRequest represents the request body for updating a product
+// UpdateProductRequest represents the request body for updating a product.
+// Fields are pointers so that omitted fields can be told apart from zero values.
 type UpdateProductRequest struct {
-	Name        string  `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
-	Description string  `json:"description,omitempty" validate:"omitempty,min=10,max=1000"`
-	Price       float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
-	Stock       int32   `json:"stock,omitempty" validate:"omitempty,gte=0"`
-	Category    string  `json:"category,omitempty" validate:"omitempty"`
+	Name        *string  `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,min=10,max=1000"`
+	Price       *float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
+	Stock       *int32   `json:"stock,omitempty" validate:"omitempty,gte=0"`
+	Category    *string  `json:"category,omitempty" validate:"omitempty"`
 }
 
 // BatchCreateProductsRequest represents the request body for creating multiple products
 type BatchCreateProductsRequest struct {
-	Products []CreateProductRequest `json:"products" validate:"required,min=1,dive"`
+	Products []CreateProductRequest `json:"products" validate:"required,min=1,maxbatch,dive"`
 }
 
 // BatchUpdateProductsRequest represents the request body for updating multiple products
 type BatchUpdateProductsRequest struct {
-	Updates map[string]UpdateProductRequest `json:"updates" validate:"required,min=1"`
+	Updates map[string]UpdateProductRequest `json:"updates" validate:"required,min=1,maxbatch,dive"`
 }
 
 // BatchDeleteProductsRequest represents the request body for deleting multiple products
 type BatchDeleteProductsRequest struct {
-	IDs []string `json:"ids" validate:"required,min=1"`
+	IDs []string `json:"ids" validate:"required,min=1,maxbatch"`
 }
 
 // ProductFilterRequest represents the request body for filtering products
+// The filter fields can also be sent as query parameters
 type ProductFilterRequest struct {
-	Name     string  `json:"name,omitempty"`
-	Category string  `json:"category,omitempty"`
-	MinPrice float64 `json:"min_price,omitempty"`
-	MaxPrice float64 `json:"max_price,omitempty"`
+	Name     string  `json:"name,omitempty" query:"name"`
+	Category string  `json:"category,omitempty" query:"category"`
+	MinPrice float64 `json:"min_price,omitempty" query:"min_price"`
+	MaxPrice float64 `json:"max_price,omitempty" query:"max_price"`
 	Limit    int64   `json:"limit,omitempty"`
 	Skip     int64   `json:"skip,omitempty"`
+	// Page switches to paginated results with a total; Limit and Skip are then ignored
+	Page         int64  `json:"page,omitempty"`
+	ItemsPerPage int64  `json:"items_per_page,omitempty"`
+	Sort         string `json:"sort,omitempty"`
+	Order        string `json:"order,omitempty"`
+}
+
+// ProductExportFields lists the fields that can be selected in a product export
+var ProductExportFields = []string{"id", "name", "description", "price", "stock", "category", "created_at", "updated_at"}
+
+// ProductExportRequest represents the request body for exporting products
+type ProductExportRequest struct {
+	Name     string   `json:"name,omitempty"`
+	Category string   `json:"category,omitempty"`
+	MinPrice float64  `json:"min_price,omitempty"`
+	MaxPrice float64  `json:"max_price,omitempty"`
+	Format   string   `json:"format,omitempty" validate:"omitempty,oneof=ndjson csv"`
+	Fields   []string `json:"fields,omitempty" validate:"omitempty,dive,oneof=id name description price stock category created_at updated_at"`
+}
+
+// ToFilter converts ProductFilterRequest to a filter map
+func (r *ProductFilterRequest) ToFilter() map[string]interface{} {
+	filter := make(map[string]interface{})
+	if r.Name != "" {
+		filter["name"] = r.Name
+	}
+	if r.Category != "" {
+		filter["category"] = r.Category
+	}
+	if r.MinPrice > 0 {
+		filter["min_price"] = r.MinPrice
+	}
+	if r.MaxPrice > 0 {
+		filter["max_price"] = r.MaxPrice
+	}
+	return filter
+}
+
+// ToFilter converts ProductExportRequest to a filter map
+func (r *ProductExportRequest) ToFilter() map[string]interface{} {
+	filter := make(map[string]interface{})
+	if r.Name != "" {
+		filter["name"] = r.Name
+	}
+	if r.Category != "" {
+		filter["category"] = r.Category
+	}
+	if r.MinPrice > 0 {
+		filter["min_price"] = r.MinPrice
+	}
+	if r.MaxPrice > 0 {
+		filter["max_price"] = r.MaxPrice
+	}
+	return filter
 }
 
 // ToModel converts CreateProductRequest to model.Product
 func (r *CreateProductRequest) ToModel() *model.Product {
+	ownerID, _ := primitive.ObjectIDFromHex(r.OwnerID)
 	return &model.Product{
 		Name:        r.Name,
 		Description: r.Description,
 		Price:       r.Price,
 		Stock:       r.Stock,
 		Category:    r.Category,
+		OwnerID:     ownerID,
 	}
 }
 
-// ToModel converts UpdateProductRequest to model.Product
+// ToModel applies the provided fields of UpdateProductRequest to an existing model.Product
 func (r *UpdateProductRequest) ToModel(existing *model.Product) *model.Product {
-	if r.Name != "" {
-		existing.Name = r.Name
+	if r.Name != nil {
+		existing.Name = *r.Name
 	}
-	if r.Description != "" {
-		existing.Description = r.Description
+	if r.Description != nil {
+		existing.Description = *r.Description
 	}
-	if r.Price > 0 {
-		existing.Price = r.Price
+	if r.Price != nil {
+		existing.Price = *r.Price
 	}
-	if r.Stock >= 0 {
-		existing.Stock = r.Stock
+	if r.Stock != nil {
+		existing.Stock = *r.Stock
 	}
-	if r.Category != "" {
-		existing.Category = r.Category
+	if r.Category != nil {
+		existing.Category = *r.Category
 	}
 	return existing
 }
 
+// ToUpdates returns only the provided fields, keyed by their BSON names
+func (r *UpdateProductRequest) ToUpdates() map[string]interface{} {
+	updates := make(map[string]interface{})
+	if r.Name != nil {
+		updates["name"] = *r.Name
+	}
+	if r.Description != nil {
+		updates["description"] = *r.Description
+	}
+	if r.Price != nil {
+		updates["price"] = *r.Price
+	}
+	if r.Stock != nil {
+		updates["stock"] = *r.Stock
+	}
+	if r.Category != nil {
+		updates["category"] = *r.Category
+	}
+	return updates
+}
+
 // FromModel creates a ProductResponse from model.Product
 func (r *ProductResponse) FromModel(product *model.Product) *ProductResponse {
 	r.ID = product.ID.Hex()
@@ -100,8 +218,17 @@ func (r *ProductResponse) FromModel(product *model.Product) *ProductResponse {
 	r.Price = product.Price
 	r.Stock = product.Stock
 	r.Category = product.Category
-	r.CreatedAt = product.CreatedAt
-	r.UpdatedAt = product.UpdatedAt
+	if !product.OwnerID.IsZero() {
+		r.OwnerID = product.OwnerID.Hex()
+	}
+	r.Metadata = product.Metadata
+	r.CreatedAt = Timestamp(product.CreatedAt)
+	r.UpdatedAt = Timestamp(product.UpdatedAt)
+	if product.LastRestockedAt != nil {
+		restockedAt := Timestamp(*product.LastRestockedAt)
+		r.LastRestockedAt = &restockedAt
+	}
+	r.LastRestockedBy = product.LastRestockedBy
 	return r
 }
 
@@ -110,6 +237,33 @@ func NewProductResponse(product *model.Product) *ProductResponse {
 	return new(ProductResponse).FromModel(product)
 }
 
+// NewProductWithOwnerResponse creates a ProductWithOwnerResponse from model.ProductWithOwner
+func NewProductWithOwnerResponse(product *model.ProductWithOwner) *ProductWithOwnerResponse {
+	r := &ProductWithOwnerResponse{ProductResponse: NewProductResponse(&product.Product)}
+	if product.Owner != nil {
+		r.Owner = &ProductOwnerResponse{
+			ID:   product.Owner.ID.Hex(),
+			Name: product.Owner.Name,
+		}
+	}
+	return r
+}
+
+// NewProductRevisionResponseList creates a slice of ProductRevisionResponse from a slice of model.ProductRevision
+func NewProductRevisionResponseList(revisions []*model.ProductRevision) []*ProductRevisionResponse {
+	result := make([]*ProductRevisionResponse, len(revisions))
+	for i, revision := range revisions {
+		result[i] = &ProductRevisionResponse{
+			ID:        revision.ID.Hex(),
+			ProductID: revision.ProductID.Hex(),
+			Snapshot:  NewProductResponse(&revision.Snapshot),
+			EditedBy:  revision.EditedBy,
+			EditedAt:  Timestamp(revision.CreatedAt),
+		}
+	}
+	return result
+}
+
 // NewProductResponseList creates a slice of ProductResponse from a slice of model.Product
 func NewProductResponseList(products []*model.Product) []*ProductResponse {
 	result := make([]*ProductResponse, len(products))
@@ -127,3 +281,49 @@ func (r *BatchCreateProductsRequest) ToModels() []*model.Product {
 	}
 	return products
 }
+
+// ExportValue returns the value of a product export field
+func (r *ProductResponse) ExportValue(field string) interface{} {
+	switch field {
+	case "id":
+		return r.ID
+	case "name":
+		return r.Name
+	case "description":
+		return r.Description
+	case "price":
+		return r.Price
+	case "stock":
+		return r.Stock
+	case "category":
+		return r.Category
+	case "created_at":
+		return r.CreatedAt
+	case "updated_at":
+		return r.UpdatedAt
+	}
+	return nil
+}
+
+// ExportMap returns the selected export fields as a map, suitable for NDJSON
+func (r *ProductResponse) ExportMap(fields []string) map[string]interface{} {
+	row := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		row[field] = r.ExportValue(field)
+	}
+	return row
+}
+
+// ExportRow returns the selected export fields as strings, suitable for CSV
+func (r *ProductResponse) ExportRow(fields []string) []string {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		switch v := r.ExportValue(field).(type) {
+		case Timestamp:
+			row[i] = v.String()
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	return row
+}
@@ -0,0 +1,70 @@
+package dto
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimestampFormat is the layout used for timestamps in responses unless configured otherwise
+const DefaultTimestampFormat = time.RFC3339
+
+var timestampFormat atomic.Value
+
+func init() {
+	timestampFormat.Store(DefaultTimestampFormat)
+}
+
+// SetTimestampFormat sets the layout used to serialize Timestamp values.
+// An empty layout restores DefaultTimestampFormat.
+func SetTimestampFormat(layout string) {
+	if layout == "" {
+		layout = DefaultTimestampFormat
+	}
+	timestampFormat.Store(layout)
+}
+
+// TimestampFormat returns the layout used to serialize Timestamp values
+func TimestampFormat() string {
+	return timestampFormat.Load().(string)
+}
+
+// Timestamp is a time serialized in UTC using the configured timestamp format,
+// so every created_at/updated_at in a response has the same shape and precision.
+// Models keep time.Time; the format is applied when converting to DTOs.
+type Timestamp time.Time
+
+// Time returns the underlying time.Time
+func (t Timestamp) Time() time.Time {
+	return time.Time(t)
+}
+
+// String formats the timestamp using the configured format
+func (t Timestamp) String() string {
+	return time.Time(t).UTC().Format(TimestampFormat())
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the configured format or RFC3339 with any precision
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("timestamp must be a JSON string")
+	}
+	value := string(data[1 : len(data)-1])
+
+	parsed, err := time.Parse(TimestampFormat(), value)
+	if err != nil {
+		if parsed, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return fmt.Errorf("invalid timestamp %q: %w", value, err)
+		}
+	}
+	*t = Timestamp(parsed)
+	return nil
+}
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+)
+
+func TestProductResponseTimestampFormat(t *testing.T) {
+	created := time.Date(2024, 3, 5, 14, 7, 9, 123456789, time.FixedZone("CET", 3600))
+	product := &model.Product{Name: "Widget"}
+	product.CreatedAt = created
+	product.UpdatedAt = created.Add(time.Second)
+
+	data, err := json.Marshal(NewProductResponse(product))
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if body["created_at"] != "2024-03-05T13:07:09Z" {
+		t.Errorf("expected created_at 2024-03-05T13:07:09Z, got %v", body["created_at"])
+	}
+	if body["updated_at"] != "2024-03-05T13:07:10Z" {
+		t.Errorf("expected updated_at 2024-03-05T13:07:10Z, got %v", body["updated_at"])
+	}
+}
+
+func TestTimestampCustomFormat(t *testing.T) {
+	SetTimestampFormat("2006-01-02T15:04:05.000Z07:00")
+	t.Cleanup(func() { SetTimestampFormat("") })
+
+	ts := Timestamp(time.Date(2024, 3, 5, 13, 7, 9, 120000000, time.UTC))
+	data, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(data) != `"2024-03-05T13:07:09.120Z"` {
+		t.Errorf("expected millisecond precision, got %s", data)
+	}
+
+	var parsed Timestamp
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if !parsed.Time().Equal(ts.Time()) {
+		t.Errorf("expected round trip to %v, got %v", ts.Time(), parsed.Time())
+	}
+}
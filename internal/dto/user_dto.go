@@ -8,54 +8,125 @@ import (
 
 // UserResponse represents the user response without sensitive data
 type UserResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Username  string         `json:"username,omitempty"`
+	Metadata  model.Metadata `json:"metadata,omitempty"`
+	CreatedAt Timestamp      `json:"created_at"`
+	UpdatedAt Timestamp      `json:"updated_at"`
+	// ApiKey is only set in the response that creates the user; it cannot be retrieved later
+	ApiKey string `json:"api_key,omitempty"`
 }
 
 // CreateUserRequest represents the request body for creating a user
 type CreateUserRequest struct {
-	Name     string `json:"name" validate:"required,min=2,max=100"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Name     string   `json:"name" validate:"required,min=2,max=100"`
+	Email    string   `json:"email" validate:"required,email"`
+	Username string   `json:"username,omitempty" validate:"omitempty,username"`
+	Password string   `json:"password" validate:"required,min=6"`
+	Roles    []string `json:"roles,omitempty" validate:"omitempty,dive,role"`
 }
 
-// UpdateUserRequest represents the request body for updating a user
+// UpdateUserRequest represents the request body for updating a user.
+// Passwords are only changed through the change-password endpoint.
 type UpdateUserRequest struct {
 	Name     string `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
 	Email    string `json:"email,omitempty" validate:"omitempty,email"`
-	Password string `json:"password,omitempty" validate:"omitempty,min=6"`
+	Username string `json:"username,omitempty" validate:"omitempty,username"`
 }
 
-// LoginRequest represents the request body for user login
+// UpdateRolesRequest represents the request body for adding, removing or setting user roles
+type UpdateRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1"`
+}
+
+// UpdateScopesRequest represents the request body for adding, removing or setting user scopes.
+// Setting an empty list revokes every scope.
+type UpdateScopesRequest struct {
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+// ChangePasswordRequest represents the request body for changing a user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// LoginRequest represents the request body for user login.
+// Identifier is the user's email or username; Email is still accepted from older clients.
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Identifier string `json:"identifier" validate:"required_without=Email"`
+	Email      string `json:"email,omitempty" validate:"omitempty,email"`
+	Password   string `json:"password" validate:"required,min=6"`
+	// TOTPCode is the current code from the user's authenticator app, required once they enable TOTP
+	TOTPCode string `json:"totp_code,omitempty"`
+}
+
+// LoginIdentifier returns the identifier to log in with, falling back to the email
+func (r *LoginRequest) LoginIdentifier() string {
+	if r.Identifier != "" {
+		return r.Identifier
+	}
+	return r.Email
+}
+
+// LoginResponse represents the response body for a successful login. The user's fields are
+// kept at the top level, where clients read them before tokens were issued, and under user.
+type LoginResponse struct {
+	*UserResponse
+	User      *UserResponse `json:"user"`
+	Token     string        `json:"token,omitempty"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+}
+
+// ImpersonationResponse represents the response body for an issued impersonation token
+type ImpersonationResponse struct {
+	User           *UserResponse `json:"user"`
+	Token          string        `json:"token"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	ImpersonatorID string        `json:"impersonator_id"`
 }
 
 // BatchCreateUsersRequest represents the request body for creating multiple users
 type BatchCreateUsersRequest struct {
-	Users []CreateUserRequest `json:"users" validate:"required,min=1,dive"`
+	Users []CreateUserRequest `json:"users" validate:"required,min=1,maxbatch,dive"`
 }
 
 // BatchUpdateUsersRequest represents the request body for updating multiple users
 type BatchUpdateUsersRequest struct {
-	Updates map[string]UpdateUserRequest `json:"updates" validate:"required,min=1"`
+	Updates map[string]UpdateUserRequest `json:"updates" validate:"required,min=1,maxbatch"`
 }
 
 // BatchDeleteUsersRequest represents the request body for deleting multiple users
 type BatchDeleteUsersRequest struct {
-	IDs []string `json:"ids" validate:"required,min=1"`
+	IDs []string `json:"ids" validate:"required,min=1,maxbatch,dive,objectid"`
 }
 
 // UserFilterRequest represents the request body for filtering users
+// The filter fields can also be sent as query parameters
 type UserFilterRequest struct {
-	Name  string `json:"name,omitempty"`
-	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty" query:"name"`
+	Email string `json:"email,omitempty" query:"email"`
 	Limit int64  `json:"limit,omitempty"`
 	Skip  int64  `json:"skip,omitempty"`
+	// Page switches to paginated results with a total; Limit and Skip are then ignored
+	Page         int64  `json:"page,omitempty"`
+	ItemsPerPage int64  `json:"items_per_page,omitempty"`
+	Sort         string `json:"sort,omitempty"`
+	Order        string `json:"order,omitempty"`
+}
+
+// ToFilter converts UserFilterRequest to a filter map
+func (r *UserFilterRequest) ToFilter() map[string]interface{} {
+	filter := make(map[string]interface{})
+	if r.Name != "" {
+		filter["name"] = r.Name
+	}
+	if r.Email != "" {
+		filter["email"] = r.Email
+	}
+	return filter
 }
 
 // ToModel converts CreateUserRequest to model.User
@@ -63,7 +134,9 @@ func (r *CreateUserRequest) ToModel() *model.User {
 	return &model.User{
 		Name:     r.Name,
 		Email:    r.Email,
+		Username: r.Username,
 		Password: r.Password,
+		Roles:    r.Roles,
 	}
 }
 
@@ -75,8 +148,8 @@ func (r *UpdateUserRequest) ToModel(existing *model.User) *model.User {
 	if r.Email != "" {
 		existing.Email = r.Email
 	}
-	if r.Password != "" {
-		existing.Password = r.Password
+	if r.Username != "" {
+		existing.Username = r.Username
 	}
 	return existing
 }
@@ -86,8 +159,11 @@ func (r *UserResponse) FromModel(user *model.User) *UserResponse {
 	r.ID = user.ID.Hex()
 	r.Name = user.Name
 	r.Email = user.Email
-	r.CreatedAt = user.CreatedAt
-	r.UpdatedAt = user.UpdatedAt
+	r.Username = user.Username
+	r.Metadata = user.Metadata
+	r.CreatedAt = Timestamp(user.CreatedAt)
+	r.UpdatedAt = Timestamp(user.UpdatedAt)
+	r.ApiKey = user.IssuedApiKey
 	return r
 }
 
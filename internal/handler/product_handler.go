@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"go-echo-mongo/internal/dto"
+	"go-echo-mongo/internal/model"
 	"go-echo-mongo/internal/service"
+	"go-echo-mongo/pkg/web/mwutil"
 	"go-echo-mongo/pkg/web/response"
-	"net/http"
+	"go-echo-mongo/pkg/web/validator"
+	"io"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
@@ -18,17 +25,29 @@ type ProductHandler interface {
 	GetByID(c echo.Context) error
 	GetAll(c echo.Context) error
 	GetPaginated(c echo.Context) error
+	Count(c echo.Context) error
+	StatsByCategory(c echo.Context) error
+	Search(c echo.Context) error
 	GetByCategory(c echo.Context) error
 	Update(c echo.Context) error
 	Delete(c echo.Context) error
+	GetHistory(c echo.Context) error
+	GetMetadata(c echo.Context) error
+	UpdateMetadata(c echo.Context) error
+	Restock(c echo.Context) error
+	DecrementStock(c echo.Context) error
 
 	// Batch operations
 	CreateMany(c echo.Context) error
 	FindByFilter(c echo.Context) error
+	Export(c echo.Context) error
 	UpdateMany(c echo.Context) error
 	DeleteMany(c echo.Context) error
 }
 
+// productSortFields are the fields paginated products can be sorted by
+var productSortFields = []string{"name", "price", "stock", "created_at", "updated_at"}
+
 // productHandler implements ProductHandler interface
 type productHandler struct {
 	service service.ProductService
@@ -44,18 +63,27 @@ func NewProductHandler(service service.ProductService) ProductHandler {
 // Register registers all product routes
 func (h *productHandler) Register(e *echo.Echo) {
 	products := e.Group("/api/v1/products")
-	products.POST("", h.Create)
+	products.POST("", h.Create, mwutil.StrictJSON(dto.CreateProductRequest{}))
 	products.GET("", h.GetAll)
 	products.GET("/paginated", h.GetPaginated)
+	products.GET("/count", h.Count)
+	products.GET("/stats/by-category", h.StatsByCategory)
+	products.GET("/search", h.Search)
 	products.GET("/:id", h.GetByID)
-	products.PUT("/:id", h.Update)
+	products.PUT("/:id", h.Update, mwutil.NewAuth(model.RoleAdmin, model.RoleManager), mwutil.StrictJSON(dto.UpdateProductRequest{}))
 	products.DELETE("/:id", h.Delete)
 	products.GET("/category/:category", h.GetByCategory)
+	products.GET("/:id/history", h.GetHistory)
+	products.GET("/:id/metadata", h.GetMetadata)
+	products.PATCH("/:id/metadata", h.UpdateMetadata, mwutil.NewAuth(model.RoleAdmin, model.RoleManager))
+	products.POST("/:id/restock", h.Restock, mwutil.NewAuth(model.RoleAdmin, model.RoleManager))
+	products.POST("/:id/decrement-stock", h.DecrementStock, mwutil.NewAuth(model.RoleAdmin, model.RoleManager))
 
 	// Batch operation routes
-	products.POST("/batch", h.CreateMany)
+	products.POST("/batch", h.CreateMany, mwutil.StrictJSON(dto.BatchCreateProductsRequest{}))
 	products.POST("/filter", h.FindByFilter)
-	products.PUT("/batch", h.UpdateMany)
+	products.POST("/export", h.Export)
+	products.PUT("/batch", h.UpdateMany, mwutil.StrictJSON(dto.BatchUpdateProductsRequest{}))
 	products.DELETE("/batch", h.DeleteMany)
 }
 
@@ -85,6 +113,10 @@ func (h *productHandler) Create(c echo.Context) error {
 
 // GetByID handles retrieving a product by ID
 func (h *productHandler) GetByID(c echo.Context) error {
+	if c.QueryParam("expand") == "owner" {
+		return h.getByIDWithOwner(c)
+	}
+
 	product, err := h.service.GetByID(c.Request().Context(), c.Param("id"))
 	if err != nil {
 		switch {
@@ -98,6 +130,74 @@ func (h *productHandler) GetByID(c echo.Context) error {
 	return response.OK(c, "Product retrieved successfully", dto.NewProductResponse(product))
 }
 
+// getByIDWithOwner handles retrieving a product with its owner embedded
+func (h *productHandler) getByIDWithOwner(c echo.Context) error {
+	product, err := h.service.GetProductWithOwner(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProductNotFound):
+			return response.NotFound(c, "Product not found")
+		default:
+			return response.InternalError(c, "Failed to retrieve product")
+		}
+	}
+
+	return response.OK(c, "Product retrieved successfully", dto.NewProductWithOwnerResponse(product))
+}
+
+// Restock handles adding stock to a product on behalf of the authenticated user
+func (h *productHandler) Restock(c echo.Context) error {
+	req := new(dto.RestockProductRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	product, err := h.service.RestockProduct(c.Request().Context(), c.Param("id"), req.Quantity, mwutil.CallerID(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProductNotFound):
+			return response.NotFound(c, "Product not found")
+		case errors.Is(err, service.ErrInvalidRestock):
+			return response.BadRequest(c, "Restock quantity must be positive")
+		default:
+			return response.InternalError(c, "Failed to restock product")
+		}
+	}
+
+	return response.OK(c, "Product restocked successfully", dto.NewProductResponse(product))
+}
+
+// DecrementStock handles atomically removing stock from a product
+func (h *productHandler) DecrementStock(c echo.Context) error {
+	req := new(dto.DecrementStockRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if err := h.service.DecrementStock(editorContext(c), c.Param("id"), req.Quantity); err != nil {
+		switch {
+		case errors.Is(err, service.ErrProductNotFound):
+			return response.NotFound(c, "Product not found")
+		case errors.Is(err, service.ErrInvalidDecrement):
+			return response.BadRequest(c, "Decrement quantity must be positive")
+		case errors.Is(err, service.ErrInsufficientStock):
+			return response.Conflict(c, "Insufficient stock")
+		default:
+			return response.InternalError(c, "Failed to decrement stock")
+		}
+	}
+
+	return response.OK(c, "Stock decremented successfully", nil)
+}
+
 // GetAll handles retrieving all products
 func (h *productHandler) GetAll(c echo.Context) error {
 	products, err := h.service.GetAll(c.Request().Context())
@@ -128,31 +228,54 @@ func (h *productHandler) Update(c echo.Context) error {
 	if err := c.Validate(req); err != nil {
 		return response.ValidationError(c, err)
 	}
-	// Get existing product first
-	existingProduct, err := h.service.GetByID(c.Request().Context(), c.Param("id"))
+	// Update only the fields that were provided
+	updatedProduct, err := h.service.PatchProduct(editorContext(c), c.Param("id"), req.ToUpdates())
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrProductNotFound):
 			return response.NotFound(c, "Product not found")
+		case errors.Is(err, service.ErrInvalidStock):
+			return response.BadRequest(c, "Stock cannot be negative")
 		default:
-			return response.InternalError(c, "Failed to retrieve product")
+			return response.InternalError(c, "Failed to update product")
 		}
 	}
 
-	// Update only the fields that were provided
-	updatedProduct := req.ToModel(existingProduct)
-	if err := h.service.Update(c.Request().Context(), c.Param("id"), updatedProduct); err != nil {
+	return response.OK(c, "Product updated successfully", dto.NewProductResponse(updatedProduct))
+}
+
+// editorContext returns the request context with the authenticated user, if any, recorded as the editor
+func editorContext(c echo.Context) context.Context {
+	ctx := c.Request().Context()
+	if id := mwutil.CallerID(c); id != "" {
+		return service.WithEditor(ctx, id)
+	}
+	return ctx
+}
+
+// GetHistory handles retrieving the paginated revisions of a product, newest first
+func (h *productHandler) GetHistory(c echo.Context) error {
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	itemsPerPage, err := strconv.ParseInt(c.QueryParam("items_per_page"), 10, 64)
+	if err != nil || itemsPerPage < 1 {
+		itemsPerPage = 10
+	}
+
+	revisions, totalCount, err := h.service.GetProductHistory(c.Request().Context(), c.Param("id"), page, itemsPerPage)
+	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrProductNotFound):
 			return response.NotFound(c, "Product not found")
-		case errors.Is(err, service.ErrInvalidStock):
-			return response.BadRequest(c, "Stock cannot be negative")
 		default:
-			return response.InternalError(c, "Failed to update product")
+			return response.InternalError(c, "Failed to retrieve product history")
 		}
 	}
 
-	return response.OK(c, "Product updated successfully", dto.NewProductResponse(updatedProduct))
+	return response.Paginated(c, dto.NewProductRevisionResponseList(revisions), page, itemsPerPage, totalCount)
 }
 
 // Delete handles deleting a product
@@ -169,6 +292,53 @@ func (h *productHandler) Delete(c echo.Context) error {
 	return response.NoContent(c)
 }
 
+// GetMetadata handles retrieving a product's metadata
+func (h *productHandler) GetMetadata(c echo.Context) error {
+	metadata, err := h.service.GetMetadata(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProductNotFound):
+			return response.NotFound(c, "Product not found")
+		default:
+			return response.InternalError(c, "Failed to retrieve product metadata")
+		}
+	}
+
+	return response.OK(c, "Product metadata retrieved successfully", dto.MetadataResponse{
+		ID:       c.Param("id"),
+		Metadata: metadata,
+	})
+}
+
+// UpdateMetadata handles merging metadata into a product
+func (h *productHandler) UpdateMetadata(c echo.Context) error {
+	req := new(dto.UpdateMetadataRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	metadata, err := h.service.UpdateMetadata(c.Request().Context(), c.Param("id"), req.Metadata)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProductNotFound):
+			return response.NotFound(c, "Product not found")
+		case errors.Is(err, model.ErrInvalidMetadata):
+			return response.BadRequest(c, err.Error())
+		default:
+			return response.InternalError(c, "Failed to update product metadata")
+		}
+	}
+
+	return response.OK(c, "Product metadata updated successfully", dto.MetadataResponse{
+		ID:       c.Param("id"),
+		Metadata: metadata,
+	})
+}
+
 // CreateMany handles batch creation of products
 func (h *productHandler) CreateMany(c echo.Context) error {
 	req := new(dto.BatchCreateProductsRequest)
@@ -187,6 +357,8 @@ func (h *productHandler) CreateMany(c echo.Context) error {
 			return response.BadRequest(c, "One or more products have invalid stock values")
 		case errors.Is(err, service.ErrEmptyBatch):
 			return response.BadRequest(c, "No products provided")
+		case errors.Is(err, service.ErrBatchTooLarge):
+			return response.BadRequest(c, err.Error())
 		default:
 			return response.InternalError(c, "Failed to create products")
 		}
@@ -202,19 +374,25 @@ func (h *productHandler) FindByFilter(c echo.Context) error {
 		return response.BadRequest(c, "Invalid request format")
 	}
 
-	// Convert DTO to filter map
-	filter := make(map[string]interface{})
-	if req.Name != "" {
-		filter["name"] = req.Name
-	}
-	if req.Category != "" {
-		filter["category"] = req.Category
-	}
-	if req.MinPrice > 0 {
-		filter["min_price"] = req.MinPrice
-	}
-	if req.MaxPrice > 0 {
-		filter["max_price"] = req.MaxPrice
+	filter := req.ToFilter()
+
+	// A page switches to sorted pagination with a total; otherwise limit and skip are used as is
+	if req.Page > 0 {
+		sort, err := toSort(req.Sort, req.Order, productSortFields...)
+		if err != nil {
+			return response.BadRequest(c, err.Error())
+		}
+		itemsPerPage := req.ItemsPerPage
+		if itemsPerPage < 1 {
+			itemsPerPage = 10
+		}
+
+		products, totalCount, err := h.service.FindProductsByFilterPaginated(c.Request().Context(), filter, sort, req.Page, itemsPerPage)
+		if err != nil {
+			return response.InternalError(c, "Failed to find products")
+		}
+
+		return response.Paginated(c, dto.NewProductResponseList(products), req.Page, itemsPerPage, totalCount)
 	}
 
 	products, err := h.service.FindProductsByFilter(c.Request().Context(), filter, req.Limit, req.Skip)
@@ -225,9 +403,128 @@ func (h *productHandler) FindByFilter(c echo.Context) error {
 	return response.OK(c, "Products found successfully", dto.NewProductResponseList(products))
 }
 
+// Count handles counting products matching the filter query parameters
+func (h *productHandler) Count(c echo.Context) error {
+	req := new(dto.ProductFilterRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	count, err := h.service.CountProductsByFilter(c.Request().Context(), req.ToFilter())
+	if err != nil {
+		return response.InternalError(c, "Failed to count products")
+	}
+
+	return response.OK(c, "Products counted successfully", map[string]int64{"count": count})
+}
+
+// StatsByCategory handles reporting total stock and average price per category
+func (h *productHandler) StatsByCategory(c echo.Context) error {
+	stats, err := h.service.GetCategoryStats(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "Failed to get category stats")
+	}
+
+	return response.OK(c, "Category stats retrieved successfully", stats)
+}
+
+// Export handles streaming products matching a filter as NDJSON or CSV
+func (h *productHandler) Export(c echo.Context) error {
+	req := new(dto.ProductExportRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	fields := req.Fields
+	if len(fields) == 0 {
+		fields = dto.ProductExportFields
+	}
+
+	filename, contentType := "products.ndjson", "application/x-ndjson"
+	if req.Format == "csv" {
+		filename, contentType = "products.csv", "text/csv; charset=utf-8"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	err := response.Stream(c, contentType, func(w io.Writer) error {
+		if req.Format == "csv" {
+			csvWriter := csv.NewWriter(w)
+			if err := csvWriter.Write(fields); err != nil {
+				return err
+			}
+			err := h.service.ExportProducts(c.Request().Context(), req.ToFilter(), req.Fields, func(product *model.Product) error {
+				return csvWriter.Write(dto.NewProductResponse(product).ExportRow(fields))
+			})
+			if err != nil {
+				return err
+			}
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+
+		encoder := json.NewEncoder(w)
+		return h.service.ExportProducts(c.Request().Context(), req.ToFilter(), req.Fields, func(product *model.Product) error {
+			return encoder.Encode(dto.NewProductResponse(product).ExportMap(fields))
+		})
+	})
+	if err != nil {
+		c.Response().Header().Del(echo.HeaderContentDisposition)
+		return response.InternalError(c, "Failed to export products")
+	}
+
+	return nil
+}
+
 // UpdateMany handles batch update of products
 func (h *productHandler) UpdateMany(c echo.Context) error {
-	return response.NotImplemented(c, "Not implemented yet")
+	req := new(dto.BatchUpdateProductsRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if len(req.Updates) == 0 {
+		return response.BadRequest(c, "No updates provided")
+	}
+
+	if id, invalid := validator.InvalidObjectIDKey(req.Updates); invalid {
+		return response.BadRequest(c, fmt.Sprintf("Invalid product ID format: %s", id))
+	}
+
+	// Map to store individual updates for each product
+	productUpdates := make(map[string]map[string]interface{})
+
+	for id, updateReq := range req.Updates {
+		// Only add to productUpdates if we have actual updates
+		if updates := updateReq.ToUpdates(); len(updates) > 0 {
+			productUpdates[id] = updates
+		}
+	}
+
+	if len(productUpdates) == 0 {
+		return response.BadRequest(c, "No updates provided")
+	}
+
+	count, err := h.service.UpdateProductsByID(c.Request().Context(), productUpdates)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStock):
+			return response.BadRequest(c, "Stock cannot be negative")
+		case errors.Is(err, service.ErrBatchTooLarge):
+			return response.BadRequest(c, err.Error())
+		default:
+			return response.InternalError(c, "Failed to update products")
+		}
+	}
+
+	return response.OK(c, fmt.Sprintf("Successfully updated %d products", count), map[string]int64{"updated_count": count})
 }
 
 // DeleteMany handles batch deletion of products
@@ -246,6 +543,8 @@ func (h *productHandler) DeleteMany(c echo.Context) error {
 		switch {
 		case errors.Is(err, service.ErrEmptyBatch):
 			return response.BadRequest(c, "No valid product IDs provided")
+		case errors.Is(err, service.ErrBatchTooLarge):
+			return response.BadRequest(c, err.Error())
 		default:
 			return response.InternalError(c, "Failed to delete products")
 		}
@@ -267,10 +566,21 @@ func (h *productHandler) GetPaginated(c echo.Context) error {
 		itemsPerPage = 10
 	}
 
+	// A cursor parameter (empty for the first page) switches to cursor-based pagination
+	if c.QueryParams().Has("cursor") {
+		return h.getCursorPaginated(c, itemsPerPage)
+	}
+
+	sort, err := parseSort(c, productSortFields...)
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
 	// Get products with pagination directly using the base service method
 	products, totalCount, err := h.service.GetPaginated(
 		c.Request().Context(),
 		nil,
+		sort,
 		page,
 		itemsPerPage,
 	)
@@ -288,19 +598,58 @@ func (h *productHandler) GetPaginated(c echo.Context) error {
 			Price:       product.Price,
 			Stock:       product.Stock,
 			Category:    product.Category,
-			CreatedAt:   product.CreatedAt,
-			UpdatedAt:   product.UpdatedAt,
+			CreatedAt:   dto.Timestamp(product.CreatedAt),
+			UpdatedAt:   dto.Timestamp(product.UpdatedAt),
 		})
 	}
 
-	// Return paginated response
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"data": responses,
-		"meta": map[string]interface{}{
-			"current_page":   page,
-			"items_per_page": itemsPerPage,
-			"total_items":    totalCount,
-			"total_pages":    (totalCount + itemsPerPage - 1) / itemsPerPage,
-		},
-	})
+	return response.Paginated(c, responses, page, itemsPerPage, totalCount)
+}
+
+// getCursorPaginated handles retrieving products a page at a time using an opaque cursor
+func (h *productHandler) getCursorPaginated(c echo.Context, itemsPerPage int64) error {
+	products, nextCursor, err := h.service.GetCursorPaginated(c.Request().Context(), nil, "", c.QueryParam("cursor"), itemsPerPage)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCursor):
+			return response.BadRequest(c, "Invalid cursor")
+		default:
+			return response.InternalError(c, "Failed to retrieve products")
+		}
+	}
+
+	return response.CursorPaginated(c, dto.NewProductResponseList(products), itemsPerPage, nextCursor)
+}
+
+// Search handles the request to search products by text with pagination
+func (h *productHandler) Search(c echo.Context) error {
+	query := c.QueryParam("q")
+	if query == "" {
+		return response.BadRequest(c, "Search query is required")
+	}
+
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	itemsPerPage, err := strconv.ParseInt(c.QueryParam("items_per_page"), 10, 64)
+	if err != nil || itemsPerPage < 1 {
+		itemsPerPage = 10
+	}
+
+	filter := map[string]interface{}{}
+	if category := c.QueryParam("category"); category != "" {
+		filter["category"] = category
+	}
+
+	products, totalCount, err := h.service.SearchProducts(c.Request().Context(), query, filter, page, itemsPerPage)
+	if err != nil {
+		if errors.Is(err, service.ErrEmptySearch) {
+			return response.BadRequest(c, "Search query is required")
+		}
+		return response.InternalError(c, "Failed to search products")
+	}
+
+	return response.Paginated(c, dto.NewProductResponseList(products), page, itemsPerPage, totalCount)
 }
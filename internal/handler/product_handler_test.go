@@ -0,0 +1,806 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/dto"
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/service"
+	"go-echo-mongo/pkg/web/mwutil"
+	"go-echo-mongo/pkg/web/validator"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeProductService records bulk updates and restocks; unimplemented methods panic
+type fakeProductService struct {
+	service.ProductService
+	updates     map[string]map[string]interface{}
+	restockedBy string
+	sort        *service.Sort
+	products    []*model.Product
+	limit, skip int64
+	metadataErr error
+	// exportFilter and exportFields are the arguments of the last ExportProducts call
+	exportFilter map[string]interface{}
+	exportFields []string
+}
+
+// FindProductsByFilterPaginated filters products by category, sorts them by price and returns the requested page
+func (s *fakeProductService) FindProductsByFilterPaginated(ctx context.Context, filter map[string]interface{}, sort service.Sort, page, itemsPerPage int64) ([]*model.Product, int64, error) {
+	s.sort = &sort
+	var matched []*model.Product
+	for _, p := range s.products {
+		if p.Category == filter["category"] {
+			matched = append(matched, p)
+		}
+	}
+	slices.SortFunc(matched, func(a, b *model.Product) int {
+		return int(sort.Order) * cmp.Compare(a.Price, b.Price)
+	})
+	start := min((page-1)*itemsPerPage, int64(len(matched)))
+	end := min(start+itemsPerPage, int64(len(matched)))
+	return matched[start:end], int64(len(matched)), nil
+}
+
+// CountProductsByFilter counts products matching the category filter, if any
+func (s *fakeProductService) CountProductsByFilter(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	var count int64
+	for _, p := range s.products {
+		if category, ok := filter["category"]; !ok || p.Category == category {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetCategoryStats totals stock and averages price per category, ordered by category
+func (s *fakeProductService) GetCategoryStats(ctx context.Context) ([]*model.CategoryStats, error) {
+	byCategory := make(map[string]*model.CategoryStats)
+	var stats []*model.CategoryStats
+	for _, p := range s.products {
+		st, ok := byCategory[p.Category]
+		if !ok {
+			st = &model.CategoryStats{Category: p.Category}
+			byCategory[p.Category] = st
+			stats = append(stats, st)
+		}
+		st.AveragePrice = (st.AveragePrice*float64(st.ProductCount) + p.Price) / float64(st.ProductCount+1)
+		st.TotalStock += int64(p.Stock)
+		st.ProductCount++
+	}
+	slices.SortFunc(stats, func(a, b *model.CategoryStats) int { return cmp.Compare(a.Category, b.Category) })
+	return stats, nil
+}
+
+// FindProductsByFilter records the raw limit and skip
+func (s *fakeProductService) FindProductsByFilter(ctx context.Context, filter map[string]interface{}, limit, skip int64) ([]*model.Product, error) {
+	s.limit, s.skip = limit, skip
+	return s.products, nil
+}
+
+// GetPaginated returns products ordered by price according to the requested sort
+func (s *fakeProductService) GetPaginated(ctx context.Context, filter interface{}, sort service.Sort, page, itemsPerPage int64) ([]*model.Product, int64, error) {
+	s.sort = &sort
+	prices := []float64{10, 20, 30}
+	if sort.Order == service.SortDesc {
+		prices = []float64{30, 20, 10}
+	}
+	products := make([]*model.Product, len(prices))
+	for i, price := range prices {
+		products[i] = &model.Product{Name: "Product", Price: price}
+		products[i].ID = primitive.NewObjectID()
+	}
+	return products, int64(len(products)), nil
+}
+
+// PatchProduct applies name updates to a stored keyboard
+func (s *fakeProductService) PatchProduct(ctx context.Context, id string, updates map[string]interface{}) (*model.Product, error) {
+	p := &model.Product{Name: "Keyboard", Stock: 10}
+	p.ID, _ = primitive.ObjectIDFromHex(id)
+	if name, ok := updates["name"].(string); ok {
+		p.Name = name
+	}
+	return p, nil
+}
+
+// GetMetadata returns the stored metadata or fails with metadataErr, if set
+func (s *fakeProductService) GetMetadata(ctx context.Context, id string) (model.Metadata, error) {
+	if s.metadataErr != nil {
+		return nil, s.metadataErr
+	}
+	return model.Metadata{"color": "black"}, nil
+}
+
+// UpdateMetadata returns the updates as the merged metadata or fails with metadataErr, if set
+func (s *fakeProductService) UpdateMetadata(ctx context.Context, id string, updates model.Metadata) (model.Metadata, error) {
+	if s.metadataErr != nil {
+		return nil, s.metadataErr
+	}
+	return updates, nil
+}
+
+func (s *fakeProductService) RestockProduct(ctx context.Context, id string, quantity int32, restockedBy string) (*model.Product, error) {
+	if quantity <= 0 {
+		return nil, service.ErrInvalidRestock
+	}
+	s.restockedBy = restockedBy
+	p := &model.Product{Name: "Keyboard", Stock: 10 + quantity}
+	p.ID, _ = primitive.ObjectIDFromHex(id)
+	return p, nil
+}
+
+// DecrementStock accepts any positive quantity
+func (s *fakeProductService) DecrementStock(ctx context.Context, id string, quantity int32) error {
+	if quantity <= 0 {
+		return service.ErrInvalidDecrement
+	}
+	return nil
+}
+
+// fakeAPIKeyValidator authenticates a single manager API key
+type fakeAPIKeyValidator struct {
+	user *model.User
+}
+
+func (v fakeAPIKeyValidator) GetByApiKey(ctx context.Context, apiKey string) (*model.User, error) {
+	if apiKey != v.user.ApiKey {
+		return nil, errors.New("invalid api key")
+	}
+	return v.user, nil
+}
+
+func newTestManager() *model.User {
+	u := &model.User{Name: "Manager", ApiKey: "manager-key", Roles: []string{model.RoleManager}}
+	u.ID = primitive.NewObjectID()
+	return u
+}
+
+func newProductTestServer(svc *fakeProductService, manager *model.User) *echo.Echo {
+	e := echo.New()
+	e.Validator = validator.New()
+	mwutil.SetAPIKeyValidator(fakeAPIKeyValidator{user: manager})
+	NewProductHandler(svc).Register(e)
+	return e
+}
+
+func (s *fakeProductService) UpdateProductsByID(ctx context.Context, updates map[string]map[string]interface{}) (int64, error) {
+	s.updates = updates
+	return int64(len(updates)), nil
+}
+
+func serveProductUpdateMany(t *testing.T, svc *fakeProductService, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+
+	e := newProductTestServer(svc, newTestManager())
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/products/batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec, resp
+}
+
+func TestProductUpdateManyValidIDs(t *testing.T) {
+	first, second := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	svc := &fakeProductService{}
+
+	body := `{"updates": {"` + first + `": {"price": 19.99}, "` + second + `": {"stock": 5, "category": "books"}}}`
+	rec, resp := serveProductUpdateMany(t, svc, body)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := resp["data"].(map[string]interface{})
+	if data["updated_count"] != float64(2) {
+		t.Errorf("expected updated_count 2, got %v", data["updated_count"])
+	}
+
+	if got := svc.updates[first]; len(got) != 1 || got["price"] != 19.99 {
+		t.Errorf("expected only price for %s, got %v", first, got)
+	}
+	if got := svc.updates[second]; len(got) != 2 || got["stock"] != int32(5) || got["category"] != "books" {
+		t.Errorf("expected stock and category for %s, got %v", second, got)
+	}
+}
+
+func TestProductUpdateManyInvalidID(t *testing.T) {
+	svc := &fakeProductService{}
+
+	rec, resp := serveProductUpdateMany(t, svc, `{"updates": {"not-an-id": {"price": 10}}}`)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if msg, _ := resp["message"].(string); !strings.Contains(msg, "Invalid product ID format") {
+		t.Errorf("expected invalid ID message, got %q", msg)
+	}
+	if svc.updates != nil {
+		t.Error("expected service not to be called")
+	}
+}
+
+func TestProductUpdateManyEmptyUpdates(t *testing.T) {
+	for name, body := range map[string]string{
+		"no updates":     `{"updates": {}}`,
+		"empty per-id":   `{"updates": {"` + primitive.NewObjectID().Hex() + `": {}}}`,
+		"missing field":  `{}`,
+		"negative stock": `{"updates": {"` + primitive.NewObjectID().Hex() + `": {"stock": -1}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc := &fakeProductService{}
+			rec, _ := serveProductUpdateMany(t, svc, body)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if svc.updates != nil {
+				t.Error("expected service not to be called")
+			}
+		})
+	}
+}
+
+func TestProductRestock(t *testing.T) {
+	manager := newTestManager()
+	id := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		body     string
+		wantCode int
+	}{
+		{"restocks as the caller", "manager-key", `{"quantity": 5}`, http.StatusOK},
+		{"rejects zero", "manager-key", `{"quantity": 0}`, http.StatusBadRequest},
+		{"rejects negative", "manager-key", `{"quantity": -3}`, http.StatusBadRequest},
+		{"requires an api key", "", `{"quantity": 5}`, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeProductService{}
+			e := newProductTestServer(svc, manager)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/products/"+id+"/restock", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && svc.restockedBy != manager.ID.Hex() {
+				t.Errorf("expected restock to be attributed to %s, got %q", manager.ID.Hex(), svc.restockedBy)
+			}
+		})
+	}
+}
+
+func TestProductUpdateRequiresAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiKey   string
+		wantCode int
+	}{
+		{"updates as the caller", "manager-key", http.StatusOK},
+		{"requires an api key", "", http.StatusUnauthorized},
+		{"rejects an unknown api key", "other-key", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newProductTestServer(&fakeProductService{}, newTestManager())
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/products/"+primitive.NewObjectID().Hex(), strings.NewReader(`{"name": "Mechanical keyboard"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProductDecrementStockRequiresAuth(t *testing.T) {
+	customer := &model.User{Name: "Customer", ApiKey: "customer-key", Roles: []string{model.RoleUser}}
+	customer.ID = primitive.NewObjectID()
+
+	tests := []struct {
+		name     string
+		caller   *model.User
+		apiKey   string
+		wantCode int
+	}{
+		{"decrements as a manager", newTestManager(), "manager-key", http.StatusOK},
+		{"requires an api key", newTestManager(), "", http.StatusUnauthorized},
+		{"rejects an unknown api key", newTestManager(), "other-key", http.StatusUnauthorized},
+		{"rejects a caller without the admin or manager role", customer, "customer-key", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newProductTestServer(&fakeProductService{}, tt.caller)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/products/"+primitive.NewObjectID().Hex()+"/decrement-stock", strings.NewReader(`{"quantity": 2}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProductUpdateMetadataRequiresAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiKey   string
+		wantCode int
+	}{
+		{"updates as a manager", "manager-key", http.StatusOK},
+		{"requires an api key", "", http.StatusUnauthorized},
+		{"rejects an unknown api key", "other-key", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newProductTestServer(&fakeProductService{}, newTestManager())
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/products/"+primitive.NewObjectID().Hex()+"/metadata", strings.NewReader(`{"metadata": {"color": "black"}}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProductGetPaginatedSort(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantCode   int
+		wantSort   service.Sort
+		wantPrices []float64
+	}{
+		{"?sort=price", http.StatusOK, service.Sort{Field: "price", Order: service.SortAsc}, []float64{10, 20, 30}},
+		{"?sort=price&order=asc", http.StatusOK, service.Sort{Field: "price", Order: service.SortAsc}, []float64{10, 20, 30}},
+		{"?sort=price&order=desc", http.StatusOK, service.Sort{Field: "price", Order: service.SortDesc}, []float64{30, 20, 10}},
+		{"", http.StatusOK, service.Sort{}, []float64{10, 20, 30}},
+		{"?sort=password", http.StatusBadRequest, service.Sort{}, nil},
+		{"?sort=price&order=sideways", http.StatusBadRequest, service.Sort{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			svc := &fakeProductService{}
+			e := newProductTestServer(svc, newTestManager())
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/products/paginated"+tt.query, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				if svc.sort != nil {
+					t.Error("expected service not to be called")
+				}
+				return
+			}
+			if *svc.sort != tt.wantSort {
+				t.Errorf("expected sort %+v, got %+v", tt.wantSort, *svc.sort)
+			}
+
+			var resp struct {
+				Data []struct {
+					Price float64 `json:"price"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			for i, p := range resp.Data {
+				if p.Price != tt.wantPrices[i] {
+					t.Errorf("expected prices %v, got %+v", tt.wantPrices, resp.Data)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestProductFindByFilterPaginated(t *testing.T) {
+	svc := &fakeProductService{}
+	for _, price := range []float64{30, 10, 50, 20, 40} {
+		svc.products = append(svc.products, &model.Product{Name: "Book", Price: price, Category: "books"})
+	}
+	svc.products = append(svc.products, &model.Product{Name: "Toy", Price: 5, Category: "toys"})
+	e := newProductTestServer(svc, newTestManager())
+
+	filter := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/products/filter", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := filter(`{"category":"books","page":2,"items_per_page":2,"sort":"price","order":"desc"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data []struct {
+			Price float64 `json:"price"`
+		} `json:"data"`
+		Meta struct {
+			CurrentPage  int64 `json:"current_page"`
+			ItemsPerPage int64 `json:"items_per_page"`
+			TotalItems   int64 `json:"total_items"`
+			TotalPages   int64 `json:"total_pages"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].Price != 30 || resp.Data[1].Price != 20 {
+		t.Errorf("expected prices [30 20] on page 2, got %+v", resp.Data)
+	}
+	if resp.Meta.CurrentPage != 2 || resp.Meta.ItemsPerPage != 2 || resp.Meta.TotalItems != 5 || resp.Meta.TotalPages != 3 {
+		t.Errorf("unexpected pagination meta: %+v", resp.Meta)
+	}
+
+	if rec := filter(`{"page":1,"sort":"description"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown sort field, got %d", rec.Code)
+	}
+
+	// Without a page the raw limit and skip are honored
+	if rec := filter(`{"limit":3,"skip":1}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if svc.limit != 3 || svc.skip != 1 {
+		t.Errorf("expected limit 3 and skip 1, got %d and %d", svc.limit, svc.skip)
+	}
+}
+
+func TestProductCount(t *testing.T) {
+	svc := &fakeProductService{products: []*model.Product{
+		{Name: "Book", Category: "books"},
+		{Name: "Novel", Category: "books"},
+		{Name: "Toy", Category: "toys"},
+	}}
+	e := newProductTestServer(svc, newTestManager())
+
+	tests := []struct {
+		query string
+		want  int64
+	}{
+		{"", 3},
+		{"?category=books", 2},
+		{"?category=games", 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/products/count"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", tt.query, rec.Code, rec.Body.String())
+		}
+
+		var resp struct {
+			Data struct {
+				Count int64 `json:"count"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Data.Count != tt.want {
+			t.Errorf("%q: expected count %d, got %d", tt.query, tt.want, resp.Data.Count)
+		}
+	}
+}
+
+func TestProductStatsByCategory(t *testing.T) {
+	svc := &fakeProductService{products: []*model.Product{
+		{Name: "Toy", Category: "toys", Price: 5, Stock: 4},
+		{Name: "Book", Category: "books", Price: 10, Stock: 2},
+		{Name: "Novel", Category: "books", Price: 20, Stock: 3},
+	}}
+	e := newProductTestServer(svc, newTestManager())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/products/stats/by-category", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data []model.CategoryStats `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []model.CategoryStats{
+		{Category: "books", TotalStock: 5, AveragePrice: 15, ProductCount: 2},
+		{Category: "toys", TotalStock: 4, AveragePrice: 5, ProductCount: 1},
+	}
+	if !slices.Equal(resp.Data, want) {
+		t.Errorf("expected %+v, got %+v", want, resp.Data)
+	}
+}
+
+func (s *fakeProductService) CreateProducts(ctx context.Context, products []*model.Product) error {
+	for _, p := range products {
+		p.ID = primitive.NewObjectID()
+	}
+	return nil
+}
+
+func (s *fakeProductService) DeleteProductsByIDs(ctx context.Context, ids []string) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+// batchBody builds a JSON body holding n items under field, as a list or, for updates, an ID-keyed map
+func batchBody(t *testing.T, field string, n int, item func() interface{}, keyed bool) string {
+	t.Helper()
+
+	var items interface{}
+	if keyed {
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			m[primitive.NewObjectID().Hex()] = item()
+		}
+		items = m
+	} else {
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = item()
+		}
+		items = l
+	}
+
+	body, err := json.Marshal(map[string]interface{}{field: items})
+	if err != nil {
+		t.Fatalf("failed to encode body: %v", err)
+	}
+	return string(body)
+}
+
+// batchLimitCase is a batch endpoint to send at and just over a batch size limit of 2
+type batchLimitCase struct {
+	name   string
+	method string
+	field  string
+	item   func() interface{}
+	keyed  bool
+	ok     int
+}
+
+// expectBatchLimit lowers the batch size limit to 2, sends every case to path with 2 and
+// 3 items, and expects the case's status for 2 and a 400 naming the limit for 3
+func expectBatchLimit(t *testing.T, e *echo.Echo, path, apiKey string, tests []batchLimitCase) {
+	t.Helper()
+	validator.SetMaxBatchSize(2)
+	t.Cleanup(func() { validator.SetMaxBatchSize(validator.DefaultMaxBatchSize) })
+
+	for _, tt := range tests {
+		for n, want := range map[int]int{2: tt.ok, 3: http.StatusBadRequest} {
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(batchBody(t, tt.field, n, tt.item, tt.keyed)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if apiKey != "" {
+				req.Header.Set("X-API-Key", apiKey)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != want {
+				t.Errorf("%s with %d items: expected %d, got %d: %s", tt.name, n, want, rec.Code, rec.Body.String())
+			}
+			if want == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "at most 2 items") {
+				t.Errorf("%s with %d items: expected the limit in the message, got %s", tt.name, n, rec.Body.String())
+			}
+		}
+	}
+}
+
+// newBatchID is a batchLimitCase item for delete requests: a fresh ObjectID
+func newBatchID() interface{} {
+	return primitive.NewObjectID().Hex()
+}
+
+func TestProductBatchEndpointsEnforceMaxBatchSize(t *testing.T) {
+	e := newProductTestServer(&fakeProductService{}, newTestManager())
+	product := func() interface{} {
+		return map[string]interface{}{"name": "Keyboard", "description": "A mechanical keyboard", "price": 49.99, "stock": 1, "category": "electronics"}
+	}
+
+	expectBatchLimit(t, e, "/api/v1/products/batch", "manager-key", []batchLimitCase{
+		{"create", http.MethodPost, "products", product, false, http.StatusCreated},
+		{"update", http.MethodPut, "updates", func() interface{} { return map[string]interface{}{"price": 19.99} }, true, http.StatusOK},
+		{"delete", http.MethodDelete, "ids", newBatchID, false, http.StatusOK},
+	})
+}
+
+// ExportProducts streams the products matching the category filter, if any
+func (s *fakeProductService) ExportProducts(ctx context.Context, filter map[string]interface{}, fields []string, fn func(*model.Product) error) error {
+	s.exportFilter, s.exportFields = filter, fields
+	if s.products == nil {
+		return errors.New("export failed")
+	}
+	for _, p := range s.products {
+		if category, ok := filter["category"]; ok && p.Category != category {
+			continue
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestProductExport(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	book := &model.Product{Name: "Book", Description: "A novel", Category: "books", Price: 10, Stock: 3}
+	book.ID = primitive.NewObjectID()
+	book.CreatedAt, book.UpdatedAt = createdAt, createdAt
+	svc := &fakeProductService{products: []*model.Product{
+		book,
+		{Name: "Toy", Category: "toys", Price: 5},
+	}}
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/products/export", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		newProductTestServer(svc, newTestManager()).ServeHTTP(rec, req)
+		return rec
+	}
+	expectStreamHeaders := func(t *testing.T, rec *httptest.ResponseRecorder, contentType, filename string) {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		headers := map[string]string{
+			echo.HeaderContentType:        contentType,
+			echo.HeaderContentDisposition: `attachment; filename="` + filename + `"`,
+			"Cache-Control":               "no-cache",
+			"X-Content-Type-Options":      "nosniff",
+		}
+		for name, want := range headers {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("expected %s %q, got %q", name, want, got)
+			}
+		}
+	}
+
+	t.Run("csv with selected fields", func(t *testing.T) {
+		rec := serve(`{"format":"csv","fields":["name","category"]}`)
+		expectStreamHeaders(t, rec, "text/csv; charset=utf-8", "products.csv")
+		if want := "name,category\nBook,books\nToy,toys\n"; rec.Body.String() != want {
+			t.Errorf("expected %q, got %q", want, rec.Body.String())
+		}
+		if !slices.Equal(svc.exportFields, []string{"name", "category"}) {
+			t.Errorf("expected only the selected fields to be loaded, got %v", svc.exportFields)
+		}
+	})
+
+	t.Run("csv with every field", func(t *testing.T) {
+		rec := serve(`{"format":"csv","category":"books"}`)
+		expectStreamHeaders(t, rec, "text/csv; charset=utf-8", "products.csv")
+		if svc.exportFilter["category"] != "books" {
+			t.Errorf("expected the category filter to reach the service, got %v", svc.exportFilter)
+		}
+		if len(svc.exportFields) != 0 {
+			t.Errorf("expected every field to be loaded, got %v", svc.exportFields)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid CSV: %v", err)
+		}
+		want := [][]string{
+			dto.ProductExportFields,
+			{book.ID.Hex(), "Book", "A novel", "10", "3", "books", dto.Timestamp(createdAt).String(), dto.Timestamp(createdAt).String()},
+		}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("expected %q, got %q", want, records)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		rec := serve(`{"fields":["name","price"]}`)
+		expectStreamHeaders(t, rec, "application/x-ndjson", "products.ndjson")
+		if want := "{\"name\":\"Book\",\"price\":10}\n{\"name\":\"Toy\",\"price\":5}\n"; rec.Body.String() != want {
+			t.Errorf("expected %q, got %q", want, rec.Body.String())
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		if rec := serve(`{"fields":["password"]}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", rec.Code)
+		}
+	})
+
+	// Failures before any output are reported as a regular error response
+	t.Run("failure", func(t *testing.T) {
+		svc.products = nil
+		rec := serve(`{"format":"csv"}`)
+		if rec.Code != http.StatusInternalServerError || rec.Header().Get(echo.HeaderContentDisposition) != "" {
+			t.Errorf("expected a plain 500, got %d with headers %v", rec.Code, rec.Header())
+		}
+	})
+}
+
+func TestProductMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		err      error
+		wantCode int
+	}{
+		{"gets metadata", http.MethodGet, nil, http.StatusOK},
+		{"gets metadata of an unknown product", http.MethodGet, service.ErrProductNotFound, http.StatusNotFound},
+		{"fails to get metadata", http.MethodGet, errors.New("connection reset"), http.StatusInternalServerError},
+		{"updates metadata", http.MethodPatch, nil, http.StatusOK},
+		{"updates metadata of an unknown product", http.MethodPatch, service.ErrProductNotFound, http.StatusNotFound},
+		{"rejects invalid metadata", http.MethodPatch, model.ErrInvalidMetadata, http.StatusBadRequest},
+		{"fails to update metadata", http.MethodPatch, errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newProductTestServer(&fakeProductService{metadataErr: tt.err}, newTestManager())
+
+			req := httptest.NewRequest(tt.method, "/api/v1/products/"+primitive.NewObjectID().Hex()+"/metadata", strings.NewReader(`{"metadata": {"color": "black"}}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("X-API-Key", "manager-key")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(rec.Body.String(), `"color":"black"`) {
+				t.Errorf("expected the metadata in the response, got %s", rec.Body.String())
+			}
+		})
+	}
+}
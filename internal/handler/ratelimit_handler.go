@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/url"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/pkg/web/mwutil"
+	"go-echo-mongo/pkg/web/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitHandler defines the interface for rate limit administration handlers
+type RateLimitHandler interface {
+	Register(e *echo.Echo)
+	Reset(c echo.Context) error
+}
+
+// rateLimitHandler implements RateLimitHandler interface
+type rateLimitHandler struct {
+	reset func(identifier string) error
+}
+
+// NewRateLimitHandler creates a new RateLimitHandler instance; reset clears an identifier's limits,
+// normally mwutil.ResetRateLimit
+func NewRateLimitHandler(reset func(identifier string) error) RateLimitHandler {
+	return &rateLimitHandler{
+		reset: reset,
+	}
+}
+
+// Register registers all rate limit administration routes
+func (h *rateLimitHandler) Register(e *echo.Echo) {
+	admin := e.Group("/admin/rate-limit", mwutil.NewAuth(model.RoleAdmin))
+	admin.DELETE("/:identifier", h.Reset)
+}
+
+// Reset handles clearing a client's rate limit. Path-specific identifiers
+// contain slashes, which must be escaped as %2F.
+func (h *rateLimitHandler) Reset(c echo.Context) error {
+	identifier, err := url.PathUnescape(c.Param("identifier"))
+	if err != nil || identifier == "" {
+		return response.BadRequest(c, "Invalid rate limit identifier")
+	}
+
+	if err := h.reset(identifier); err != nil {
+		return response.InternalError(c, "Failed to reset rate limit")
+	}
+
+	return response.OK(c, "Rate limit reset successfully", map[string]string{"identifier": identifier})
+}
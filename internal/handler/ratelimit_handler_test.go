@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/pkg/web/mwutil"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRateLimitReset(t *testing.T) {
+	admin := &model.User{Name: "Admin", ApiKey: "admin-key", Roles: []string{model.RoleAdmin}}
+	admin.ID = primitive.NewObjectID()
+	mwutil.SetAPIKeyValidator(fakeAPIKeyValidator{user: admin})
+
+	var reset []string
+	e := echo.New()
+	NewRateLimitHandler(func(identifier string) error {
+		if identifier == "ip:fail" {
+			return errors.New("redis unavailable")
+		}
+		reset = append(reset, identifier)
+		return nil
+	}).Register(e)
+
+	tests := []struct {
+		name   string
+		path   string
+		apiKey string
+		want   int
+		reset  string
+	}{
+		{"without an API key", "/admin/rate-limit/ip:10.0.0.1", "", http.StatusUnauthorized, ""},
+		{"with a wrong API key", "/admin/rate-limit/ip:10.0.0.1", "other-key", http.StatusUnauthorized, ""},
+		{"global identifier", "/admin/rate-limit/ip:10.0.0.1", "admin-key", http.StatusOK, "ip:10.0.0.1"},
+		{"escaped path identifier", "/admin/rate-limit/api:key-1:%2Fapi%2Fv1%2Fusers", "admin-key", http.StatusOK, "api:key-1:/api/v1/users"},
+		{"store failure", "/admin/rate-limit/ip:fail", "admin-key", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		reset = nil
+		req := httptest.NewRequest(http.MethodDelete, tt.path, nil)
+		if tt.apiKey != "" {
+			req.Header.Set("X-API-Key", tt.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+		if tt.reset != "" && (len(reset) != 1 || reset[0] != tt.reset) {
+			t.Errorf("%s: expected %q to be reset, got %v", tt.name, tt.reset, reset)
+		}
+		if tt.reset == "" && len(reset) != 0 {
+			t.Errorf("%s: expected nothing to be reset, got %v", tt.name, reset)
+		}
+	}
+}
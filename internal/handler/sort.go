@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"go-echo-mongo/internal/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// parseSort reads the sort and order query parameters, allowing only the given fields.
+// Without a sort parameter the natural order is kept.
+func parseSort(c echo.Context, allowed ...string) (service.Sort, error) {
+	return toSort(c.QueryParam("sort"), c.QueryParam("order"), allowed...)
+}
+
+// toSort validates a sort field against the allowed fields and an order of asc or desc (default asc).
+// An empty field keeps the natural order.
+func toSort(field, order string, allowed ...string) (service.Sort, error) {
+	if field == "" {
+		return service.Sort{}, nil
+	}
+
+	valid := false
+	for _, a := range allowed {
+		if field == a {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return service.Sort{}, fmt.Errorf("invalid sort field %q, must be one of: %s", field, strings.Join(allowed, ", "))
+	}
+
+	switch strings.ToLower(order) {
+	case "", "asc":
+		return service.Sort{Field: field, Order: service.SortAsc}, nil
+	case "desc":
+		return service.Sort{Field: field, Order: service.SortDesc}, nil
+	default:
+		return service.Sort{}, fmt.Errorf("invalid sort order %q, must be asc or desc", order)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go-echo-mongo/internal/dto"
@@ -8,13 +9,12 @@ import (
 	"go-echo-mongo/internal/service"
 	"go-echo-mongo/pkg/web/mwutil"
 	"go-echo-mongo/pkg/web/response"
-	"net/http"
+	"go-echo-mongo/pkg/web/validator"
 	"strconv"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // UserHandler defines the interface for user-related HTTP handlers
@@ -24,9 +24,19 @@ type UserHandler interface {
 	GetByID(c echo.Context) error
 	GetAll(c echo.Context) error
 	GetPaginated(c echo.Context) error
+	Count(c echo.Context) error
 	Update(c echo.Context) error
 	Delete(c echo.Context) error
 	Login(c echo.Context) error
+	ChangePassword(c echo.Context) error
+	RegenerateApiKey(c echo.Context) error
+	Impersonate(c echo.Context) error
+	AddRoles(c echo.Context) error
+	RemoveRoles(c echo.Context) error
+	SetRoles(c echo.Context) error
+	AddScopes(c echo.Context) error
+	RemoveScopes(c echo.Context) error
+	SetScopes(c echo.Context) error
 
 	// Batch operations
 	CreateMany(c echo.Context) error
@@ -35,15 +45,35 @@ type UserHandler interface {
 	DeleteMany(c echo.Context) error
 }
 
+// AuthConfig holds the settings used to issue tokens on login
+type AuthConfig struct {
+	// JWTSecret signs issued tokens; if empty, login does not return a token
+	JWTSecret string
+	// TokenTTL is how long issued tokens are valid
+	TokenTTL time.Duration
+	// ImpersonationTTL is how long impersonation tokens are valid; defaults to 15 minutes
+	ImpersonationTTL time.Duration
+	// RejectImpersonated disables the impersonate endpoint
+	RejectImpersonated bool
+}
+
+// defaultImpersonationTTL keeps impersonation tokens short-lived when no lifetime is configured
+const defaultImpersonationTTL = 15 * time.Minute
+
+// userSortFields are the fields paginated users can be sorted by
+var userSortFields = []string{"name", "email", "created_at", "updated_at"}
+
 // userHandler implements UserHandler interface
 type userHandler struct {
 	service service.UserService
+	auth    AuthConfig
 }
 
 // NewUserHandler creates a new UserHandler instance
-func NewUserHandler(service service.UserService) UserHandler {
+func NewUserHandler(service service.UserService, auth AuthConfig) UserHandler {
 	return &userHandler{
 		service: service,
+		auth:    auth,
 	}
 }
 
@@ -51,18 +81,28 @@ func NewUserHandler(service service.UserService) UserHandler {
 func (h *userHandler) Register(e *echo.Echo) {
 	users := e.Group("/api/v1/users")
 	users.Use(mwutil.NewFixedRateLimiter(3, 1*time.Minute))
-	users.POST("", h.Create, mwutil.NewAPIKeyAuth(model.RoleAdmin))
+	users.POST("", h.Create, mwutil.NewAuth(model.RoleAdmin), mwutil.StrictJSON(dto.CreateUserRequest{}))
 	users.GET("", h.GetAll)
 	users.GET("/paginated", h.GetPaginated)
+	users.GET("/count", h.Count)
 	users.GET("/:id", h.GetByID)
-	users.PUT("/:id", h.Update)
+	users.PUT("/:id", h.Update, mwutil.StrictJSON(dto.UpdateUserRequest{}))
 	users.DELETE("/:id", h.Delete)
 	users.POST("/login", h.Login)
+	users.POST("/:id/change-password", h.ChangePassword, mwutil.NewAuth())
+	users.POST("/:id/regenerate-api-key", h.RegenerateApiKey, mwutil.NewAuth(model.RoleAdmin))
+	users.POST("/:id/impersonate", h.Impersonate, mwutil.NewAuth(model.RoleAdmin))
+	users.POST("/:id/roles", h.AddRoles, mwutil.NewAuth(model.RoleAdmin))
+	users.DELETE("/:id/roles", h.RemoveRoles, mwutil.NewAuth(model.RoleAdmin))
+	users.PUT("/:id/roles", h.SetRoles, mwutil.NewAuth(model.RoleAdmin))
+	users.POST("/:id/scopes", h.AddScopes, mwutil.NewAuth(model.RoleAdmin))
+	users.DELETE("/:id/scopes", h.RemoveScopes, mwutil.NewAuth(model.RoleAdmin))
+	users.PUT("/:id/scopes", h.SetScopes, mwutil.NewAuth(model.RoleAdmin))
 
 	// Batch operation routes
-	users.POST("/batch", h.CreateMany, mwutil.NewAPIKeyAuth(model.RoleAdmin))
+	users.POST("/batch", h.CreateMany, mwutil.NewAuth(model.RoleAdmin), mwutil.StrictJSON(dto.BatchCreateUsersRequest{}))
 	users.POST("/filter", h.FindByFilter)
-	users.PUT("/batch", h.UpdateMany)
+	users.PUT("/batch", h.UpdateMany, mwutil.StrictJSON(dto.BatchUpdateUsersRequest{}))
 	users.DELETE("/batch", h.DeleteMany)
 }
 
@@ -82,6 +122,10 @@ func (h *userHandler) Create(c echo.Context) error {
 		switch {
 		case errors.Is(err, service.ErrEmailExists):
 			return response.Conflict(c, "User with this email already exists")
+		case errors.Is(err, service.ErrUsernameExists):
+			return response.Conflict(c, "User with this username already exists")
+		case errors.Is(err, service.ErrUnknownRole), errors.Is(err, service.ErrInvalidUsername):
+			return response.BadRequest(c, err.Error())
 		default:
 			return response.InternalError(c, "Failed to create user")
 		}
@@ -128,10 +172,21 @@ func (h *userHandler) GetPaginated(c echo.Context) error {
 		itemsPerPage = 10
 	}
 
+	// A cursor parameter (empty for the first page) switches to cursor-based pagination
+	if c.QueryParams().Has("cursor") {
+		return h.getCursorPaginated(c, itemsPerPage)
+	}
+
+	sort, err := parseSort(c, userSortFields...)
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
 	// Get users with pagination directly using the base service method
 	users, totalCount, err := h.service.GetPaginated(
 		c.Request().Context(),
 		nil,
+		sort,
 		page,
 		itemsPerPage,
 	)
@@ -146,21 +201,27 @@ func (h *userHandler) GetPaginated(c echo.Context) error {
 			ID:        user.ID.Hex(),
 			Name:      user.Name,
 			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+			CreatedAt: dto.Timestamp(user.CreatedAt),
+			UpdatedAt: dto.Timestamp(user.UpdatedAt),
 		})
 	}
 
-	// Return paginated response
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"data": responses,
-		"meta": map[string]interface{}{
-			"current_page":   page,
-			"items_per_page": itemsPerPage,
-			"total_items":    totalCount,
-			"total_pages":    (totalCount + itemsPerPage - 1) / itemsPerPage,
-		},
-	})
+	return response.Paginated(c, responses, page, itemsPerPage, totalCount)
+}
+
+// getCursorPaginated handles retrieving users a page at a time using an opaque cursor
+func (h *userHandler) getCursorPaginated(c echo.Context, itemsPerPage int64) error {
+	users, nextCursor, err := h.service.GetCursorPaginated(c.Request().Context(), nil, "", c.QueryParam("cursor"), itemsPerPage)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCursor):
+			return response.BadRequest(c, "Invalid cursor")
+		default:
+			return response.InternalError(c, "Failed to retrieve users")
+		}
+	}
+
+	return response.CursorPaginated(c, dto.NewUserResponseList(users), itemsPerPage, nextCursor)
 }
 
 // Update handles updating a user
@@ -193,6 +254,10 @@ func (h *userHandler) Update(c echo.Context) error {
 			return response.NotFound(c, "User not found")
 		case errors.Is(err, service.ErrEmailExists):
 			return response.Conflict(c, "Email is already taken")
+		case errors.Is(err, service.ErrUsernameExists):
+			return response.Conflict(c, "Username is already taken")
+		case errors.Is(err, service.ErrInvalidUsername):
+			return response.BadRequest(c, err.Error())
 		default:
 			return response.InternalError(c, "Failed to update user")
 		}
@@ -215,6 +280,133 @@ func (h *userHandler) Delete(c echo.Context) error {
 	return response.NoContent(c)
 }
 
+// AddRoles handles adding roles to a user
+func (h *userHandler) AddRoles(c echo.Context) error {
+	req := new(dto.UpdateRolesRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if err := h.service.AddRoles(c.Request().Context(), c.Param("id"), req.Roles); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrUnknownRole):
+			return response.BadRequest(c, err.Error())
+		default:
+			return response.InternalError(c, "Failed to add roles")
+		}
+	}
+
+	return h.respondWithUser(c, "Roles added successfully")
+}
+
+// RemoveRoles handles removing roles from a user
+func (h *userHandler) RemoveRoles(c echo.Context) error {
+	req := new(dto.UpdateRolesRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if err := h.service.RemoveRoles(c.Request().Context(), c.Param("id"), req.Roles); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrLastAdmin):
+			return response.Conflict(c, "Cannot remove the admin role from the last admin")
+		default:
+			return response.InternalError(c, "Failed to remove roles")
+		}
+	}
+
+	return h.respondWithUser(c, "Roles removed successfully")
+}
+
+// SetRoles handles replacing all of a user's roles
+func (h *userHandler) SetRoles(c echo.Context) error {
+	req := new(dto.UpdateRolesRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if err := h.service.SetRoles(c.Request().Context(), c.Param("id"), req.Roles); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrUnknownRole), errors.Is(err, service.ErrNoRoles):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrLastAdmin):
+			return response.Conflict(c, "Cannot remove the admin role from the last admin")
+		default:
+			return response.InternalError(c, "Failed to set roles")
+		}
+	}
+
+	return h.respondWithUser(c, "Roles set successfully")
+}
+
+// AddScopes handles granting scopes to a user
+func (h *userHandler) AddScopes(c echo.Context) error {
+	return h.updateScopes(c, h.service.AddScopes, "Scopes added successfully", "Failed to add scopes")
+}
+
+// RemoveScopes handles revoking scopes from a user
+func (h *userHandler) RemoveScopes(c echo.Context) error {
+	return h.updateScopes(c, h.service.RemoveScopes, "Scopes removed successfully", "Failed to remove scopes")
+}
+
+// SetScopes handles replacing all of a user's scopes
+func (h *userHandler) SetScopes(c echo.Context) error {
+	return h.updateScopes(c, h.service.SetScopes, "Scopes set successfully", "Failed to set scopes")
+}
+
+// updateScopes binds an UpdateScopesRequest and applies it with update
+func (h *userHandler) updateScopes(c echo.Context, update func(ctx context.Context, id string, scopes []string) error, message, failure string) error {
+	req := new(dto.UpdateScopesRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if err := update(c.Request().Context(), c.Param("id"), req.Scopes); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrInvalidScope):
+			return response.BadRequest(c, err.Error())
+		default:
+			return response.InternalError(c, failure)
+		}
+	}
+
+	return h.respondWithUser(c, message)
+}
+
+// respondWithUser sends the current state of the user identified by the id path parameter
+func (h *userHandler) respondWithUser(c echo.Context, message string) error {
+	user, err := h.service.GetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return response.NotFound(c, "User not found")
+	}
+
+	return response.OK(c, message, dto.NewUserResponse(user))
+}
+
 // Login handles user authentication
 func (h *userHandler) Login(c echo.Context) error {
 	req := new(dto.LoginRequest)
@@ -226,17 +418,156 @@ func (h *userHandler) Login(c echo.Context) error {
 		return response.ValidationError(c, err)
 	}
 
-	user, err := h.service.ValidateCredentials(c.Request().Context(), req.Email, req.Password)
+	user, err := h.service.ValidateCredentials(c.Request().Context(), req.LoginIdentifier(), req.Password)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrInvalidCredentials):
-			return response.Unauthorized(c, "Invalid email or password")
+			return response.Unauthorized(c, "Invalid email, username or password")
+		default:
+			return response.InternalError(c, "Failed to authenticate user")
+		}
+	}
+
+	if err := h.service.VerifyTOTP(c.Request().Context(), user, req.TOTPCode); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTOTPRequired):
+			return response.Unauthorized(c, "Two-factor code required")
+		case errors.Is(err, service.ErrInvalidTOTP):
+			return response.Unauthorized(c, "Invalid two-factor code")
 		default:
 			return response.InternalError(c, "Failed to authenticate user")
 		}
 	}
 
-	return response.OK(c, "Login successful", dto.NewUserResponse(user))
+	userResponse := dto.NewUserResponse(user)
+	resp := &dto.LoginResponse{UserResponse: userResponse, User: userResponse}
+	if h.auth.JWTSecret != "" {
+		token, err := mwutil.GenerateToken(jwt.MapClaims{
+			"sub":   user.ID.Hex(),
+			"email": user.Email,
+			"roles": user.Roles,
+		}, h.auth.JWTSecret, h.auth.TokenTTL)
+		if err != nil {
+			return response.InternalError(c, "Failed to issue token")
+		}
+		expiresAt, err := mwutil.TokenExpiry(token)
+		if err != nil {
+			return response.InternalError(c, "Failed to issue token")
+		}
+		resp.Token = token
+		resp.ExpiresAt = &expiresAt
+	}
+
+	return response.OK(c, "Login successful", resp)
+}
+
+// Impersonate issues a short-lived token for the user identified by the id path parameter
+// to the calling admin. The token is flagged as impersonated and names the admin, and every
+// impersonation is recorded in the audit log.
+func (h *userHandler) Impersonate(c echo.Context) error {
+	if h.auth.RejectImpersonated {
+		return response.Forbidden(c, "Impersonation is disabled")
+	}
+	if h.auth.JWTSecret == "" {
+		return response.ServiceUnavailable(c, "Token issuance is not configured")
+	}
+
+	adminID := mwutil.CallerID(c)
+	if adminID == "" {
+		return response.Forbidden(c, "Impersonation requires an authenticated admin")
+	}
+	if p, ok := c.Get("principal").(*mwutil.Principal); ok && p.Impersonated() {
+		return response.Forbidden(c, "Impersonation tokens cannot impersonate")
+	}
+
+	user, err := h.service.Impersonate(c.Request().Context(), adminID, c.Param("id"), c.RealIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrImpersonateSelf):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrImpersonateAdmin):
+			return response.Forbidden(c, err.Error())
+		case errors.Is(err, service.ErrImpersonationUnavailable):
+			return response.ServiceUnavailable(c, "Impersonation is not available")
+		default:
+			return response.InternalError(c, "Failed to impersonate user")
+		}
+	}
+
+	ttl := h.auth.ImpersonationTTL
+	if ttl <= 0 {
+		ttl = defaultImpersonationTTL
+	}
+	token, err := mwutil.GenerateToken(jwt.MapClaims{
+		"sub":                    user.ID.Hex(),
+		"email":                  user.Email,
+		"roles":                  user.Roles,
+		mwutil.ClaimImpersonated: true,
+		mwutil.ClaimImpersonator: adminID,
+	}, h.auth.JWTSecret, ttl)
+	if err != nil {
+		return response.InternalError(c, "Failed to issue token")
+	}
+	expiresAt, err := mwutil.TokenExpiry(token)
+	if err != nil {
+		return response.InternalError(c, "Failed to issue token")
+	}
+
+	return response.OK(c, "Impersonation token issued", &dto.ImpersonationResponse{
+		User:           dto.NewUserResponse(user),
+		Token:          token,
+		ExpiresAt:      expiresAt,
+		ImpersonatorID: adminID,
+	})
+}
+
+// ChangePassword handles a user changing their own password
+func (h *userHandler) ChangePassword(c echo.Context) error {
+	// Only the user themselves can change their password
+	if mwutil.CallerID(c) != c.Param("id") {
+		return response.Forbidden(c, "Cannot change another user's password")
+	}
+
+	req := new(dto.ChangePasswordRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.ValidationError(c, err)
+	}
+
+	if err := h.service.ChangePassword(c.Request().Context(), c.Param("id"), req.CurrentPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, service.ErrWeakPassword):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return response.Unauthorized(c, "Current password is incorrect")
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		default:
+			return response.InternalError(c, "Failed to change password")
+		}
+	}
+
+	return response.OK(c, "Password changed successfully", nil)
+}
+
+// RegenerateApiKey handles replacing a user's API key and returns the new key
+func (h *userHandler) RegenerateApiKey(c echo.Context) error {
+	apiKey, err := h.service.RegenerateApiKey(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.NotFound(c, "User not found")
+		default:
+			return response.InternalError(c, "Failed to regenerate API key")
+		}
+	}
+
+	return response.OK(c, "API key regenerated successfully", map[string]string{"api_key": apiKey})
 }
 
 // CreateMany handles batch creation of users
@@ -255,8 +586,16 @@ func (h *userHandler) CreateMany(c echo.Context) error {
 		switch {
 		case errors.Is(err, service.ErrEmailExists):
 			return response.Conflict(c, "One or more users with the provided emails already exist")
+		case errors.Is(err, service.ErrUsernameExists):
+			return response.Conflict(c, "One or more users with the provided usernames already exist")
+		case errors.Is(err, service.ErrInvalidUsername):
+			return response.BadRequest(c, err.Error())
 		case errors.Is(err, service.ErrEmptyBatch):
 			return response.BadRequest(c, "No users provided")
+		case errors.Is(err, service.ErrBatchTooLarge):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrUnknownRole):
+			return response.BadRequest(c, err.Error())
 		default:
 			return response.InternalError(c, "Failed to create users")
 		}
@@ -272,13 +611,25 @@ func (h *userHandler) FindByFilter(c echo.Context) error {
 		return response.BadRequest(c, "Invalid request format")
 	}
 
-	// Convert DTO to filter map
-	filter := make(map[string]interface{})
-	if req.Name != "" {
-		filter["name"] = req.Name
-	}
-	if req.Email != "" {
-		filter["email"] = req.Email
+	filter := req.ToFilter()
+
+	// A page switches to sorted pagination with a total; otherwise limit and skip are used as is
+	if req.Page > 0 {
+		sort, err := toSort(req.Sort, req.Order, userSortFields...)
+		if err != nil {
+			return response.BadRequest(c, err.Error())
+		}
+		itemsPerPage := req.ItemsPerPage
+		if itemsPerPage < 1 {
+			itemsPerPage = 10
+		}
+
+		users, totalCount, err := h.service.FindUsersByFilterPaginated(c.Request().Context(), filter, sort, req.Page, itemsPerPage)
+		if err != nil {
+			return response.InternalError(c, "Failed to find users")
+		}
+
+		return response.Paginated(c, dto.NewUserResponseList(users), req.Page, itemsPerPage, totalCount)
 	}
 
 	users, err := h.service.FindUsersByFilter(c.Request().Context(), filter, req.Limit, req.Skip)
@@ -289,6 +640,21 @@ func (h *userHandler) FindByFilter(c echo.Context) error {
 	return response.OK(c, "Users found successfully", dto.NewUserResponseList(users))
 }
 
+// Count handles counting users matching the filter query parameters
+func (h *userHandler) Count(c echo.Context) error {
+	req := new(dto.UserFilterRequest)
+	if err := c.Bind(req); err != nil {
+		return response.BadRequest(c, "Invalid request format")
+	}
+
+	count, err := h.service.CountUsersByFilter(c.Request().Context(), req.ToFilter())
+	if err != nil {
+		return response.InternalError(c, "Failed to count users")
+	}
+
+	return response.OK(c, "Users counted successfully", map[string]int64{"count": count})
+}
+
 // UpdateMany handles batch update of users
 func (h *userHandler) UpdateMany(c echo.Context) error {
 	req := new(dto.BatchUpdateUsersRequest)
@@ -304,16 +670,15 @@ func (h *userHandler) UpdateMany(c echo.Context) error {
 		return response.BadRequest(c, "No updates provided")
 	}
 
+	if id, invalid := validator.InvalidObjectIDKey(req.Updates); invalid {
+		return response.BadRequest(c, fmt.Sprintf("Invalid user ID format: %s", id))
+	}
+
 	// Map to store individual updates for each user
 	userUpdates := make(map[string]map[string]interface{})
 
 	// Process each user's updates separately
 	for id, updateReq := range req.Updates {
-		// Validate the ID
-		if _, err := primitive.ObjectIDFromHex(id); err != nil {
-			return response.BadRequest(c, fmt.Sprintf("Invalid user ID format: %s", id))
-		}
-
 		// Create a map for this user's updates
 		updates := make(map[string]interface{})
 
@@ -324,13 +689,8 @@ func (h *userHandler) UpdateMany(c echo.Context) error {
 		if updateReq.Email != "" {
 			updates["email"] = updateReq.Email
 		}
-		if updateReq.Password != "" {
-			// Hash password before updating
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateReq.Password), bcrypt.DefaultCost)
-			if err != nil {
-				return response.InternalError(c, "Failed to process password")
-			}
-			updates["password"] = string(hashedPassword)
+		if updateReq.Username != "" {
+			updates["username"] = updateReq.Username
 		}
 
 		// Add updated_at timestamp
@@ -346,7 +706,14 @@ func (h *userHandler) UpdateMany(c echo.Context) error {
 	// This uses the Case 1 approach in the service method
 	count, err := h.service.UpdateUsersByFilter(c.Request().Context(), userUpdates, nil)
 	if err != nil {
-		return response.InternalError(c, "Failed to update users")
+		switch {
+		case errors.Is(err, service.ErrBatchTooLarge), errors.Is(err, service.ErrInvalidUsername):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrUsernameExists):
+			return response.Conflict(c, "Username is already taken")
+		default:
+			return response.InternalError(c, "Failed to update users")
+		}
 	}
 
 	return response.OK(c, fmt.Sprintf("Successfully updated %d users", count), map[string]int64{"updated_count": count})
@@ -368,6 +735,8 @@ func (h *userHandler) DeleteMany(c echo.Context) error {
 		switch {
 		case errors.Is(err, service.ErrEmptyBatch):
 			return response.BadRequest(c, "No valid user IDs provided")
+		case errors.Is(err, service.ErrBatchTooLarge):
+			return response.BadRequest(c, err.Error())
 		default:
 			return response.InternalError(c, "Failed to delete users")
 		}
@@ -0,0 +1,447 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/dto"
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/service"
+	"go-echo-mongo/pkg/web/mwutil"
+	"go-echo-mongo/pkg/web/validator"
+
+	playground "github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeUserService checks passwords against a plain-text map; unimplemented methods panic
+type fakeUserService struct {
+	service.UserService
+	passwords map[string]string
+	audit     []*model.AuditLog
+	// totpCode, when set, enables TOTP for logged in users and is the only code accepted
+	totpCode string
+	// updates records the per-user updates of the last batch update, which fails with updateErr if set
+	updates   map[string]map[string]interface{}
+	updateErr error
+}
+
+func (s *fakeUserService) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+	current, ok := s.passwords[id]
+	switch {
+	case newPassword == "weak":
+		return service.ErrWeakPassword
+	case !ok:
+		return service.ErrUserNotFound
+	case current != oldPassword:
+		return service.ErrInvalidCredentials
+	}
+	s.passwords[id] = newPassword
+	return nil
+}
+
+func TestUserChangePassword(t *testing.T) {
+	user := &model.User{Name: "Jane", ApiKey: "jane-key", Roles: []string{model.RoleUser}}
+	user.ID = primitive.NewObjectID()
+	other := primitive.NewObjectID().Hex()
+	svc := &fakeUserService{passwords: map[string]string{user.ID.Hex(): "Old-passw0rd", other: "Other-passw0rd"}}
+
+	e := echo.New()
+	e.Validator = validator.New()
+	mwutil.SetAPIKeyValidator(fakeAPIKeyValidator{user: user})
+	// Register's group rate limiter needs Redis, so mount the route as Register does without it
+	h := NewUserHandler(svc, AuthConfig{})
+	e.POST("/api/v1/users/:id/change-password", h.ChangePassword, mwutil.NewAPIKeyAuth())
+
+	tests := []struct {
+		name   string
+		id     string
+		apiKey string
+		body   string
+		want   int
+	}{
+		{"no api key", user.ID.Hex(), "", `{"current_password":"Old-passw0rd","new_password":"New-passw0rd"}`, http.StatusUnauthorized},
+		{"another user", other, "jane-key", `{"current_password":"Other-passw0rd","new_password":"New-passw0rd"}`, http.StatusForbidden},
+		{"missing fields", user.ID.Hex(), "jane-key", `{"new_password":"New-passw0rd"}`, http.StatusBadRequest},
+		{"weak password", user.ID.Hex(), "jane-key", `{"current_password":"Old-passw0rd","new_password":"weak"}`, http.StatusBadRequest},
+		{"wrong password", user.ID.Hex(), "jane-key", `{"current_password":"Wrong-passw0rd","new_password":"New-passw0rd"}`, http.StatusUnauthorized},
+		{"success", user.ID.Hex(), "jane-key", `{"current_password":"Old-passw0rd","new_password":"New-passw0rd"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/"+tt.id+"/change-password", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if tt.apiKey != "" {
+			req.Header.Set("X-API-Key", tt.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	if svc.passwords[user.ID.Hex()] != "New-passw0rd" || svc.passwords[other] != "Other-passw0rd" {
+		t.Errorf("expected only the caller's password to change, got %v", svc.passwords)
+	}
+}
+
+func (s *fakeUserService) CreateUsers(ctx context.Context, users []*model.User) error {
+	for _, u := range users {
+		u.ID = primitive.NewObjectID()
+	}
+	return nil
+}
+
+func (s *fakeUserService) UpdateUsersByFilter(ctx context.Context, filter interface{}, updates interface{}) (int64, error) {
+	if s.updateErr != nil {
+		return 0, s.updateErr
+	}
+	s.updates = filter.(map[string]map[string]interface{})
+	return int64(len(s.updates)), nil
+}
+
+func (s *fakeUserService) DeleteUsersByIDs(ctx context.Context, ids []string) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func TestUserBatchEndpointsEnforceMaxBatchSize(t *testing.T) {
+	v := validator.New()
+	if err := v.RegisterCustomValidation("role", func(fl playground.FieldLevel) bool {
+		return model.IsKnownRole(fl.Field().String())
+	}); err != nil {
+		t.Fatalf("failed to register role validation: %v", err)
+	}
+	e := echo.New()
+	e.Validator = v
+	// Register's group rate limiter needs Redis, so mount the batch routes directly
+	h := NewUserHandler(&fakeUserService{}, AuthConfig{})
+	e.POST("/api/v1/users/batch", h.CreateMany)
+	e.PUT("/api/v1/users/batch", h.UpdateMany)
+	e.DELETE("/api/v1/users/batch", h.DeleteMany)
+
+	email := 0
+	user := func() interface{} {
+		email++
+		return map[string]interface{}{"name": "Jane", "email": fmt.Sprintf("jane%d@example.com", email), "password": "Str0ng-passw0rd"}
+	}
+
+	expectBatchLimit(t, e, "/api/v1/users/batch", "", []batchLimitCase{
+		{"create", http.MethodPost, "users", user, false, http.StatusCreated},
+		{"update", http.MethodPut, "updates", func() interface{} { return map[string]interface{}{"name": "Renamed"} }, true, http.StatusOK},
+		{"delete", http.MethodDelete, "ids", newBatchID, false, http.StatusOK},
+	})
+}
+
+func TestUserUpdateManyUsernames(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"sets the username", nil, http.StatusOK},
+		{"rejects a taken username", service.ErrUsernameExists, http.StatusConflict},
+		{"rejects an invalid username", service.ErrInvalidUsername, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeUserService{updateErr: tt.err}
+			e := echo.New()
+			e.Validator = validator.New()
+			h := NewUserHandler(svc, AuthConfig{})
+			e.PUT("/api/v1/users/batch", h.UpdateMany)
+
+			id := primitive.NewObjectID().Hex()
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/users/batch", strings.NewReader(fmt.Sprintf(`{"updates":{%q:{"username":"jane_doe"}}}`, id)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if tt.want == http.StatusOK && svc.updates[id]["username"] != "jane_doe" {
+				t.Errorf("expected the username to be passed on, got %v", svc.updates[id])
+			}
+		})
+	}
+}
+
+func TestUserUpdateManyRejectsPasswords(t *testing.T) {
+	svc := &fakeUserService{}
+	e := echo.New()
+	e.Validator = validator.New()
+	h := NewUserHandler(svc, AuthConfig{})
+	// Mounted with the StrictJSON middleware Register uses, since its rate limiter needs Redis
+	e.PUT("/api/v1/users/batch", h.UpdateMany, mwutil.StrictJSON(dto.BatchUpdateUsersRequest{}))
+
+	body := fmt.Sprintf(`{"updates":{%q:{"name":"Jane","password":"N3w-passw0rd"}}}`, primitive.NewObjectID().Hex())
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected passwords to be rejected with 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if svc.updates != nil {
+		t.Errorf("expected no update to be made, got %v", svc.updates)
+	}
+}
+
+func TestUserDeleteManyRejectsInvalidIDs(t *testing.T) {
+	e := echo.New()
+	e.Validator = validator.New()
+	h := NewUserHandler(&fakeUserService{}, AuthConfig{})
+	e.DELETE("/api/v1/users/batch", h.DeleteMany)
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{fmt.Sprintf(`{"ids":[%q]}`, primitive.NewObjectID().Hex()), http.StatusOK},
+		{fmt.Sprintf(`{"ids":[%q,"not-an-id"]}`, primitive.NewObjectID().Hex()), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/batch", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.body, tt.want, rec.Code, rec.Body.String())
+		}
+		if tt.want == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "ids[1]") {
+			t.Errorf("%s: expected the invalid ID to be named, got %s", tt.body, rec.Body.String())
+		}
+	}
+}
+
+// ValidateCredentials accepts the identifiers of the users in passwords, keyed by email or username
+func (s *fakeUserService) ValidateCredentials(ctx context.Context, identifier, password string) (*model.User, error) {
+	if s.passwords[identifier] != password {
+		return nil, service.ErrInvalidCredentials
+	}
+	u := &model.User{Name: "Jane", Email: "jane@example.com", Username: "jane_doe", Roles: []string{model.RoleUser}, TOTPEnabled: s.totpCode != ""}
+	u.ID = primitive.NewObjectID()
+	return u, nil
+}
+
+func (s *fakeUserService) VerifyTOTP(ctx context.Context, user *model.User, code string) error {
+	switch {
+	case !user.TOTPEnabled:
+		return nil
+	case code == "":
+		return service.ErrTOTPRequired
+	case code != s.totpCode:
+		return service.ErrInvalidTOTP
+	}
+	return nil
+}
+
+func TestUserLoginByIdentifier(t *testing.T) {
+	svc := &fakeUserService{passwords: map[string]string{"jane@example.com": "Str0ng-passw0rd", "jane_doe": "Str0ng-passw0rd"}}
+
+	e := echo.New()
+	e.Validator = validator.New()
+	h := NewUserHandler(svc, AuthConfig{})
+	e.POST("/api/v1/users/login", h.Login)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"email identifier", `{"identifier":"jane@example.com","password":"Str0ng-passw0rd"}`, http.StatusOK},
+		{"username identifier", `{"identifier":"jane_doe","password":"Str0ng-passw0rd"}`, http.StatusOK},
+		{"legacy email field", `{"email":"jane@example.com","password":"Str0ng-passw0rd"}`, http.StatusOK},
+		{"wrong password", `{"identifier":"jane_doe","password":"Wrong-passw0rd"}`, http.StatusUnauthorized},
+		{"missing identifier", `{"password":"Str0ng-passw0rd"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/login", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+		if tt.want == http.StatusOK && !strings.Contains(rec.Body.String(), `"username":"jane_doe"`) {
+			t.Errorf("%s: expected the username in the response, got %s", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestUserLoginIssuesToken(t *testing.T) {
+	svc := &fakeUserService{passwords: map[string]string{"jane_doe": "Str0ng-passw0rd"}}
+
+	e := echo.New()
+	e.Validator = validator.New()
+	h := NewUserHandler(svc, AuthConfig{JWTSecret: "f3b1c2d4e5a6978812ab34cd56ef7890", TokenTTL: time.Hour})
+	e.POST("/api/v1/users/login", h.Login)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/login", strings.NewReader(`{"identifier":"jane_doe","password":"Str0ng-passw0rd"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Data struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+			User     struct {
+				ID string `json:"id"`
+			} `json:"user"`
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expires_at"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// Clients that read the user from data itself keep working
+	if body.Data.ID == "" || body.Data.Username != "jane_doe" || body.Data.User.ID != body.Data.ID {
+		t.Errorf("expected the user at the top level and under user, got %s", rec.Body.String())
+	}
+	if exp, err := mwutil.TokenExpiry(body.Data.Token); err != nil || !exp.Equal(body.Data.ExpiresAt) {
+		t.Errorf("expected expires_at to match the token's exp %v, got %v (%v)", exp, body.Data.ExpiresAt, err)
+	}
+}
+
+// Impersonate records the impersonation like the real service's audit log and returns a user for any other ID
+func (s *fakeUserService) Impersonate(ctx context.Context, actorID, targetID, remoteIP string) (*model.User, error) {
+	if actorID == targetID {
+		return nil, service.ErrImpersonateSelf
+	}
+	id, err := primitive.ObjectIDFromHex(targetID)
+	if err != nil {
+		return nil, service.ErrUserNotFound
+	}
+	s.audit = append(s.audit, &model.AuditLog{Action: model.AuditActionImpersonate, ActorID: actorID, TargetID: targetID, RemoteIP: remoteIP})
+	u := &model.User{Name: "Jane", Email: "jane@example.com", Roles: []string{model.RoleUser}}
+	u.ID = id
+	return u, nil
+}
+
+func TestUserImpersonate(t *testing.T) {
+	admin := &model.User{Name: "Admin", ApiKey: "admin-key", Roles: []string{model.RoleAdmin}}
+	admin.ID = primitive.NewObjectID()
+	target := primitive.NewObjectID().Hex()
+	auth := AuthConfig{JWTSecret: "test-secret", TokenTTL: 24 * time.Hour, ImpersonationTTL: 10 * time.Minute}
+
+	tests := []struct {
+		name   string
+		auth   AuthConfig
+		id     string
+		apiKey string
+		want   int
+	}{
+		{"no api key", auth, target, "", http.StatusUnauthorized},
+		{"disabled", AuthConfig{JWTSecret: "test-secret", RejectImpersonated: true}, target, "admin-key", http.StatusForbidden},
+		{"no secret", AuthConfig{}, target, "admin-key", http.StatusServiceUnavailable},
+		{"self", auth, admin.ID.Hex(), "admin-key", http.StatusBadRequest},
+		{"success", auth, target, "admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeUserService{}
+			e := echo.New()
+			mwutil.SetAPIKeyValidator(fakeAPIKeyValidator{user: admin})
+			// Register's group rate limiter needs Redis, so mount the route as Register does without it
+			h := NewUserHandler(svc, tt.auth)
+			e.POST("/api/v1/users/:id/impersonate", h.Impersonate, mwutil.NewAPIKeyAuth(model.RoleAdmin))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users/"+tt.id+"/impersonate", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				if len(svc.audit) != 0 {
+					t.Errorf("expected no audit record for a refused impersonation, got %d", len(svc.audit))
+				}
+				return
+			}
+
+			if len(svc.audit) != 1 || svc.audit[0].ActorID != admin.ID.Hex() || svc.audit[0].TargetID != target {
+				t.Fatalf("expected one audit record naming the admin and target, got %+v", svc.audit)
+			}
+
+			var body struct {
+				Data struct {
+					Token          string    `json:"token"`
+					ExpiresAt      time.Time `json:"expires_at"`
+					ImpersonatorID string    `json:"impersonator_id"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Data.ImpersonatorID != admin.ID.Hex() {
+				t.Errorf("expected impersonator %s, got %q", admin.ID.Hex(), body.Data.ImpersonatorID)
+			}
+			if until := time.Until(body.Data.ExpiresAt); until > 10*time.Minute || until < 9*time.Minute {
+				t.Errorf("expected the token to expire in about 10 minutes, got %s", until)
+			}
+			if exp, err := mwutil.TokenExpiry(body.Data.Token); err != nil || !exp.Equal(body.Data.ExpiresAt) {
+				t.Errorf("expected expires_at to match the token's exp %v, got %v (%v)", exp, body.Data.ExpiresAt, err)
+			}
+
+			claims := jwt.MapClaims{}
+			if _, err := jwt.ParseWithClaims(body.Data.Token, claims, func(*jwt.Token) (interface{}, error) {
+				return []byte("test-secret"), nil
+			}); err != nil {
+				t.Fatalf("failed to parse token: %v", err)
+			}
+			if claims["sub"] != target || !mwutil.IsImpersonated(claims) || claims[mwutil.ClaimImpersonator] != admin.ID.Hex() {
+				t.Errorf("expected an impersonated token for %s issued to %s, got %v", target, admin.ID.Hex(), claims)
+			}
+		})
+	}
+}
+
+func TestUserLoginRequiresTOTPWhenEnabled(t *testing.T) {
+	svc := &fakeUserService{passwords: map[string]string{"jane_doe": "Str0ng-passw0rd"}, totpCode: "287082"}
+
+	e := echo.New()
+	e.Validator = validator.New()
+	h := NewUserHandler(svc, AuthConfig{})
+	e.POST("/api/v1/users/login", h.Login)
+
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		message string
+	}{
+		{"missing code", `{"identifier":"jane_doe","password":"Str0ng-passw0rd"}`, http.StatusUnauthorized, "Two-factor code required"},
+		{"wrong code", `{"identifier":"jane_doe","password":"Str0ng-passw0rd","totp_code":"000000"}`, http.StatusUnauthorized, "Invalid two-factor code"},
+		{"wrong password with code", `{"identifier":"jane_doe","password":"Wrong-passw0rd","totp_code":"287082"}`, http.StatusUnauthorized, "Invalid email, username or password"},
+		{"valid code", `{"identifier":"jane_doe","password":"Str0ng-passw0rd","totp_code":"287082"}`, http.StatusOK, "Login successful"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/login", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.message) {
+			t.Errorf("%s: expected %d %q, got %d: %s", tt.name, tt.want, tt.message, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/service"
+	"go-echo-mongo/pkg/web/mwutil"
+	"go-echo-mongo/pkg/web/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WebhookHandler defines the interface for webhook-related HTTP handlers
+type WebhookHandler interface {
+	Register(e *echo.Echo)
+	GetDeadLetters(c echo.Context) error
+	ReplayDeadLetter(c echo.Context) error
+}
+
+// webhookHandler implements WebhookHandler interface
+type webhookHandler struct {
+	service service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler instance
+func NewWebhookHandler(service service.WebhookService) WebhookHandler {
+	return &webhookHandler{
+		service: service,
+	}
+}
+
+// Register registers all webhook routes
+func (h *webhookHandler) Register(e *echo.Echo) {
+	webhooks := e.Group("/api/v1/webhooks", mwutil.NewAuth(model.RoleAdmin))
+	webhooks.GET("/dead-letters", h.GetDeadLetters)
+	webhooks.POST("/dead-letters/:id/replay", h.ReplayDeadLetter)
+}
+
+// GetDeadLetters handles listing failed webhook deliveries with pagination
+func (h *webhookHandler) GetDeadLetters(c echo.Context) error {
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	itemsPerPage, err := strconv.ParseInt(c.QueryParam("items_per_page"), 10, 64)
+	if err != nil || itemsPerPage < 1 {
+		itemsPerPage = 10
+	}
+
+	deadLetters, totalCount, err := h.service.GetDeadLetters(c.Request().Context(), page, itemsPerPage)
+	if err != nil {
+		return response.InternalError(c, "Failed to retrieve dead letters")
+	}
+
+	return response.Paginated(c, deadLetters, page, itemsPerPage, totalCount)
+}
+
+// ReplayDeadLetter handles delivering a dead letter again
+func (h *webhookHandler) ReplayDeadLetter(c echo.Context) error {
+	if err := h.service.ReplayDeadLetter(c.Request().Context(), c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, service.ErrDeadLetterNotFound):
+			return response.NotFound(c, "Dead letter not found")
+		case errors.Is(err, service.ErrWebhookDeliveryFailed):
+			return response.Error(c, http.StatusBadGateway, "Webhook delivery failed again")
+		default:
+			return response.InternalError(c, "Failed to replay dead letter")
+		}
+	}
+
+	return response.OK(c, "Dead letter replayed successfully", nil)
+}
@@ -0,0 +1,28 @@
+package model
+
+// Audit actions
+const (
+	// AuditActionImpersonate records an admin issuing a token for another user
+	AuditActionImpersonate = "user.impersonate"
+)
+
+// AuditLog records a privileged action and who performed it.
+// CreatedAt records when the action happened.
+type AuditLog struct {
+	BaseModel `bson:",inline"`
+	Action    string `json:"action" bson:"action"`
+	// ActorID is the ID of the user who performed the action
+	ActorID string `json:"actor_id" bson:"actor_id"`
+	// TargetID is the ID of the user or resource the action was performed on
+	TargetID string `json:"target_id" bson:"target_id"`
+	// RemoteIP is the client IP the request came from, if known
+	RemoteIP string `json:"remote_ip,omitempty" bson:"remote_ip,omitempty"`
+}
+
+// CollectionName returns the MongoDB collection for audit logs
+func (*AuditLog) CollectionName() string {
+	return "audit_logs"
+}
+
+// Ensure AuditLog implements BaseModel interface
+var _ Model = (*AuditLog)(nil)
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// DeadLetter records a webhook delivery that still failed after every retry
+type DeadLetter struct {
+	BaseModel `bson:",inline"`
+	URL       string `json:"url" bson:"url"`
+	EventType string `json:"event_type" bson:"event_type"`
+	// Payload is the exact request body that was sent
+	Payload   string `json:"payload" bson:"payload"`
+	Attempts  int    `json:"attempts" bson:"attempts"`
+	LastError string `json:"last_error" bson:"last_error"`
+
+	// LastReplayedAt is set when a replay was attempted and also failed
+	LastReplayedAt *time.Time `json:"last_replayed_at,omitempty" bson:"last_replayed_at,omitempty"`
+}
+
+// CollectionName returns the MongoDB collection for dead letters
+func (*DeadLetter) CollectionName() string {
+	return "webhook_dead_letters"
+}
+
+// Ensure DeadLetter implements BaseModel interface
+var _ Model = (*DeadLetter)(nil)
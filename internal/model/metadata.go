@@ -0,0 +1,97 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxMetadataKeys is the maximum number of top-level metadata keys
+	MaxMetadataKeys = 50
+	// MaxMetadataDepth is the maximum nesting depth of metadata values
+	MaxMetadataDepth = 3
+	// MaxMetadataSize is the maximum size of encoded metadata in bytes
+	MaxMetadataSize = 16 * 1024
+)
+
+// ErrInvalidMetadata is returned when metadata exceeds the allowed size or depth
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata holds arbitrary key/value attributes attached to a model
+type Metadata map[string]interface{}
+
+// Validate checks that the metadata stays within the key, depth and size limits
+func (m Metadata) Validate() error {
+	if len(m) > MaxMetadataKeys {
+		return fmt.Errorf("%w: more than %d keys", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+
+	for key, value := range m {
+		if key == "" {
+			return fmt.Errorf("%w: keys cannot be empty", ErrInvalidMetadata)
+		}
+		if metadataDepth(value) > MaxMetadataDepth {
+			return fmt.Errorf("%w: key %q is nested deeper than %d levels", ErrInvalidMetadata, key, MaxMetadataDepth)
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	if len(data) > MaxMetadataSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidMetadata, MaxMetadataSize)
+	}
+
+	return nil
+}
+
+// Merge returns a copy of the metadata with updates applied.
+// Keys with a nil value are removed.
+func (m Metadata) Merge(updates Metadata) Metadata {
+	merged := make(Metadata, len(m)+len(updates))
+	for key, value := range m {
+		merged[key] = value
+	}
+	for key, value := range updates {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// metadataDepth returns the nesting depth of a metadata value, where scalars have depth 1
+func metadataDepth(value interface{}) int {
+	maxChild := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			maxChild = max(maxChild, metadataDepth(child))
+		}
+	case Metadata:
+		for _, child := range v {
+			maxChild = max(maxChild, metadataDepth(child))
+		}
+	case primitive.M:
+		for _, child := range v {
+			maxChild = max(maxChild, metadataDepth(child))
+		}
+	case []interface{}:
+		for _, child := range v {
+			maxChild = max(maxChild, metadataDepth(child))
+		}
+	case primitive.A:
+		for _, child := range v {
+			maxChild = max(maxChild, metadataDepth(child))
+		}
+	default:
+		return 1
+	}
+	return maxChild + 1
+}
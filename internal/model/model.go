@@ -14,6 +14,10 @@ type Model interface {
 	GetUpdatedAt() time.Time
 	SetCreatedAt(time.Time)
 	SetUpdatedAt(time.Time)
+	GetMetadata() Metadata
+	SetMetadata(Metadata)
+	// CollectionName returns the MongoDB collection the model is stored in
+	CollectionName() string
 }
 
 // model implements Model interface with common fields
@@ -21,6 +25,9 @@ type BaseModel struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+	Metadata  Metadata           `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	// DeletedAt is set when the model is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
 }
 
 // GetID returns the ID of the model
@@ -53,6 +60,21 @@ func (m *BaseModel) SetUpdatedAt(t time.Time) {
 	m.UpdatedAt = t
 }
 
+// GetMetadata returns the metadata attributes
+func (m *BaseModel) GetMetadata() Metadata {
+	return m.Metadata
+}
+
+// SetMetadata sets the metadata attributes
+func (m *BaseModel) SetMetadata(metadata Metadata) {
+	m.Metadata = metadata
+}
+
+// IsDeleted reports whether the model has been soft-deleted
+func (m *BaseModel) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
 // StringToObjectID converts a string ID to a primitive.ObjectID
 func StringToObjectID(id string) (primitive.ObjectID, error) {
 	return primitive.ObjectIDFromHex(id)
@@ -0,0 +1,65 @@
+package model
+
+import "testing"
+
+func TestCollectionName(t *testing.T) {
+	tests := []struct {
+		model Model
+		want  string
+	}{
+		{&User{}, "users"},
+		{&Product{}, "products"},
+		{(*User)(nil), "users"},
+		{(*Product)(nil), "products"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.model.CollectionName(); got != tt.want {
+			t.Errorf("%T: expected collection %q, got %q", tt.model, tt.want, got)
+		}
+	}
+}
+
+func TestUserHasScope(t *testing.T) {
+	tests := []struct {
+		granted []string
+		scope   string
+		want    bool
+	}{
+		{[]string{"products:read"}, "products:read", true},
+		{[]string{"products:read"}, "products:write", false},
+		{[]string{"products:*"}, "products:write", true},
+		{[]string{"products:*"}, "users:read", false},
+		{[]string{"*"}, "users:write", true},
+		{[]string{"prod:*"}, "products:read", false},
+		{nil, "products:read", false},
+	}
+
+	for _, tt := range tests {
+		u := &User{Scopes: tt.granted}
+		if got := u.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%v has %q: expected %v, got %v", tt.granted, tt.scope, tt.want, got)
+		}
+	}
+
+	u := &User{Scopes: []string{"products:*", "users:read"}}
+	if !u.HasAllScopes("products:write", "users:read") || u.HasAllScopes("products:read", "users:write") {
+		t.Error("expected HasAllScopes to require every scope")
+	}
+	if !u.HasAllScopes() {
+		t.Error("expected no required scopes to always match")
+	}
+}
+
+func TestIsValidScope(t *testing.T) {
+	for _, scope := range []string{"*", "products:*", "products:read", "api-keys:rotate"} {
+		if !IsValidScope(scope) {
+			t.Errorf("expected %q to be valid", scope)
+		}
+	}
+	for _, scope := range []string{"", "products", "*:read", "products:", "Products:read", "products:read:all"} {
+		if IsValidScope(scope) {
+			t.Errorf("expected %q to be invalid", scope)
+		}
+	}
+}
@@ -1,13 +1,60 @@
 package model
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // Product represents the product model in the system
 type Product struct {
 	BaseModel   `bson:",inline"`
-	Name        string  `json:"name" bson:"name" validate:"required,min=2,max=100"`
-	Description string  `json:"description" bson:"description" validate:"required,min=10,max=1000"`
-	Price       float64 `json:"price" bson:"price" validate:"required,gt=0"`
-	Stock       int32   `json:"stock" bson:"stock" validate:"required,gte=0"`
-	Category    string  `json:"category" bson:"category" validate:"required"`
+	Name        string             `json:"name" bson:"name" validate:"required,min=2,max=100"`
+	Description string             `json:"description" bson:"description" validate:"required,min=10,max=1000"`
+	Price       float64            `json:"price" bson:"price" validate:"required,gt=0"`
+	Stock       int32              `json:"stock" bson:"stock" validate:"required,gte=0"`
+	Category    string             `json:"category" bson:"category" validate:"required"`
+	OwnerID     primitive.ObjectID `json:"owner_id,omitempty" bson:"owner_id,omitempty"`
+
+	// Restock audit
+	LastRestockedAt *time.Time `json:"last_restocked_at,omitempty" bson:"last_restocked_at,omitempty"`
+	LastRestockedBy string     `json:"last_restocked_by,omitempty" bson:"last_restocked_by,omitempty"`
+
+	// Reservations hold stock for checkouts in progress. Only the product repository's
+	// reservation methods change them; expired ones no longer count and are pruned lazily.
+	Reservations []StockReservation `json:"-" bson:"reservations,omitempty"`
+}
+
+// StockReservation holds quantity of a product's stock until it expires
+type StockReservation struct {
+	ID        string    `bson:"id"`
+	Quantity  int32     `bson:"quantity"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// ProductOwner holds the public fields of a product's owner
+type ProductOwner struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id"`
+	Name string             `json:"name" bson:"name"`
+}
+
+// ProductWithOwner is a product joined with its owner's public fields
+type ProductWithOwner struct {
+	Product `bson:",inline"`
+	Owner   *ProductOwner `json:"owner,omitempty" bson:"owner,omitempty"`
+}
+
+// CategoryStats summarizes the products in a single category
+type CategoryStats struct {
+	Category     string  `json:"category" bson:"_id"`
+	TotalStock   int64   `json:"total_stock" bson:"total_stock"`
+	AveragePrice float64 `json:"average_price" bson:"average_price"`
+	ProductCount int64   `json:"product_count" bson:"product_count"`
+}
+
+// CollectionName returns the MongoDB collection for products
+func (*Product) CollectionName() string {
+	return "products"
 }
 
 // Ensure Product implements BaseModel interface
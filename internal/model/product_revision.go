@@ -0,0 +1,21 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// ProductRevision is a snapshot of a product taken just before it was updated.
+// CreatedAt records when the update happened.
+type ProductRevision struct {
+	BaseModel `bson:",inline"`
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Snapshot  Product            `json:"snapshot" bson:"snapshot"`
+	// EditedBy is the ID of the user who made the update, if known
+	EditedBy string `json:"edited_by,omitempty" bson:"edited_by,omitempty"`
+}
+
+// CollectionName returns the MongoDB collection for product revisions
+func (*ProductRevision) CollectionName() string {
+	return "product_revisions"
+}
+
+// Ensure ProductRevision implements BaseModel interface
+var _ Model = (*ProductRevision)(nil)
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"regexp"
+	"strings"
+)
+
 // Role constants for easier access and consistency
 const (
 	RoleAdmin     = "admin"
@@ -10,14 +15,73 @@ const (
 	RoleModerator = "moderator"
 )
 
+// knownRoles is the set of roles that may be assigned to users
+var knownRoles = map[string]bool{
+	RoleAdmin:     true,
+	RoleUser:      true,
+	RoleEditor:    true,
+	RoleViewer:    true,
+	RoleManager:   true,
+	RoleModerator: true,
+}
+
+// SetKnownRoles replaces the set of roles that may be assigned to users
+func SetKnownRoles(roles ...string) {
+	known := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		known[role] = true
+	}
+	knownRoles = known
+}
+
+// IsKnownRole reports whether role may be assigned to users
+func IsKnownRole(role string) bool {
+	return knownRoles[role]
+}
+
 // User represents the user model in the system
 type User struct {
 	BaseModel `bson:",inline"`
-	Name      string   `json:"name" bson:"name" validate:"required,min=2,max=100"`
-	Email     string   `json:"email" bson:"email" validate:"required,email"`
-	Password  string   `json:"password,omitempty" bson:"password" validate:"required,min=6"`
-	ApiKey    string   `json:"api_key,omitempty" bson:"api_key"`
-	Roles     []string `json:"roles" bson:"roles"`
+	Name      string `json:"name" bson:"name" validate:"required,min=2,max=100"`
+	Email     string `json:"email" bson:"email" validate:"required,email"`
+	// Username is optional; it is unique ignoring case and can be used instead of the email to log in
+	Username string   `json:"username,omitempty" bson:"username,omitempty"`
+	Password string   `json:"password,omitempty" bson:"password" validate:"required,min=6"`
+	ApiKey   string   `json:"api_key,omitempty" bson:"api_key"`
+	Roles    []string `json:"roles" bson:"roles"`
+	// Scopes grant fine-grained "resource:action" permissions such as "products:read";
+	// "resource:*" grants every action on a resource and "*" grants everything
+	Scopes []string `json:"scopes,omitempty" bson:"scopes,omitempty"`
+
+	// TOTPSecret is the base32 secret of the user's authenticator app, if they enrolled one
+	TOTPSecret string `json:"-" bson:"totp_secret,omitempty"`
+	// TOTPEnabled requires a TOTP code from the authenticator app on every login
+	TOTPEnabled bool `json:"totp_enabled,omitempty" bson:"totp_enabled,omitempty"`
+
+	// IssuedApiKey holds the plaintext API key right after it is generated so it can be shown once;
+	// only its hash is stored in ApiKey
+	IssuedApiKey string `json:"-" bson:"-"`
+}
+
+// CollectionName returns the MongoDB collection for users
+func (*User) CollectionName() string {
+	return "users"
+}
+
+// ScopeWildcard matches any resource or action in a scope
+const ScopeWildcard = "*"
+
+// scopePattern matches "*", "resource:*" and "resource:action"
+var scopePattern = regexp.MustCompile(`^(\*|[a-z][a-z0-9_-]*:(\*|[a-z][a-z0-9_-]*))$`)
+
+// IsValidScope reports whether scope is "*", "resource:*" or "resource:action"
+func IsValidScope(scope string) bool {
+	return scopePattern.MatchString(scope)
+}
+
+// NormalizeEmail returns the canonical form emails are stored and looked up in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // HasRole checks if the user has a specific role
@@ -57,3 +121,24 @@ func (u *User) IsAdmin() bool {
 
 // Ensure User implements BaseModel interface
 var _ Model = (*User)(nil)
+
+// HasScope checks if the user has been granted scope, directly or through a wildcard
+func (u *User) HasScope(scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, granted := range u.Scopes {
+		if granted == scope || granted == ScopeWildcard || granted == resource+":"+ScopeWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllScopes checks if the user has been granted all of the specified scopes
+func (u *User) HasAllScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !u.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
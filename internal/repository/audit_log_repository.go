@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuditLogRepository defines the interface for audit log database operations
+type AuditLogRepository interface {
+	BaseRepository[*model.AuditLog]
+}
+
+// auditLogRepository implements AuditLogRepository interface
+type auditLogRepository struct {
+	BaseRepository[*model.AuditLog]
+}
+
+// NewAuditLogRepository creates a new AuditLogRepository instance
+func NewAuditLogRepository(db *mongo.Database) AuditLogRepository {
+	return &auditLogRepository{
+		BaseRepository: newBaseRepository[*model.AuditLog](collectionFor[*model.AuditLog](db)),
+	}
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go-echo-mongo/internal/model"
+	"go-echo-mongo/pkg/database"
 	"log"
 	"time"
 
@@ -13,26 +15,66 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// BaseRepository represents the base repository interface with generic operations
+// BaseRepository represents the base repository interface with generic operations.
+// Every method accepts a mongo.SessionContext as ctx to run inside a transaction.
 type BaseRepository[T model.Model] interface {
 	// GetCollection returns the MongoDB collection
 	GetCollection() *mongo.Collection
 
+	// WithTransaction runs fn inside a transaction, committing only if it returns nil
+	WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) (err error)
+	// SupportsTransactions reports whether the server can run transactions; a standalone mongod cannot
+	SupportsTransactions(ctx context.Context) (supported bool, err error)
+
 	// Single document operations
 	Create(ctx context.Context, model T) (err error)
 	FindByID(ctx context.Context, id string) (model T, err error)
+	ExistsByID(ctx context.Context, id string) (exists bool, err error)
 	FindAll(ctx context.Context) (model []T, err error)
-	FindPaginated(ctx context.Context, filter interface{}, page, itemsPerPage int64) (models []T, totalCount int64, err error)
+	FindPaginated(ctx context.Context, filter interface{}, sort Sort, page, itemsPerPage int64) (models []T, totalCount int64, err error)
+	SearchPaginated(ctx context.Context, search string, filter bson.M, page, itemsPerPage int64) (models []T, totalCount int64, err error)
+	FindCursorPaginated(ctx context.Context, filter interface{}, sortField string, afterID string, limit int64) (models []T, nextCursor string, err error)
+	Count(ctx context.Context, filter interface{}) (count int64, err error)
+	Aggregate(ctx context.Context, pipeline mongo.Pipeline, result interface{}) (err error)
 	Update(ctx context.Context, id string, model T) (err error)
+	UpdateFields(ctx context.Context, id string, fields bson.M) (err error)
 	Delete(ctx context.Context, id string) (err error)
+	DeleteAndReturn(ctx context.Context, id string) (model T, err error)
+	SoftDelete(ctx context.Context, id string) (err error)
+	Restore(ctx context.Context, id string) (err error)
 
 	// Batch operations
 	InsertMany(ctx context.Context, models []T) (err error)
+	UpsertMany(ctx context.Context, models []T, keyFields []string) (result *UpsertResult, err error)
 	FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) (model []T, err error)
+	FindEach(ctx context.Context, filter interface{}, opts *options.FindOptions, fn func(T) error) (err error)
 	UpdateMany(ctx context.Context, filter interface{}, update interface{}) (modifiedCount int64, err error)
 	DeleteMany(ctx context.Context, filter interface{}) (deletedCount int64, err error)
 }
 
+// SortOrder is the direction results are sorted in
+type SortOrder int
+
+const (
+	// SortAsc sorts results in ascending order
+	SortAsc SortOrder = 1
+	// SortDesc sorts results in descending order
+	SortDesc SortOrder = -1
+)
+
+// Sort specifies the field and direction to order results by.
+// The zero value keeps the natural order.
+type Sort struct {
+	Field string
+	Order SortOrder
+}
+
+// UpsertResult reports how many documents an UpsertMany inserted and how many it matched and updated
+type UpsertResult struct {
+	Inserted int64 `json:"inserted"`
+	Updated  int64 `json:"updated"`
+}
+
 // baseRepository implements BaseRepository for MongoDB
 type baseRepository[T model.Model] struct {
 	collection *mongo.Collection
@@ -48,11 +90,27 @@ func newBaseRepository[T model.Model](collection *mongo.Collection) *baseReposit
 	}
 }
 
+// collectionFor returns the collection that models of type T are stored in
+func collectionFor[T model.Model](db *mongo.Database) *mongo.Collection {
+	var m T
+	return db.Collection(m.CollectionName())
+}
+
 // GetCollection returns the MongoDB collection
 func (r *baseRepository[T]) GetCollection() *mongo.Collection {
 	return r.collection
 }
 
+// WithTransaction runs fn inside a transaction on the repository's client
+func (r *baseRepository[T]) WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	return database.WithTransaction(ctx, r.collection.Database().Client(), fn)
+}
+
+// SupportsTransactions reports whether the repository's server can run transactions
+func (r *baseRepository[T]) SupportsTransactions(ctx context.Context) (bool, error) {
+	return database.SupportsTransactions(ctx, r.collection.Database().Client())
+}
+
 // Create inserts a new model into the database
 func (r *baseRepository[T]) Create(ctx context.Context, model T) error {
 	// Set both timestamps to the same time
@@ -82,19 +140,33 @@ func (r *baseRepository[T]) FindByID(ctx context.Context, id string) (T, error)
 		return model, fmt.Errorf("invalid ID format: %w", err)
 	}
 
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&model)
+	err = r.collection.FindOne(ctx, scopeDeleted(ctx, bson.M{"_id": objectID})).Decode(&model)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return model, fmt.Errorf("model not found with ID %s", id)
+			return model, fmt.Errorf("model not found with ID %s: %w", id, ErrNotFound)
 		}
 		return model, fmt.Errorf("failed to find model: %w", err)
 	}
 	return model, nil
 }
 
+// ExistsByID reports whether a model with the given ID exists without decoding it
+func (r *baseRepository[T]) ExistsByID(ctx context.Context, id string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	count, err := r.collection.CountDocuments(ctx, scopeDeleted(ctx, bson.M{"_id": objectID}), options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to count documents: %w", err)
+	}
+	return count > 0, nil
+}
+
 // FindAll retrieves all models
 func (r *baseRepository[T]) FindAll(ctx context.Context) ([]T, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{})
+	cursor, err := r.collection.Find(ctx, scopeDeleted(ctx, nil))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute find query: %w", err)
 	}
@@ -109,7 +181,7 @@ func (r *baseRepository[T]) FindAll(ctx context.Context) ([]T, error) {
 }
 
 // FindPaginated retrieves models with simple pagination
-func (r *baseRepository[T]) FindPaginated(ctx context.Context, filter interface{}, page, itemsPerPage int64) ([]T, int64, error) {
+func (r *baseRepository[T]) FindPaginated(ctx context.Context, filter interface{}, sort Sort, page, itemsPerPage int64) ([]T, int64, error) {
 	// Validate pagination parameters
 	if page < 1 {
 		page = 1
@@ -118,9 +190,7 @@ func (r *baseRepository[T]) FindPaginated(ctx context.Context, filter interface{
 		itemsPerPage = 10 // Default items per page
 	}
 
-	if filter == nil {
-		filter = bson.M{}
-	}
+	filter = scopeDeleted(ctx, filter)
 
 	// Calculate skip value
 	skip := (page - 1) * itemsPerPage
@@ -132,9 +202,7 @@ func (r *baseRepository[T]) FindPaginated(ctx context.Context, filter interface{
 	}
 
 	// Set up options for pagination
-	findOptions := options.Find().
-		SetSkip(skip).
-		SetLimit(itemsPerPage)
+	findOptions := paginationOptions(sort, skip, itemsPerPage)
 
 	// Execute the query
 	cursor, err := r.collection.Find(ctx, filter, findOptions)
@@ -152,6 +220,181 @@ func (r *baseRepository[T]) FindPaginated(ctx context.Context, filter interface{
 	return models, totalCount, nil
 }
 
+// Count returns the number of models matching the filter; a nil filter counts every model
+func (r *baseRepository[T]) Count(ctx context.Context, filter interface{}) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, scopeDeleted(ctx, filter))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	return count, nil
+}
+
+// Aggregate runs pipeline on the collection and decodes every result into result, which must be a
+// pointer to a slice. Soft-deleted documents are not excluded; pipelines should $match them out.
+func (r *baseRepository[T]) Aggregate(ctx context.Context, pipeline mongo.Pipeline, result interface{}) error {
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to execute aggregation: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, result); err != nil {
+		return fmt.Errorf("failed to decode aggregation results: %w", err)
+	}
+	return nil
+}
+
+// paginationOptions builds the find options for a page, sorting by sort.Field with _id as the
+// tiebreaker so documents with equal values keep a stable order across pages
+func paginationOptions(sort Sort, skip, limit int64) *options.FindOptions {
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(limit)
+
+	if sort.Field != "" {
+		order := sort.Order
+		if order != SortDesc {
+			order = SortAsc
+		}
+		keys := bson.D{{Key: sort.Field, Value: int(order)}}
+		if sort.Field != "_id" {
+			keys = append(keys, bson.E{Key: "_id", Value: int(order)})
+		}
+		findOptions.SetSort(keys)
+	}
+
+	return findOptions
+}
+
+// SearchPaginated retrieves models matching a $text search, sorted by relevance, with pagination.
+// The collection must have a text index.
+func (r *baseRepository[T]) SearchPaginated(ctx context.Context, search string, filter bson.M, page, itemsPerPage int64) ([]T, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if itemsPerPage < 1 {
+		itemsPerPage = 10
+	}
+
+	// Count and find must share the same filter so the total matches the pages
+	query := scopeDeleted(ctx, textSearchFilter(search, filter))
+
+	totalCount, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	cursor, err := r.collection.Find(ctx, query, textSearchOptions(page, itemsPerPage))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to execute search query: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var models []T
+	if err = cursor.All(ctx, &models); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode models: %w", err)
+	}
+
+	return models, totalCount, nil
+}
+
+// textSearchFilter combines a $text search with an additional filter
+func textSearchFilter(search string, filter bson.M) bson.M {
+	query := bson.M{}
+	for k, v := range filter {
+		query[k] = v
+	}
+	query["$text"] = bson.M{"$search": search}
+	return query
+}
+
+// textSearchOptions sorts by text score, breaking ties by _id so pages are stable
+func textSearchOptions(page, itemsPerPage int64) *options.FindOptions {
+	score := bson.M{"$meta": "textScore"}
+	return options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetSkip((page - 1) * itemsPerPage).
+		SetLimit(itemsPerPage)
+}
+
+// FindCursorPaginated retrieves up to limit models ordered by sortField (then _id), starting after the
+// document identified by afterID. An empty sortField orders by _id and an empty afterID starts from the
+// first document. The returned cursor is the ID to pass as afterID for the next page, or empty on the last page.
+func (r *baseRepository[T]) FindCursorPaginated(ctx context.Context, filter interface{}, sortField string, afterID string, limit int64) ([]T, string, error) {
+	if limit < 1 {
+		limit = 10
+	}
+	filter = scopeDeleted(ctx, filter)
+	if sortField == "" {
+		sortField = "_id"
+	}
+
+	query := filter
+	if afterID != "" {
+		objectID, err := primitive.ObjectIDFromHex(afterID)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+
+		var after interface{}
+		if sortField != "_id" {
+			var doc bson.M
+			err := r.collection.FindOne(ctx, bson.M{"_id": objectID}, options.FindOne().SetProjection(bson.M{sortField: 1})).Decode(&doc)
+			if err != nil {
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					return nil, "", ErrInvalidCursor
+				}
+				return nil, "", fmt.Errorf("failed to find cursor document: %w", err)
+			}
+			after = doc[sortField]
+		}
+
+		query = bson.M{"$and": bson.A{filter, cursorRangeFilter(sortField, after, objectID)}}
+	}
+
+	// Fetch one extra document to learn whether another page exists
+	findOptions := options.Find().
+		SetSort(cursorSort(sortField)).
+		SetLimit(limit + 1)
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to execute find query: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	models := []T{}
+	if err = cursor.All(ctx, &models); err != nil {
+		return nil, "", fmt.Errorf("failed to decode models: %w", err)
+	}
+
+	if int64(len(models)) <= limit {
+		return models, "", nil
+	}
+	models = models[:limit]
+	return models, models[limit-1].GetID().Hex(), nil
+}
+
+// cursorRangeFilter matches documents after the cursor position in (sortField, _id) order
+func cursorRangeFilter(sortField string, after interface{}, afterID primitive.ObjectID) bson.M {
+	if sortField == "_id" {
+		return bson.M{"_id": bson.M{"$gt": afterID}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{sortField: bson.M{"$gt": after}},
+		bson.M{sortField: after, "_id": bson.M{"$gt": afterID}},
+	}}
+}
+
+// cursorSort orders by sortField with _id as the tiebreaker so page boundaries are stable
+func cursorSort(sortField string) bson.D {
+	if sortField == "_id" {
+		return bson.D{{Key: "_id", Value: 1}}
+	}
+	return bson.D{{Key: sortField, Value: 1}, {Key: "_id", Value: 1}}
+}
+
 // Update updates a model in the database
 func (r *baseRepository[T]) Update(ctx context.Context, id string, model T) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -172,6 +415,30 @@ func (r *baseRepository[T]) Update(ctx context.Context, id string, model T) erro
 	return nil
 }
 
+// UpdateFields sets only the given fields on a model, leaving all others untouched
+func (r *baseRepository[T]) UpdateFields(ctx context.Context, id string, fields bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	set := bson.M{"updated_at": time.Now().UTC()}
+	for k, v := range fields {
+		set[k] = v
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("failed to update model: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("model not found with ID %s: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
 // Delete removes a model from the database
 func (r *baseRepository[T]) Delete(ctx context.Context, id string) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -191,6 +458,74 @@ func (r *baseRepository[T]) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// DeleteAndReturn atomically deletes a model and returns the document that was removed
+func (r *baseRepository[T]) DeleteAndReturn(ctx context.Context, id string) (T, error) {
+	var deleted T
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return deleted, fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	err = r.collection.FindOneAndDelete(ctx, bson.M{"_id": objectID}).Decode(&deleted)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return deleted, fmt.Errorf("model not found with ID %s: %w", id, ErrNotFound)
+		}
+		return deleted, fmt.Errorf("failed to delete model: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// SoftDelete marks a model as deleted so normal reads no longer return it; Restore undoes it
+func (r *baseRepository[T]) SoftDelete(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	now := time.Now().UTC()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to soft delete model: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("model not found with ID %s: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// Restore clears the deletion mark of a soft-deleted model
+func (r *baseRepository[T]) Restore(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{
+			"$set":   bson.M{"updated_at": time.Now().UTC()},
+			"$unset": bson.M{"deleted_at": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore model: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("soft-deleted model not found with ID %s: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
 // InsertMany creates multiple documents
 func (r *baseRepository[T]) InsertMany(ctx context.Context, models []T) error {
 	if len(models) == 0 {
@@ -222,9 +557,76 @@ func (r *baseRepository[T]) InsertMany(ctx context.Context, models []T) error {
 	return nil
 }
 
-// FindMany retrieves documents based on filter
+// UpsertMany writes models idempotently: each is matched on the values of its keyFields
+// (BSON field names forming a natural key, e.g. "name" and "category") and updated if found,
+// inserted otherwise, so re-running the same batch creates no duplicates. Matched documents get
+// every field from the model except _id and created_at, which keep their original values.
+// Soft-deleted documents are matched too and stay deleted. IDs are set on inserted models only.
+func (r *baseRepository[T]) UpsertMany(ctx context.Context, models []T, keyFields []string) (*UpsertResult, error) {
+	if len(keyFields) == 0 {
+		return nil, errors.New("at least one key field is required")
+	}
+	if len(models) == 0 {
+		return &UpsertResult{}, nil
+	}
+
+	now := time.Now().UTC()
+	writeModels := make([]mongo.WriteModel, len(models))
+	for i, model := range models {
+		model.SetUpdatedAt(now)
+		fields, err := toBsonM(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode model at index %d: %w", i, err)
+		}
+		delete(fields, "_id")
+		delete(fields, "created_at")
+
+		filter := bson.M{}
+		for _, key := range keyFields {
+			value, ok := fields[key]
+			if !ok {
+				return nil, fmt.Errorf("model at index %d has no value for key field %q", i, key)
+			}
+			filter[key] = value
+		}
+
+		writeModels[i] = mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": fields, "$setOnInsert": bson.M{"created_at": now}}).
+			SetUpsert(true)
+	}
+
+	result, err := r.collection.BulkWrite(ctx, writeModels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert models: %w", err)
+	}
+
+	for i, upsertedID := range result.UpsertedIDs {
+		if id, ok := upsertedID.(primitive.ObjectID); ok {
+			models[i].SetID(id)
+			models[i].SetCreatedAt(now)
+		}
+	}
+
+	return &UpsertResult{Inserted: result.UpsertedCount, Updated: result.MatchedCount}, nil
+}
+
+// toBsonM encodes a model into a document using its bson tags
+func toBsonM(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// FindMany retrieves documents based on filter, leaving out soft-deleted ones
 func (r *baseRepository[T]) FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]T, error) {
-	cursor, err := r.collection.Find(ctx, filter, opts)
+	cursor, err := r.collection.Find(ctx, scopeDeleted(ctx, filter), opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute find query: %w", err)
 	}
@@ -238,6 +640,33 @@ func (r *baseRepository[T]) FindMany(ctx context.Context, filter interface{}, op
 	return models, nil
 }
 
+// FindEach iterates over documents matching the filter one at a time using a cursor,
+// so large result sets are never loaded into memory. Iteration stops at the first error returned by fn.
+// Soft-deleted documents are skipped.
+func (r *baseRepository[T]) FindEach(ctx context.Context, filter interface{}, opts *options.FindOptions, fn func(T) error) error {
+	cursor, err := r.collection.Find(ctx, scopeDeleted(ctx, filter), opts)
+	if err != nil {
+		return fmt.Errorf("failed to execute find query: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var model T
+		if err := cursor.Decode(&model); err != nil {
+			return fmt.Errorf("failed to decode model: %w", err)
+		}
+		if err := fn(model); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor error: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateMany modifies multiple documents matching the filter
 func (r *baseRepository[T]) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (int64, error) {
 	// For BulkWrite, we expect a slice of write models
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// unconnectedProductRepository returns a repository whose client never connects, for
+// tests of input that is rejected before any query
+func unconnectedProductRepository(t *testing.T) *baseRepository[*model.Product] {
+	t.Helper()
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	return newBaseRepository[*model.Product](collectionFor[*model.Product](client.Database("test")))
+}
+
+func TestExistsByIDRejectsMalformedID(t *testing.T) {
+	repo := unconnectedProductRepository(t)
+
+	exists, err := repo.ExistsByID(context.Background(), "not-an-id")
+	if err == nil || exists {
+		t.Errorf("expected an invalid ID error, got %v (exists=%v)", err, exists)
+	}
+}
+
+func TestExistsByID(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Present", Description: "A test product", Price: 10, Stock: 1, Category: "books"}
+	if err := repo.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{product.ID.Hex(), true},
+		{primitive.NewObjectID().Hex(), false},
+	}
+	for _, tt := range tests {
+		got, err := repo.ExistsByID(ctx, tt.id)
+		if err != nil {
+			t.Fatalf("ExistsByID(%q): unexpected error: %v", tt.id, err)
+		}
+		if got != tt.want {
+			t.Errorf("ExistsByID(%q): expected %v, got %v", tt.id, tt.want, got)
+		}
+	}
+
+	if err := repo.SoftDelete(ctx, product.ID.Hex()); err != nil {
+		t.Fatalf("failed to soft delete product: %v", err)
+	}
+	if got, _ := repo.ExistsByID(ctx, product.ID.Hex()); got {
+		t.Error("expected a soft-deleted product not to exist")
+	}
+}
+
+func TestUpsertManyRejectsMissingKeyFields(t *testing.T) {
+	repo := unconnectedProductRepository(t)
+	products := []*model.Product{{Name: "Keyboard", Category: "electronics"}}
+
+	if _, err := repo.UpsertMany(context.Background(), products, nil); err == nil {
+		t.Error("expected an error without key fields")
+	}
+	if _, err := repo.UpsertMany(context.Background(), products, []string{"name", "sku"}); err == nil || !strings.Contains(err.Error(), `"sku"`) {
+		t.Errorf("expected an error naming the missing key field, got %v", err)
+	}
+}
+
+func TestUpsertManyIsIdempotent(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewProductRepository(db)
+	ctx := context.Background()
+
+	batch := func(price float64) []*model.Product {
+		return []*model.Product{
+			{Name: "Keyboard", Description: "A test product", Price: price, Stock: 5, Category: "electronics"},
+			{Name: "Keyboard", Description: "A test product", Price: price, Stock: 2, Category: "music"},
+		}
+	}
+	keys := []string{"name", "category"}
+
+	first, err := repo.UpsertMany(ctx, batch(10), keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *first != (UpsertResult{Inserted: 2}) {
+		t.Errorf("expected 2 inserts on the first run, got %+v", *first)
+	}
+	original, err := repo.FindMany(ctx, bson.M{}, nil)
+	if err != nil || len(original) != 2 {
+		t.Fatalf("expected 2 products, got %d (%v)", len(original), err)
+	}
+
+	second, err := repo.UpsertMany(ctx, batch(12), keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *second != (UpsertResult{Updated: 2}) {
+		t.Errorf("expected 2 updates on the second run, got %+v", *second)
+	}
+
+	products, err := repo.FindMany(ctx, bson.M{}, nil)
+	if err != nil {
+		t.Fatalf("failed to find products: %v", err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("expected re-running the batch to create no duplicates, got %d products", len(products))
+	}
+	createdAt := map[primitive.ObjectID]time.Time{}
+	for _, p := range original {
+		createdAt[p.ID] = p.CreatedAt
+	}
+	for _, p := range products {
+		if p.Price != 12 {
+			t.Errorf("expected %s/%s to be updated to price 12, got %v", p.Name, p.Category, p.Price)
+		}
+		if want, ok := createdAt[p.ID]; !ok || !p.CreatedAt.Equal(want) {
+			t.Errorf("expected %s/%s to keep its ID and created_at, got %v %v", p.Name, p.Category, p.ID, p.CreatedAt)
+		}
+	}
+}
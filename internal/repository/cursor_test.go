@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRangeFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	byID := cursorRangeFilter("_id", nil, id)
+	if gt := byID["_id"].(bson.M)["$gt"]; gt != id {
+		t.Errorf("expected _id > cursor, got %v", byID)
+	}
+
+	byField := cursorRangeFilter("price", 9.5, id)
+	or, ok := byField["$or"].(bson.A)
+	if !ok || len(or) != 2 {
+		t.Fatalf("expected $or of field range and _id tiebreak, got %v", byField)
+	}
+	tie := or[1].(bson.M)
+	if tie["price"] != 9.5 || tie["_id"].(bson.M)["$gt"] != id {
+		t.Errorf("expected ties on price to be broken by _id, got %v", tie)
+	}
+
+	if sort := cursorSort("price"); len(sort) != 2 || sort[1].Key != "_id" {
+		t.Errorf("expected price then _id sort, got %v", sort)
+	}
+}
+
+func TestFindCursorPaginated(t *testing.T) {
+	db := testDatabase(t)
+	repo := newBaseRepository[*model.Product](collectionFor[*model.Product](db))
+	ctx := context.Background()
+
+	// Prices descend as IDs ascend so the price order differs from insertion order
+	for i := 0; i < 5; i++ {
+		product := &model.Product{Name: fmt.Sprintf("Product %d", i), Description: "A test product", Price: float64(10 - i), Stock: 1, Category: "books"}
+		if err := repo.Create(ctx, product); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+
+	for _, sortField := range []string{"", "price"} {
+		t.Run("sort="+sortField, func(t *testing.T) {
+			first, next, err := repo.FindCursorPaginated(ctx, bson.M{"category": "books"}, sortField, "", 2)
+			if err != nil {
+				t.Fatalf("first page: unexpected error: %v", err)
+			}
+			if len(first) != 2 || next != first[1].ID.Hex() {
+				t.Fatalf("first page: expected 2 products and a cursor, got %d and %q", len(first), next)
+			}
+
+			second, next, err := repo.FindCursorPaginated(ctx, bson.M{"category": "books"}, sortField, next, 2)
+			if err != nil {
+				t.Fatalf("second page: unexpected error: %v", err)
+			}
+			if len(second) != 2 || next == "" {
+				t.Fatalf("second page: expected 2 products and a cursor, got %d and %q", len(second), next)
+			}
+
+			last, next, err := repo.FindCursorPaginated(ctx, bson.M{"category": "books"}, sortField, next, 2)
+			if err != nil {
+				t.Fatalf("last page: unexpected error: %v", err)
+			}
+			if len(last) != 1 || next != "" {
+				t.Fatalf("last page: expected 1 product and no cursor, got %d and %q", len(last), next)
+			}
+
+			seen := make(map[primitive.ObjectID]bool)
+			all := append(append(first, second...), last...)
+			for i, p := range all {
+				if seen[p.ID] {
+					t.Errorf("product %s returned twice", p.ID.Hex())
+				}
+				seen[p.ID] = true
+				if sortField == "price" && i > 0 && p.Price < all[i-1].Price {
+					t.Errorf("expected ascending prices, got %v after %v", p.Price, all[i-1].Price)
+				}
+			}
+
+			empty, next, err := repo.FindCursorPaginated(ctx, bson.M{"category": "books"}, sortField, last[0].ID.Hex(), 2)
+			if err != nil {
+				t.Fatalf("empty page: unexpected error: %v", err)
+			}
+			if len(empty) != 0 || next != "" {
+				t.Errorf("expected an empty final page, got %d products and cursor %q", len(empty), next)
+			}
+		})
+	}
+
+	if _, _, err := repo.FindCursorPaginated(ctx, nil, "price", primitive.NewObjectID().Hex(), 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for an unknown cursor document, got %v", err)
+	}
+}
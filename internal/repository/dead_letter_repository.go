@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DeadLetterRepository defines the interface for webhook dead letter database operations
+type DeadLetterRepository interface {
+	BaseRepository[*model.DeadLetter]
+}
+
+// deadLetterRepository implements DeadLetterRepository interface
+type deadLetterRepository struct {
+	BaseRepository[*model.DeadLetter]
+}
+
+// NewDeadLetterRepository creates a new DeadLetterRepository instance
+func NewDeadLetterRepository(db *mongo.Database) DeadLetterRepository {
+	return &deadLetterRepository{
+		BaseRepository: newBaseRepository[*model.DeadLetter](collectionFor[*model.DeadLetter](db)),
+	}
+}
@@ -5,4 +5,13 @@ import "errors"
 var (
 	// ErrNotFound is returned when a document is not found in the database
 	ErrNotFound = errors.New("document not found")
+
+	// ErrInvalidCursor is returned when a pagination cursor does not reference an existing document
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrInsufficientStock is returned when a product has less stock than a requested decrement
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// ErrReservationNotFound is returned when a stock reservation does not exist, has expired or was already released or committed
+	ErrReservationNotFound = errors.New("reservation not found")
 )
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPaginationOptionsSort(t *testing.T) {
+	tests := []struct {
+		sort Sort
+		want bson.D
+	}{
+		{Sort{}, nil},
+		{Sort{Field: "price", Order: SortAsc}, bson.D{{Key: "price", Value: 1}, {Key: "_id", Value: 1}}},
+		{Sort{Field: "price", Order: SortDesc}, bson.D{{Key: "price", Value: -1}, {Key: "_id", Value: -1}}},
+		{Sort{Field: "_id", Order: SortDesc}, bson.D{{Key: "_id", Value: -1}}},
+	}
+
+	for _, tt := range tests {
+		opts := paginationOptions(tt.sort, 20, 10)
+		if *opts.Skip != 20 || *opts.Limit != 10 {
+			t.Errorf("%+v: expected skip 20 and limit 10, got %d and %d", tt.sort, *opts.Skip, *opts.Limit)
+		}
+		if tt.want == nil {
+			if opts.Sort != nil {
+				t.Errorf("%+v: expected natural order, got %v", tt.sort, opts.Sort)
+			}
+			continue
+		}
+		if got, ok := opts.Sort.(bson.D); !ok || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%+v: expected sort %v, got %v", tt.sort, tt.want, opts.Sort)
+		}
+	}
+}
+
+func TestFindPaginatedSorted(t *testing.T) {
+	db := testDatabase(t)
+	repo := newBaseRepository[*model.Product](collectionFor[*model.Product](db))
+	ctx := context.Background()
+
+	for _, price := range []float64{30, 10, 50, 20, 40} {
+		product := &model.Product{Name: fmt.Sprintf("Product %v", price), Description: "A test product", Price: price, Stock: 1, Category: "books"}
+		if err := repo.Create(ctx, product); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+
+	tests := []struct {
+		order SortOrder
+		want  []float64
+	}{
+		{SortAsc, []float64{10, 20, 30, 40, 50}},
+		{SortDesc, []float64{50, 40, 30, 20, 10}},
+	}
+	for _, tt := range tests {
+		var got []float64
+		for page := int64(1); page <= 3; page++ {
+			products, total, err := repo.FindPaginated(ctx, nil, Sort{Field: "price", Order: tt.order}, page, 2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if total != 5 {
+				t.Errorf("expected total 5, got %d", total)
+			}
+			for _, p := range products {
+				got = append(got, p.Price)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("order %d: expected prices %v, got %v", tt.order, tt.want, got)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewProductRepository(db)
+	ctx := context.Background()
+
+	for i, category := range []string{"books", "books", "toys"} {
+		product := &model.Product{Name: fmt.Sprintf("Product %d", i), Description: "A test product", Price: 10, Stock: 1, Category: category}
+		if err := repo.Create(ctx, product); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter interface{}
+		want   int64
+	}{
+		{nil, 3},
+		{bson.M{}, 3},
+		{bson.M{"category": "books"}, 2},
+		{bson.M{"category": "games"}, 0},
+	}
+	for _, tt := range tests {
+		got, err := repo.Count(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Count(%v): expected %d, got %d", tt.filter, tt.want, got)
+		}
+	}
+}
@@ -2,17 +2,30 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
 
 	"go-echo-mongo/internal/model"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // ProductRepository defines the interface for product-related database operations
 type ProductRepository interface {
 	BaseRepository[*model.Product]
 	FindByCategory(context.Context, string) ([]*model.Product, error)
+	FindProductsWithOwner(ctx context.Context, filter bson.M) ([]*model.ProductWithOwner, error)
+	Restock(ctx context.Context, id string, quantity int32, restockedBy string, restockedAt time.Time) (*model.Product, error)
+	DecrementStock(ctx context.Context, id string, quantity int32) (*model.Product, error)
+	Reserve(ctx context.Context, id string, quantity int32, ttl time.Duration) (holdID string, err error)
+	ReleaseReservation(ctx context.Context, id, holdID string) error
+	CommitReservation(ctx context.Context, id, holdID string) (*model.Product, error)
+	StatsByCategory(ctx context.Context) ([]*model.CategoryStats, error)
 }
 
 // productRepository implements ProductRepository interface
@@ -20,16 +33,46 @@ type productRepository struct {
 	BaseRepository[*model.Product]
 }
 
+// createProductIndexes creates indexes for the product collection
+func createProductIndexes(collection *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: &options.IndexOptions{
+				Weights:    bson.M{"name": 10, "description": 1},
+				Background: &[]bool{true}[0],
+			},
+		},
+		{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 // NewProductRepository creates a new ProductRepository instance
 func NewProductRepository(db *mongo.Database) ProductRepository {
+	collection := collectionFor[*model.Product](db)
+
+	// Create indexes for the product collection if they don't exist
+	createProductIndexes(collection)
+
 	return &productRepository{
-		BaseRepository: newBaseRepository[*model.Product](db.Collection("products")),
+		BaseRepository: newBaseRepository[*model.Product](collection),
 	}
 }
 
 // FindByCategory retrieves all products in a specific category
 func (r *productRepository) FindByCategory(ctx context.Context, category string) ([]*model.Product, error) {
-	cursor, err := r.GetCollection().Find(ctx, bson.M{"category": category})
+	cursor, err := r.GetCollection().Find(ctx, scopeDeleted(ctx, bson.M{"category": category}))
 	if err != nil {
 		return nil, err
 	}
@@ -42,3 +85,285 @@ func (r *productRepository) FindByCategory(ctx context.Context, category string)
 
 	return products, nil
 }
+
+// productWithOwnerPipeline builds the aggregation that joins matching products with their owner.
+// Only the owner's public fields are projected so credentials never leave the database.
+func productWithOwnerPipeline(filter interface{}) mongo.Pipeline {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": (*model.User)(nil).CollectionName(),
+			"let":  bson.M{"ownerId": "$owner_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$ownerId"}}}},
+				bson.M{"$project": bson.M{"_id": 1, "name": 1}},
+			},
+			"as": "owner",
+		}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$owner", "preserveNullAndEmptyArrays": true}}},
+	}
+}
+
+// FindProductsWithOwner retrieves products matching the filter with their owner embedded
+func (r *productRepository) FindProductsWithOwner(ctx context.Context, filter bson.M) ([]*model.ProductWithOwner, error) {
+	cursor, err := r.GetCollection().Aggregate(ctx, productWithOwnerPipeline(scopeDeleted(ctx, filter)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate products with owner: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	products := []*model.ProductWithOwner{}
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, fmt.Errorf("failed to decode products with owner: %w", err)
+	}
+
+	return products, nil
+}
+
+// categoryStatsPipeline groups the products matching filter by category, totalling stock and
+// averaging price, ordered by category name
+func categoryStatsPipeline(filter interface{}) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$category",
+			"total_stock":   bson.M{"$sum": "$stock"},
+			"average_price": bson.M{"$avg": "$price"},
+			"product_count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+}
+
+// StatsByCategory returns the total stock and average price of the products in each category
+func (r *productRepository) StatsByCategory(ctx context.Context) ([]*model.CategoryStats, error) {
+	stats := []*model.CategoryStats{}
+	if err := r.Aggregate(ctx, categoryStatsPipeline(scopeDeleted(ctx, nil)), &stats); err != nil {
+		return nil, fmt.Errorf("failed to aggregate category stats: %w", err)
+	}
+	return stats, nil
+}
+
+// Restock atomically increments a product's stock, records who restocked it and when,
+// and returns the product as it was before the restock. Soft-deleted products are not restocked.
+func (r *productRepository) Restock(ctx context.Context, id string, quantity int32, restockedBy string, restockedAt time.Time) (*model.Product, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"stock": quantity},
+		"$set": bson.M{
+			"last_restocked_at": restockedAt,
+			"last_restocked_by": restockedBy,
+			"updated_at":        restockedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	prior := &model.Product{}
+	err = r.GetCollection().FindOneAndUpdate(ctx, bson.M{"_id": objectID, "deleted_at": nil}, update, opts).Decode(prior)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to restock product: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to restock product: %w", err)
+	}
+
+	return prior, nil
+}
+
+// unexpiredReservations is an aggregation expression for the product's reservations that have not expired at now
+func unexpiredReservations(now time.Time) bson.M {
+	return bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$reservations", bson.A{}}},
+		"cond":  bson.M{"$gt": bson.A{"$$this.expires_at", now}},
+	}}
+}
+
+// availableStockAtLeast is a query filter matching products whose stock, less what unexpired
+// reservations hold, is at least quantity
+func availableStockAtLeast(quantity int32, now time.Time) bson.M {
+	reserved := bson.M{"$sum": bson.M{"$map": bson.M{"input": unexpiredReservations(now), "in": "$$this.quantity"}}}
+	return bson.M{"$expr": bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{"$stock", reserved}}, quantity}}}
+}
+
+// stockUpdateError tells a missing or soft-deleted product apart from one that did not match for lack of stock
+func (r *productRepository) stockUpdateError(ctx context.Context, objectID primitive.ObjectID, action string) error {
+	count, err := r.GetCollection().CountDocuments(ctx, bson.M{"_id": objectID, "deleted_at": nil})
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("failed to %s: %w", action, ErrNotFound)
+	}
+	return fmt.Errorf("failed to %s: %w", action, ErrInsufficientStock)
+}
+
+// DecrementStock atomically removes quantity from a product's stock in a single update that only
+// matches while enough stock remains, so concurrent decrements can never oversell.
+// Stock held by unexpired reservations is left in stock. It returns the product as it was before.
+func (r *productRepository) DecrementStock(ctx context.Context, id string, quantity int32) (*model.Product, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	now := time.Now().UTC()
+	filter := availableStockAtLeast(quantity, now)
+	filter["_id"] = objectID
+	filter["deleted_at"] = nil
+	update := bson.M{
+		"$inc": bson.M{"stock": -quantity},
+		"$set": bson.M{"updated_at": now},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	prior := &model.Product{}
+	if err := r.GetCollection().FindOneAndUpdate(ctx, filter, update, opts).Decode(prior); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, r.stockUpdateError(ctx, objectID, "decrement stock")
+		}
+		return nil, fmt.Errorf("failed to decrement stock: %w", err)
+	}
+	return prior, nil
+}
+
+// Reserve holds quantity of a product's stock for ttl and returns the hold's ID. The availability
+// check and the hold are a single update on the product, so concurrent reservations and
+// decrements can never hold or sell more than is in stock. Expired holds are pruned on the way.
+func (r *productRepository) Reserve(ctx context.Context, id string, quantity int32, ttl time.Duration) (string, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	now := time.Now().UTC()
+	hold := model.StockReservation{ID: primitive.NewObjectID().Hex(), Quantity: quantity, ExpiresAt: now.Add(ttl)}
+	filter := availableStockAtLeast(quantity, now)
+	filter["_id"] = objectID
+	filter["deleted_at"] = nil
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"reservations": bson.M{"$concatArrays": bson.A{unexpiredReservations(now), bson.A{hold}}},
+	}}}}
+	result, err := r.GetCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return "", fmt.Errorf("failed to reserve stock: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return "", r.stockUpdateError(ctx, objectID, "reserve stock")
+	}
+	return hold.ID, nil
+}
+
+// unexpiredHold is a query filter matching the live product holding the unexpired reservation holdID
+func unexpiredHold(objectID primitive.ObjectID, holdID string, now time.Time) bson.M {
+	return bson.M{
+		"_id":          objectID,
+		"deleted_at":   nil,
+		"reservations": bson.M{"$elemMatch": bson.M{"id": holdID, "expires_at": bson.M{"$gt": now}}},
+	}
+}
+
+// ReleaseReservation cancels a reservation, returning ErrReservationNotFound if it is no longer held
+func (r *productRepository) ReleaseReservation(ctx context.Context, id, holdID string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrReservationNotFound
+	}
+
+	result, err := r.GetCollection().UpdateOne(ctx,
+		unexpiredHold(objectID, holdID, time.Now().UTC()),
+		bson.M{"$pull": bson.M{"reservations": bson.M{"id": holdID}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+// CommitReservation removes a reservation's quantity from stock and drops the reservation in a
+// single update, so the hold is only released once the stock has been taken. If stock was lowered
+// below the reservation in the meantime, ErrInsufficientStock is returned and the hold is kept.
+// It returns the product as it was before the commit.
+func (r *productRepository) CommitReservation(ctx context.Context, id, holdID string) (*model.Product, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrReservationNotFound
+	}
+
+	now := time.Now().UTC()
+	product := &model.Product{}
+	opts := options.FindOne().SetProjection(bson.M{"reservations.$": 1})
+	if err := r.GetCollection().FindOne(ctx, unexpiredHold(objectID, holdID, now), opts).Decode(product); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrReservationNotFound
+		}
+		return nil, fmt.Errorf("failed to commit reservation: %w", err)
+	}
+	quantity := product.Reservations[0].Quantity
+
+	// A hold's quantity never changes, so matching the hold again makes the commit happen at most once
+	filter := unexpiredHold(objectID, holdID, now)
+	filter["stock"] = bson.M{"$gte": quantity}
+	update := bson.M{
+		"$inc":  bson.M{"stock": -quantity},
+		"$pull": bson.M{"reservations": bson.M{"id": holdID}},
+		"$set":  bson.M{"updated_at": now},
+	}
+	updateOpts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	prior := &model.Product{}
+	if err := r.GetCollection().FindOneAndUpdate(ctx, filter, update, updateOpts).Decode(prior); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to commit reservation: %w", err)
+		}
+		count, err := r.GetCollection().CountDocuments(ctx, unexpiredHold(objectID, holdID, now))
+		if err != nil {
+			return nil, fmt.Errorf("failed to commit reservation: %w", err)
+		}
+		if count == 0 {
+			return nil, ErrReservationNotFound
+		}
+		return nil, fmt.Errorf("failed to commit reservation: %w", ErrInsufficientStock)
+	}
+	return prior, nil
+}
+
+// Update replaces a product like BaseRepository.Update, but keeps the reservations currently
+// stored rather than the ones read with the product, so a concurrent reservation is never lost
+func (r *productRepository) Update(ctx context.Context, id string, product *model.Product) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	product.SetUpdatedAt(time.Now().UTC())
+	doc, err := toBsonM(product)
+	if err != nil {
+		return fmt.Errorf("failed to encode product: %w", err)
+	}
+	delete(doc, "_id")
+	delete(doc, "reservations")
+
+	// $literal keeps values beginning with "$" from being read as field paths
+	update := mongo.Pipeline{{{Key: "$replaceWith", Value: bson.M{"$mergeObjects": bson.A{
+		bson.M{"_id": "$_id", "reservations": "$reservations"},
+		bson.M{"$literal": doc},
+	}}}}}
+	result, err := r.GetCollection().UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update model: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("model not found with ID %s", id)
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestProductWithOwnerPipelineProjectsPublicFields(t *testing.T) {
+	pipeline := productWithOwnerPipeline(bson.M{"category": "books"})
+	if len(pipeline) != 3 {
+		t.Fatalf("expected $match, $lookup and $unwind stages, got %v", pipeline)
+	}
+
+	lookup, ok := pipeline[1][0].Value.(bson.M)
+	if pipeline[1][0].Key != "$lookup" || !ok {
+		t.Fatalf("expected $lookup stage, got %v", pipeline[1])
+	}
+	if lookup["from"] != "users" {
+		t.Errorf("expected lookup from users, got %v", lookup["from"])
+	}
+
+	stages := lookup["pipeline"].(bson.A)
+	project := stages[len(stages)-1].(bson.M)["$project"].(bson.M)
+	for field := range project {
+		if field != "_id" && field != "name" {
+			t.Errorf("expected only public owner fields to be projected, got %q", field)
+		}
+	}
+}
+
+func TestProductRepositoryFindProductsWithOwner(t *testing.T) {
+	db := testDatabase(t)
+	users := NewUserRepository(db)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	owner := &model.User{
+		Name:     "Jane Doe",
+		Email:    "jane@example.com",
+		Password: "hashed",
+		ApiKey:   "test-api-key",
+		Roles:    []string{model.RoleUser},
+	}
+	if err := users.Create(ctx, owner); err != nil {
+		t.Fatalf("failed to create owner: %v", err)
+	}
+
+	product := &model.Product{
+		Name:        "Owned product",
+		Description: "A product with an owner",
+		Price:       10,
+		Stock:       1,
+		Category:    "books",
+		OwnerID:     owner.ID,
+	}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	found, err := products.FindProductsWithOwner(ctx, bson.M{"_id": product.ID})
+	if err != nil {
+		t.Fatalf("expected products to be found, got error: %v", err)
+	}
+	if len(found) != 1 || found[0].Owner == nil {
+		t.Fatalf("expected one product with owner embedded, got %+v", found)
+	}
+	if found[0].Owner.ID != owner.ID || found[0].Owner.Name != owner.Name {
+		t.Errorf("expected owner %v, got %+v", owner.ID, found[0].Owner)
+	}
+
+	cursor, err := products.GetCollection().Aggregate(ctx, productWithOwnerPipeline(bson.M{"_id": product.ID}))
+	if err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	var raw []bson.M
+	if err := cursor.All(ctx, &raw); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	embedded := raw[0]["owner"].(bson.M)
+	for _, field := range []string{"password", "api_key", "email"} {
+		if _, ok := embedded[field]; ok {
+			t.Errorf("expected %q to be excluded from the embedded owner", field)
+		}
+	}
+}
+
+func TestProductRepositoryDecrementStockConcurrent(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Limited", Description: "Only a few left", Price: 10, Stock: 10, Category: "books"}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	id := product.ID.Hex()
+
+	var succeeded, insufficient atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := products.DecrementStock(ctx, id, 1)
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, ErrInsufficientStock):
+				insufficient.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded.Load() != 10 || insufficient.Load() != 40 {
+		t.Errorf("expected 10 decrements to succeed and 40 to fail, got %d and %d", succeeded.Load(), insufficient.Load())
+	}
+
+	found, err := products.FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to find product: %v", err)
+	}
+	if found.Stock != 0 {
+		t.Errorf("expected stock 0, got %d", found.Stock)
+	}
+
+	if _, err := products.DecrementStock(ctx, primitive.NewObjectID().Hex(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing product, got %v", err)
+	}
+}
+
+func TestProductRepositoryDecrementStockLeavesReserved(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Limited", Description: "Only a few left", Price: 10, Stock: 5, Category: "books"}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	id := product.ID.Hex()
+
+	if _, err := products.Reserve(ctx, id, 3, time.Minute); err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	if _, err := products.DecrementStock(ctx, id, 3); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("expected reserved stock to be kept, got %v", err)
+	}
+	prior, err := products.DecrementStock(ctx, id, 2)
+	if err != nil {
+		t.Fatalf("expected the unreserved stock to be sold, got %v", err)
+	}
+	if prior.Stock != 5 {
+		t.Errorf("expected the product before the decrement, got stock %d", prior.Stock)
+	}
+	if found, _ := products.FindByID(ctx, id); found.Stock != 3 {
+		t.Errorf("expected the 3 reserved to remain, got %d", found.Stock)
+	}
+}
+
+func TestProductRepositorySoftDeletedProductIsNotChanged(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Discontinued", Description: "No longer sold", Price: 10, Stock: 5, Category: "retired"}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	id := product.ID.Hex()
+	holdID, err := products.Reserve(ctx, id, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	if err := products.SoftDelete(ctx, id); err != nil {
+		t.Fatalf("failed to soft delete: %v", err)
+	}
+
+	if _, err := products.DecrementStock(ctx, id, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DecrementStock: expected ErrNotFound, got %v", err)
+	}
+	if _, err := products.Reserve(ctx, id, 1, time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Reserve: expected ErrNotFound, got %v", err)
+	}
+	if _, err := products.Restock(ctx, id, 1, "manager", time.Now().UTC()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restock: expected ErrNotFound, got %v", err)
+	}
+	if _, err := products.CommitReservation(ctx, id, holdID); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("CommitReservation: expected ErrReservationNotFound, got %v", err)
+	}
+	if err := products.ReleaseReservation(ctx, id, holdID); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("ReleaseReservation: expected ErrReservationNotFound, got %v", err)
+	}
+	if found, err := products.FindByCategory(ctx, "retired"); err != nil || len(found) != 0 {
+		t.Errorf("expected FindByCategory to hide the product, got %d (%v)", len(found), err)
+	}
+	if found, err := products.FindProductsWithOwner(ctx, bson.M{"_id": product.ID}); err != nil || len(found) != 0 {
+		t.Errorf("expected FindProductsWithOwner to hide the product, got %d (%v)", len(found), err)
+	}
+
+	stored, err := products.FindByID(IncludeDeleted(ctx), id)
+	if err != nil {
+		t.Fatalf("failed to find the soft-deleted product: %v", err)
+	}
+	if stored.Stock != 5 || len(stored.Reservations) != 1 {
+		t.Errorf("expected stock and reservations to be untouched, got stock %d and %d reservations", stored.Stock, len(stored.Reservations))
+	}
+}
+
+func TestProductRepositoryReservationsNeverOversell(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Limited", Description: "Only a few left", Price: 10, Stock: 10, Category: "books"}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	id := product.ID.Hex()
+
+	// Reservations of 2 race decrements of 1; together they can take at most the 10 in stock
+	var taken atomic.Int32
+	var holds sync.Map
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			holdID, err := products.Reserve(ctx, id, 2, time.Minute)
+			if err == nil {
+				taken.Add(2)
+				holds.Store(holdID, true)
+			} else if !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := products.DecrementStock(ctx, id, 1)
+			if err == nil {
+				taken.Add(1)
+			} else if !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := taken.Load(); got != 10 {
+		t.Errorf("expected exactly the 10 in stock to be reserved or sold, got %d", got)
+	}
+
+	// Committing every hold takes its stock and leaves nothing behind
+	holds.Range(func(holdID, _ any) bool {
+		if _, err := products.CommitReservation(ctx, id, holdID.(string)); err != nil {
+			t.Errorf("failed to commit: %v", err)
+		}
+		if _, err := products.CommitReservation(ctx, id, holdID.(string)); !errors.Is(err, ErrReservationNotFound) {
+			t.Errorf("expected a hold to be committed once, got %v", err)
+		}
+		return true
+	})
+	found, err := products.FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to find product: %v", err)
+	}
+	if found.Stock != 0 || len(found.Reservations) != 0 {
+		t.Errorf("expected no stock and no reservations left, got %d and %v", found.Stock, found.Reservations)
+	}
+}
+
+func TestProductRepositoryUpdateKeepsReservations(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Limited", Description: "Costs $5", Price: 10, Stock: 5, Category: "books"}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	id := product.ID.Hex()
+
+	// The product was read before the reservation, as a concurrent update would have
+	stale, err := products.FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to find product: %v", err)
+	}
+	holdID, err := products.Reserve(ctx, id, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	stale.Name = "Renamed"
+	if err := products.Update(ctx, id, stale); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	found, err := products.FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to find product: %v", err)
+	}
+	if found.Name != "Renamed" || found.Description != "Costs $5" {
+		t.Errorf("expected the update to be stored as given, got %+v", found)
+	}
+	if len(found.Reservations) != 1 || found.Reservations[0].ID != holdID {
+		t.Errorf("expected the reservation made meanwhile to be kept, got %v", found.Reservations)
+	}
+}
+
+func TestProductRepositoryStatsByCategory(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	fixtures := []*model.Product{
+		{Name: "Book", Description: "A test product", Price: 10, Stock: 2, Category: "books"},
+		{Name: "Novel", Description: "A test product", Price: 20, Stock: 3, Category: "books"},
+		{Name: "Toy", Description: "A test product", Price: 5, Stock: 4, Category: "toys"},
+		{Name: "Old toy", Description: "A test product", Price: 100, Stock: 50, Category: "toys"},
+	}
+	for _, p := range fixtures {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+	// Soft-deleted products are left out of the stats
+	if err := products.SoftDelete(ctx, fixtures[3].ID.Hex()); err != nil {
+		t.Fatalf("failed to soft delete product: %v", err)
+	}
+
+	stats, err := products.StatsByCategory(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []model.CategoryStats{
+		{Category: "books", TotalStock: 5, AveragePrice: 15, ProductCount: 2},
+		{Category: "toys", TotalStock: 4, AveragePrice: 5, ProductCount: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("expected %d categories, got %+v", len(want), stats)
+	}
+	for i, st := range stats {
+		if *st != want[i] {
+			t.Errorf("category %d: expected %+v, got %+v", i, want[i], *st)
+		}
+	}
+}
+
+func TestProductRepositorySearchRanksAndFilters(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	fixtures := []*model.Product{
+		{Name: "Desk lamp", Description: "Pairs well with a keyboard tray", Price: 30, Category: "office"},
+		{Name: "Mechanical keyboard", Description: "Tactile switches", Price: 120, Category: "office"},
+		{Name: "Wireless keyboard", Description: "Quiet keys", Price: 40, Category: "office"},
+		{Name: "Keyboard stand", Description: "For musicians", Price: 60, Category: "music"},
+		{Name: "Monitor", Description: "A large screen", Price: 200, Category: "office"},
+		{Name: "Old keyboard", Description: "Discontinued", Price: 10, Category: "office"},
+	}
+	for _, p := range fixtures {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+	// Soft-deleted products never match
+	if err := products.SoftDelete(ctx, fixtures[5].ID.Hex()); err != nil {
+		t.Fatalf("failed to soft delete product: %v", err)
+	}
+
+	names := func(list []*model.Product) []string {
+		var result []string
+		for _, p := range list {
+			result = append(result, p.Name)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name       string
+		filter     bson.M
+		page, size int64
+		wantTotal  int64
+		// want lists the products on the page in any order; last, if set, must rank last
+		want []string
+		last string
+	}{
+		// Name matches outweigh description matches
+		{"all matches", nil, 1, 10, 4, []string{"Mechanical keyboard", "Wireless keyboard", "Keyboard stand", "Desk lamp"}, "Desk lamp"},
+		{"category filter", bson.M{"category": "music"}, 1, 10, 1, []string{"Keyboard stand"}, ""},
+		{"price filter", bson.M{"category": "office", "price": bson.M{"$lte": 50}}, 1, 10, 2, []string{"Wireless keyboard", "Desk lamp"}, "Desk lamp"},
+		// The total counts every match even when the page holds fewer
+		{"second page", nil, 2, 3, 4, []string{"Desk lamp"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := products.SearchPaginated(ctx, "keyboard", tt.filter, tt.page, tt.size)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("expected %d matches, got %d", tt.wantTotal, total)
+			}
+			gotNames := names(got)
+			sorted := slices.Sorted(slices.Values(gotNames))
+			if !slices.Equal(sorted, slices.Sorted(slices.Values(tt.want))) {
+				t.Fatalf("expected %v, got %v", tt.want, gotNames)
+			}
+			if tt.last != "" && gotNames[len(gotNames)-1] != tt.last {
+				t.Errorf("expected %q to rank last, got %v", tt.last, gotNames)
+			}
+		})
+	}
+}
+
+func TestAggregateDecodesIntoSlice(t *testing.T) {
+	db := testDatabase(t)
+	products := NewProductRepository(db)
+	ctx := context.Background()
+
+	for _, stock := range []int32{1, 2, 3} {
+		p := &model.Product{Name: "Product", Description: "A test product", Price: 10, Stock: stock, Category: "books"}
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+
+	var result []struct {
+		Total int32 `bson:"total"`
+	}
+	pipeline := mongo.Pipeline{{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$stock"}}}}}
+	if err := products.Aggregate(ctx, pipeline, &result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0].Total != 6 {
+		t.Errorf("expected a single total of 6, got %+v", result)
+	}
+}
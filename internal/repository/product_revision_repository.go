@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProductRevisionRepository defines the interface for product revision database operations
+type ProductRevisionRepository interface {
+	BaseRepository[*model.ProductRevision]
+}
+
+// productRevisionRepository implements ProductRevisionRepository interface
+type productRevisionRepository struct {
+	BaseRepository[*model.ProductRevision]
+}
+
+// createProductRevisionIndexes creates the index used to page through a product's history
+func createProductRevisionIndexes(collection *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetBackground(true),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewProductRevisionRepository creates a new ProductRevisionRepository instance
+func NewProductRevisionRepository(db *mongo.Database) ProductRevisionRepository {
+	collection := collectionFor[*model.ProductRevision](db)
+
+	// Create indexes for the product revision collection if they don't exist
+	createProductRevisionIndexes(collection)
+
+	return &productRevisionRepository{
+		BaseRepository: newBaseRepository[*model.ProductRevision](collection),
+	}
+}
@@ -0,0 +1,35 @@
+package redisrepo
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Cache lookup counters, exported on /metrics through the default Prometheus registry.
+// The cache label is the key's namespace, e.g. "apikey" for "apikey:<hash>".
+var (
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_hits_total",
+		Help: "Number of cache lookups that found a value, by cache name.",
+	}, []string{"cache"})
+
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_misses_total",
+		Help: "Number of cache lookups that found no value, by cache name.",
+	}, []string{"cache"})
+)
+
+// defaultCacheName labels keys that have no namespace
+const defaultCacheName = "default"
+
+// cacheName returns the logical cache a key belongs to: the part before its first colon.
+// Keys should be namespaced this way so the label stays low-cardinality.
+func cacheName(key string) string {
+	name, _, found := strings.Cut(key, ":")
+	if !found || name == "" {
+		return defaultCacheName
+	}
+	return name
+}
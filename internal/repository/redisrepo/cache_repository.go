@@ -3,7 +3,9 @@ package redisrepo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 )
 
@@ -14,23 +16,50 @@ type CacheRepository interface {
 	Get(ctx context.Context, key string, dest interface{}) error
 	Invalidate(ctx context.Context, keys ...string) error
 
+	// Cache-aside: return the cached value, or load, cache and return it on a miss
+	GetOrSet(ctx context.Context, key string, dest interface{}, ttl time.Duration, loader func() (interface{}, error)) error
+
 	// Cache with tags for group invalidation
 	SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error
 	InvalidateByTag(ctx context.Context, tag string) error
 }
 
+// Cache fill locking: on a miss only the lock holder runs the loader, while other callers
+// wait for it to fill the cache. The lock TTL bounds how long a crashed holder blocks them.
+const (
+	cacheFillLockTTL      = 5 * time.Second
+	cacheFillPollInterval = 20 * time.Millisecond
+)
+
 // cacheRepository implements the CacheRepository interface
 type cacheRepository struct {
-	redis Repository
+	redis   Repository
+	version string
 }
 
 // NewCacheRepository creates a new cache repository
 func NewCacheRepository(redis Repository) CacheRepository {
+	return NewVersionedCacheRepository(redis, "")
+}
+
+// NewVersionedCacheRepository creates a cache repository whose keys are prefixed with a version.
+// Bumping the version (e.g. on deploy) makes every previously cached entry miss,
+// so stale payloads with an old shape are never deserialized.
+func NewVersionedCacheRepository(redis Repository, version string) CacheRepository {
 	return &cacheRepository{
-		redis: redis,
+		redis:   redis,
+		version: version,
 	}
 }
 
+// versionedKey prefixes a key with the cache version when one is configured
+func (c *cacheRepository) versionedKey(key string) string {
+	if c.version == "" {
+		return key
+	}
+	return fmt.Sprintf("cache:v%s:%s", c.version, key)
+}
+
 // Set stores a serialized value in the cache
 func (c *cacheRepository) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	// Serialize the value to JSON
@@ -40,24 +69,104 @@ func (c *cacheRepository) Set(ctx context.Context, key string, value interface{}
 	}
 
 	// Store in Redis
-	return c.redis.Set(ctx, key, data, expiration)
+	return c.redis.Set(ctx, c.versionedKey(key), data, expiration)
 }
 
-// Get retrieves and deserializes a value from the cache
+// Get retrieves and deserializes a value from the cache, counting the lookup as a hit or miss
 func (c *cacheRepository) Get(ctx context.Context, key string, dest interface{}) error {
 	// Get from Redis
-	data, err := c.redis.Get(ctx, key)
+	data, err := c.redis.Get(ctx, c.versionedKey(key))
+	if errors.Is(err, ErrNotFound) {
+		cacheMisses.WithLabelValues(cacheName(key)).Inc()
+	}
 	if err != nil {
 		return err
 	}
+	cacheHits.WithLabelValues(cacheName(key)).Inc()
 
 	// Deserialize the value from JSON
 	return json.Unmarshal([]byte(data), dest)
 }
 
+// GetOrSet fills dest from the cache, or on a miss from loader, caching the loaded value for ttl.
+// Concurrent misses for a key, across instances, run the loader once: one caller holds a short
+// fill lock while the rest wait for its value. If Redis fails, the loader is used uncached.
+func (c *cacheRepository) GetOrSet(ctx context.Context, key string, dest interface{}, ttl time.Duration, loader func() (interface{}, error)) error {
+	err := c.Get(ctx, key, dest)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		_, err := load(dest, loader)
+		return err
+	}
+
+	locks := NewLockRepository(c.redis)
+	lockName := "cache-fill:" + c.versionedKey(key)
+	deadline := time.Now().Add(cacheFillLockTTL)
+	for {
+		token, ok, err := locks.Acquire(ctx, lockName, cacheFillLockTTL)
+		if err != nil {
+			_, err := load(dest, loader)
+			return err
+		}
+		if ok {
+			defer locks.Release(context.WithoutCancel(ctx), lockName, token)
+			break
+		}
+
+		// Wait for the lock holder to fill the cache, loading uncached once its lock should have expired
+		if data, err := c.redis.Get(ctx, c.versionedKey(key)); err == nil {
+			return json.Unmarshal([]byte(data), dest)
+		}
+		if time.Now().After(deadline) {
+			_, err := load(dest, loader)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cacheFillPollInterval):
+		}
+	}
+
+	// Another caller may have filled the cache before the lock was taken
+	if data, err := c.redis.Get(ctx, c.versionedKey(key)); err == nil {
+		return json.Unmarshal([]byte(data), dest)
+	}
+
+	data, err := load(dest, loader)
+	if err != nil {
+		return err
+	}
+	if err := c.redis.Set(ctx, c.versionedKey(key), data, ttl); err != nil {
+		slog.Warn("Failed to cache loaded value", "key", key, "error", err)
+	}
+	return nil
+}
+
+// load calls loader and fills dest with its value, returning the serialized value.
+// The value is round-tripped through JSON so dest is filled exactly as a later cache hit would fill it.
+func load(dest interface{}, loader func() (interface{}, error)) ([]byte, error) {
+	value, err := loader()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize value: %w", err)
+	}
+	return data, json.Unmarshal(data, dest)
+}
+
 // Invalidate removes keys from the cache
 func (c *cacheRepository) Invalidate(ctx context.Context, keys ...string) error {
-	return c.redis.Delete(ctx, keys...)
+	versioned := make([]string, len(keys))
+	for i, key := range keys {
+		versioned[i] = c.versionedKey(key)
+	}
+	return c.redis.Delete(ctx, versioned...)
 }
 
 // SetWithTags stores a value in the cache and associates it with tags for later invalidation
@@ -69,15 +178,11 @@ func (c *cacheRepository) SetWithTags(ctx context.Context, key string, value int
 
 	// Then associate the key with each tag
 	for _, tag := range tags {
-		tagKey := fmt.Sprintf("tag:%s", tag)
-		if err := c.redis.SAdd(ctx, tagKey, key); err != nil {
+		tagKey := c.versionedKey(fmt.Sprintf("tag:%s", tag))
+		// The tag set is created without a TTL, so it outlives its members until it is invalidated
+		if err := c.redis.SAdd(ctx, tagKey, c.versionedKey(key)); err != nil {
 			return fmt.Errorf("failed to associate key with tag %s: %w", tag, err)
 		}
-
-		// Make sure the tag set doesn't expire
-		if err := c.redis.Expire(ctx, tagKey, 0); err != nil {
-			return fmt.Errorf("failed to set expiration for tag %s: %w", tag, err)
-		}
 	}
 
 	return nil
@@ -85,7 +190,7 @@ func (c *cacheRepository) SetWithTags(ctx context.Context, key string, value int
 
 // InvalidateByTag invalidates all cache entries associated with the given tag
 func (c *cacheRepository) InvalidateByTag(ctx context.Context, tag string) error {
-	tagKey := fmt.Sprintf("tag:%s", tag)
+	tagKey := c.versionedKey(fmt.Sprintf("tag:%s", tag))
 
 	// Get all keys associated with the tag
 	keys, err := c.redis.SMembers(ctx, tagKey)
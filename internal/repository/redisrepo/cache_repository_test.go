@@ -0,0 +1,188 @@
+package redisrepo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCacheGetCountsHitsAndMisses(t *testing.T) {
+	cache := NewVersionedCacheRepository(newMemoryRedis(), "3")
+	ctx := context.Background()
+
+	hits := testutil.ToFloat64(cacheHits.WithLabelValues("product"))
+	misses := testutil.ToFloat64(cacheMisses.WithLabelValues("product"))
+
+	var dest map[string]string
+	if err := cache.Get(ctx, "product:42", &dest); err == nil {
+		t.Fatal("expected a miss for an empty cache")
+	}
+	if err := cache.Set(ctx, "product:42", map[string]string{"name": "Widget"}, time.Minute); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := cache.Get(ctx, "product:42", &dest); err != nil || dest["name"] != "Widget" {
+			t.Fatalf("expected a hit, got %v %v", dest, err)
+		}
+	}
+
+	if got := testutil.ToFloat64(cacheHits.WithLabelValues("product")) - hits; got != 2 {
+		t.Errorf("expected 2 hits, got %v", got)
+	}
+	if got := testutil.ToFloat64(cacheMisses.WithLabelValues("product")) - misses; got != 1 {
+		t.Errorf("expected 1 miss, got %v", got)
+	}
+}
+
+func TestCacheName(t *testing.T) {
+	tests := map[string]string{
+		"apikey:abc123":  "apikey",
+		"product:42:v2":  "product",
+		"unprefixed":     defaultCacheName,
+		":leading-colon": defaultCacheName,
+	}
+	for key, want := range tests {
+		if got := cacheName(key); got != want {
+			t.Errorf("cacheName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestCacheGetOrSetLoadsOnceOnConcurrentMisses(t *testing.T) {
+	cache := NewCacheRepository(newMemoryRedis())
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	loader := func() (interface{}, error) {
+		loads.Add(1)
+		// Keep the fill lock held long enough for every caller to miss
+		time.Sleep(50 * time.Millisecond)
+		return map[string]string{"name": "Widget"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var dest map[string]string
+			if err := cache.GetOrSet(ctx, "product:42", &dest, time.Minute, loader); err != nil || dest["name"] != "Widget" {
+				t.Errorf("expected the loaded value, got %v %v", dest, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("expected the loader to run once, ran %d times", got)
+	}
+
+	// Later calls are served from the cache
+	var dest map[string]string
+	if err := cache.GetOrSet(ctx, "product:42", &dest, time.Minute, loader); err != nil || dest["name"] != "Widget" {
+		t.Fatalf("expected a cached value, got %v %v", dest, err)
+	}
+	if got := loads.Load(); got != 1 {
+		t.Errorf("expected a hit not to call the loader, ran %d times", got)
+	}
+}
+
+func TestCacheGetOrSetDoesNotCacheLoaderErrors(t *testing.T) {
+	redis := newMemoryRedis()
+	cache := NewCacheRepository(redis)
+	ctx := context.Background()
+
+	boom := errors.New("boom")
+	var dest map[string]string
+	if err := cache.GetOrSet(ctx, "product:42", &dest, time.Minute, func() (interface{}, error) { return nil, boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected the loader error, got %v", err)
+	}
+	if _, ok := redis.values["product:42"]; ok {
+		t.Error("expected a failed load not to be cached")
+	}
+	if _, ok := redis.values["lock:cache-fill:product:42"]; ok {
+		t.Error("expected the fill lock to be released")
+	}
+}
+
+func TestCacheInvalidateByTagDeletesTaggedKeys(t *testing.T) {
+	redis := newMemoryRedis()
+	cache := NewVersionedCacheRepository(redis, "3")
+	ctx := context.Background()
+
+	for _, key := range []string{"product:1", "product:2"} {
+		if err := cache.SetWithTags(ctx, key, map[string]string{"name": "Widget"}, time.Minute, "products"); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+	}
+	if err := cache.Set(ctx, "product:3", map[string]string{"name": "Untagged"}, time.Minute); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+
+	if members, _ := redis.SMembers(ctx, "cache:v3:tag:products"); len(members) != 2 {
+		t.Fatalf("expected the tag set to hold both keys, got %v", members)
+	}
+
+	if err := cache.InvalidateByTag(ctx, "products"); err != nil {
+		t.Fatalf("failed to invalidate by tag: %v", err)
+	}
+	var dest map[string]string
+	for _, key := range []string{"product:1", "product:2"} {
+		if err := cache.Get(ctx, key, &dest); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %s to be invalidated, got %v", key, err)
+		}
+	}
+	if err := cache.Get(ctx, "product:3", &dest); err != nil {
+		t.Errorf("expected an untagged key to survive, got %v", err)
+	}
+	if members, _ := redis.SMembers(ctx, "cache:v3:tag:products"); len(members) != 0 {
+		t.Errorf("expected the tag set to be cleared, got %v", members)
+	}
+}
+
+func TestCacheVersionBumpMissesOldEntries(t *testing.T) {
+	redis := newMemoryRedis()
+	ctx := context.Background()
+
+	v3 := NewVersionedCacheRepository(redis, "3")
+	if err := v3.Set(ctx, "product:42", map[string]string{"name": "Old shape"}, time.Minute); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if _, ok := redis.values["cache:v3:product:42"]; !ok {
+		t.Fatalf("expected the key to carry the version prefix, got %v", redis.values)
+	}
+
+	var dest map[string]string
+	for name, cache := range map[string]CacheRepository{
+		"bumped version": NewVersionedCacheRepository(redis, "4"),
+		"no version":     NewCacheRepository(redis),
+	} {
+		if err := cache.Get(ctx, "product:42", &dest); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected an entry cached under v3 to miss, got %v (%v)", name, err, dest)
+		}
+	}
+
+	// The loader runs again after the bump and its value is cached under the new version only
+	v4 := NewVersionedCacheRepository(redis, "4")
+	loads := 0
+	loader := func() (interface{}, error) {
+		loads++
+		return map[string]string{"name": "New shape"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		if err := v4.GetOrSet(ctx, "product:42", &dest, time.Minute, loader); err != nil || dest["name"] != "New shape" {
+			t.Fatalf("expected the loaded value, got %v %v", dest, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected one load after the bump, got %d", loads)
+	}
+	if err := v3.Get(ctx, "product:42", &dest); err != nil || dest["name"] != "Old shape" {
+		t.Errorf("expected the v3 entry to be left alone, got %v %v", dest, err)
+	}
+}
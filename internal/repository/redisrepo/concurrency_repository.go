@@ -0,0 +1,55 @@
+package redisrepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultConcurrencySlotTTL bounds how long a slot outlives a process that never released it
+const DefaultConcurrencySlotTTL = 5 * time.Minute
+
+// ConcurrencyRepository counts in-flight requests per key across instances.
+// It satisfies mwutil.ConcurrencyStore.
+type ConcurrencyRepository interface {
+	// Acquire takes a slot for key and reports false when limit slots are already taken
+	Acquire(ctx context.Context, key string, limit int) (bool, error)
+
+	// Release frees a slot taken by a successful Acquire
+	Release(ctx context.Context, key string) error
+}
+
+// concurrencyRepository implements the ConcurrencyRepository interface
+type concurrencyRepository struct {
+	redis   Repository
+	slotTTL time.Duration
+}
+
+// NewConcurrencyRepository creates a new concurrency repository.
+// slotTTL should exceed the longest request; zero uses DefaultConcurrencySlotTTL.
+func NewConcurrencyRepository(redis Repository, slotTTL time.Duration) ConcurrencyRepository {
+	if slotTTL <= 0 {
+		slotTTL = DefaultConcurrencySlotTTL
+	}
+	return &concurrencyRepository{
+		redis:   redis,
+		slotTTL: slotTTL,
+	}
+}
+
+// Acquire takes a slot for key unless limit slots are already taken
+func (r *concurrencyRepository) Acquire(ctx context.Context, key string, limit int) (bool, error) {
+	acquired, err := r.redis.AcquireSlot(ctx, key, limit, r.slotTTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire slot: %w", err)
+	}
+	return acquired, nil
+}
+
+// Release frees a slot taken by Acquire
+func (r *concurrencyRepository) Release(ctx context.Context, key string) error {
+	if err := r.redis.ReleaseSlot(ctx, key); err != nil {
+		return fmt.Errorf("failed to release slot: %w", err)
+	}
+	return nil
+}
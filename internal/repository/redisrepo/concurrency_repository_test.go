@@ -0,0 +1,54 @@
+package redisrepo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyRepositoryAllowsExactlyLimit(t *testing.T) {
+	redis := testRedis(t)
+	repo := NewConcurrencyRepository(redis, time.Minute)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:concurrency:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = redis.Delete(ctx, key) })
+
+	const limit = 5
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := repo.Acquire(ctx, key, limit)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired.Load() != limit {
+		t.Fatalf("expected %d slots to be acquired, got %d", limit, acquired.Load())
+	}
+
+	if err := repo.Release(ctx, key); err != nil {
+		t.Fatalf("failed to release slot: %v", err)
+	}
+	if ok, _ := repo.Acquire(ctx, key, limit); !ok {
+		t.Error("expected a released slot to be acquirable")
+	}
+
+	for i := 0; i < limit; i++ {
+		_ = repo.Release(ctx, key)
+	}
+	if exists, _ := redis.Exists(ctx, key); exists {
+		t.Error("expected the counter to be deleted once every slot was released")
+	}
+}
@@ -0,0 +1,71 @@
+package redisrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrLockNotHeld is returned by Release when the lock has expired or is held under another token
+var ErrLockNotHeld = errors.New("lock not held")
+
+// LockRepository provides mutual exclusion across instances
+type LockRepository interface {
+	// Acquire takes the lock on key for ttl and returns the token needed to release it.
+	// ok is false when another holder has the lock.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (token string, ok bool, err error)
+
+	// Release frees the lock on key if it is still held under token
+	Release(ctx context.Context, key, token string) error
+}
+
+// lockRepository implements the LockRepository interface
+type lockRepository struct {
+	redis Repository
+}
+
+// NewLockRepository creates a new lock repository
+func NewLockRepository(redis Repository) LockRepository {
+	return &lockRepository{
+		redis: redis,
+	}
+}
+
+// Acquire takes the lock on key unless it is already held.
+// The ttl bounds how long a holder that never releases keeps the lock, so it should exceed the critical section.
+func (l *lockRepository) Acquire(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	if ttl <= 0 {
+		return "", false, fmt.Errorf("lock ttl must be positive")
+	}
+
+	token := uuid.New().String()
+	ok, err := l.redis.SetNX(ctx, lockKey(key), token, ttl)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	if !ok {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+// Release frees the lock on key. It returns ErrLockNotHeld instead of deleting a lock
+// that expired and was taken by someone else.
+func (l *lockRepository) Release(ctx context.Context, key, token string) error {
+	deleted, err := l.redis.DeleteIfEquals(ctx, lockKey(key), token)
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	if !deleted {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// lockKey returns the Redis key a lock is stored under
+func lockKey(key string) string {
+	return fmt.Sprintf("lock:%s", key)
+}
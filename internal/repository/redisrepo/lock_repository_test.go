@@ -0,0 +1,101 @@
+package redisrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockRepositoryContendedAcquire(t *testing.T) {
+	redis := testRedis(t)
+	repo := NewLockRepository(redis)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = redis.Delete(ctx, lockKey(key)) })
+
+	var acquired atomic.Int32
+	tokens := make(chan string, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, ok, err := repo.Acquire(ctx, key, time.Minute)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if ok {
+				acquired.Add(1)
+				tokens <- token
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired.Load() != 1 {
+		t.Fatalf("expected exactly one holder, got %d", acquired.Load())
+	}
+
+	if err := repo.Release(ctx, key, <-tokens); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+	if _, ok, _ := repo.Acquire(ctx, key, time.Minute); !ok {
+		t.Error("expected a released lock to be acquirable")
+	}
+}
+
+func TestLockRepositoryExpires(t *testing.T) {
+	redis := testRedis(t)
+	repo := NewLockRepository(redis)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = redis.Delete(ctx, lockKey(key)) })
+
+	stale, ok, err := repo.Acquire(ctx, key, 50*time.Millisecond)
+	if err != nil || !ok {
+		t.Fatalf("expected to acquire the lock, got %v %v", ok, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	token, ok, err := repo.Acquire(ctx, key, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected an expired lock to be acquirable, got %v %v", ok, err)
+	}
+	if token == stale {
+		t.Error("expected each acquisition to get a fresh token")
+	}
+}
+
+func TestLockRepositoryReleaseRequiresToken(t *testing.T) {
+	redis := testRedis(t)
+	repo := NewLockRepository(redis)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = redis.Delete(ctx, lockKey(key)) })
+
+	stale, _, _ := repo.Acquire(ctx, key, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	token, ok, _ := repo.Acquire(ctx, key, time.Minute)
+	if !ok {
+		t.Fatal("expected to acquire the expired lock")
+	}
+
+	// The first holder's lock expired; releasing with its token must not free the new holder's lock
+	if err := repo.Release(ctx, key, stale); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected ErrLockNotHeld for a stale token, got %v", err)
+	}
+	if _, ok, _ := repo.Acquire(ctx, key, time.Minute); ok {
+		t.Error("expected the lock to still be held after a stale release")
+	}
+
+	if err := repo.Release(ctx, key, token); err != nil {
+		t.Errorf("expected the holder to release the lock, got %v", err)
+	}
+	if err := repo.Release(ctx, key, token); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected a second release to report ErrLockNotHeld, got %v", err)
+	}
+}
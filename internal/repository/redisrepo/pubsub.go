@@ -0,0 +1,187 @@
+package redisrepo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-echo-mongo/pkg/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// subscribeBackoff spaces resubscribe attempts after a connection loss
+var subscribeBackoff = database.Backoff{Base: 100 * time.Millisecond, Max: 5 * time.Second}
+
+// subscription tracks the active PubSub connection so it can be replaced on reconnect
+// and closed from outside the receive loop
+type subscription struct {
+	client   *redis.Client
+	channels []string
+	out      chan *redis.Message
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	closed bool
+}
+
+// Subscribe subscribes to the given channels and delivers messages on the returned channel
+// until the context is canceled or the returned close function is called.
+// If the connection to Redis is lost, it resubscribes with exponential backoff and resumes delivery.
+func (r *repository) Subscribe(ctx context.Context, channels ...string) (<-chan *redis.Message, func() error, error) {
+	if len(channels) == 0 {
+		return nil, nil, fmt.Errorf("at least one channel is required")
+	}
+
+	pubsub, err := r.subscribe(ctx, channels)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &subscription{
+		client:   r.client,
+		channels: channels,
+		out:      make(chan *redis.Message),
+		pubsub:   pubsub,
+	}
+
+	// Closing the PubSub unblocks a pending receive when the context ends
+	go func() {
+		<-subCtx.Done()
+		sub.close()
+	}()
+	go sub.receive(subCtx)
+
+	closeFn := func() error {
+		cancel()
+		return nil
+	}
+
+	return sub.out, closeFn, nil
+}
+
+// SubscribeFunc subscribes to the given channels and calls handler for each message, one at a time,
+// until the context is canceled or the returned close function is called.
+// The close function waits for an in-flight handler call to return, so it must not be called from the handler.
+func (r *repository) SubscribeFunc(ctx context.Context, handler func(*redis.Message), channels ...string) (func() error, error) {
+	if handler == nil {
+		return nil, fmt.Errorf("handler is required")
+	}
+
+	messages, closeSub, err := r.Subscribe(ctx, channels...)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range messages {
+			handler(msg)
+		}
+	}()
+
+	closeFn := func() error {
+		err := closeSub()
+		<-done
+		return err
+	}
+
+	return closeFn, nil
+}
+
+// subscribe opens a PubSub connection and waits for the subscription to be confirmed
+func (r *repository) subscribe(ctx context.Context, channels []string) (*redis.PubSub, error) {
+	pubsub := r.client.Subscribe(ctx, channels...)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to channels %v: %w", channels, err)
+	}
+	return pubsub, nil
+}
+
+// receive forwards messages to the output channel, reconnecting on errors
+func (s *subscription) receive(ctx context.Context) {
+	defer close(s.out)
+
+	attempt := 0
+	for {
+		pubsub := s.current()
+		if pubsub == nil {
+			return
+		}
+
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			attempt++
+			backoff := subscribeBackoff.Delay(attempt)
+			slog.Warn("Redis subscription lost, reconnecting", "channels", s.channels, "backoff", backoff, "error", err)
+			if !s.reconnect(ctx, backoff) {
+				return
+			}
+			continue
+		}
+
+		attempt = 0
+		select {
+		case s.out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconnect waits for the backoff period and replaces the PubSub connection.
+// It returns false if the context ended while reconnecting.
+func (s *subscription) reconnect(ctx context.Context, backoff time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(backoff):
+	}
+
+	pubsub := s.client.Subscribe(ctx, s.channels...)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		// Keep the old connection; the next receive fails fast and retries with a longer backoff
+		return ctx.Err() == nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		_ = pubsub.Close()
+		return false
+	}
+	_ = s.pubsub.Close()
+	s.pubsub = pubsub
+	return true
+}
+
+// current returns the active PubSub connection, or nil once closed
+func (s *subscription) current() *redis.PubSub {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	return s.pubsub
+}
+
+// close closes the active PubSub connection
+func (s *subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	_ = s.pubsub.Close()
+}
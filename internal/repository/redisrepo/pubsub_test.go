@@ -0,0 +1,130 @@
+package redisrepo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSubscribeReceivesPublishedMessage(t *testing.T) {
+	repo := testRedis(t)
+	ctx := context.Background()
+	channel := fmt.Sprintf("test:pubsub:%d", time.Now().UnixNano())
+
+	messages, closeFn, err := repo.Subscribe(ctx, channel)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	if err := repo.Publish(ctx, channel, "hello"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.Channel != channel || msg.Payload != "hello" {
+			t.Errorf("expected hello on %s, got %q on %s", channel, msg.Payload, msg.Channel)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	if err := closeFn(); err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Error("expected the message channel to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message channel to close")
+	}
+}
+
+func TestSubscribeFuncStopsOnContextCancel(t *testing.T) {
+	repo := testRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	channel := fmt.Sprintf("test:pubsub:%d", time.Now().UnixNano())
+
+	received := make(chan string, 1)
+	closeFn, err := repo.SubscribeFunc(ctx, func(msg *redis.Message) {
+		received <- msg.Payload
+	}, channel)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	if err := repo.Publish(context.Background(), channel, "hello"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	select {
+	case payload := <-received:
+		if payload != "hello" {
+			t.Errorf("expected hello, got %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		_ = closeFn()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the subscription to stop")
+	}
+}
+
+func TestSubscribeResubscribesAfterConnectionLoss(t *testing.T) {
+	repo := testRedis(t)
+	client := repo.(*repository).client
+	ctx := context.Background()
+	channel := fmt.Sprintf("test:pubsub:%d", time.Now().UnixNano())
+
+	messages, closeFn, err := repo.Subscribe(ctx, channel)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer closeFn()
+
+	// Drop every subscriber connection, as a Redis restart or network failure would
+	killed, err := client.ClientKillByFilter(ctx, "TYPE", "pubsub").Result()
+	if err != nil {
+		t.Fatalf("failed to kill subscriber connections: %v", err)
+	}
+	if killed == 0 {
+		t.Fatal("expected the subscription's connection to be killed")
+	}
+
+	// Messages published before the subscription is restored are lost, so keep publishing until one arrives
+	deadline := time.After(10 * time.Second)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				t.Fatal("expected the message channel to stay open across the reconnect")
+			}
+			if msg.Channel != channel || msg.Payload != "after reconnect" {
+				t.Fatalf("expected the message on %s, got %q on %s", channel, msg.Payload, msg.Channel)
+			}
+			return
+		case <-tick.C:
+			if err := repo.Publish(ctx, channel, "after reconnect"); err != nil {
+				t.Fatalf("failed to publish: %v", err)
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for the subscription to be restored")
+		}
+	}
+}
@@ -3,6 +3,7 @@ package redisrepo
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"strconv"
 	"time"
 )
@@ -17,6 +18,9 @@ type RateLimitRepository interface {
 	// If the key doesn't exist, it sets the provided expiration
 	IncrementPreserveTTL(ctx context.Context, key string, defaultExpiration time.Duration) (int, error)
 
+	// IncrAndExpire atomically increments the counter and sets the TTL if the key has none
+	IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int, error)
+
 	// Check checks if a rate limit has been exceeded without incrementing
 	Check(ctx context.Context, key string) (int, error)
 
@@ -28,6 +32,19 @@ type RateLimitRepository interface {
 
 	// GetState gets the bucket state of a rate limit
 	GetState(ctx context.Context, key string) (string, error)
+
+	// LogRequest drops logged requests older than window, then logs one at now if fewer than limit remain.
+	// It returns whether the request was logged and how many requests the window holds.
+	LogRequest(ctx context.Context, key string, now time.Time, window time.Duration, limit int) (bool, int, error)
+
+	// CountLog drops logged requests older than window and returns how many remain
+	// and when the oldest of them was logged
+	CountLog(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error)
+
+	// AllowGCRA checks a request against the theoretical arrival time stored at key, spacing requests
+	// interval apart with up to burst at once. It returns whether the request is allowed and the
+	// theoretical arrival time after the call. The stored value is readable with GetState as Unix microseconds.
+	AllowGCRA(ctx context.Context, key string, now time.Time, interval time.Duration, burst int) (bool, time.Time, error)
 }
 
 // rateLimitRepository implements the RateLimitRepository interface
@@ -43,61 +60,33 @@ func NewRateLimitRepository(redis Repository) RateLimitRepository {
 }
 
 // Increment increments a counter and returns the current count
+// The expiration is refreshed on every increment
 func (r *rateLimitRepository) Increment(ctx context.Context, key string, expiration time.Duration) (int, error) {
-	// Check if the key exists
-	exists, err := r.redis.Exists(ctx, key)
+	val, err := r.redis.Increment(ctx, key)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check if key exists: %w", err)
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
 	}
 
-	var count int
-
-	if !exists {
-		// Key doesn't exist, create it with a value of 1
-		if err := r.redis.Set(ctx, key, "1", expiration); err != nil {
-			return 0, fmt.Errorf("failed to create rate limit counter: %w", err)
-		}
-		count = 1
-	} else {
-		// Key exists, increment it
-		countStr, err := r.redis.Get(ctx, key)
-		if err != nil {
-			return 0, fmt.Errorf("failed to get rate limit counter: %w", err)
-		}
-
-		count, err = strconv.Atoi(countStr)
-		if err != nil {
-			return 0, fmt.Errorf("invalid rate limit counter value: %w", err)
-		}
-
-		count++
-
-		if err := r.redis.Set(ctx, key, strconv.Itoa(count), expiration); err != nil {
-			return 0, fmt.Errorf("failed to update rate limit counter: %w", err)
+	if expiration > 0 {
+		if err := r.redis.Expire(ctx, key, expiration); err != nil {
+			return 0, fmt.Errorf("failed to set expiration: %w", err)
 		}
 	}
 
-	return count, nil
+	return int(val), nil
 }
 
 // IncrementPreserveTTL increments a counter and preserves the existing TTL
 func (r *rateLimitRepository) IncrementPreserveTTL(ctx context.Context, key string, defaultExpiration time.Duration) (int, error) {
-	// Check if the key exists
-	val, err := r.redis.Increment(ctx, key)
+	return r.IncrAndExpire(ctx, key, defaultExpiration)
+}
+
+// IncrAndExpire atomically increments a counter and sets the TTL if the key has none
+func (r *rateLimitRepository) IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	val, err := r.redis.IncrAndExpire(ctx, key, ttl)
 	if err != nil {
 		return 0, fmt.Errorf("failed to increment counter: %w", err)
 	}
-
-	// If this is a new key (val == 1) or expiration is provided, set the expiration
-	if val == 1 && defaultExpiration > 0 {
-		// Only set expiration if it's greater than 0
-		if defaultExpiration > 0 {
-			if err := r.redis.Expire(ctx, key, defaultExpiration); err != nil {
-				return 0, fmt.Errorf("failed to set expiration: %w", err)
-			}
-		}
-	}
-
 	return int(val), nil
 }
 
@@ -149,3 +138,48 @@ func (r *rateLimitRepository) GetState(ctx context.Context, key string) (string,
 	}
 	return val, nil
 }
+
+// LogRequest records a request in a sorted-set log if the window has room, in one atomic step
+func (r *rateLimitRepository) LogRequest(ctx context.Context, key string, now time.Time, window time.Duration, limit int) (bool, int, error) {
+	// Members must be unique, or requests logged in the same microsecond would collapse into one
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(rand.Uint64(), 36)
+	logged, count, err := r.redis.AddToWindowLog(ctx, key, member, now, window, limit)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to log request: %w", err)
+	}
+	return logged, int(count), nil
+}
+
+// CountLog counts the requests logged within the window ending at now
+func (r *rateLimitRepository) CountLog(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error) {
+	cutoff := strconv.FormatInt(now.Add(-window).UnixMicro(), 10)
+	if err := r.redis.ZRemRangeByScore(ctx, key, "-inf", cutoff); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to trim request log: %w", err)
+	}
+
+	count, err := r.redis.ZCard(ctx, key)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count request log: %w", err)
+	}
+	if count == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	oldest, err := r.redis.ZRangeWithScores(ctx, key, 0, 0)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read request log: %w", err)
+	}
+	if len(oldest) == 0 {
+		return int(count), time.Time{}, nil
+	}
+	return int(count), time.UnixMicro(int64(oldest[0].Score)), nil
+}
+
+// AllowGCRA advances the theoretical arrival time at key if the request conforms, in one atomic step
+func (r *rateLimitRepository) AllowGCRA(ctx context.Context, key string, now time.Time, interval time.Duration, burst int) (bool, time.Time, error) {
+	allowed, tat, err := r.redis.AllowGCRA(ctx, key, now, interval, burst)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("failed to apply GCRA: %w", err)
+	}
+	return allowed, time.UnixMicro(tat), nil
+}
@@ -0,0 +1,137 @@
+package redisrepo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis connects to the Redis instance at REDIS_TEST_ADDR.
+// Tests are skipped when no test instance is configured.
+func testRedis(t *testing.T) Repository {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR not set; skipping Redis integration test")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to ping Redis: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return New(client)
+}
+
+func TestIncrAndExpireConcurrentAllowsExactlyLimit(t *testing.T) {
+	repo := NewRateLimitRepository(testRedis(t))
+	ctx := context.Background()
+	key := fmt.Sprintf("test:rate_limit:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = repo.Reset(ctx, key) })
+
+	const limit = 100
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := repo.IncrAndExpire(ctx, key, time.Minute)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if count <= limit {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != limit {
+		t.Errorf("expected exactly %d requests allowed, got %d", limit, allowed)
+	}
+}
+
+func TestLogRequestConcurrentAllowsExactlyLimit(t *testing.T) {
+	repo := NewRateLimitRepository(testRedis(t))
+	ctx := context.Background()
+	key := fmt.Sprintf("test:rate_limit_log:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = repo.Reset(ctx, key) })
+
+	// Every request is logged at the same instant, so only unique members keep them apart
+	now := time.Now()
+	const limit = 100
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _, err := repo.LogRequest(ctx, key, now, time.Minute, limit)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if ok {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != limit {
+		t.Errorf("expected exactly %d requests allowed, got %d", limit, allowed)
+	}
+
+	// Once the window has moved past them, the logged requests no longer count
+	count, _, err := repo.CountLog(ctx, key, now.Add(time.Minute), time.Minute)
+	if err != nil || count != 0 {
+		t.Errorf("expected an empty window a minute later, got %d (%v)", count, err)
+	}
+}
+
+func TestAllowGCRAConcurrentAllowsExactlyBurst(t *testing.T) {
+	repo := NewRateLimitRepository(testRedis(t))
+	ctx := context.Background()
+	key := fmt.Sprintf("test:rate_limit_gcra:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = repo.Reset(ctx, key) })
+
+	now := time.Now()
+	const burst = 100
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _, err := repo.AllowGCRA(ctx, key, now, time.Second, burst)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if ok {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != burst {
+		t.Errorf("expected exactly %d requests allowed, got %d", burst, allowed)
+	}
+
+	// The stored TAT is a plain integer so the strategy can read it back
+	state, err := repo.GetState(ctx, key)
+	if err != nil || state != fmt.Sprint(now.Add(burst*time.Second).UnixMicro()) {
+		t.Errorf("expected the TAT %d µs, got %q (%v)", now.Add(burst*time.Second).UnixMicro(), state, err)
+	}
+}
@@ -2,12 +2,16 @@ package redisrepo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrNotFound is wrapped by Get and HGet when the key or field does not exist
+var ErrNotFound = errors.New("not found")
+
 // Repository defines the interface for Redis operations
 type Repository interface {
 	// Key-Value Operations
@@ -15,6 +19,9 @@ type Repository interface {
 	Get(ctx context.Context, key string) (string, error)
 	Delete(ctx context.Context, keys ...string) error
 	Exists(ctx context.Context, key string) (bool, error)
+	Scan(ctx context.Context, match string, count int64) ([]string, error)
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	DeleteIfEquals(ctx context.Context, key, value string) (bool, error)
 
 	// List Operations
 	LPush(ctx context.Context, key string, values ...interface{}) error
@@ -25,22 +32,37 @@ type Repository interface {
 	HSet(ctx context.Context, key string, values ...interface{}) error
 	HGet(ctx context.Context, key, field string) (string, error)
 	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HDel(ctx context.Context, key string, fields ...string) error
+	HExists(ctx context.Context, key, field string) (bool, error)
 
 	// Set Operations
 	SAdd(ctx context.Context, key string, members ...interface{}) error
+	SRem(ctx context.Context, key string, members ...interface{}) error
 	SMembers(ctx context.Context, key string) ([]string, error)
 
 	// Sorted Set Operations
 	ZAdd(ctx context.Context, key string, members ...redis.Z) error
 	ZRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	ZRangeWithScores(ctx context.Context, key string, start, stop int64) ([]redis.Z, error)
+	ZRemRangeByScore(ctx context.Context, key, min, max string) error
+	ZCard(ctx context.Context, key string) (int64, error)
 
 	// Pub/Sub Operations
 	Publish(ctx context.Context, channel string, message interface{}) error
+	Subscribe(ctx context.Context, channels ...string) (<-chan *redis.Message, func() error, error)
+	SubscribeFunc(ctx context.Context, handler func(*redis.Message), channels ...string) (func() error, error)
 
 	// Utility Operations
 	Expire(ctx context.Context, key string, expiration time.Duration) error
 	TTL(ctx context.Context, key string) (time.Duration, error)
 	Increment(ctx context.Context, key string) (int64, error)
+	IncrementBy(ctx context.Context, key string, delta int64) (int64, error)
+	DecrementBy(ctx context.Context, key string, delta int64) (int64, error)
+	IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	AcquireSlot(ctx context.Context, key string, limit int, ttl time.Duration) (bool, error)
+	ReleaseSlot(ctx context.Context, key string) error
+	AddToWindowLog(ctx context.Context, key, member string, now time.Time, window time.Duration, limit int) (bool, int64, error)
+	AllowGCRA(ctx context.Context, key string, now time.Time, interval time.Duration, burst int) (bool, int64, error)
 }
 
 // repository implements the Repository interface
@@ -64,7 +86,7 @@ func (r *repository) Set(ctx context.Context, key string, value interface{}, exp
 func (r *repository) Get(ctx context.Context, key string) (string, error) {
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		return "", fmt.Errorf("key %s not found", key)
+		return "", fmt.Errorf("key %s %w", key, ErrNotFound)
 	}
 	return val, err
 }
@@ -80,6 +102,54 @@ func (r *repository) Exists(ctx context.Context, key string) (bool, error) {
 	return result > 0, err
 }
 
+// Scan returns every key matching the glob-style pattern match. It walks the keyspace with the
+// SCAN cursor, asking for about count keys per call, so unlike KEYS it never blocks Redis.
+// Keys added or removed during the scan may or may not be returned.
+func (r *repository) Scan(ctx context.Context, match string, count int64) ([]string, error) {
+	var keys []string
+	seen := make(map[string]struct{})
+	var cursor uint64
+	for {
+		batch, next, err := r.client.Scan(ctx, cursor, match, count).Result()
+		if err != nil {
+			return nil, err
+		}
+		// SCAN may return a key more than once
+		for _, key := range batch {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+// SetNX sets a key only if it does not exist yet and reports whether it was set
+func (r *repository) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+// deleteIfEqualsScript deletes a key only while it still holds the expected value
+var deleteIfEqualsScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DeleteIfEquals atomically deletes a key if its value is value and reports whether it was deleted
+func (r *repository) DeleteIfEquals(ctx context.Context, key, value string) (bool, error) {
+	deleted, err := deleteIfEqualsScript.Run(ctx, r.client, []string{key}, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
 // LPush adds values to the beginning of a list
 func (r *repository) LPush(ctx context.Context, key string, values ...interface{}) error {
 	return r.client.LPush(ctx, key, values...).Err()
@@ -104,7 +174,7 @@ func (r *repository) HSet(ctx context.Context, key string, values ...interface{}
 func (r *repository) HGet(ctx context.Context, key, field string) (string, error) {
 	val, err := r.client.HGet(ctx, key, field).Result()
 	if err == redis.Nil {
-		return "", fmt.Errorf("field %s in key %s not found", field, key)
+		return "", fmt.Errorf("field %s in key %s %w", field, key, ErrNotFound)
 	}
 	return val, err
 }
@@ -114,11 +184,26 @@ func (r *repository) HGetAll(ctx context.Context, key string) (map[string]string
 	return r.client.HGetAll(ctx, key).Result()
 }
 
+// HDel removes fields from a hash; fields that do not exist are ignored
+func (r *repository) HDel(ctx context.Context, key string, fields ...string) error {
+	return r.client.HDel(ctx, key, fields...).Err()
+}
+
+// HExists checks if a field exists in a hash
+func (r *repository) HExists(ctx context.Context, key, field string) (bool, error) {
+	return r.client.HExists(ctx, key, field).Result()
+}
+
 // SAdd adds members to a set
 func (r *repository) SAdd(ctx context.Context, key string, members ...interface{}) error {
 	return r.client.SAdd(ctx, key, members...).Err()
 }
 
+// SRem removes members from a set
+func (r *repository) SRem(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SRem(ctx, key, members...).Err()
+}
+
 // SMembers retrieves all members of a set
 func (r *repository) SMembers(ctx context.Context, key string) ([]string, error) {
 	return r.client.SMembers(ctx, key).Result()
@@ -134,6 +219,21 @@ func (r *repository) ZRange(ctx context.Context, key string, start, stop int64)
 	return r.client.ZRange(ctx, key, start, stop).Result()
 }
 
+// ZRangeWithScores retrieves elements and their scores from a sorted set within the specified range
+func (r *repository) ZRangeWithScores(ctx context.Context, key string, start, stop int64) ([]redis.Z, error) {
+	return r.client.ZRangeWithScores(ctx, key, start, stop).Result()
+}
+
+// ZRemRangeByScore removes the members of a sorted set scored between min and max
+func (r *repository) ZRemRangeByScore(ctx context.Context, key, min, max string) error {
+	return r.client.ZRemRangeByScore(ctx, key, min, max).Err()
+}
+
+// ZCard returns the number of members in a sorted set
+func (r *repository) ZCard(ctx context.Context, key string) (int64, error) {
+	return r.client.ZCard(ctx, key).Result()
+}
+
 // Publish publishes a message to a channel
 func (r *repository) Publish(ctx context.Context, channel string, message interface{}) error {
 	return r.client.Publish(ctx, channel, message).Err()
@@ -149,6 +249,122 @@ func (r *repository) TTL(ctx context.Context, key string) (time.Duration, error)
 	return r.client.TTL(ctx, key).Result()
 }
 
+// Increment increments the integer value of a key by one
 func (r *repository) Increment(ctx context.Context, key string) (int64, error) {
 	return r.client.Incr(ctx, key).Result()
 }
+
+// IncrementBy increments the integer value of a key by delta, which may be negative,
+// and returns the new value. A missing key counts as zero.
+func (r *repository) IncrementBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return r.client.IncrBy(ctx, key, delta).Result()
+}
+
+// DecrementBy decrements the integer value of a key by delta, which may be negative,
+// and returns the new value. A missing key counts as zero.
+func (r *repository) DecrementBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return r.client.DecrBy(ctx, key, delta).Result()
+}
+
+// incrAndExpireScript increments a key and sets its TTL if it has none, in one atomic step
+var incrAndExpireScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// IncrAndExpire atomically increments a key and sets ttl when the key has no expiration yet.
+// An existing TTL is preserved, so the key expires at the end of the window it was created in.
+func (r *repository) IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrAndExpireScript.Run(ctx, r.client, []string{key}, ttl.Milliseconds()).Int64()
+}
+
+// acquireSlotScript increments a counter unless it has reached the limit, refreshing its TTL
+var acquireSlotScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	return 0
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// releaseSlotScript decrements a counter and deletes it once it drops to zero
+var releaseSlotScript = redis.NewScript(`
+if redis.call("DECR", KEYS[1]) <= 0 then
+	redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireSlot atomically takes one of limit slots counted under key.
+// The ttl bounds how long slots leaked by a crashed process are held.
+func (r *repository) AcquireSlot(ctx context.Context, key string, limit int, ttl time.Duration) (bool, error) {
+	acquired, err := acquireSlotScript.Run(ctx, r.client, []string{key}, limit, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// ReleaseSlot frees a slot taken by AcquireSlot
+func (r *repository) ReleaseSlot(ctx context.Context, key string) error {
+	return releaseSlotScript.Run(ctx, r.client, []string{key}).Err()
+}
+
+// windowLogScript trims a sorted-set log to the current window, then adds the member
+// unless the log already holds limit entries
+var windowLogScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+local count = redis.call("ZCARD", KEYS[1])
+if count >= tonumber(ARGV[3]) then
+	return {0, count}
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[5])
+return {1, count + 1}
+`)
+
+// AddToWindowLog atomically drops entries logged at or before now minus window from the sorted set at key,
+// then logs member at now if fewer than limit entries remain. Scores are Unix microseconds.
+// It returns whether member was added and how many entries the window holds.
+func (r *repository) AddToWindowLog(ctx context.Context, key, member string, now time.Time, window time.Duration, limit int) (bool, int64, error) {
+	cutoff := now.Add(-window).UnixMicro()
+	result, err := windowLogScript.Run(ctx, r.client, []string{key}, cutoff, now.UnixMicro(), limit, member, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, result[1], nil
+}
+
+// gcraScript advances a stored theoretical arrival time (TAT) by one emission interval
+// unless that would put it more than burst intervals ahead of now. The TAT is stored as
+// Unix microseconds formatted without an exponent, and expires once it is in the past.
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+if new_tat - now > interval * tonumber(ARGV[3]) then
+	return {0, tat}
+end
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, new_tat}
+`)
+
+// AllowGCRA atomically applies the generic cell rate algorithm to the TAT stored at key:
+// requests are spaced interval apart, and up to burst of them may arrive at once.
+// It returns whether the request conforms and the TAT, in Unix microseconds, after the call.
+func (r *repository) AllowGCRA(ctx context.Context, key string, now time.Time, interval time.Duration, burst int) (bool, int64, error) {
+	result, err := gcraScript.Run(ctx, r.client, []string{key}, now.UnixMicro(), interval.Microseconds(), burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, result[1], nil
+}
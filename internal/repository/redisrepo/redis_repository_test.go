@@ -0,0 +1,119 @@
+package redisrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScanMatchesPattern(t *testing.T) {
+	redis := testRedis(t)
+	ctx := context.Background()
+	prefix := fmt.Sprintf("test:scan:%d", time.Now().UnixNano())
+
+	var want []string
+	keys := []string{"session:a", "session:b", "session:c", "user:1:sessions", "sessions"}
+	for _, key := range keys {
+		key = prefix + ":" + key
+		if err := redis.Set(ctx, key, "1", time.Minute); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+		t.Cleanup(func() { _ = redis.Delete(ctx, key) })
+	}
+	for _, key := range keys[:3] {
+		want = append(want, prefix+":"+key)
+	}
+
+	// A small count makes the scan take several round trips
+	got, err := redis.Scan(ctx, prefix+":session:*", 2)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if got, err := redis.Scan(ctx, prefix+":nothing:*", 10); err != nil || len(got) != 0 {
+		t.Errorf("expected no matches, got %v %v", got, err)
+	}
+}
+
+func TestHashFieldDeletion(t *testing.T) {
+	redis := testRedis(t)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:hash:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = redis.Delete(ctx, key) })
+
+	if err := redis.HSet(ctx, key, "name", "widget", "color", "blue"); err != nil {
+		t.Fatalf("failed to set hash: %v", err)
+	}
+	if exists, err := redis.HExists(ctx, key, "name"); err != nil || !exists {
+		t.Fatalf("expected name to exist before deletion, got %v %v", exists, err)
+	}
+
+	if err := redis.HDel(ctx, key, "name"); err != nil {
+		t.Fatalf("failed to delete field: %v", err)
+	}
+	if exists, err := redis.HExists(ctx, key, "name"); err != nil || exists {
+		t.Errorf("expected name to be gone after deletion, got %v %v", exists, err)
+	}
+	if _, err := redis.HGet(ctx, key, "name"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the deleted field, got %v", err)
+	}
+
+	// Deleting a missing field is not an error and leaves the other fields alone
+	if err := redis.HDel(ctx, key, "missing"); err != nil {
+		t.Errorf("expected deleting a missing field to succeed, got %v", err)
+	}
+	if exists, err := redis.HExists(ctx, key, "color"); err != nil || !exists {
+		t.Errorf("expected color to be kept, got %v %v", exists, err)
+	}
+	if exists, err := redis.HExists(ctx, key+":missing", "color"); err != nil || exists {
+		t.Errorf("expected no field in a missing hash, got %v %v", exists, err)
+	}
+}
+
+func TestIncrementAndDecrementBy(t *testing.T) {
+	redis := testRedis(t)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:counter:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _ = redis.Delete(ctx, key) })
+
+	steps := []struct {
+		name  string
+		op    func(ctx context.Context, key string, delta int64) (int64, error)
+		delta int64
+		want  int64
+	}{
+		{"increment a missing key", redis.IncrementBy, 5, 5},
+		{"increment", redis.IncrementBy, 10, 15},
+		{"negative increment", redis.IncrementBy, -20, -5},
+		{"decrement", redis.DecrementBy, 3, -8},
+		{"negative decrement", redis.DecrementBy, -10, 2},
+	}
+	for _, step := range steps {
+		got, err := step.op(ctx, key, step.delta)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: expected %d, got %d", step.name, step.want, got)
+		}
+	}
+
+	if value, err := redis.Get(ctx, key); err != nil || value != "2" {
+		t.Errorf("expected the stored value to be 2, got %q %v", value, err)
+	}
+
+	// Non-integer values are rejected rather than overwritten
+	if err := redis.Set(ctx, key, "not-a-number", time.Minute); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+	if _, err := redis.IncrementBy(ctx, key, 1); err == nil {
+		t.Error("expected an error incrementing a non-integer value")
+	}
+}
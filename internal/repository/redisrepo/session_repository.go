@@ -40,8 +40,15 @@ type SessionRepository interface {
 
 	// Delete all sessions for a user
 	DeleteByUserID(ctx context.Context, userID string) error
+
+	// Delete expired sessions and drop references to them from users' session lists.
+	// Nothing calls it on a schedule; code that creates sessions should run it periodically.
+	DeleteExpired(ctx context.Context) (int, error)
 }
 
+// sessionScanCount is how many keys DeleteExpired asks Redis for per SCAN call
+const sessionScanCount = 100
+
 // sessionRepository implements the SessionRepository interface
 type sessionRepository struct {
 	redis Repository
@@ -176,7 +183,7 @@ func (s *sessionRepository) Delete(ctx context.Context, sessionID string) error
 
 	// Remove the session from the user's session list
 	userSessionsKey := fmt.Sprintf("user:%s:sessions", session.UserID)
-	if err := s.redis.SAdd(ctx, userSessionsKey, sessionID); err != nil {
+	if err := s.redis.SRem(ctx, userSessionsKey, sessionID); err != nil {
 		// Log the error but continue with deletion
 		fmt.Printf("Failed to remove session from user's list: %v\n", err)
 	}
@@ -240,3 +247,64 @@ func (s *sessionRepository) DeleteByUserID(ctx context.Context, userID string) e
 	// Delete the user's session list
 	return s.redis.Delete(ctx, userSessionsKey)
 }
+
+// DeleteExpired deletes sessions past their expiry and removes IDs of sessions that no longer exist,
+// such as those Redis expired, from users' session lists. Only the dead IDs are removed, so a session
+// added to a list during the sweep is kept; Redis deletes a list once its last ID is removed.
+// It returns how many sessions and list entries were removed.
+func (s *sessionRepository) DeleteExpired(ctx context.Context) (int, error) {
+	removed := 0
+
+	sessionKeys, err := s.redis.Scan(ctx, "session:*", sessionScanCount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan sessions: %w", err)
+	}
+	for _, sessionKey := range sessionKeys {
+		data, err := s.redis.Get(ctx, sessionKey)
+		if err != nil {
+			// Expired or deleted since the scan
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil || !time.Now().After(session.ExpiresAt) {
+			continue
+		}
+		if err := s.redis.Delete(ctx, sessionKey); err != nil {
+			return removed, fmt.Errorf("failed to delete session %s: %w", session.ID, err)
+		}
+		removed++
+	}
+
+	// Deleted sessions, and those Redis expired on its own, leave their IDs in the user's list
+	userKeys, err := s.redis.Scan(ctx, "user:*:sessions", sessionScanCount)
+	if err != nil {
+		return removed, fmt.Errorf("failed to scan user sessions: %w", err)
+	}
+	for _, userSessionsKey := range userKeys {
+		sessionIDs, err := s.redis.SMembers(ctx, userSessionsKey)
+		if err != nil {
+			return removed, fmt.Errorf("failed to get user sessions: %w", err)
+		}
+
+		var dead []interface{}
+		for _, sessionID := range sessionIDs {
+			exists, err := s.redis.Exists(ctx, fmt.Sprintf("session:%s", sessionID))
+			if err != nil {
+				return removed, fmt.Errorf("failed to check session %s: %w", sessionID, err)
+			}
+			if !exists {
+				dead = append(dead, sessionID)
+			}
+		}
+		if len(dead) == 0 {
+			continue
+		}
+
+		if err := s.redis.SRem(ctx, userSessionsKey, dead...); err != nil {
+			return removed, fmt.Errorf("failed to remove expired sessions from user's list: %w", err)
+		}
+		removed += len(dead)
+	}
+
+	return removed, nil
+}
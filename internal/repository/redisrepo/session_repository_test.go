@@ -0,0 +1,245 @@
+package redisrepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRedis is an in-memory Repository covering the key and set operations used by sessions
+// and the cache; expirations are ignored and unimplemented methods panic
+type memoryRedis struct {
+	Repository
+	mu     sync.Mutex
+	values map[string]string
+	sets   map[string]map[string]struct{}
+}
+
+func newMemoryRedis() *memoryRedis {
+	return &memoryRedis{values: make(map[string]string), sets: make(map[string]map[string]struct{})}
+}
+
+func (m *memoryRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch v := value.(type) {
+	case []byte:
+		m.values[key] = string(v)
+	default:
+		m.values[key] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+func (m *memoryRedis) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[key]
+	if !ok {
+		return "", fmt.Errorf("key %s %w", key, ErrNotFound)
+	}
+	return v, nil
+}
+
+func (m *memoryRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; ok {
+		return false, nil
+	}
+	m.values[key] = fmt.Sprint(value)
+	return true, nil
+}
+
+func (m *memoryRedis) DeleteIfEquals(ctx context.Context, key, value string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.values[key]; !ok || v != value {
+		return false, nil
+	}
+	delete(m.values, key)
+	return true, nil
+}
+
+func (m *memoryRedis) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.values, key)
+		delete(m.sets, key)
+	}
+	return nil
+}
+
+func (m *memoryRedis) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, isValue := m.values[key]
+	_, isSet := m.sets[key]
+	return isValue || isSet, nil
+}
+
+// Scan matches keys with path.Match, which agrees with Redis globs for the patterns tests use
+func (m *memoryRedis) Scan(ctx context.Context, match string, count int64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.values {
+		if ok, _ := path.Match(match, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	for key := range m.sets {
+		if ok, _ := path.Match(match, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *memoryRedis) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sets[key] == nil {
+		m.sets[key] = make(map[string]struct{})
+	}
+	for _, member := range members {
+		m.sets[key][fmt.Sprint(member)] = struct{}{}
+	}
+	return nil
+}
+
+func (m *memoryRedis) SRem(ctx context.Context, key string, members ...interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, member := range members {
+		delete(m.sets[key], fmt.Sprint(member))
+	}
+	// Like Redis, a set is deleted with its last member
+	if len(m.sets[key]) == 0 {
+		delete(m.sets, key)
+	}
+	return nil
+}
+
+func (m *memoryRedis) SMembers(ctx context.Context, key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := make([]string, 0, len(m.sets[key]))
+	for member := range m.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func TestSessionDeleteRemovesSessionFromUserSet(t *testing.T) {
+	redis := newMemoryRedis()
+	repo := NewSessionRepository(redis)
+	ctx := context.Background()
+
+	first, err := repo.Create(ctx, "user-1", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	second, err := repo.Create(ctx, "user-1", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	if err := repo.Delete(ctx, first.ID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+
+	sessions, err := repo.GetByUserID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("failed to get sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != second.ID {
+		t.Errorf("expected only session %s, got %v", second.ID, sessions)
+	}
+
+	ids, _ := redis.SMembers(ctx, "user:user-1:sessions")
+	if len(ids) != 1 || ids[0] != second.ID {
+		t.Errorf("expected the deleted session to be removed from the user's set, got %v", ids)
+	}
+}
+
+func TestSessionDeleteExpired(t *testing.T) {
+	redis := newMemoryRedis()
+	repo := NewSessionRepository(redis)
+	ctx := context.Background()
+
+	live, _ := repo.Create(ctx, "user-1", time.Hour, nil)
+	stale, _ := repo.Create(ctx, "user-1", time.Hour, nil)
+	evicted, _ := repo.Create(ctx, "user-2", time.Hour, nil)
+
+	// One session is past its expiry but still stored, another was already expired by Redis
+	stale.ExpiresAt = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(stale)
+	_ = redis.Set(ctx, "session:"+stale.ID, data, time.Hour)
+	_ = redis.Delete(ctx, "session:"+evicted.ID)
+
+	removed, err := repo.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatalf("failed to delete expired sessions: %v", err)
+	}
+	// The stale session, its list entry, and the evicted session's list entry
+	if removed != 3 {
+		t.Errorf("expected 3 removals, got %d", removed)
+	}
+
+	if exists, _ := redis.Exists(ctx, "session:"+stale.ID); exists {
+		t.Error("expected the expired session to be deleted")
+	}
+	if _, err := repo.Get(ctx, live.ID); err != nil {
+		t.Errorf("expected the live session to be kept, got %v", err)
+	}
+	if ids, _ := redis.SMembers(ctx, "user:user-1:sessions"); len(ids) != 1 || ids[0] != live.ID {
+		t.Errorf("expected only the live session in user-1's list, got %v", ids)
+	}
+	if exists, _ := redis.Exists(ctx, "user:user-2:sessions"); exists {
+		t.Error("expected user-2's empty session list to be deleted")
+	}
+}
+
+// sweepRaceRedis runs onSMembers after each SMembers call, letting a test act between
+// DeleteExpired reading a user's session list and updating it
+type sweepRaceRedis struct {
+	*memoryRedis
+	onSMembers func()
+}
+
+func (r *sweepRaceRedis) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := r.memoryRedis.SMembers(ctx, key)
+	if r.onSMembers != nil {
+		r.onSMembers()
+		r.onSMembers = nil
+	}
+	return members, err
+}
+
+func TestSessionDeleteExpiredKeepsSessionCreatedDuringSweep(t *testing.T) {
+	redis := &sweepRaceRedis{memoryRedis: newMemoryRedis()}
+	repo := NewSessionRepository(redis)
+	ctx := context.Background()
+
+	evicted, _ := repo.Create(ctx, "user-1", time.Hour, nil)
+	_ = redis.Delete(ctx, "session:"+evicted.ID)
+
+	// The user logs in again after the sweep read their list, which held only the evicted session
+	var created *Session
+	redis.onSMembers = func() {
+		created, _ = repo.Create(ctx, "user-1", time.Hour, nil)
+	}
+
+	if _, err := repo.DeleteExpired(ctx); err != nil {
+		t.Fatalf("failed to delete expired sessions: %v", err)
+	}
+	if ids, _ := redis.SMembers(ctx, "user:user-1:sessions"); len(ids) != 1 || ids[0] != created.ID {
+		t.Errorf("expected the new session to stay in the user's list, got %v", ids)
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTextSearchFilter(t *testing.T) {
+	filter := bson.M{"category": "books"}
+	query := textSearchFilter("go programming", filter)
+
+	if query["category"] != "books" {
+		t.Errorf("expected category filter to be kept, got %v", query["category"])
+	}
+	text, ok := query["$text"].(bson.M)
+	if !ok || text["$search"] != "go programming" {
+		t.Errorf("expected $text search, got %v", query["$text"])
+	}
+	if _, ok := filter["$text"]; ok {
+		t.Error("expected the caller's filter not to be modified")
+	}
+}
+
+func TestTextSearchOptionsPaginatesByScore(t *testing.T) {
+	tests := []struct {
+		page, itemsPerPage int64
+		wantSkip           int64
+	}{
+		{1, 10, 0},
+		{2, 10, 10},
+		{3, 25, 50},
+	}
+
+	for _, tt := range tests {
+		opts := textSearchOptions(tt.page, tt.itemsPerPage)
+
+		if opts.Skip == nil || *opts.Skip != tt.wantSkip {
+			t.Errorf("page %d: expected skip %d, got %v", tt.page, tt.wantSkip, opts.Skip)
+		}
+		if opts.Limit == nil || *opts.Limit != tt.itemsPerPage {
+			t.Errorf("page %d: expected limit %d, got %v", tt.page, tt.itemsPerPage, opts.Limit)
+		}
+
+		sort, ok := opts.Sort.(bson.D)
+		if !ok || len(sort) != 2 {
+			t.Fatalf("page %d: expected score and _id sort, got %v", tt.page, opts.Sort)
+		}
+		if sort[0].Key != "score" || sort[1].Key != "_id" {
+			t.Errorf("page %d: expected sort by score then _id, got %v", tt.page, sort)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/pkg/web/mwutil"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestMongoSessionReadsOwnWriteAfterUpdate(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+
+	user := &model.User{Name: "Before", Email: "session@example.com", Password: "hashed", ApiKey: "key-1"}
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	// Reads go to a secondary when there is one, where only the session guarantees the update is visible
+	secondaries := db.Collection("users", options.Collection().SetReadPreference(readpref.SecondaryPreferred()))
+
+	e := echo.New()
+	e.Use(mwutil.MongoSession(db.Client()))
+	e.PUT("/users/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if mongo.SessionFromContext(ctx) == nil {
+			t.Error("expected the request context to carry a session")
+		}
+
+		if err := repo.UpdateFields(ctx, c.Param("id"), bson.M{"name": "After"}); err != nil {
+			return err
+		}
+		var got model.User
+		if err := secondaries.FindOne(ctx, bson.M{"_id": user.ID}).Decode(&got); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, got.Name)
+	})
+
+	for i := 0; i < 5; i++ {
+		if err := repo.UpdateFields(context.Background(), user.ID.Hex(), bson.M{"name": "Before"}); err != nil {
+			t.Fatalf("failed to reset user: %v", err)
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/"+user.ID.Hex(), nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "After" {
+			t.Fatalf("request %d: expected the handler to read its own update, got %d %q", i+1, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// includeDeletedKey marks a context whose reads also return soft-deleted documents
+type includeDeletedKey struct{}
+
+// IncludeDeleted returns a context in which FindByID, FindAll, FindMany, FindEach, the paginated
+// finds, the user lookups and the product category and owner lookups also return soft-deleted
+// documents. Stock changes and reservations never apply to soft-deleted products.
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+// includesDeleted reports whether reads in ctx should return soft-deleted documents
+func includesDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
+
+// scopeDeleted restricts filter to documents that have not been soft-deleted,
+// unless ctx opts in to including them
+func scopeDeleted(ctx context.Context, filter interface{}) interface{} {
+	if includesDeleted(ctx) {
+		if filter == nil {
+			return bson.M{}
+		}
+		return filter
+	}
+
+	switch f := filter.(type) {
+	case nil:
+		return bson.M{"deleted_at": nil}
+	case bson.M:
+		if _, ok := f["deleted_at"]; !ok {
+			scoped := bson.M{"deleted_at": nil}
+			for k, v := range f {
+				scoped[k] = v
+			}
+			return scoped
+		}
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{"deleted_at": nil}}}
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScopeDeleted(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		filter interface{}
+		want   interface{}
+	}{
+		{"nil filter", ctx, nil, bson.M{"deleted_at": nil}},
+		{"map filter", ctx, bson.M{"category": "books"}, bson.M{"category": "books", "deleted_at": nil}},
+		{"explicit deleted_at", ctx, bson.M{"deleted_at": bson.M{"$ne": nil}}, bson.M{"$and": bson.A{bson.M{"deleted_at": bson.M{"$ne": nil}}, bson.M{"deleted_at": nil}}}},
+		{"other filter type", ctx, bson.D{{Key: "category", Value: "books"}}, bson.M{"$and": bson.A{bson.D{{Key: "category", Value: "books"}}, bson.M{"deleted_at": nil}}}},
+		{"include deleted", IncludeDeleted(ctx), bson.M{"category": "books"}, bson.M{"category": "books"}},
+		{"include deleted nil filter", IncludeDeleted(ctx), nil, bson.M{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scopeDeleted(tt.ctx, tt.filter); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSoftDeleteHidesAndRestores(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewProductRepository(db)
+	ctx := context.Background()
+
+	product := &model.Product{Name: "Soft deleted", Description: "A test product", Price: 10, Stock: 1, Category: "books"}
+	if err := repo.Create(ctx, product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	id := product.ID.Hex()
+
+	if err := repo.SoftDelete(ctx, id); err != nil {
+		t.Fatalf("failed to soft delete: %v", err)
+	}
+	if err := repo.SoftDelete(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound when soft deleting twice, got %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, id); err == nil {
+		t.Error("expected FindByID to hide the soft-deleted product")
+	}
+	if all, err := repo.FindAll(ctx); err != nil || len(all) != 0 {
+		t.Errorf("expected FindAll to hide the soft-deleted product, got %d (%v)", len(all), err)
+	}
+	if page, total, err := repo.FindPaginated(ctx, nil, Sort{}, 1, 10); err != nil || len(page) != 0 || total != 0 {
+		t.Errorf("expected FindPaginated to hide the soft-deleted product, got %d of %d (%v)", len(page), total, err)
+	}
+
+	found, err := repo.FindByID(IncludeDeleted(ctx), id)
+	if err != nil {
+		t.Fatalf("expected IncludeDeleted to return the product, got %v", err)
+	}
+	if !found.IsDeleted() {
+		t.Error("expected DeletedAt to be set")
+	}
+	if _, total, _ := repo.FindPaginated(IncludeDeleted(ctx), nil, Sort{}, 1, 10); total != 1 {
+		t.Errorf("expected IncludeDeleted pagination to count the product, got %d", total)
+	}
+
+	if err := repo.Restore(ctx, id); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if err := repo.Restore(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound when restoring a live product, got %v", err)
+	}
+
+	restored, err := repo.FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("expected restored product to be visible, got %v", err)
+	}
+	if restored.IsDeleted() {
+		t.Error("expected DeletedAt to be cleared")
+	}
+}
+
+func TestSoftDeletedUserLookups(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{Name: "Gone", Email: "gone@example.com", Username: "gone", Password: "hashed", ApiKey: HashApiKey("gone-key")}
+	legacy := &model.User{Name: "Legacy", Email: "legacy@example.com", Password: "hashed", ApiKey: "legacy-plaintext-key"}
+	for _, u := range []*model.User{user, legacy} {
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := repo.SoftDelete(ctx, u.ID.Hex()); err != nil {
+			t.Fatalf("failed to soft delete: %v", err)
+		}
+	}
+
+	if _, err := repo.FindByEmail(ctx, "gone@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected FindByEmail to hide the soft-deleted user, got %v", err)
+	}
+	if _, err := repo.FindByUsername(ctx, "gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected FindByUsername to hide the soft-deleted user, got %v", err)
+	}
+	if _, err := repo.FindByApiKey(ctx, "gone-key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the soft-deleted user's API key to be rejected, got %v", err)
+	}
+	if _, err := repo.FindByApiKey(ctx, "legacy-plaintext-key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the soft-deleted user's legacy API key to be rejected, got %v", err)
+	}
+	if users, err := repo.FindMany(ctx, bson.M{}, nil); err != nil || len(users) != 0 {
+		t.Errorf("expected FindMany to hide soft-deleted users, got %d (%v)", len(users), err)
+	}
+
+	if found, err := repo.FindByEmail(IncludeDeleted(ctx), "gone@example.com"); err != nil || found.ID != user.ID {
+		t.Errorf("expected IncludeDeleted to find the user, got %v, %v", found, err)
+	}
+}
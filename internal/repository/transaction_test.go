@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWithTransactionRollsBackPartialInsert(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	// Create the collection up front; older servers cannot create it inside a transaction
+	if err := db.CreateCollection(ctx, "users"); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	first := &model.User{Name: "First", Email: "first@example.com", Password: "hashed", ApiKey: "key-1"}
+	second := &model.User{Name: "Second", Email: "second@example.com", Password: "hashed", ApiKey: "key-2"}
+	third := &model.User{Name: "Third", Email: "third@example.com", Password: "hashed", ApiKey: "key-3"}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := repo.Delete(ctx, first.ID.Hex()); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	// Reusing the ID makes the insert of third fail after second has been written
+	third.ID = first.ID
+
+	err := repo.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if err := repo.Create(sessCtx, first); err != nil {
+			return err
+		}
+		return repo.InsertMany(sessCtx, []*model.User{second, third})
+	})
+	if err != nil && strings.Contains(err.Error(), "replica set") {
+		t.Skip("MongoDB test server does not support transactions")
+	}
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("expected duplicate key error, got %v", err)
+	}
+
+	count, err := repo.GetCollection().CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("failed to count users: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected the failed transaction to persist no users, got %d", count)
+	}
+}
@@ -2,12 +2,19 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"regexp"
+	"sort"
 	"time"
 
 	"go-echo-mongo/internal/model"
+	"go-echo-mongo/pkg/secutil"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -16,7 +23,31 @@ import (
 type UserRepository interface {
 	BaseRepository[*model.User]
 	FindByEmail(context.Context, string) (*model.User, error)
+	FindByUsername(context.Context, string) (*model.User, error)
 	FindByApiKey(context.Context, string) (*model.User, error)
+	FindOrCreate(context.Context, *model.User) (bool, *model.User, error)
+	UpsertUsers(context.Context, []*model.User, []string) (*UpsertResult, error)
+	HashLegacyApiKeys(context.Context) (int64, error)
+	ReplacePasswordHash(ctx context.Context, id, oldHash, newHash string) (replaced bool, err error)
+}
+
+// emailCollation compares emails case-insensitively; queries must use it to be served by the email_case_insensitive index
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// usernameCollation compares usernames case-insensitively; queries must use it to be served by the username_case_insensitive index
+var usernameCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// apiKeyHashPattern matches the hex-encoded SHA-256 digests API keys are stored as
+var apiKeyHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// HashApiKey returns the value an API key is stored and looked up as
+func HashApiKey(apiKey string) string {
+	return secutil.HashAPIKey(apiKey)
+}
+
+// IsHashedApiKey reports whether a stored API key is already hashed rather than a legacy plaintext key
+func IsHashedApiKey(stored string) bool {
+	return apiKeyHashPattern.MatchString(stored)
 }
 
 // userRepository implements UserRepository interface
@@ -37,6 +68,17 @@ func createUserIndexes(collection *mongo.Collection) {
 				Background: &[]bool{true}[0],
 			},
 		},
+		{
+			// Usernames are optional, so only users that have one are indexed
+			Keys: bson.D{{Key: "username", Value: 1}},
+			Options: &options.IndexOptions{
+				Name:                    &[]string{"username_case_insensitive"}[0],
+				Unique:                  &[]bool{true}[0],
+				Background:              &[]bool{true}[0],
+				Collation:               usernameCollation,
+				PartialFilterExpression: bson.M{"username": bson.M{"$type": "string"}},
+			},
+		},
 		{
 			Keys: bson.D{{Key: "api_key", Value: 1}},
 			Options: &options.IndexOptions{
@@ -56,11 +98,74 @@ func createUserIndexes(collection *mongo.Collection) {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	createEmailCaseIndex(ctx, collection)
+}
+
+// createEmailCaseIndex creates the unique index that rejects case variants of an email. Emails are
+// stored lowercased, but users stored before they were normalized may already clash; the index then
+// can't be built, so the clashing emails are logged for an operator to merge instead of failing startup.
+func createEmailCaseIndex(ctx context.Context, collection *mongo.Collection) {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}},
+		Options: &options.IndexOptions{
+			Name:       &[]string{"email_case_insensitive"}[0],
+			Unique:     &[]bool{true}[0],
+			Background: &[]bool{true}[0],
+			Collation:  emailCollation,
+		},
+	})
+	if err == nil {
+		return
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		log.Fatal(err)
+	}
+
+	conflicts, findErr := findEmailCaseConflicts(ctx, collection)
+	if findErr != nil {
+		slog.Error("Case-insensitive email index not created: emails differ only by case", "error", err, "lookup_error", findErr)
+		return
+	}
+	for _, emails := range conflicts {
+		slog.Error("Case-insensitive email index not created: emails differ only by case", "emails", emails)
+	}
+}
+
+// findEmailCaseConflicts returns the stored emails that differ only by case, grouped by their lowercased form
+func findEmailCaseConflicts(ctx context.Context, collection *mongo.Collection) ([][]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"$toLower": "$email"},
+			"emails": bson.M{"$addToSet": "$email"},
+			"count":  bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		Emails []string `bson:"emails"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	conflicts := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		sort.Strings(group.Emails)
+		conflicts = append(conflicts, group.Emails)
+	}
+	return conflicts, nil
 }
 
 // NewUserRepository creates a new UserRepository instance
 func NewUserRepository(db *mongo.Database) UserRepository {
-	collection := db.Collection("users")
+	collection := collectionFor[*model.User](db)
 
 	// Create indexes for the user collection if they don't exist
 	createUserIndexes(collection)
@@ -70,25 +175,231 @@ func NewUserRepository(db *mongo.Database) UserRepository {
 	}
 }
 
-// FindByEmail retrieves a user by their email
+// FindByEmail retrieves a user by their email, ignoring case. Soft-deleted users are not found.
 func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
-	var user *model.User
-	err := r.GetCollection().FindOne(ctx, bson.M{"email": email}).Decode(user)
+	user := &model.User{}
+	opts := options.FindOne().SetCollation(emailCollation)
+	err := r.GetCollection().FindOne(ctx, scopeDeleted(ctx, bson.M{"email": model.NormalizeEmail(email)}), opts).Decode(user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindOrCreate atomically inserts user unless one with the same email (ignoring case) already exists,
+// and returns whether it was inserted along with the stored user. Concurrent calls for the same email
+// create exactly one document; the others get the existing user back.
+func (r *userRepository) FindOrCreate(ctx context.Context, user *model.User) (bool, *model.User, error) {
+	user.Email = model.NormalizeEmail(user.Email)
+	user.ID = primitive.NewObjectID()
+	now := time.Now().UTC()
+	user.SetCreatedAt(now)
+	user.SetUpdatedAt(now)
+	fields, err := toBsonM(user)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to encode user: %w", err)
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After).
+		SetCollation(emailCollation)
+	for attempt := 1; ; attempt++ {
+		result := &model.User{}
+		err = r.GetCollection().FindOneAndUpdate(ctx, bson.M{"email": user.Email}, bson.M{"$setOnInsert": fields}, opts).Decode(result)
+		// Two upserts racing on the unique email index can both miss; the loser's retry finds the winner's document
+		if mongo.IsDuplicateKeyError(err) && attempt == 1 {
+			continue
+		}
+		if err != nil {
+			return false, nil, err
+		}
+		return result.ID == user.ID, result, nil
+	}
+}
+
+// UpsertUsers writes users idempotently, matching each on the values of its keyFields like UpsertMany.
+// Unlike UpsertMany, a matched user only gets the fields the model supplies: name, email and, when set,
+// username and password. The API key, roles and scopes are written on insert only, so an upsert never
+// replaces credentials or permissions of an existing user. Email and username keys match ignoring case.
+// IDs are set on inserted users only.
+func (r *userRepository) UpsertUsers(ctx context.Context, users []*model.User, keyFields []string) (*UpsertResult, error) {
+	if len(keyFields) == 0 {
+		return nil, errors.New("at least one key field is required")
+	}
+	if len(users) == 0 {
+		return &UpsertResult{}, nil
+	}
+
+	now := time.Now().UTC()
+	writeModels := make([]mongo.WriteModel, len(users))
+	for i, user := range users {
+		user.SetUpdatedAt(now)
+		// Only these fields can serve as keys; the password hash differs on every upsert
+		keys := bson.M{"name": user.Name, "email": user.Email}
+		if user.Username != "" {
+			keys["username"] = user.Username
+		}
+		set := bson.M{"updated_at": now}
+		for k, v := range keys {
+			set[k] = v
+		}
+		if user.Password != "" {
+			set["password"] = user.Password
+		}
+		onInsert := bson.M{"api_key": user.ApiKey, "roles": user.Roles, "created_at": now}
+		if len(user.Scopes) > 0 {
+			onInsert["scopes"] = user.Scopes
+		}
+
+		filter := bson.M{}
+		caseInsensitive := false
+		for _, key := range keyFields {
+			value, ok := keys[key]
+			if !ok {
+				return nil, fmt.Errorf("user at index %d has no value for key field %q", i, key)
+			}
+			filter[key] = value
+			caseInsensitive = caseInsensitive || key == "email" || key == "username"
+		}
+
+		update := mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": set, "$setOnInsert": onInsert}).
+			SetUpsert(true)
+		if caseInsensitive {
+			update.SetCollation(emailCollation)
+		}
+		writeModels[i] = update
+	}
+
+	result, err := r.GetCollection().BulkWrite(ctx, writeModels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert users: %w", err)
+	}
+
+	for i, upsertedID := range result.UpsertedIDs {
+		if id, ok := upsertedID.(primitive.ObjectID); ok {
+			users[i].SetID(id)
+			users[i].SetCreatedAt(now)
+		}
+	}
+	return &UpsertResult{Inserted: result.UpsertedCount, Updated: result.MatchedCount}, nil
+}
+
+// FindByUsername retrieves a user by their username, ignoring case. Soft-deleted users are not found.
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	user := &model.User{}
+	opts := options.FindOne().SetCollation(usernameCollation)
+	err := r.GetCollection().FindOne(ctx, scopeDeleted(ctx, bson.M{"username": username}), opts).Decode(user)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return user, nil
 }
 
-// FindByApiKey retrieves a user by their API key
+// FindByApiKey retrieves a user by their raw API key, which is hashed before the lookup.
+// Users still holding a legacy plaintext key are found as well, and their key is hashed in place.
+// Soft-deleted users are not found, so their keys stop authenticating.
+//
+// The api_key index is queried with the digest, so any timing the index traversal leaks is about
+// digest prefixes, which cannot be steered toward a valid key without a SHA-256 preimage. Giving up
+// the index for a scan with constant-time comparisons would cost far more than it protects; the
+// matched document is still re-checked in constant time. Legacy keys are queried in plaintext and
+// do leak prefix timing, which is why HashLegacyApiKeys runs at startup.
 func (r *userRepository) FindByApiKey(ctx context.Context, apiKey string) (*model.User, error) {
+	hashed := HashApiKey(apiKey)
 	user := &model.User{}
-	err := r.GetCollection().FindOne(ctx, bson.M{"api_key": apiKey}).Decode(user)
+	err := r.GetCollection().FindOne(ctx, scopeDeleted(ctx, bson.M{"api_key": hashed})).Decode(user)
+	if err == nil {
+		if !secutil.VerifyAPIKey(apiKey, user.ApiKey) {
+			return nil, ErrNotFound
+		}
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	// A hash-shaped input is never tried as plaintext, so a leaked stored hash cannot be used as a key
+	if IsHashedApiKey(apiKey) {
+		return nil, ErrNotFound
+	}
+
+	err = r.GetCollection().FindOne(ctx, scopeDeleted(ctx, bson.M{"api_key": apiKey})).Decode(user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+	if !secutil.CompareHashes(user.ApiKey, apiKey) {
+		return nil, ErrNotFound
+	}
+
+	_, err = r.GetCollection().UpdateOne(ctx,
+		bson.M{"_id": user.ID, "api_key": apiKey},
+		bson.M{"$set": bson.M{"api_key": hashed}},
+	)
+	if err != nil {
+		slog.Warn("Failed to hash legacy API key", "user_id", user.ID.Hex(), "error", err)
+	} else {
+		user.ApiKey = hashed
+	}
 	return user, nil
 }
+
+// HashLegacyApiKeys hashes every API key still stored in plaintext and returns how many were updated
+func (r *userRepository) HashLegacyApiKeys(ctx context.Context) (int64, error) {
+	filter := bson.M{"api_key": bson.M{"$exists": true, "$ne": "", "$not": primitive.Regex{Pattern: apiKeyHashPattern.String()}}}
+	cursor, err := r.GetCollection().Find(ctx, filter, options.Find().SetProjection(bson.M{"api_key": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			ApiKey string             `bson:"api_key"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return updated, err
+		}
+
+		// Match on the old value so a key regenerated meanwhile is left alone
+		result, err := r.GetCollection().UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "api_key": doc.ApiKey},
+			bson.M{"$set": bson.M{"api_key": HashApiKey(doc.ApiKey)}},
+		)
+		if err != nil {
+			return updated, err
+		}
+		updated += result.ModifiedCount
+	}
+	return updated, cursor.Err()
+}
+
+// ReplacePasswordHash swaps a user's password hash for newHash only while it is still oldHash, so a
+// password changed in the meantime is never overwritten. It reports whether the hash was replaced.
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id, oldHash, newHash string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid ID format: %w", err)
+	}
+
+	result, err := r.GetCollection().UpdateOne(ctx,
+		bson.M{"_id": objectID, "password": oldHash},
+		bson.M{"$set": bson.M{"password": newHash, "updated_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace password hash: %w", err)
+	}
+	return result.MatchedCount > 0, nil
+}
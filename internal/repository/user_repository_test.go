@@ -0,0 +1,374 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase connects to the Mongo instance at MONGODB_TEST_URI and returns a throwaway database.
+// Tests are skipped when no test instance is configured.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set; skipping MongoDB integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("failed to ping MongoDB: %v", err)
+	}
+
+	db := client.Database(fmt.Sprintf("test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return db
+}
+
+func TestUserRepositoryFindByEmail(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{
+		Name:     "Jane Doe",
+		Email:    "jane@example.com",
+		Password: "hashed",
+		ApiKey:   "test-api-key",
+		Roles:    []string{model.RoleUser},
+	}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	found, err := repo.FindByEmail(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatalf("expected user to be found, got error: %v", err)
+	}
+	if found.ID != user.ID || found.Name != user.Name || found.Email != user.Email {
+		t.Errorf("expected %+v, got %+v", user, found)
+	}
+
+	missing, err := repo.FindByEmail(ctx, "nobody@example.com")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if missing != nil {
+		t.Errorf("expected nil user, got %+v", missing)
+	}
+}
+
+func TestUserRepositoryEmailIgnoresCase(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{Name: "John Doe", Email: "john@example.com", Password: "hashed", ApiKey: HashApiKey("john-key")}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	found, err := repo.FindByEmail(ctx, "John@Example.COM")
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("expected a differently-cased email to find the user, got %v, %v", found, err)
+	}
+
+	// The case-insensitive unique index rejects variants even if they reach the database unnormalized
+	variant := &model.User{Name: "Other", Email: "JOHN@example.com", Password: "hashed", ApiKey: HashApiKey("other-key")}
+	if err := repo.Create(ctx, variant); !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("expected a duplicate key error for a differently-cased email, got %v", err)
+	}
+}
+
+func TestUserRepositoryReportsEmailCaseConflicts(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+
+	// Users stored before emails were normalized can differ only by case
+	collection := collectionFor[*model.User](db)
+	_, err := collection.InsertMany(ctx, []any{
+		bson.M{"name": "John", "email": "John@Example.com", "api_key": HashApiKey("john-key")},
+		bson.M{"name": "Other", "email": "john@example.com", "api_key": HashApiKey("other-key")},
+		bson.M{"name": "Jane", "email": "jane@example.com", "api_key": HashApiKey("jane-key")},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert users: %v", err)
+	}
+
+	// Startup carries on without the case-insensitive index
+	repo := NewUserRepository(db)
+	if _, err := repo.FindByEmail(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("expected the repository to work, got %v", err)
+	}
+
+	conflicts, err := findEmailCaseConflicts(ctx, collection)
+	if err != nil {
+		t.Fatalf("failed to find conflicts: %v", err)
+	}
+	if len(conflicts) != 1 || len(conflicts[0]) != 2 || conflicts[0][0] != "John@Example.com" || conflicts[0][1] != "john@example.com" {
+		t.Errorf("expected the two John emails to conflict, got %v", conflicts)
+	}
+}
+
+func TestUserRepositoryUsernameIgnoresCase(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{Name: "John Doe", Email: "john@example.com", Username: "john_doe", Password: "hashed", ApiKey: HashApiKey("john-key")}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	// Users without a username are left out of the unique index
+	for i, email := range []string{"a@example.com", "b@example.com"} {
+		u := &model.User{Name: "No username", Email: email, Password: "hashed", ApiKey: HashApiKey(email)}
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatalf("user %d: expected users without a username not to conflict, got %v", i+1, err)
+		}
+	}
+
+	found, err := repo.FindByUsername(ctx, "John_Doe")
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("expected a differently-cased username to find the user, got %v, %v", found, err)
+	}
+	if _, err := repo.FindByUsername(ctx, "nobody"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	variant := &model.User{Name: "Other", Email: "other@example.com", Username: "JOHN_DOE", Password: "hashed", ApiKey: HashApiKey("other-key")}
+	if err := repo.Create(ctx, variant); !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("expected a duplicate key error for a differently-cased username, got %v", err)
+	}
+}
+
+func TestIsHashedApiKey(t *testing.T) {
+	if !IsHashedApiKey(HashApiKey("raw-key")) {
+		t.Error("expected a hashed key to be recognised")
+	}
+	for _, key := range []string{"", "raw-key", strings.Repeat("a", 32), strings.ToUpper(HashApiKey("raw-key"))} {
+		if IsHashedApiKey(key) {
+			t.Errorf("expected %q not to be recognised as a hashed key", key)
+		}
+	}
+}
+
+func TestUserRepositoryFindByApiKey(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{Name: "Jane Doe", Email: "jane@example.com", Password: "hashed", ApiKey: HashApiKey("raw-key")}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	found, err := repo.FindByApiKey(ctx, "raw-key")
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("expected the raw key to find the user, got %v, %v", found, err)
+	}
+	if _, err := repo.FindByApiKey(ctx, user.ApiKey); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the stored hash not to work as a key, got %v", err)
+	}
+}
+
+func TestUserRepositoryFindByApiKeyHashesLegacyKey(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	legacy := &model.User{Name: "Jane Doe", Email: "jane@example.com", Password: "hashed", ApiKey: "legacy-key"}
+	if err := repo.Create(ctx, legacy); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	found, err := repo.FindByApiKey(ctx, "legacy-key")
+	if err != nil || found.ID != legacy.ID {
+		t.Fatalf("expected the legacy key to find the user, got %v, %v", found, err)
+	}
+
+	stored, err := repo.FindByID(ctx, legacy.ID.Hex())
+	if err != nil {
+		t.Fatalf("failed to find user: %v", err)
+	}
+	if stored.ApiKey != HashApiKey("legacy-key") {
+		t.Errorf("expected the legacy key to be hashed after use, got %q", stored.ApiKey)
+	}
+	if _, err := repo.FindByApiKey(ctx, "legacy-key"); err != nil {
+		t.Errorf("expected the key to keep working once hashed, got %v", err)
+	}
+}
+
+func TestUserRepositoryHashLegacyApiKeys(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	users := []*model.User{
+		{Name: "Legacy One", Email: "one@example.com", Password: "hashed", ApiKey: "legacy-key-1"},
+		{Name: "Legacy Two", Email: "two@example.com", Password: "hashed", ApiKey: "legacy-key-2"},
+		{Name: "Hashed", Email: "hashed@example.com", Password: "hashed", ApiKey: HashApiKey("new-key")},
+	}
+	for _, u := range users {
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+
+	migrated, err := repo.HashLegacyApiKeys(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if migrated != 2 {
+		t.Errorf("expected 2 legacy keys to be hashed, got %d", migrated)
+	}
+
+	for _, raw := range []string{"legacy-key-1", "legacy-key-2", "new-key"} {
+		found, err := repo.FindByApiKey(ctx, raw)
+		if err != nil {
+			t.Errorf("expected %q to authenticate, got %v", raw, err)
+			continue
+		}
+		if found.ApiKey != HashApiKey(raw) {
+			t.Errorf("expected %q to be stored hashed, got %q", raw, found.ApiKey)
+		}
+	}
+
+	if migrated, err := repo.HashLegacyApiKeys(ctx); err != nil || migrated != 0 {
+		t.Errorf("expected a second run to be a no-op, got %d, %v", migrated, err)
+	}
+}
+
+func TestUserRepositoryFindOrCreateIsAtomic(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	const callers = 10
+	var created atomic.Int32
+	ids := make([]primitive.ObjectID, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := &model.User{Name: "Jane", Email: "Jane@Example.com", Password: "hashed", ApiKey: HashApiKey(fmt.Sprintf("key-%d", i))}
+			ok, result, err := repo.FindOrCreate(ctx, user)
+			if err != nil {
+				t.Errorf("caller %d: unexpected error: %v", i, err)
+				return
+			}
+			if ok {
+				created.Add(1)
+			}
+			ids[i] = result.ID
+		}(i)
+	}
+	wg.Wait()
+
+	if created.Load() != 1 {
+		t.Errorf("expected exactly one creation, got %d", created.Load())
+	}
+	for i, id := range ids {
+		if id != ids[0] {
+			t.Errorf("caller %d: expected user %v, got %v", i, ids[0], id)
+		}
+	}
+	count, err := repo.Count(ctx, bson.M{"email": "jane@example.com"})
+	if err != nil || count != 1 {
+		t.Errorf("expected one stored user, got %d (%v)", count, err)
+	}
+}
+
+func TestUpsertUsersKeepsCredentialsAndRoles(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	admin := &model.User{Name: "Admin", Email: "admin@example.com", Password: "old-hash", ApiKey: HashApiKey("admin-key"), Roles: []string{model.RoleAdmin}}
+	if err := repo.Create(ctx, admin); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	users := []*model.User{
+		{Name: "Renamed", Email: "ADMIN@example.com", ApiKey: HashApiKey("new-key"), Roles: []string{model.RoleUser}},
+		{Name: "Jane", Email: "jane@example.com", Password: "hash", ApiKey: HashApiKey("jane-key"), Roles: []string{model.RoleUser}},
+	}
+	result, err := repo.UpsertUsers(ctx, users, []string{"email"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *result != (UpsertResult{Inserted: 1, Updated: 1}) {
+		t.Errorf("expected one insert and one update, got %+v", *result)
+	}
+	if !users[0].ID.IsZero() || users[1].ID.IsZero() {
+		t.Errorf("expected an ID on the inserted user only, got %v and %v", users[0].ID, users[1].ID)
+	}
+
+	stored, err := repo.FindByID(ctx, admin.ID.Hex())
+	if err != nil {
+		t.Fatalf("failed to find user: %v", err)
+	}
+	if stored.Name != "Renamed" {
+		t.Errorf("expected the supplied name to be updated, got %+v", stored)
+	}
+	if stored.Password != "old-hash" || stored.ApiKey != admin.ApiKey || !stored.IsAdmin() {
+		t.Errorf("expected the password, API key and roles to be kept, got %+v", stored)
+	}
+
+	if _, err := repo.UpsertUsers(ctx, users, []string{"password"}); err == nil {
+		t.Error("expected the password to be rejected as a key field")
+	}
+}
+
+func TestUserRepositoryReplacePasswordHash(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{Name: "John Doe", Email: "john@example.com", Password: "old-hash", ApiKey: HashApiKey("john-key")}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	id := user.ID.Hex()
+
+	if replaced, err := repo.ReplacePasswordHash(ctx, id, "stale-hash", "new-hash"); err != nil || replaced {
+		t.Fatalf("expected a stale hash to be a no-op, got %v, %v", replaced, err)
+	}
+	if replaced, err := repo.ReplacePasswordHash(ctx, id, "old-hash", "new-hash"); err != nil || !replaced {
+		t.Fatalf("expected the current hash to be replaced, got %v, %v", replaced, err)
+	}
+
+	found, err := repo.FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to find user: %v", err)
+	}
+	if found.Password != "new-hash" {
+		t.Errorf("expected the new hash to be stored, got %q", found.Password)
+	}
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
@@ -13,6 +16,7 @@ import (
 	slogzerolog "github.com/samber/slog-zerolog/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 
+	"go-echo-mongo/internal/dto"
 	"go-echo-mongo/internal/handler"
 	"go-echo-mongo/internal/repository"
 	"go-echo-mongo/internal/repository/redisrepo"
@@ -21,31 +25,52 @@ import (
 	"go-echo-mongo/pkg/ratelimit"
 	"go-echo-mongo/pkg/web/mwutil"
 	"go-echo-mongo/pkg/web/response"
+	"go-echo-mongo/pkg/web/validator"
 )
 
 // Bootstrap initializes all dependencies and sets up the server
-func bootstrap(e *echo.Echo, cfg *Config) (*mongo.Database, *redis.Client) {
+func bootstrap(e *echo.Echo, cfg *Config) (*mongo.Database, *redis.Client, func() error) {
 	// Setup logger
 	logger := setupLogger()
 
 	// Setup echo logger
 	setupEchoLogger(e, logger)
 
+	// Let GET/POST-only clients override POST to PUT, PATCH or DELETE before routing
+	if cfg.MethodOverride {
+		e.Pre(mwutil.MethodOverride())
+	}
+
+	// Report handler duration in the X-Response-Time header
+	e.Use(mwutil.ResponseTime())
+
+	// Serialize response timestamps in a single format
+	dto.SetTimestampFormat(cfg.TimestampFormat)
+
 	// Setup prometheus
 	setupPrometheus(e)
 
 	// Setup database
 	db := setupDatabase(cfg)
 
+	// Share one causally consistent session across each request's repository calls
+	if cfg.MongoDB.CausalSessions {
+		e.Use(mwutil.MongoSession(db.Client()))
+	}
+
 	// Setup Redis
 	redisClient := setupRedis(e, cfg)
 
 	// Setup Repositories, Services and Routes
-	setupReposServicesRoutes(e, db, redisClient)
+	closeServices, err := setupReposServicesRoutes(e, cfg, db, redisClient)
+	if err != nil {
+		slog.Error("Failed to register routes", "error", err)
+		log.Fatal(err)
+	}
 
 	slog.Info("Server initialized successfully")
 
-	return db, redisClient
+	return db, redisClient, closeServices
 }
 
 // setupLogger initializes and configures the logger
@@ -88,6 +113,8 @@ func setupDatabase(cfg *Config) *mongo.Database {
 	dbConfig := database.DefaultConfig()
 	dbConfig.URI = cfg.MongoDB.URI
 	dbConfig.Database = cfg.MongoDB.Database
+	dbConfig.ConnectRetryBase = cfg.ConnectRetry.Base
+	dbConfig.ConnectRetryMax = cfg.ConnectRetry.Max
 
 	mongoDBService, err := database.NewMongoDBService(dbConfig)
 	if err != nil {
@@ -112,6 +139,8 @@ func setupRedis(e *echo.Echo, cfg *Config) *redis.Client {
 	if cfg.Redis.DB != 0 {
 		redisConfig.DB = cfg.Redis.DB
 	}
+	redisConfig.ConnectRetryBase = cfg.ConnectRetry.Base
+	redisConfig.ConnectRetryMax = cfg.ConnectRetry.Max
 
 	redisService, err := database.NewRedisService(redisConfig)
 	if err != nil {
@@ -131,21 +160,81 @@ func setupRedis(e *echo.Echo, cfg *Config) *redis.Client {
 }
 
 // setupRedisRepositories initializes all Redis repositories
-func setupRedisRepositories(redisClient *redis.Client) (redisrepo.Repository, redisrepo.CacheRepository, redisrepo.SessionRepository, redisrepo.RateLimitRepository) {
+func setupRedisRepositories(cfg *Config, redisClient *redis.Client) (redisrepo.Repository, redisrepo.CacheRepository, redisrepo.SessionRepository, redisrepo.RateLimitRepository) {
 	// Create base Redis repository
 	baseRepo := redisrepo.New(redisClient)
 
 	// Create specialized repositories
-	cacheRepo := redisrepo.NewCacheRepository(baseRepo)
+	cacheRepo := redisrepo.NewVersionedCacheRepository(baseRepo, cfg.Cache.Version)
 	sessionRepo := redisrepo.NewSessionRepository(baseRepo)
 	rateLimitRepo := redisrepo.NewRateLimitRepository(baseRepo)
 
 	return baseRepo, cacheRepo, sessionRepo, rateLimitRepo
 }
 
-func setupReposServicesRoutes(e *echo.Echo, db *mongo.Database, redisClient *redis.Client) {
+// setupFeatureFlagSource selects where feature flags are loaded from
+func setupFeatureFlagSource(cfg *Config, redisRepo redisrepo.Repository) mwutil.FlagSource {
+	if cfg.FeatureFlags.Source == "redis" {
+		return mwutil.NewHashFlagSource(redisRepo, cfg.FeatureFlags.RedisKey, 30*time.Second)
+	}
+	return mwutil.NewEnvFlagSource("FEATURE_FLAGS")
+}
+
+// hashLegacyApiKeys hashes API keys stored in plaintext before API keys were hashed at rest.
+// Keys it misses are still hashed the first time they authenticate.
+func hashLegacyApiKeys(userRepo repository.UserRepository) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	migrated, err := userRepo.HashLegacyApiKeys(ctx)
+	if err != nil {
+		slog.Warn("Failed to hash legacy API keys", "migrated", migrated, "error", err)
+		return
+	}
+	if migrated > 0 {
+		slog.Info("Hashed legacy API keys", "count", migrated)
+	}
+}
+
+// webhookQueueSize is how many events can wait for webhook delivery before new ones are dropped
+const webhookQueueSize = 1024
+
+// setupWebhooks creates the webhook service and, when a webhook URL is configured,
+// forwards every published event to it through a background dispatcher.
+// The returned function unsubscribes and waits for queued deliveries to finish.
+func setupWebhooks(cfg *Config, deadLetterRepo repository.DeadLetterRepository, redisRepo redisrepo.Repository) (service.WebhookService, func() error) {
+	webhookConfig := service.DefaultWebhookConfig
+	webhookConfig.URL = cfg.Webhook.URL
+	webhookConfig.Secret = cfg.Webhook.Secret
+	webhookConfig.MaxAttempts = cfg.Webhook.MaxAttempts
+	webhookService := service.NewWebhookService(deadLetterRepo, webhookConfig)
+
+	if cfg.Webhook.URL == "" {
+		return webhookService, func() error { return nil }
+	}
+
+	dispatcher := service.NewWebhookDispatcher(webhookService, webhookQueueSize)
+	unsubscribe, err := redisRepo.SubscribeFunc(context.Background(), func(msg *redis.Message) {
+		dispatcher.Enqueue(msg.Channel, []byte(msg.Payload))
+	}, service.EventTypes...)
+	if err != nil {
+		slog.Error("Failed to subscribe to events for webhooks", "error", err)
+		log.Fatal(err)
+	}
+
+	closeWebhooks := func() error {
+		// Stop receiving events before draining, so nothing is queued after Close
+		err := unsubscribe()
+		return errors.Join(err, dispatcher.Close())
+	}
+	return webhookService, closeWebhooks
+}
+
+// setupReposServicesRoutes wires repositories, services and handlers and registers the routes.
+// The returned function stops the background work they started.
+func setupReposServicesRoutes(e *echo.Echo, cfg *Config, db *mongo.Database, redisClient *redis.Client) (func() error, error) {
 	// Initialize Redis repositories
-	baseRedisRepo, cacheRepo, sessionRepo, rateLimitRepo := setupRedisRepositories(redisClient)
+	baseRedisRepo, cacheRepo, sessionRepo, rateLimitRepo := setupRedisRepositories(cfg, redisClient)
 
 	// Log Redis repositories initialization
 	slog.Info("Redis repositories initialized",
@@ -159,20 +248,50 @@ func setupReposServicesRoutes(e *echo.Echo, db *mongo.Database, redisClient *red
 
 	// Initialize MongoDB repositories
 	userRepo := repository.NewUserRepository(db)
+	hashLegacyApiKeys(userRepo)
 	productRepo := repository.NewProductRepository(db)
+	productRevisionRepo := repository.NewProductRevisionRepository(db)
+	deadLetterRepo := repository.NewDeadLetterRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
 
 	// Initialize services
-	userService := service.NewUserService(userRepo, baseRedisRepo)
-	productService := service.NewProductService(productRepo, baseRedisRepo)
+	cursorSecret, err := cfg.PaginationSecret()
+	if err != nil {
+		return nil, err
+	}
+	service.SetCursorSecret(cursorSecret)
+	service.SetDefaultFindLimit(cfg.FindLimit)
+	validator.SetMaxBatchSize(cfg.MaxBatchSize)
+	service.SetApiKeyEnvironment(cfg.APIKeyEnv)
+	service.SetPasswordCost(cfg.PasswordCost)
+	userService := service.NewUserService(userRepo, baseRedisRepo, cacheRepo, auditLogRepo)
+	productService := service.NewProductService(productRepo, baseRedisRepo, productRevisionRepo)
+	webhookService, closeWebhooks := setupWebhooks(cfg, deadLetterRepo, baseRedisRepo)
 	// Add new services here as needed
 
 	// Set API key validator
 	mwutil.SetAPIKeyValidator(userService)
+	mwutil.SetAPIKeyCache(cacheRepo, cfg.Cache.APIKeyTTL)
+	// Let protected routes accept the tokens login issues as well as API keys
+	mwutil.SetAuthJWT(cfg.JWT.Secret, cfg.JWT.RejectImpersonated)
+
+	// Load feature flags into every request context
+	e.Use(mwutil.NewFeatureFlags(setupFeatureFlagSource(cfg, baseRedisRepo)))
 
 	// Initialize handlers and register routes
 	routesRegistry := NewRegistry()
-	routesRegistry.Add(handler.NewUserHandler(userService))
+	routesRegistry.Add(handler.NewUserHandler(userService, handler.AuthConfig{
+		JWTSecret:          cfg.JWT.Secret,
+		TokenTTL:           cfg.JWT.TTL,
+		ImpersonationTTL:   cfg.JWT.ImpersonationTTL,
+		RejectImpersonated: cfg.JWT.RejectImpersonated,
+	}))
 	routesRegistry.Add(handler.NewProductHandler(productService))
+	routesRegistry.Add(handler.NewWebhookHandler(webhookService))
+	routesRegistry.Add(handler.NewRateLimitHandler(mwutil.ResetRateLimit))
 	// Add new handlers here as needed
-	routesRegistry.RegisterAll(e)
+	if err := routesRegistry.RegisterAll(e); err != nil {
+		return nil, errors.Join(err, closeWebhooks())
+	}
+	return closeWebhooks, nil
 }
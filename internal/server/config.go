@@ -1,19 +1,30 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"go-echo-mongo/pkg/secutil"
+	"go-echo-mongo/pkg/web/validator"
+
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
 // MongoDBCfg holds MongoDB connection configuration
 type MongoDBCfg struct {
 	URI      string
 	Database string
+	// CausalSessions gives every request a causally consistent session so handlers read their own writes
+	CausalSessions bool
 }
 
 // RedisCfg holds Redis connection configuration
@@ -23,12 +34,74 @@ type RedisCfg struct {
 	DB       int
 }
 
+// ConnectRetryCfg bounds the jittered exponential backoff between MongoDB and Redis connection attempts
+type ConnectRetryCfg struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// CacheCfg holds cache configuration
+type CacheCfg struct {
+	// Version prefixes every cache key; bump it on deploy to invalidate all cached entries
+	Version string
+	// APIKeyTTL is how long validated API key lookups are cached
+	APIKeyTTL time.Duration
+}
+
+// FeatureFlagsCfg holds feature flag configuration
+type FeatureFlagsCfg struct {
+	// Source is "env" to read FEATURE_FLAGS or "redis" to read the RedisKey hash
+	Source string
+	// RedisKey is the hash holding flags when Source is "redis"
+	RedisKey string
+}
+
+// JWTCfg holds token issuance configuration
+type JWTCfg struct {
+	Secret string
+	TTL    time.Duration
+	// ImpersonationTTL is how long tokens issued by the impersonate endpoint are valid
+	ImpersonationTTL time.Duration
+	// RejectImpersonated disables impersonation and rejects impersonated tokens
+	RejectImpersonated bool
+}
+
+// WebhookCfg holds webhook delivery configuration
+type WebhookCfg struct {
+	// URL receives domain events; webhooks are disabled when it is empty
+	URL    string
+	Secret string
+	// MaxAttempts is how many times a delivery is tried before it is dead-lettered
+	MaxAttempts int
+}
+
 // Config holds server configuration
 type Config struct {
+	// Host is the interface to listen on; empty binds every interface
+	Host            string
 	Port            string
 	MongoDB         MongoDBCfg
 	Redis           RedisCfg
+	ConnectRetry    ConnectRetryCfg
+	Cache           CacheCfg
+	FeatureFlags    FeatureFlagsCfg
+	JWT             JWTCfg
+	Webhook         WebhookCfg
 	ShutdownTimeout time.Duration
+	// TimestampFormat is the Go time layout used for timestamps in JSON responses
+	TimestampFormat string
+	// CursorSecret signs pagination cursors; without one, a key derived from the JWT secret is used
+	CursorSecret string
+	// FindLimit caps filter queries that do not set a limit
+	FindLimit int64
+	// MaxBatchSize caps the number of items a batch endpoint accepts
+	MaxBatchSize int
+	// PasswordCost is the bcrypt cost new password hashes are made with
+	PasswordCost int
+	// APIKeyEnv is "live" or "test"; new API keys are prefixed sk_<env>_ and keys from the other environment are rejected
+	APIKeyEnv string
+	// MethodOverride lets POST requests name PUT, PATCH or DELETE in a header or form field
+	MethodOverride bool
 }
 
 // NewConfig creates a new Config instance with values from environment variables
@@ -38,11 +111,8 @@ func NewConfig() *Config {
 		slog.Warn("Warning: .env file not found or error loading it", "error", err)
 	}
 
-	// Handle port configuration
-	port, err := strconv.Atoi(os.Getenv("PORT"))
-	if err != nil || port <= 0 {
-		port = 8080 // Default port if not specified
-	}
+	// Handle port configuration; an invalid value is kept so Validate can report it
+	port := getEnv("PORT", "8080")
 
 	mongoURI := getEnv("MONGODB_URI", "")
 	if mongoURI == "" {
@@ -54,24 +124,122 @@ func NewConfig() *Config {
 		)
 	}
 
+	// Parse whether requests get causally consistent MongoDB sessions
+	causalSessions, err := strconv.ParseBool(getEnv("MONGODB_CAUSAL_SESSIONS", "false"))
+	if err != nil {
+		causalSessions = false
+	}
+
+	// Parse whether POST requests may override their method
+	methodOverride, err := strconv.ParseBool(getEnv("METHOD_OVERRIDE", "false"))
+	if err != nil {
+		methodOverride = false
+	}
+
 	// Parse Redis DB index
 	redisDB, err := strconv.Atoi(getEnv("REDIS_DB", "0"))
 	if err != nil {
 		redisDB = 0
 	}
 
+	// Parse connection retry delays; invalid values are kept so Validate can report them
+	retryBase, err := time.ParseDuration(getEnv("CONNECT_RETRY_BASE_DELAY", "1s"))
+	if err != nil {
+		retryBase = 0
+	}
+	retryMax, err := time.ParseDuration(getEnv("CONNECT_RETRY_MAX_DELAY", "30s"))
+	if err != nil {
+		retryMax = 0
+	}
+
+	// Parse API key cache lifetime
+	apiKeyTTL, err := time.ParseDuration(getEnv("CACHE_API_KEY_TTL", "1m"))
+	if err != nil || apiKeyTTL <= 0 {
+		apiKeyTTL = time.Minute
+	}
+
+	// Parse default limit for filter queries
+	findLimit, err := strconv.ParseInt(getEnv("FIND_DEFAULT_LIMIT", "100"), 10, 64)
+	if err != nil || findLimit <= 0 {
+		findLimit = 100
+	}
+
+	// Parse the maximum batch size; an invalid value is kept so Validate can report it
+	maxBatchSize, err := strconv.Atoi(getEnv("MAX_BATCH_SIZE", strconv.Itoa(validator.DefaultMaxBatchSize)))
+	if err != nil {
+		maxBatchSize = 0
+	}
+
+	// Parse the bcrypt cost; an invalid value is kept so Validate can report it
+	passwordCost, err := strconv.Atoi(getEnv("BCRYPT_COST", strconv.Itoa(secutil.DefaultCost)))
+	if err != nil {
+		passwordCost = 0
+	}
+
+	// Parse webhook delivery attempts; an invalid value is kept so Validate can report it
+	webhookAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "3"))
+	if err != nil {
+		webhookAttempts = 0
+	}
+
+	// Parse JWT token lifetime
+	jwtTTL, err := time.ParseDuration(getEnv("JWT_TTL", "24h"))
+	if err != nil || jwtTTL <= 0 {
+		jwtTTL = 24 * time.Hour
+	}
+	impersonationTTL, err := time.ParseDuration(getEnv("JWT_IMPERSONATION_TTL", "15m"))
+	if err != nil || impersonationTTL <= 0 {
+		impersonationTTL = 15 * time.Minute
+	}
+	rejectImpersonated, err := strconv.ParseBool(getEnv("JWT_REJECT_IMPERSONATED", "false"))
+	if err != nil {
+		rejectImpersonated = false
+	}
+
 	return &Config{
-		Port: fmt.Sprintf(":%d", port),
+		Host: getEnv("HOST", ""),
+		Port: ":" + port,
 		MongoDB: MongoDBCfg{
-			URI:      mongoURI,
-			Database: getEnv("DB_NAME", "development_db"),
+			URI:            mongoURI,
+			Database:       getEnv("DB_NAME", "development_db"),
+			CausalSessions: causalSessions,
 		},
 		Redis: RedisCfg{
 			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       redisDB,
 		},
+		ConnectRetry: ConnectRetryCfg{
+			Base: retryBase,
+			Max:  retryMax,
+		},
+		Cache: CacheCfg{
+			Version:   getEnv("CACHE_VERSION", ""),
+			APIKeyTTL: apiKeyTTL,
+		},
+		FeatureFlags: FeatureFlagsCfg{
+			Source:   getEnv("FEATURE_FLAGS_SOURCE", "env"),
+			RedisKey: getEnv("FEATURE_FLAGS_KEY", "feature_flags"),
+		},
+		JWT: JWTCfg{
+			Secret:             getEnv("JWT_SECRET", ""),
+			TTL:                jwtTTL,
+			ImpersonationTTL:   impersonationTTL,
+			RejectImpersonated: rejectImpersonated,
+		},
+		Webhook: WebhookCfg{
+			URL:         getEnv("WEBHOOK_URL", ""),
+			Secret:      getEnv("WEBHOOK_SECRET", ""),
+			MaxAttempts: webhookAttempts,
+		},
 		ShutdownTimeout: 10 * time.Second,
+		TimestampFormat: getEnv("TIMESTAMP_FORMAT", time.RFC3339),
+		CursorSecret:    getEnv("CURSOR_SECRET", ""),
+		FindLimit:       findLimit,
+		MaxBatchSize:    maxBatchSize,
+		PasswordCost:    passwordCost,
+		APIKeyEnv:       getEnv("API_KEY_ENV", "live"),
+		MethodOverride:  methodOverride,
 	}
 }
 
@@ -82,3 +250,122 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// PaginationSecret returns the secret pagination cursors are signed with: CURSOR_SECRET if set,
+// otherwise a key derived from the JWT secret, so a cursor signature never doubles as a token
+// signature. It is empty if neither is set, leaving cursors signed with a per-process secret.
+func (c *Config) PaginationSecret() (string, error) {
+	if c.CursorSecret != "" || c.JWT.Secret == "" {
+		return c.CursorSecret, nil
+	}
+	return secutil.DeriveKey(c.JWT.Secret, "go-echo-mongo pagination cursors")
+}
+
+// ListenAddr returns the address the server listens on, e.g. "127.0.0.1:8080",
+// or ":8080" to listen on every interface
+func (c *Config) ListenAddr() string {
+	return net.JoinHostPort(c.Host, strings.TrimPrefix(c.Port, ":"))
+}
+
+// placeholderSecrets are example values that must never be used to sign anything
+var placeholderSecrets = []string{"change-me", "changeme", "secret", "your-secret", "your-secret-key", "jwt-secret", "password"}
+
+// isPlaceholderSecret reports whether value is one of the example secrets from docs and templates
+func isPlaceholderSecret(value string) bool {
+	return slices.Contains(placeholderSecrets, strings.ToLower(strings.TrimSpace(value)))
+}
+
+// Validate checks that the configuration is usable, reporting every problem at once
+// so startup can fail before connecting to anything
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(strings.TrimPrefix(c.Port, ":")); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q is not a valid port, use a number between 1 and 65535", strings.TrimPrefix(c.Port, ":")))
+	}
+	if c.Host != "" && net.ParseIP(c.Host) == nil && strings.ContainsAny(c.Host, ":/ ") {
+		errs = append(errs, fmt.Errorf("HOST: %q must be an IP address or host name without a port", c.Host))
+	}
+
+	switch {
+	case c.MongoDB.URI == "":
+		errs = append(errs, errors.New("MONGODB_URI: is required, or set DB_HOST and DB_PORT"))
+	case !strings.HasPrefix(c.MongoDB.URI, "mongodb://") && !strings.HasPrefix(c.MongoDB.URI, "mongodb+srv://"):
+		errs = append(errs, errors.New("MONGODB_URI: must start with mongodb:// or mongodb+srv://"))
+	case strings.HasPrefix(c.MongoDB.URI, "mongodb+srv://"):
+		// SRV URIs are resolved through DNS when connecting, so only their shape is checked here
+		if u, err := url.Parse(c.MongoDB.URI); err != nil || u.Host == "" {
+			errs = append(errs, errors.New("MONGODB_URI: mongodb+srv:// URI must include a host name"))
+		}
+	default:
+		if _, err := connstring.ParseAndValidate(c.MongoDB.URI); err != nil {
+			// The driver error may include the URI and its credentials, so only report the reason
+			errs = append(errs, fmt.Errorf("MONGODB_URI: is not a valid connection string: %s", redactURIError(err, c.MongoDB.URI)))
+		}
+	}
+	if c.MongoDB.Database == "" {
+		errs = append(errs, errors.New("DB_NAME: is required"))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Redis.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("REDIS_ADDR: %q must be host:port", c.Redis.Addr))
+	}
+	if c.Redis.DB < 0 {
+		errs = append(errs, fmt.Errorf("REDIS_DB: %d must not be negative", c.Redis.DB))
+	}
+
+	if c.ConnectRetry.Base <= 0 {
+		errs = append(errs, fmt.Errorf("CONNECT_RETRY_BASE_DELAY: %s must be positive", c.ConnectRetry.Base))
+	}
+	if c.ConnectRetry.Max < c.ConnectRetry.Base {
+		errs = append(errs, fmt.Errorf("CONNECT_RETRY_MAX_DELAY: %s must not be less than CONNECT_RETRY_BASE_DELAY", c.ConnectRetry.Max))
+	}
+
+	if c.FeatureFlags.Source != "env" && c.FeatureFlags.Source != "redis" {
+		errs = append(errs, fmt.Errorf("FEATURE_FLAGS_SOURCE: %q must be env or redis", c.FeatureFlags.Source))
+	}
+
+	if c.ShutdownTimeout <= 0 || c.ShutdownTimeout > 5*time.Minute {
+		errs = append(errs, fmt.Errorf("shutdown timeout: %s must be between 0 and 5m", c.ShutdownTimeout))
+	}
+	if isPlaceholderSecret(c.JWT.Secret) {
+		errs = append(errs, errors.New("JWT_SECRET: is an example value, set a long random one (e.g. openssl rand -hex 32) or leave it empty"))
+	}
+	if isPlaceholderSecret(c.CursorSecret) {
+		errs = append(errs, errors.New("CURSOR_SECRET: is an example value, set a long random one (e.g. openssl rand -hex 32) or leave it empty"))
+	}
+	if c.JWT.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("JWT_TTL: %s must be positive", c.JWT.TTL))
+	}
+	if c.Cache.APIKeyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("CACHE_API_KEY_TTL: %s must be positive", c.Cache.APIKeyTTL))
+	}
+	if c.FindLimit <= 0 {
+		errs = append(errs, fmt.Errorf("FIND_DEFAULT_LIMIT: %d must be positive", c.FindLimit))
+	}
+	if c.MaxBatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_BATCH_SIZE: %d must be positive", c.MaxBatchSize))
+	}
+	if c.PasswordCost < secutil.MinCost || c.PasswordCost > secutil.MaxCost {
+		errs = append(errs, fmt.Errorf("BCRYPT_COST: %d must be between %d and %d", c.PasswordCost, secutil.MinCost, secutil.MaxCost))
+	}
+	if c.APIKeyEnv != "live" && c.APIKeyEnv != "test" {
+		errs = append(errs, fmt.Errorf("API_KEY_ENV: %q must be live or test", c.APIKeyEnv))
+	}
+
+	if c.Webhook.URL != "" {
+		if u, err := url.Parse(c.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("WEBHOOK_URL: must be an absolute http:// or https:// URL"))
+		}
+		if c.Webhook.MaxAttempts < 1 {
+			errs = append(errs, errors.New("WEBHOOK_MAX_ATTEMPTS: must be a positive number"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// redactURIError removes the URI from a driver parse error
+func redactURIError(err error, uri string) string {
+	return strings.ReplaceAll(err.Error(), uri, "<uri>")
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"go-echo-mongo/internal/handler"
+	"go-echo-mongo/pkg/web/mwutil"
 
 	"github.com/labstack/echo/v4"
 )
@@ -23,9 +24,13 @@ func (r *Registry) Add(h handler.BaseHandler) {
 	r.handlers = append(r.handlers, h)
 }
 
-// RegisterAll registers all handlers with Echo
-func (r *Registry) RegisterAll(e *echo.Echo) {
+// RegisterAll registers all handlers with Echo.
+// Middleware misconfiguration raised while registering routes is returned as an error.
+func (r *Registry) RegisterAll(e *echo.Echo) (err error) {
+	defer mwutil.RecoverConfigError(&err)
+
 	for _, h := range r.handlers {
 		h.Register(e)
 	}
+	return nil
 }
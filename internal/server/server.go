@@ -2,14 +2,18 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"go-echo-mongo/pkg/web/response"
+
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,12 +25,27 @@ type Server struct {
 	echo   *echo.Echo
 	db     *mongo.Database
 	redis  *redis.Client
+	// closeServices stops the background work started by bootstrap, such as webhook delivery
+	closeServices func() error
+	// httpServer serves s itself, so requests reach echo only once it is ready
+	httpServer *http.Server
+	// ready is set once bootstrap has finished; until then every request gets a 503
+	ready atomic.Bool
 }
 
 // NewServer creates and initializes a new server instance
 func NewServer() *Server {
 	e := echo.New()
 	config := NewConfig()
+	if err := config.Validate(); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		log.Fatal(err)
+	}
+
+	s := &Server{
+		config: config,
+		echo:   e,
+	}
 
 	// Setup middleware
 	setupMiddleware(e)
@@ -34,25 +53,46 @@ func NewServer() *Server {
 	// Setup validator
 	setupValidator(e)
 
-	return &Server{
-		config: config,
-		echo:   e,
-	}
+	return s
 }
 
-// Start initializes the server, sets up routes and starts listening
+// Start starts listening, initializes dependencies and routes, then begins serving requests.
+// The port is open during initialization so health checks get a 503 instead of a refused connection.
 func (s *Server) Start() error {
-	// Initialize all dependencies
-	s.db, s.redis = bootstrap(s.echo, s.config)
-
 	// Start server
+	s.httpServer = &http.Server{
+		Addr:     s.config.ListenAddr(),
+		Handler:  s,
+		ErrorLog: s.echo.StdLogger,
+	}
 	go s.startServer()
 
+	// Initialize all dependencies
+	s.db, s.redis, s.closeServices = bootstrap(s.echo, s.config)
+	s.ready.Store(true)
+	slog.Info("Server is ready to accept requests")
+
 	return s.gracefulShutdown()
 }
 
+// ServeHTTP answers 503 with a Retry-After until the server is ready, then hands requests to echo.
+// Echo is not touched at all before then, so the routes and middleware bootstrap is still
+// registering are never read concurrently and its context pool is only filled once every
+// route is known.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.ready.Load() {
+		s.echo.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(response.New(http.StatusServiceUnavailable, "Server is starting, try again shortly", nil))
+}
+
 func (s *Server) startServer() {
-	if err := s.echo.Start(s.config.Port); err != nil && err != http.ErrServerClosed {
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		slog.Error("Shutting down the server", "error", err)
 		log.Fatalf("http server error: %s", err)
 	}
@@ -76,6 +116,10 @@ func (s *Server) gracefulShutdown() error {
 	// Create a channel to track shutdown completion
 	done := make(chan bool, 1)
 	go func() {
+		// Stop background work while the connections it needs are still open
+		if err := s.closeServices(); err != nil {
+			slog.Error("Error stopping background services", "error", err)
+		}
 		// Close MongoDB connection
 		if err := s.db.Client().Disconnect(shutdownCtx); err != nil {
 			slog.Error("Error disconnecting from MongoDB", "error", err)
@@ -85,7 +129,7 @@ func (s *Server) gracefulShutdown() error {
 			slog.Error("Error disconnecting from Redis", "error", err)
 		}
 		// Shutdown server
-		if err := s.echo.Shutdown(shutdownCtx); err != nil {
+		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
 			slog.Error("Error during server shutdown", "error", err)
 		}
 
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestServerServesOnlyOnceReady(t *testing.T) {
+	e := echo.New()
+	s := &Server{echo: e}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Before bootstrap has registered anything, requests are turned away rather than 404ing
+	rec := get("/users/1")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After before routes exist, got %d %v", rec.Code, rec.Header())
+	}
+
+	// Routes with path parameters are registered after requests were already turned away;
+	// echo's context pool must not have been filled with contexts sized for no parameters
+	e.GET("/users/:id", func(c echo.Context) error { return c.String(http.StatusOK, c.Param("id")) })
+	if rec := get("/users/1"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 until the server is marked ready, got %d", rec.Code)
+	}
+
+	s.ready.Store(true)
+	if rec := get("/users/42"); rec.Code != http.StatusOK || rec.Body.String() != "42" {
+		t.Errorf("expected 200 once ready, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"go-echo-mongo/internal/model"
 	"go-echo-mongo/pkg/web/validator"
 
+	playground "github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
@@ -19,6 +21,11 @@ import (
 // setupValidator configures the validator for the server
 func setupValidator(e *echo.Echo) {
 	v := validator.New()
+	if err := v.RegisterCustomValidation("role", func(fl playground.FieldLevel) bool {
+		return model.IsKnownRole(fl.Field().String())
+	}); err != nil {
+		panic(err)
+	}
 	// v.RegisterValidation("strongpassword", func(fl validator.FieldLevel) bool {
 	// 	return validation.IsStrongPassword(fl.Field().String())
 	// })
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"go-echo-mongo/pkg/strutil"
+)
+
+// API key environments; keys issued in one are rejected by the other
+const (
+	ApiKeyEnvLive = "live"
+	ApiKeyEnvTest = "test"
+)
+
+// apiKeyRandomLength is the length of the random part of an API key, after its prefix
+const apiKeyRandomLength = 32
+
+var apiKeyEnv atomic.Value
+
+func init() {
+	apiKeyEnv.Store(ApiKeyEnvLive)
+}
+
+// SetApiKeyEnvironment sets the environment new API keys are issued for and lookups accept.
+// Values other than ApiKeyEnvLive and ApiKeyEnvTest are ignored.
+func SetApiKeyEnvironment(env string) {
+	if env != ApiKeyEnvLive && env != ApiKeyEnvTest {
+		return
+	}
+	apiKeyEnv.Store(env)
+}
+
+// ApiKeyPrefix returns the prefix of API keys issued for env, e.g. "sk_live_"
+func ApiKeyPrefix(env string) string {
+	return "sk_" + env + "_"
+}
+
+// ApiKeyEnvironment returns the environment an API key was issued for, or false for
+// keys without a recognised prefix, such as those issued before prefixes were added
+func ApiKeyEnvironment(apiKey string) (string, bool) {
+	for _, env := range []string{ApiKeyEnvLive, ApiKeyEnvTest} {
+		if strings.HasPrefix(apiKey, ApiKeyPrefix(env)) {
+			return env, true
+		}
+	}
+	return "", false
+}
+
+// generateApiKey generates a new random API key prefixed with the current environment
+func generateApiKey() (string, error) {
+	prefix := ApiKeyPrefix(apiKeyEnv.Load().(string))
+	return strutil.GenerateKey(len(prefix)+apiKeyRandomLength, prefix)
+}
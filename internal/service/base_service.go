@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strings"
 
 	"go-echo-mongo/internal/model"
 	"go-echo-mongo/internal/repository"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -16,32 +18,86 @@ var (
 	ErrNilContext    = errors.New("context cannot be nil")
 	ErrNilRepository = errors.New("repository cannot be nil")
 	ErrEmptyBatch    = errors.New("batch cannot be empty")
+	ErrBatchTooLarge = errors.New("batch is too large")
+	ErrEmptySearch   = errors.New("search query cannot be empty")
+	ErrInvalidCursor = errors.New("invalid cursor")
 
 	// User service errors
 	ErrUserNotFound       = errors.New("user not found")
 	ErrEmailExists        = errors.New("email already exists")
-	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrUsernameExists     = errors.New("username already exists")
+	ErrInvalidUsername    = errors.New("username must be 3 to 30 letters, digits, underscores or hyphens")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrLastAdmin          = errors.New("cannot remove the last admin")
+	ErrUnknownRole        = errors.New("unknown role")
+	ErrNoRoles            = errors.New("at least one role is required")
+	ErrInvalidScope       = errors.New("invalid scope")
+	ErrApiKeyEnvironment  = errors.New("api key was issued for another environment")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters with upper and lower case letters, a number and a symbol")
+	ErrTOTPRequired       = errors.New("two-factor code required")
+	ErrInvalidTOTP        = errors.New("invalid two-factor code")
+
+	// Impersonation errors
+	ErrImpersonateSelf          = errors.New("cannot impersonate yourself")
+	ErrImpersonateAdmin         = errors.New("cannot impersonate an admin")
+	ErrImpersonationUnavailable = errors.New("impersonation requires an audit log")
 
 	// Product service errors
-	ErrProductNotFound = errors.New("product not found")
-	ErrInvalidStock    = errors.New("invalid stock value")
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInvalidStock      = errors.New("invalid stock value")
+	ErrInvalidRestock    = errors.New("restock quantity must be positive")
+	ErrInvalidDecrement  = errors.New("decrement quantity must be positive")
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// Stock reservation errors
+	ErrInvalidReservation  = errors.New("reservation quantity and duration must be positive")
+	ErrReservationNotFound = errors.New("reservation not found or expired")
+)
+
+// Sort specifies the field and direction to order paginated results by
+type Sort = repository.Sort
+
+// UpsertResult reports how many models an UpsertMany inserted and how many it updated
+type UpsertResult = repository.UpsertResult
+
+// Sort directions
+const (
+	SortAsc  = repository.SortAsc
+	SortDesc = repository.SortDesc
 )
 
+// IncludeDeleted returns a context in which reads also return soft-deleted models
+func IncludeDeleted(ctx context.Context) context.Context {
+	return repository.IncludeDeleted(ctx)
+}
+
 // BaseService provides common functionality for all services
 type BaseService[T model.Model] interface {
 	// Common CRUD operations
 	Create(ctx context.Context, model T) error
 	GetByID(ctx context.Context, id string) (T, error)
 	GetAll(ctx context.Context) ([]T, error)
-	GetPaginated(ctx context.Context, filter interface{}, page, itemsPerPage int64) ([]T, int64, error)
+	GetPaginated(ctx context.Context, filter interface{}, sort Sort, page, itemsPerPage int64) ([]T, int64, error)
+	SearchPaginated(ctx context.Context, search string, filter map[string]interface{}, page, itemsPerPage int64) ([]T, int64, error)
+	GetCursorPaginated(ctx context.Context, filter interface{}, sortField, cursor string, limit int64) ([]T, string, error)
+	Count(ctx context.Context, filter interface{}) (int64, error)
 	Update(ctx context.Context, id string, model T) error
 	Delete(ctx context.Context, id string) error
+	DeleteAndReturn(ctx context.Context, id string) (T, error)
+	SoftDelete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
 
 	// Batch operations
 	CreateMany(ctx context.Context, models []T) error
+	UpsertMany(ctx context.Context, models []T, keyFields []string) (*UpsertResult, error)
 	FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]T, error)
+	FindEach(ctx context.Context, filter interface{}, opts *options.FindOptions, fn func(T) error) error
 	UpdateMany(ctx context.Context, filter interface{}, update interface{}) (int64, error)
 	DeleteMany(ctx context.Context, filter interface{}) (int64, error)
+
+	// Metadata operations
+	GetMetadata(ctx context.Context, id string) (model.Metadata, error)
+	UpdateMetadata(ctx context.Context, id string, updates model.Metadata) (model.Metadata, error)
 }
 
 // baseService implements common service functionality
@@ -93,11 +149,57 @@ func (s *baseService[T]) GetAll(ctx context.Context) ([]T, error) {
 }
 
 // GetPaginated retrieves models with pagination
-func (s *baseService[T]) GetPaginated(ctx context.Context, filter interface{}, page, itemsPerPage int64) ([]T, int64, error) {
+func (s *baseService[T]) GetPaginated(ctx context.Context, filter interface{}, sort Sort, page, itemsPerPage int64) ([]T, int64, error) {
 	if err := validateContext(ctx); err != nil {
 		return nil, 0, err
 	}
-	return s.repo.FindPaginated(ctx, filter, page, itemsPerPage)
+	return s.repo.FindPaginated(ctx, filter, sort, page, itemsPerPage)
+}
+
+// Count returns the number of models matching the filter
+func (s *baseService[T]) Count(ctx context.Context, filter interface{}) (int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return 0, err
+	}
+	return s.repo.Count(ctx, filter)
+}
+
+// SearchPaginated retrieves models matching a text search with pagination and total match count
+func (s *baseService[T]) SearchPaginated(ctx context.Context, search string, filter map[string]interface{}, page, itemsPerPage int64) ([]T, int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	if strings.TrimSpace(search) == "" {
+		return nil, 0, ErrEmptySearch
+	}
+	return s.repo.SearchPaginated(ctx, search, filter, page, itemsPerPage)
+}
+
+// GetCursorPaginated retrieves a page of models after the given signed cursor and returns the cursor
+// for the next page, which is empty on the last page. An empty cursor starts from the first page.
+func (s *baseService[T]) GetCursorPaginated(ctx context.Context, filter interface{}, sortField, cursor string, limit int64) ([]T, string, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, "", err
+	}
+
+	afterID, err := decodeCursor(cursor, sortField)
+	if err != nil {
+		return nil, "", err
+	}
+
+	models, lastID, err := s.repo.FindCursorPaginated(ctx, filter, sortField, afterID, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, "", ErrInvalidCursor
+		}
+		return nil, "", err
+	}
+
+	next, err := encodeCursor(lastID, sortField)
+	if err != nil {
+		return nil, "", err
+	}
+	return models, next, nil
 }
 
 // Update implements generic update operation
@@ -116,17 +218,53 @@ func (s *baseService[T]) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
 }
 
+// DeleteAndReturn deletes a model and returns its state prior to deletion
+func (s *baseService[T]) DeleteAndReturn(ctx context.Context, id string) (T, error) {
+	var empty T
+	if err := validateContext(ctx); err != nil {
+		return empty, err
+	}
+	return s.repo.DeleteAndReturn(ctx, id)
+}
+
+// SoftDelete hides a model from normal reads without removing it
+func (s *baseService[T]) SoftDelete(ctx context.Context, id string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+	return s.repo.SoftDelete(ctx, id)
+}
+
+// Restore makes a soft-deleted model visible to normal reads again
+func (s *baseService[T]) Restore(ctx context.Context, id string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+	return s.repo.Restore(ctx, id)
+}
+
 // CreateMany implements batch create operation
 func (s *baseService[T]) CreateMany(ctx context.Context, models []T) error {
 	if err := validateContext(ctx); err != nil {
 		return err
 	}
-	if len(models) == 0 {
-		return ErrEmptyBatch
+	if err := validateBatchSize(len(models)); err != nil {
+		return err
 	}
 	return s.repo.InsertMany(ctx, models)
 }
 
+// UpsertMany implements idempotent batch create, updating models that match on keyFields
+func (s *baseService[T]) UpsertMany(ctx context.Context, models []T, keyFields []string) (*UpsertResult, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+	if err := validateBatchSize(len(models)); err != nil {
+		return nil, err
+	}
+	return s.repo.UpsertMany(ctx, models, keyFields)
+}
+
 // FindMany implements batch find operation
 func (s *baseService[T]) FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]T, error) {
 	if err := validateContext(ctx); err != nil {
@@ -135,6 +273,14 @@ func (s *baseService[T]) FindMany(ctx context.Context, filter interface{}, opts
 	return s.repo.FindMany(ctx, filter, opts)
 }
 
+// FindEach implements streaming find operation
+func (s *baseService[T]) FindEach(ctx context.Context, filter interface{}, opts *options.FindOptions, fn func(T) error) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+	return s.repo.FindEach(ctx, filter, opts, fn)
+}
+
 // UpdateMany implements batch update operation
 func (s *baseService[T]) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (int64, error) {
 	if err := validateContext(ctx); err != nil {
@@ -150,3 +296,45 @@ func (s *baseService[T]) DeleteMany(ctx context.Context, filter interface{}) (in
 	}
 	return s.repo.DeleteMany(ctx, filter)
 }
+
+// GetMetadata retrieves the metadata attributes of a model
+func (s *baseService[T]) GetMetadata(ctx context.Context, id string) (model.Metadata, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	m, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.GetMetadata() == nil {
+		return model.Metadata{}, nil
+	}
+	return m.GetMetadata(), nil
+}
+
+// UpdateMetadata merges updates into a model's metadata and returns the result
+// Keys set to nil are removed; the merged metadata must pass size and depth validation
+func (s *baseService[T]) UpdateMetadata(ctx context.Context, id string, updates model.Metadata) (model.Metadata, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	m, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := m.GetMetadata().Merge(updates)
+	if err := merged.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Write only the metadata so concurrent changes to other fields are not overwritten
+	if err := s.repo.UpdateFields(ctx, id, bson.M{"metadata": merged}); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
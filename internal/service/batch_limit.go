@@ -0,0 +1,19 @@
+package service
+
+import (
+	"fmt"
+
+	"go-echo-mongo/pkg/web/validator"
+)
+
+// validateBatchSize rejects empty batches and batches larger than the maximum batch size.
+// The limit is the one request validation enforces, set with validator.SetMaxBatchSize.
+func validateBatchSize(size int) error {
+	if size == 0 {
+		return ErrEmptyBatch
+	}
+	if limit := validator.MaxBatchSize(); int64(size) > limit {
+		return fmt.Errorf("%w: at most %d items are allowed, got %d", ErrBatchTooLarge, limit, size)
+	}
+	return nil
+}
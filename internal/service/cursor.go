@@ -0,0 +1,70 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"go-echo-mongo/pkg/secutil"
+)
+
+var (
+	cursorSecretMu sync.RWMutex
+	cursorSecret   = newCursorSecret()
+)
+
+// newCursorSecret returns a random per-process secret used until SetCursorSecret is called
+func newCursorSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// SetCursorSecret sets the secret used to sign pagination cursors.
+// Without it cursors are signed with a random secret and do not survive restarts.
+func SetCursorSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	cursorSecretMu.Lock()
+	defer cursorSecretMu.Unlock()
+	cursorSecret = secret
+}
+
+func getCursorSecret() string {
+	cursorSecretMu.RLock()
+	defer cursorSecretMu.RUnlock()
+	return cursorSecret
+}
+
+// cursorToken is the signed payload of a pagination cursor
+type cursorToken struct {
+	LastID string `json:"last_id"`
+	Sort   string `json:"sort,omitempty"`
+}
+
+// encodeCursor signs the ID of the last document on a page
+func encodeCursor(lastID, sortField string) (string, error) {
+	if lastID == "" {
+		return "", nil
+	}
+	return secutil.EncodeCursor(cursorToken{LastID: lastID, Sort: sortField}, getCursorSecret())
+}
+
+// decodeCursor verifies a cursor and returns the ID it continues after.
+// A cursor issued for a different sort order is rejected.
+func decodeCursor(cursor, sortField string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	var token cursorToken
+	if err := secutil.DecodeCursor(cursor, getCursorSecret(), &token); err != nil {
+		return "", ErrInvalidCursor
+	}
+	if token.Sort != sortField || token.LastID == "" {
+		return "", ErrInvalidCursor
+	}
+	return token.LastID, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"go-echo-mongo/internal/repository/redisrepo"
+)
+
+// Event channels, named "<resource>.<past tense verb>"
+const (
+	EventProductDeleted   = "product.deleted"
+	EventProductRestocked = "product.restocked"
+)
+
+// EventTypes lists every event channel, e.g. for forwarding events to webhooks
+var EventTypes = []string{EventProductDeleted, EventProductRestocked}
+
+// Event is the payload published to Redis when a domain change happens
+type Event struct {
+	Type       string      `json:"type"`
+	Data       interface{} `json:"data"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// ProductRestocked is the data of a product.restocked event
+type ProductRestocked struct {
+	ProductID   string    `json:"product_id"`
+	Quantity    int32     `json:"quantity"`
+	Stock       int32     `json:"stock"`
+	RestockedBy string    `json:"restocked_by"`
+	RestockedAt time.Time `json:"restocked_at"`
+}
+
+// publishEvent publishes an event on the channel named after its type.
+// Publishing is best-effort: failures are logged and never fail the caller.
+func publishEvent(ctx context.Context, redis redisrepo.Repository, eventType string, data interface{}) {
+	if redis == nil {
+		return
+	}
+
+	payload, err := json.Marshal(Event{
+		Type:       eventType,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		slog.Warn("Failed to encode event", "type", eventType, "error", err)
+		return
+	}
+
+	if err := redis.Publish(ctx, eventType, payload); err != nil {
+		slog.Warn("Failed to publish event", "type", eventType, "error", err)
+	}
+}
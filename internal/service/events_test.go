@@ -0,0 +1,15 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestEventTypesAreResourcePastTense(t *testing.T) {
+	name := regexp.MustCompile(`^[a-z_]+\.[a-z_]+ed$`)
+	for _, eventType := range EventTypes {
+		if !name.MatchString(eventType) {
+			t.Errorf("event %q does not follow the <resource>.<past tense verb> scheme", eventType)
+		}
+	}
+}
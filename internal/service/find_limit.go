@@ -0,0 +1,38 @@
+package service
+
+import (
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultFindLimit caps filter queries that do not set a limit
+const DefaultFindLimit int64 = 100
+
+var findLimit atomic.Int64
+
+func init() {
+	findLimit.Store(DefaultFindLimit)
+}
+
+// SetDefaultFindLimit sets the limit applied to filter queries without one.
+// Values <= 0 are ignored.
+func SetDefaultFindLimit(limit int64) {
+	if limit <= 0 {
+		return
+	}
+	findLimit.Store(limit)
+}
+
+// filterFindOptions builds find options for limit and skip, falling back to the
+// default limit so a filter query never scans the whole collection
+func filterFindOptions(limit, skip int64) *options.FindOptions {
+	if limit <= 0 {
+		limit = findLimit.Load()
+	}
+	opts := options.Find().SetLimit(limit)
+	if skip > 0 {
+		opts.SetSkip(skip)
+	}
+	return opts
+}
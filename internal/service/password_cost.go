@@ -0,0 +1,28 @@
+package service
+
+import (
+	"sync/atomic"
+
+	"go-echo-mongo/pkg/secutil"
+)
+
+var passwordCost atomic.Int64
+
+func init() {
+	passwordCost.Store(secutil.DefaultCost)
+}
+
+// SetPasswordCost sets the bcrypt cost new password hashes are made with. Logins with a
+// password hashed at a lower cost re-hash it at this cost.
+// Values outside secutil.MinCost and secutil.MaxCost are ignored.
+func SetPasswordCost(cost int) {
+	if cost < secutil.MinCost || cost > secutil.MaxCost {
+		return
+	}
+	passwordCost.Store(int64(cost))
+}
+
+// hashPassword hashes a password with the configured bcrypt cost
+func hashPassword(password string) (string, error) {
+	return secutil.HashPasswordWithCost(password, int(passwordCost.Load()))
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go-echo-mongo/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// editorKey holds the ID of the user making changes in a context
+type editorKey struct{}
+
+// WithEditor returns a context whose product updates are attributed to editor in the history
+func WithEditor(ctx context.Context, editor string) context.Context {
+	return context.WithValue(ctx, editorKey{}, editor)
+}
+
+// editorFrom returns the editor set with WithEditor, or an empty string
+func editorFrom(ctx context.Context) string {
+	editor, _ := ctx.Value(editorKey{}).(string)
+	return editor
+}
+
+// newRevision returns the revision recording prior as the state before an update made in ctx
+func newRevision(ctx context.Context, prior *model.Product) *model.ProductRevision {
+	return &model.ProductRevision{
+		ProductID: prior.ID,
+		Snapshot:  *prior,
+		EditedBy:  editorFrom(ctx),
+	}
+}
+
+// withRevision records prior as a revision and then runs update. The revision is written first so
+// an update is never left without history; if the update fails the revision is removed again.
+func (s *productService) withRevision(ctx context.Context, prior *model.Product, update func() error) error {
+	if s.revisions == nil {
+		return update()
+	}
+
+	revision := newRevision(ctx, prior)
+	if err := s.revisions.Create(ctx, revision); err != nil {
+		return fmt.Errorf("failed to record product revision: %w", err)
+	}
+
+	if err := update(); err != nil {
+		if delErr := s.revisions.Delete(context.WithoutCancel(ctx), revision.ID.Hex()); delErr != nil {
+			slog.Warn("Failed to remove revision of failed product update", "product_id", prior.ID.Hex(), "error", delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// recordRevision records prior as a revision of an atomic stock update that already happened.
+// Those updates return the state they replaced, so the revision is written after them; the update
+// is not undone if that fails, since retrying it would apply the stock change twice.
+func (s *productService) recordRevision(ctx context.Context, prior *model.Product) {
+	if s.revisions == nil {
+		return
+	}
+	if err := s.revisions.Create(context.WithoutCancel(ctx), newRevision(ctx, prior)); err != nil {
+		slog.Error("Failed to record product revision", "product_id", prior.ID.Hex(), "error", err)
+	}
+}
+
+// GetProductHistory retrieves the revisions of a product, newest first, with the total count
+func (s *productService) GetProductHistory(ctx context.Context, id string, page, itemsPerPage int64) ([]*model.ProductRevision, int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, 0, ErrProductNotFound
+	}
+
+	exists, err := s.repo.ExistsByID(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !exists {
+		return nil, 0, ErrProductNotFound
+	}
+
+	if s.revisions == nil {
+		return []*model.ProductRevision{}, 0, nil
+	}
+
+	return s.revisions.FindPaginated(ctx, bson.M{"product_id": objectID}, Sort{Field: "created_at", Order: SortDesc}, page, itemsPerPage)
+}
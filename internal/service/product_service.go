@@ -2,13 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"go-echo-mongo/internal/model"
 	"go-echo-mongo/internal/repository"
 	"go-echo-mongo/internal/repository/redisrepo"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -17,22 +23,39 @@ type ProductService interface {
 	BaseService[*model.Product]
 	GetByCategory(ctx context.Context, category string) ([]*model.Product, error)
 	UpdateStock(ctx context.Context, id string, quantity int32) error
+	RestockProduct(ctx context.Context, id string, quantity int32, restockedBy string) (*model.Product, error)
+	DecrementStock(ctx context.Context, id string, quantity int32) error
+	Reserve(ctx context.Context, id string, quantity int32, ttl time.Duration) (reservationID string, err error)
+	ReleaseReservation(ctx context.Context, reservationID string) error
+	CommitReservation(ctx context.Context, reservationID string) error
+	PatchProduct(ctx context.Context, id string, updates map[string]interface{}) (*model.Product, error)
+	GetProductWithOwner(ctx context.Context, id string) (*model.ProductWithOwner, error)
+	FindProductsWithOwner(ctx context.Context, filter map[string]interface{}) ([]*model.ProductWithOwner, error)
+	GetCategoryStats(ctx context.Context) ([]*model.CategoryStats, error)
+	GetProductHistory(ctx context.Context, id string, page, itemsPerPage int64) ([]*model.ProductRevision, int64, error)
 
 	// Batch operations
 	CreateProducts(ctx context.Context, products []*model.Product) error
 	FindProductsByFilter(ctx context.Context, filter map[string]interface{}, limit, skip int64) ([]*model.Product, error)
+	FindProductsByFilterPaginated(ctx context.Context, filter map[string]interface{}, sort Sort, page, itemsPerPage int64) ([]*model.Product, int64, error)
+	CountProductsByFilter(ctx context.Context, filter map[string]interface{}) (int64, error)
+	SearchProducts(ctx context.Context, search string, filter map[string]interface{}, page, itemsPerPage int64) ([]*model.Product, int64, error)
+	ExportProducts(ctx context.Context, filter map[string]interface{}, fields []string, fn func(*model.Product) error) error
 	UpdateProductsByFilter(ctx context.Context, filter map[string]interface{}, updates map[string]interface{}) (int64, error)
+	UpdateProductsByID(ctx context.Context, updates map[string]map[string]interface{}) (int64, error)
 	DeleteProductsByIDs(ctx context.Context, ids []string) (int64, error)
 }
 
 type productService struct {
 	BaseService[*model.Product]
-	repo  repository.ProductRepository
-	redis redisrepo.Repository
+	repo      repository.ProductRepository
+	redis     redisrepo.Repository
+	revisions repository.ProductRevisionRepository
 }
 
-// NewProductService creates a new ProductService instance
-func NewProductService(repo repository.ProductRepository, redis redisrepo.Repository) ProductService {
+// NewProductService creates a new ProductService instance.
+// If revisions is nil, updates are not recorded in the product history.
+func NewProductService(repo repository.ProductRepository, redis redisrepo.Repository, revisions repository.ProductRevisionRepository) ProductService {
 	if repo == nil {
 		log.Fatal(ErrNilRepository)
 	}
@@ -40,6 +63,7 @@ func NewProductService(repo repository.ProductRepository, redis redisrepo.Reposi
 		BaseService: newBaseService(repo),
 		repo:        repo,
 		redis:       redis,
+		revisions:   revisions,
 	}
 }
 
@@ -72,21 +96,162 @@ func (s *productService) GetByCategory(ctx context.Context, category string) ([]
 	return s.repo.FindByCategory(ctx, category)
 }
 
-// Update overrides base Update to add stock validation
+// Update overrides base Update so a product is never replaced as a whole. The non-zero editable
+// fields of updates (name, description, price, stock and category) are written with PatchProduct
+// and every other field keeps its stored value; set stock to zero with UpdateStock or PatchProduct.
 func (s *productService) Update(ctx context.Context, id string, updates *model.Product) error {
 	if err := validateContext(ctx); err != nil {
 		return err
 	}
 
-	if err := validateStock(updates.Stock); err != nil {
-		return err
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return ErrProductNotFound
 	}
 
-	if _, err := s.GetByID(ctx, id); err != nil {
-		return ErrProductNotFound
+	fields := make(map[string]interface{})
+	if updates.Name != "" {
+		fields["name"] = updates.Name
+	}
+	if updates.Description != "" {
+		fields["description"] = updates.Description
+	}
+	if updates.Price != 0 {
+		fields["price"] = updates.Price
+	}
+	if updates.Stock != 0 {
+		fields["stock"] = updates.Stock
+	}
+	if updates.Category != "" {
+		fields["category"] = updates.Category
+	}
+
+	_, err := s.PatchProduct(ctx, id, fields)
+	return err
+}
+
+// PatchProduct updates only the provided fields of a product and returns the updated product.
+// It never replaces the whole document, so omitted fields keep their values.
+func (s *productService) PatchProduct(ctx context.Context, id string, updates map[string]interface{}) (*model.Product, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if stock, ok := updates["stock"]; ok {
+		stockValue, isInt := stock.(int32)
+		if !isInt {
+			return nil, ErrInvalidStock
+		}
+		if err := validateStock(stockValue); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(updates) > 0 {
+		update := func() error {
+			return s.repo.UpdateFields(ctx, id, bson.M(updates))
+		}
+
+		var err error
+		if s.revisions == nil {
+			err = update()
+		} else {
+			var prior *model.Product
+			if prior, err = s.repo.FindByID(ctx, id); err != nil {
+				return nil, ErrProductNotFound
+			}
+			err = s.withRevision(ctx, prior, update)
+		}
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrProductNotFound
+			}
+			return nil, err
+		}
+	}
+
+	product, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	return product, nil
+}
+
+// Delete overrides base Delete to publish the deleted product
+func (s *productService) Delete(ctx context.Context, id string) error {
+	_, err := s.DeleteAndReturn(ctx, id)
+	return err
+}
+
+// DeleteAndReturn deletes a product, publishes a product.deleted event with its prior state and returns it
+func (s *productService) DeleteAndReturn(ctx context.Context, id string) (*model.Product, error) {
+	product, err := s.BaseService.DeleteAndReturn(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	publishEvent(ctx, s.redis, EventProductDeleted, product)
+
+	return product, nil
+}
+
+// GetMetadata overrides base GetMetadata to report a missing product as ErrProductNotFound
+func (s *productService) GetMetadata(ctx context.Context, id string) (model.Metadata, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	metadata, err := s.BaseService.GetMetadata(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	}
+	return metadata, err
+}
+
+// UpdateMetadata overrides base UpdateMetadata to report a missing product as ErrProductNotFound
+func (s *productService) UpdateMetadata(ctx context.Context, id string, updates model.Metadata) (model.Metadata, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	metadata, err := s.BaseService.UpdateMetadata(ctx, id, updates)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	}
+	return metadata, err
+}
+
+// GetProductWithOwner retrieves a product with its owner's public fields embedded
+func (s *productService) GetProductWithOwner(ctx context.Context, id string) (*model.ProductWithOwner, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrProductNotFound
 	}
 
-	return s.BaseService.Update(ctx, id, updates)
+	products, err := s.repo.FindProductsWithOwner(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	return products[0], nil
+}
+
+// FindProductsWithOwner retrieves products matching the filter with their owners embedded
+func (s *productService) FindProductsWithOwner(ctx context.Context, filter map[string]interface{}) ([]*model.ProductWithOwner, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.FindProductsWithOwner(ctx, buildProductFilter(filter))
 }
 
 // UpdateStock updates a product's stock quantity
@@ -104,9 +269,168 @@ func (s *productService) UpdateStock(ctx context.Context, id string, quantity in
 		return ErrProductNotFound
 	}
 
+	prior := *product
 	product.Stock = quantity
 
-	return s.BaseService.Update(ctx, id, product)
+	return s.withRevision(ctx, &prior, func() error {
+		return s.BaseService.Update(ctx, id, product)
+	})
+}
+
+// GetCategoryStats returns the total stock and average price of each product category
+func (s *productService) GetCategoryStats(ctx context.Context) ([]*model.CategoryStats, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.StatsByCategory(ctx)
+}
+
+// DecrementStock atomically removes quantity from a product's stock.
+// It returns ErrInsufficientStock instead of letting stock go negative or selling reserved stock.
+func (s *productService) DecrementStock(ctx context.Context, id string, quantity int32) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	if quantity <= 0 {
+		return ErrInvalidDecrement
+	}
+
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return ErrProductNotFound
+	}
+
+	prior, err := s.repo.DecrementStock(ctx, id, quantity)
+	if err != nil {
+		return stockError(err)
+	}
+	s.recordRevision(ctx, prior)
+	return nil
+}
+
+// stockError maps the repository errors of stock updates to service errors
+func stockError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return ErrInsufficientStock
+	case errors.Is(err, repository.ErrReservationNotFound):
+		return ErrReservationNotFound
+	default:
+		return err
+	}
+}
+
+// Reserve holds quantity of a product's stock for ttl, e.g. during checkout, without selling it.
+// Reserved stock cannot be sold by DecrementStock or reserved again until the reservation is
+// released, committed or expires; expired reservations release themselves. Reservations are
+// stored on the product, so the stock check and the hold are one atomic update.
+func (s *productService) Reserve(ctx context.Context, id string, quantity int32, ttl time.Duration) (string, error) {
+	if err := validateContext(ctx); err != nil {
+		return "", err
+	}
+
+	if quantity <= 0 || ttl <= 0 {
+		return "", ErrInvalidReservation
+	}
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return "", ErrProductNotFound
+	}
+
+	holdID, err := s.repo.Reserve(ctx, id, quantity, ttl)
+	if err != nil {
+		return "", stockError(err)
+	}
+	// The reservation ID names the product so it can be released or committed on its own
+	return id + "." + holdID, nil
+}
+
+// parseReservationID splits a reservation ID into the product and hold it refers to
+func parseReservationID(reservationID string) (productID, holdID string, err error) {
+	productID, holdID, ok := strings.Cut(reservationID, ".")
+	if !ok || productID == "" || holdID == "" {
+		return "", "", ErrReservationNotFound
+	}
+	return productID, holdID, nil
+}
+
+// ReleaseReservation cancels a reservation, returning its stock to what can be sold
+func (s *productService) ReleaseReservation(ctx context.Context, reservationID string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	id, holdID, err := parseReservationID(reservationID)
+	if err != nil {
+		return err
+	}
+	return stockError(s.repo.ReleaseReservation(ctx, id, holdID))
+}
+
+// CommitReservation turns a reservation into a sale by removing its quantity from stock.
+// The reservation is only released once its stock has been taken; if stock was lowered by
+// UpdateStock in the meantime, ErrInsufficientStock is returned and the reservation is kept.
+func (s *productService) CommitReservation(ctx context.Context, reservationID string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	id, holdID, err := parseReservationID(reservationID)
+	if err != nil {
+		return err
+	}
+	prior, err := s.repo.CommitReservation(ctx, id, holdID)
+	if err != nil {
+		return stockError(err)
+	}
+	s.recordRevision(ctx, prior)
+	return nil
+}
+
+// RestockProduct atomically adds quantity to a product's stock, records the restock and
+// publishes a product.restocked event
+func (s *productService) RestockProduct(ctx context.Context, id string, quantity int32, restockedBy string) (*model.Product, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if quantity <= 0 {
+		return nil, ErrInvalidRestock
+	}
+
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	restockedAt := time.Now().UTC()
+	prior, err := s.repo.Restock(ctx, id, quantity, restockedBy, restockedAt)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	s.recordRevision(WithEditor(ctx, restockedBy), prior)
+
+	// The restock was applied to exactly prior, so the result is prior with the restock applied
+	product := *prior
+	product.Stock += quantity
+	product.LastRestockedBy = restockedBy
+	product.LastRestockedAt = &restockedAt
+	product.SetUpdatedAt(restockedAt)
+
+	publishEvent(ctx, s.redis, EventProductRestocked, ProductRestocked{
+		ProductID:   product.ID.Hex(),
+		Quantity:    quantity,
+		Stock:       product.Stock,
+		RestockedBy: restockedBy,
+		RestockedAt: restockedAt,
+	})
+
+	return &product, nil
 }
 
 // CreateProducts creates multiple products with validation
@@ -115,8 +439,8 @@ func (s *productService) CreateProducts(ctx context.Context, products []*model.P
 		return err
 	}
 
-	if len(products) == 0 {
-		return ErrEmptyBatch
+	if err := validateBatchSize(len(products)); err != nil {
+		return err
 	}
 
 	// Validate stock for all products
@@ -130,11 +454,62 @@ func (s *productService) CreateProducts(ctx context.Context, products []*model.P
 }
 
 // FindProductsByFilter finds products by filter criteria
+// Without a limit at most the default find limit is returned
 func (s *productService) FindProductsByFilter(ctx context.Context, filter map[string]interface{}, limit, skip int64) ([]*model.Product, error) {
 	if err := validateContext(ctx); err != nil {
 		return nil, err
 	}
 
+	bsonFilter := buildProductFilter(filter)
+
+	return s.BaseService.FindMany(ctx, bsonFilter, filterFindOptions(limit, skip))
+}
+
+// FindProductsByFilterPaginated finds a sorted page of products by filter criteria along with the total match count
+func (s *productService) FindProductsByFilterPaginated(ctx context.Context, filter map[string]interface{}, sort Sort, page, itemsPerPage int64) ([]*model.Product, int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.BaseService.GetPaginated(ctx, buildProductFilter(filter), sort, page, itemsPerPage)
+}
+
+// CountProductsByFilter counts products matching the filter criteria
+func (s *productService) CountProductsByFilter(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return 0, err
+	}
+	return s.BaseService.Count(ctx, buildProductFilter(filter))
+}
+
+// ExportProducts streams products matching the filter to fn one at a time
+// If fields is not empty, only those fields are loaded from the database
+func (s *productService) ExportProducts(ctx context.Context, filter map[string]interface{}, fields []string, fn func(*model.Product) error) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if len(fields) > 0 {
+		projection := bson.M{}
+		for _, field := range fields {
+			if field == "id" {
+				field = "_id"
+			}
+			projection[field] = 1
+		}
+		opts.SetProjection(projection)
+	}
+
+	return s.BaseService.FindEach(ctx, buildProductFilter(filter), opts, fn)
+}
+
+// SearchProducts runs a text search over name and description, narrowed by the product filter
+func (s *productService) SearchProducts(ctx context.Context, search string, filter map[string]interface{}, page, itemsPerPage int64) ([]*model.Product, int64, error) {
+	return s.SearchPaginated(ctx, search, buildProductFilter(filter), page, itemsPerPage)
+}
+
+// buildProductFilter converts a filter map to a BSON filter, handling price range keys
+func buildProductFilter(filter map[string]interface{}) bson.M {
 	// Convert map to BSON filter
 	bsonFilter := bson.M{}
 
@@ -160,16 +535,7 @@ func (s *productService) FindProductsByFilter(ctx context.Context, filter map[st
 		}
 	}
 
-	// Set options
-	opts := options.Find()
-	if limit > 0 {
-		opts.SetLimit(limit)
-	}
-	if skip > 0 {
-		opts.SetSkip(skip)
-	}
-
-	return s.BaseService.FindMany(ctx, bsonFilter, opts)
+	return bsonFilter
 }
 
 // UpdateProductsByFilter updates multiple products matching the filter
@@ -199,14 +565,55 @@ func (s *productService) UpdateProductsByFilter(ctx context.Context, filter map[
 	return s.BaseService.UpdateMany(ctx, bsonFilter, bsonUpdate)
 }
 
+// UpdateProductsByID applies per-product updates keyed by product ID in a single bulk write
+func (s *productService) UpdateProductsByID(ctx context.Context, updates map[string]map[string]interface{}) (int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return 0, err
+	}
+
+	if err := validateBatchSize(len(updates)); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	writeModels := make([]mongo.WriteModel, 0, len(updates))
+	for id, productUpdates := range updates {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ID format: %s", id)
+		}
+
+		if stock, ok := productUpdates["stock"]; ok {
+			stockValue, isInt := stock.(int32)
+			if !isInt {
+				return 0, ErrInvalidStock
+			}
+			if err := validateStock(stockValue); err != nil {
+				return 0, err
+			}
+		}
+
+		set := bson.M{"updated_at": now}
+		for k, v := range productUpdates {
+			set[k] = v
+		}
+
+		writeModels = append(writeModels, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objID}).
+			SetUpdate(bson.M{"$set": set}))
+	}
+
+	return s.BaseService.UpdateMany(ctx, nil, writeModels)
+}
+
 // DeleteProductsByIDs deletes multiple products by their IDs
 func (s *productService) DeleteProductsByIDs(ctx context.Context, ids []string) (int64, error) {
 	if err := validateContext(ctx); err != nil {
 		return 0, err
 	}
 
-	if len(ids) == 0 {
-		return 0, ErrEmptyBatch
+	if err := validateBatchSize(len(ids)); err != nil {
+		return 0, err
 	}
 
 	// Convert string IDs to ObjectIDs
@@ -0,0 +1,1036 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/dto"
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/repository"
+	"go-echo-mongo/internal/repository/redisrepo"
+	"go-echo-mongo/pkg/web/validator"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeProductRepo is an in-memory ProductRepository; unimplemented methods panic
+type fakeProductRepo struct {
+	repository.ProductRepository
+	products map[string]*model.Product
+}
+
+func newFakeProductRepo(products ...*model.Product) *fakeProductRepo {
+	r := &fakeProductRepo{products: make(map[string]*model.Product)}
+	for _, p := range products {
+		clone := *p
+		r.products[p.ID.Hex()] = &clone
+	}
+	return r
+}
+
+func (r *fakeProductRepo) FindByID(ctx context.Context, id string) (*model.Product, error) {
+	p, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	clone := *p
+	return &clone, nil
+}
+
+func (r *fakeProductRepo) ExistsByID(ctx context.Context, id string) (bool, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, err
+	}
+	_, ok := r.products[id]
+	return ok, nil
+}
+
+// Update replaces a product but, like the real repository, keeps its stored reservations
+func (r *fakeProductRepo) Update(ctx context.Context, id string, product *model.Product) error {
+	stored, ok := r.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	clone := *product
+	clone.Reservations = stored.Reservations
+	r.products[id] = &clone
+	return nil
+}
+
+func (r *fakeProductRepo) UpdateFields(ctx context.Context, id string, fields bson.M) error {
+	p, ok := r.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for k, v := range fields {
+		switch k {
+		case "name":
+			p.Name = v.(string)
+		case "description":
+			p.Description = v.(string)
+		case "price":
+			p.Price = v.(float64)
+		case "stock":
+			p.Stock = v.(int32)
+		case "category":
+			p.Category = v.(string)
+		case "metadata":
+			p.Metadata = v.(model.Metadata)
+		}
+	}
+	return nil
+}
+
+func (r *fakeProductRepo) Restock(ctx context.Context, id string, quantity int32, restockedBy string, restockedAt time.Time) (*model.Product, error) {
+	p, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	prior := *p
+	p.Stock += quantity
+	p.LastRestockedBy = restockedBy
+	p.LastRestockedAt = &restockedAt
+	return &prior, nil
+}
+
+// reserved returns the stock held by p's unexpired reservations
+func reserved(p *model.Product, now time.Time) int32 {
+	var held int32
+	for _, hold := range p.Reservations {
+		if hold.ExpiresAt.After(now) {
+			held += hold.Quantity
+		}
+	}
+	return held
+}
+
+func (r *fakeProductRepo) DecrementStock(ctx context.Context, id string, quantity int32) (*model.Product, error) {
+	p, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if p.Stock-reserved(p, time.Now()) < quantity {
+		return nil, repository.ErrInsufficientStock
+	}
+	prior := *p
+	p.Stock -= quantity
+	return &prior, nil
+}
+
+func (r *fakeProductRepo) Reserve(ctx context.Context, id string, quantity int32, ttl time.Duration) (string, error) {
+	p, ok := r.products[id]
+	if !ok {
+		return "", repository.ErrNotFound
+	}
+	now := time.Now()
+	if p.Stock-reserved(p, now) < quantity {
+		return "", repository.ErrInsufficientStock
+	}
+	hold := model.StockReservation{ID: primitive.NewObjectID().Hex(), Quantity: quantity, ExpiresAt: now.Add(ttl)}
+	p.Reservations = append(p.Reservations, hold)
+	return hold.ID, nil
+}
+
+// takeHold removes the unexpired hold holdID from product id, keeping it if take fails,
+// and returns the product as it was before
+func (r *fakeProductRepo) takeHold(id, holdID string, take func(p *model.Product, hold model.StockReservation) error) (*model.Product, error) {
+	p, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrReservationNotFound
+	}
+	for i, hold := range p.Reservations {
+		if hold.ID == holdID && hold.ExpiresAt.After(time.Now()) {
+			prior := *p
+			if err := take(p, hold); err != nil {
+				return nil, err
+			}
+			p.Reservations = append(p.Reservations[:i:i], p.Reservations[i+1:]...)
+			return &prior, nil
+		}
+	}
+	return nil, repository.ErrReservationNotFound
+}
+
+func (r *fakeProductRepo) ReleaseReservation(ctx context.Context, id, holdID string) error {
+	_, err := r.takeHold(id, holdID, func(*model.Product, model.StockReservation) error { return nil })
+	return err
+}
+
+func (r *fakeProductRepo) CommitReservation(ctx context.Context, id, holdID string) (*model.Product, error) {
+	return r.takeHold(id, holdID, func(p *model.Product, hold model.StockReservation) error {
+		if p.Stock < hold.Quantity {
+			return repository.ErrInsufficientStock
+		}
+		p.Stock -= hold.Quantity
+		return nil
+	})
+}
+
+func (r *fakeProductRepo) DeleteAndReturn(ctx context.Context, id string) (*model.Product, error) {
+	p, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(r.products, id)
+	return p, nil
+}
+
+// fakePublisher records published messages; other Redis methods panic
+type fakePublisher struct {
+	redisrepo.Repository
+	messages map[string][]interface{}
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, channel string, message interface{}) error {
+	if p.messages == nil {
+		p.messages = make(map[string][]interface{})
+	}
+	p.messages[channel] = append(p.messages[channel], message)
+	return nil
+}
+
+func newTestProduct() *model.Product {
+	p := &model.Product{
+		Name:     "Keyboard",
+		Price:    49.99,
+		Stock:    12,
+		Category: "electronics",
+	}
+	p.ID = primitive.NewObjectID()
+	return p
+}
+
+func TestProductDeleteAndReturnReturnsStoredProduct(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	pub := &fakePublisher{}
+	svc := NewProductService(repo, pub, nil)
+
+	deleted, err := svc.DeleteAndReturn(context.Background(), stored.ID.Hex())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted.ID != stored.ID || deleted.Name != stored.Name || deleted.Price != stored.Price || deleted.Stock != stored.Stock {
+		t.Errorf("expected deleted product %+v, got %+v", stored, deleted)
+	}
+	if _, ok := repo.products[stored.ID.Hex()]; ok {
+		t.Error("expected product to be removed")
+	}
+
+	events := pub.messages[EventProductDeleted]
+	if len(events) != 1 {
+		t.Fatalf("expected 1 %s event, got %d", EventProductDeleted, len(events))
+	}
+	var event struct {
+		Type string        `json:"type"`
+		Data model.Product `json:"data"`
+	}
+	if err := json.Unmarshal(events[0].([]byte), &event); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if event.Type != EventProductDeleted || event.Data.ID != stored.ID || event.Data.Stock != stored.Stock {
+		t.Errorf("expected event with prior product state, got %+v", event)
+	}
+}
+
+func TestProductDeleteAndReturnNotFound(t *testing.T) {
+	pub := &fakePublisher{}
+	svc := NewProductService(newFakeProductRepo(), pub, nil)
+
+	_, err := svc.DeleteAndReturn(context.Background(), primitive.NewObjectID().Hex())
+	if !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+	if len(pub.messages) != 0 {
+		t.Error("expected no event for a missing product")
+	}
+}
+
+func TestProductPatchOnlyPriceKeepsStock(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(repo, &fakePublisher{}, nil)
+
+	var req dto.UpdateProductRequest
+	if err := json.Unmarshal([]byte(`{"price": 59.99}`), &req); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	updated, err := svc.PatchProduct(context.Background(), stored.ID.Hex(), req.ToUpdates())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Price != 59.99 {
+		t.Errorf("expected price 59.99, got %v", updated.Price)
+	}
+	if updated.Stock != stored.Stock {
+		t.Errorf("expected stock to stay %d, got %d", stored.Stock, updated.Stock)
+	}
+	if updated.Name != stored.Name || updated.Category != stored.Category {
+		t.Errorf("expected other fields unchanged, got %+v", updated)
+	}
+}
+
+func TestProductPatchRejectsNegativeStock(t *testing.T) {
+	stored := newTestProduct()
+	svc := NewProductService(newFakeProductRepo(stored), &fakePublisher{}, nil)
+
+	_, err := svc.PatchProduct(context.Background(), stored.ID.Hex(), map[string]interface{}{"stock": int32(-1)})
+	if !errors.Is(err, ErrInvalidStock) {
+		t.Errorf("expected ErrInvalidStock, got %v", err)
+	}
+}
+
+// cursorRepo pages over a fixed, ID-ordered product list
+type cursorRepo struct {
+	repository.ProductRepository
+	products []*model.Product
+	afterIDs []string
+}
+
+func (r *cursorRepo) FindCursorPaginated(ctx context.Context, filter interface{}, sortField string, afterID string, limit int64) ([]*model.Product, string, error) {
+	r.afterIDs = append(r.afterIDs, afterID)
+	start := 0
+	for i, p := range r.products {
+		if p.ID.Hex() == afterID {
+			start = i + 1
+		}
+	}
+	end := min(start+int(limit), len(r.products))
+	page := r.products[start:end]
+	if end == len(r.products) {
+		return page, "", nil
+	}
+	return page, page[len(page)-1].ID.Hex(), nil
+}
+
+func TestGetCursorPaginatedSignsCursors(t *testing.T) {
+	repo := &cursorRepo{}
+	for i := 0; i < 3; i++ {
+		p := &model.Product{Name: "Product"}
+		p.ID = primitive.NewObjectID()
+		repo.products = append(repo.products, p)
+	}
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+
+	first, next, err := svc.GetCursorPaginated(ctx, nil, "", "", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != 2 || next == "" || next == first[1].ID.Hex() {
+		t.Fatalf("expected 2 products and an opaque cursor, got %d and %q", len(first), next)
+	}
+
+	last, next, err := svc.GetCursorPaginated(ctx, nil, "", next, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(last) != 1 || next != "" {
+		t.Errorf("expected the last product and no cursor, got %d and %q", len(last), next)
+	}
+	if repo.afterIDs[1] != first[1].ID.Hex() {
+		t.Errorf("expected the cursor to resume after %s, got %s", first[1].ID.Hex(), repo.afterIDs[1])
+	}
+
+	// A raw ID or a cursor issued for another sort order is rejected
+	if _, _, err := svc.GetCursorPaginated(ctx, nil, "", first[1].ID.Hex(), 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for an unsigned cursor, got %v", err)
+	}
+	priceCursor, _ := encodeCursor(first[1].ID.Hex(), "price")
+	if _, _, err := svc.GetCursorPaginated(ctx, nil, "", priceCursor, 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for a cursor from another sort, got %v", err)
+	}
+}
+
+func TestRestockProductIncreasesStockAndPublishesEvent(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	pub := &fakePublisher{}
+	svc := NewProductService(repo, pub, nil)
+
+	restocked, err := svc.RestockProduct(context.Background(), stored.ID.Hex(), 8, "manager-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restocked.Stock != stored.Stock+8 {
+		t.Errorf("expected stock %d, got %d", stored.Stock+8, restocked.Stock)
+	}
+	if restocked.LastRestockedBy != "manager-1" || restocked.LastRestockedAt == nil {
+		t.Errorf("expected restock audit fields to be set, got %+v", restocked)
+	}
+
+	events := pub.messages[EventProductRestocked]
+	if len(events) != 1 {
+		t.Fatalf("expected 1 %s event, got %d", EventProductRestocked, len(events))
+	}
+	var event struct {
+		Type string           `json:"type"`
+		Data ProductRestocked `json:"data"`
+	}
+	if err := json.Unmarshal(events[0].([]byte), &event); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if event.Type != EventProductRestocked || event.Data.ProductID != stored.ID.Hex() ||
+		event.Data.Quantity != 8 || event.Data.Stock != stored.Stock+8 || event.Data.RestockedBy != "manager-1" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestRestockProductRejectsNonPositiveQuantity(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	pub := &fakePublisher{}
+	svc := NewProductService(repo, pub, nil)
+
+	for _, quantity := range []int32{0, -5} {
+		if _, err := svc.RestockProduct(context.Background(), stored.ID.Hex(), quantity, "manager-1"); !errors.Is(err, ErrInvalidRestock) {
+			t.Errorf("quantity %d: expected ErrInvalidRestock, got %v", quantity, err)
+		}
+	}
+	if repo.products[stored.ID.Hex()].Stock != stored.Stock {
+		t.Error("expected stock to be unchanged")
+	}
+	if len(pub.messages) != 0 {
+		t.Error("expected no events for rejected restocks")
+	}
+}
+
+// findOptionsRepo records the filter and options passed to FindMany and FindEach
+type findOptionsRepo struct {
+	repository.ProductRepository
+	filter   interface{}
+	opts     *options.FindOptions
+	products []*model.Product
+}
+
+func (r *findOptionsRepo) FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]*model.Product, error) {
+	r.filter, r.opts = filter, opts
+	return nil, nil
+}
+
+// FindEach yields every stored product, ignoring the filter
+func (r *findOptionsRepo) FindEach(ctx context.Context, filter interface{}, opts *options.FindOptions, fn func(*model.Product) error) error {
+	r.filter, r.opts = filter, opts
+	for _, p := range r.products {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestFindProductsByFilterAppliesDefaultLimit(t *testing.T) {
+	repo := &findOptionsRepo{}
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+
+	if _, err := svc.FindProductsByFilter(ctx, map[string]interface{}{"category": "books"}, 0, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.opts == nil || repo.opts.Limit == nil || *repo.opts.Limit != DefaultFindLimit {
+		t.Fatalf("expected default limit %d, got %+v", DefaultFindLimit, repo.opts)
+	}
+
+	SetDefaultFindLimit(25)
+	defer SetDefaultFindLimit(DefaultFindLimit)
+	if _, err := svc.FindProductsByFilter(ctx, nil, 0, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *repo.opts.Limit != 25 || *repo.opts.Skip != 5 {
+		t.Errorf("expected limit 25 and skip 5, got %d and %d", *repo.opts.Limit, *repo.opts.Skip)
+	}
+
+	if _, err := svc.FindProductsByFilter(ctx, nil, 10, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *repo.opts.Limit != 10 {
+		t.Errorf("expected explicit limit 10 to be kept, got %d", *repo.opts.Limit)
+	}
+}
+
+func TestExportProducts(t *testing.T) {
+	repo := &findOptionsRepo{products: []*model.Product{{Name: "Book"}, {Name: "Novel"}}}
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+
+	var names []string
+	collect := func(p *model.Product) error {
+		names = append(names, p.Name)
+		return nil
+	}
+	filter := map[string]interface{}{"category": "books", "min_price": 5.0}
+	if err := svc.ExportProducts(ctx, filter, []string{"id", "name"}, collect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(names, []string{"Book", "Novel"}) {
+		t.Errorf("expected every product to be streamed in order, got %v", names)
+	}
+	wantFilter := bson.M{"category": "books", "price": bson.M{"$gte": 5.0}}
+	if !reflect.DeepEqual(repo.filter, wantFilter) {
+		t.Errorf("expected filter %v, got %v", wantFilter, repo.filter)
+	}
+	if want := (bson.M{"_id": 1, "name": 1}); !reflect.DeepEqual(repo.opts.Projection, want) {
+		t.Errorf("expected projection %v, got %v", want, repo.opts.Projection)
+	}
+	if want := (bson.D{{Key: "_id", Value: 1}}); !reflect.DeepEqual(repo.opts.Sort, want) {
+		t.Errorf("expected a stable _id sort, got %v", repo.opts.Sort)
+	}
+
+	// Without fields every field is loaded; an error from fn stops the export
+	stop := errors.New("stop")
+	calls := 0
+	err := svc.ExportProducts(ctx, nil, nil, func(p *model.Product) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the export to stop at the first error, got %v after %d calls", err, calls)
+	}
+	if repo.opts.Projection != nil {
+		t.Errorf("expected no projection, got %v", repo.opts.Projection)
+	}
+}
+
+// searchRepo ranks stored products by how often the search term occurs in their name,
+// like a text index weighting names, and records the filter it was given
+type searchRepo struct {
+	repository.ProductRepository
+	products []*model.Product
+	filter   bson.M
+	calls    int
+}
+
+func (r *searchRepo) SearchPaginated(ctx context.Context, search string, filter bson.M, page, itemsPerPage int64) ([]*model.Product, int64, error) {
+	r.calls++
+	r.filter = filter
+	var matched []*model.Product
+	for _, p := range r.products {
+		if strings.Contains(strings.ToLower(p.Name), search) && (filter["category"] == nil || p.Category == filter["category"]) {
+			matched = append(matched, p)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b *model.Product) int {
+		return strings.Count(strings.ToLower(b.Name), search) - strings.Count(strings.ToLower(a.Name), search)
+	})
+	start := min((page-1)*itemsPerPage, int64(len(matched)))
+	end := min(start+itemsPerPage, int64(len(matched)))
+	return matched[start:end], int64(len(matched)), nil
+}
+
+func TestSearchProducts(t *testing.T) {
+	repo := &searchRepo{products: []*model.Product{
+		{Name: "Keyboard", Category: "office"},
+		{Name: "Keyboard keyboard cover", Category: "office"},
+		{Name: "Keyboard stand", Category: "music"},
+		{Name: "Monitor", Category: "office"},
+	}}
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+
+	for _, search := range []string{"", "   "} {
+		if _, _, err := svc.SearchProducts(ctx, search, nil, 1, 10); !errors.Is(err, ErrEmptySearch) {
+			t.Errorf("search %q: expected ErrEmptySearch, got %v", search, err)
+		}
+	}
+	if repo.calls != 0 {
+		t.Fatalf("expected blank searches not to reach the repository, got %d calls", repo.calls)
+	}
+
+	products, total, err := svc.SearchProducts(ctx, "keyboard", map[string]interface{}{"category": "office"}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != 2 || len(products) != 2 || products[0].Name != "Keyboard keyboard cover" {
+		t.Errorf("expected the 2 office keyboards, best match first, got %d %+v", total, products)
+	}
+
+	// Price bounds are turned into a range on the price field and pagination is passed through
+	products, total, err = svc.SearchProducts(ctx, "keyboard", map[string]interface{}{"min_price": 5.0, "max_price": 50.0}, 2, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != 3 || len(products) != 1 || products[0].Name != "Keyboard stand" {
+		t.Errorf("expected the last of 3 matches on page 2, got %d %+v", total, products)
+	}
+	if want := (bson.M{"price": bson.M{"$gte": 5.0, "$lte": 50.0}}); !reflect.DeepEqual(repo.filter, want) {
+		t.Errorf("expected filter %v, got %v", want, repo.filter)
+	}
+}
+
+func TestBatchOperationsEnforceMaxBatchSize(t *testing.T) {
+	validator.SetMaxBatchSize(2)
+	defer validator.SetMaxBatchSize(validator.DefaultMaxBatchSize)
+
+	if err := validateBatchSize(2); err != nil {
+		t.Errorf("expected a batch at the limit to be accepted, got %v", err)
+	}
+	if err := validateBatchSize(0); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("expected ErrEmptyBatch, got %v", err)
+	}
+
+	users := NewUserService(newFakeUserRepo(), nil, nil, nil)
+	products := NewProductService(newFakeProductRepo(), nil, nil)
+	ctx := context.Background()
+	ids := []string{primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()}
+	updates := make(map[string]map[string]interface{}, len(ids))
+	for _, id := range ids {
+		updates[id] = map[string]interface{}{"name": "Renamed"}
+	}
+
+	ops := map[string]func() error{
+		"CreateUsers": func() error {
+			return users.CreateUsers(ctx, []*model.User{{Email: "a@example.com"}, {Email: "b@example.com"}, {Email: "c@example.com"}})
+		},
+		"UpdateUsersByFilter": func() error {
+			_, err := users.UpdateUsersByFilter(ctx, updates, nil)
+			return err
+		},
+		"DeleteUsersByIDs": func() error {
+			_, err := users.DeleteUsersByIDs(ctx, ids)
+			return err
+		},
+		"CreateProducts": func() error {
+			return products.CreateProducts(ctx, []*model.Product{{Stock: 1}, {Stock: 1}, {Stock: 1}})
+		},
+		"UpdateProductsByID": func() error {
+			_, err := products.UpdateProductsByID(ctx, updates)
+			return err
+		},
+		"DeleteProductsByIDs": func() error {
+			_, err := products.DeleteProductsByIDs(ctx, ids)
+			return err
+		},
+		"UpsertMany": func() error {
+			_, err := products.UpsertMany(ctx, []*model.Product{{}, {}, {}}, []string{"name", "category"})
+			return err
+		},
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, ErrBatchTooLarge) {
+			t.Errorf("%s: expected ErrBatchTooLarge for 3 items, got %v", name, err)
+		}
+	}
+}
+
+func TestDecrementStock(t *testing.T) {
+	stored := &model.Product{Name: "Keyboard", Stock: 3}
+	stored.ID = primitive.NewObjectID()
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+	id := stored.ID.Hex()
+
+	if err := svc.DecrementStock(ctx, id, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := repo.products[id].Stock; got != 1 {
+		t.Errorf("expected stock 1, got %d", got)
+	}
+
+	tests := []struct {
+		id       string
+		quantity int32
+		want     error
+	}{
+		{id, 2, ErrInsufficientStock},
+		{id, 0, ErrInvalidDecrement},
+		{primitive.NewObjectID().Hex(), 1, ErrProductNotFound},
+		{"not-an-id", 1, ErrProductNotFound},
+	}
+	for _, tt := range tests {
+		if err := svc.DecrementStock(ctx, tt.id, tt.quantity); !errors.Is(err, tt.want) {
+			t.Errorf("DecrementStock(%q, %d): expected %v, got %v", tt.id, tt.quantity, tt.want, err)
+		}
+	}
+	if got := repo.products[id].Stock; got != 1 {
+		t.Errorf("expected failed decrements to leave stock at 1, got %d", got)
+	}
+}
+
+func TestReserveHoldsStockUntilReleased(t *testing.T) {
+	stored := &model.Product{Name: "Keyboard", Stock: 5}
+	stored.ID = primitive.NewObjectID()
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+	id := stored.ID.Hex()
+
+	reservation, err := svc.Reserve(ctx, id, 3, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	if got := repo.products[id].Stock; got != 5 {
+		t.Errorf("expected a reservation to leave stock at 5, got %d", got)
+	}
+
+	// Only the 2 unreserved can be reserved or sold
+	if _, err := svc.Reserve(ctx, id, 3, time.Minute); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("expected reserved stock not to be reserved again, got %v", err)
+	}
+	if err := svc.DecrementStock(ctx, id, 3); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("expected reserved stock not to be sold, got %v", err)
+	}
+	if err := svc.DecrementStock(ctx, id, 2); err != nil {
+		t.Fatalf("expected unreserved stock to be sold, got %v", err)
+	}
+
+	if err := svc.ReleaseReservation(ctx, reservation); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if err := svc.ReleaseReservation(ctx, reservation); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("expected a second release to fail, got %v", err)
+	}
+	if err := svc.DecrementStock(ctx, id, 3); err != nil {
+		t.Errorf("expected released stock to be sold, got %v", err)
+	}
+
+	tests := []struct {
+		id       string
+		quantity int32
+		ttl      time.Duration
+		want     error
+	}{
+		{id, 0, time.Minute, ErrInvalidReservation},
+		{id, 1, 0, ErrInvalidReservation},
+		{primitive.NewObjectID().Hex(), 1, time.Minute, ErrProductNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := svc.Reserve(ctx, tt.id, tt.quantity, tt.ttl); !errors.Is(err, tt.want) {
+			t.Errorf("Reserve(%q, %d, %v): expected %v, got %v", tt.id, tt.quantity, tt.ttl, tt.want, err)
+		}
+	}
+
+}
+
+func TestReserveExpiresAutomatically(t *testing.T) {
+	stored := &model.Product{Name: "Keyboard", Stock: 2}
+	stored.ID = primitive.NewObjectID()
+	svc := NewProductService(newFakeProductRepo(stored), nil, nil)
+	ctx := context.Background()
+	id := stored.ID.Hex()
+
+	reservation, err := svc.Reserve(ctx, id, 2, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	if err := svc.DecrementStock(ctx, id, 1); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("expected the reservation to hold the stock, got %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if err := svc.CommitReservation(ctx, reservation); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("expected an expired reservation not to be committed, got %v", err)
+	}
+	if _, err := svc.Reserve(ctx, id, 2, time.Minute); err != nil {
+		t.Errorf("expected the expired reservation's stock to be reservable, got %v", err)
+	}
+}
+
+func TestCommitReservationTakesStock(t *testing.T) {
+	stored := &model.Product{Name: "Keyboard", Stock: 5}
+	stored.ID = primitive.NewObjectID()
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+	id := stored.ID.Hex()
+
+	first, _ := svc.Reserve(ctx, id, 3, time.Minute)
+	if _, err := svc.Reserve(ctx, id, 2, time.Minute); err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+
+	if err := svc.CommitReservation(ctx, first); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if got := repo.products[id].Stock; got != 2 {
+		t.Errorf("expected the committed 3 to leave stock at 2, got %d", got)
+	}
+	if err := svc.CommitReservation(ctx, first); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("expected a reservation to be committed once, got %v", err)
+	}
+
+	// The remaining 2 are still held by the other reservation
+	if err := svc.DecrementStock(ctx, id, 1); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("expected the other reservation to keep its stock, got %v", err)
+	}
+}
+
+func TestCommitReservationKeepsHoldWhenStockWasLowered(t *testing.T) {
+	stored := &model.Product{Name: "Keyboard", Stock: 5}
+	stored.ID = primitive.NewObjectID()
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+	id := stored.ID.Hex()
+
+	reservation, err := svc.Reserve(ctx, id, 3, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	if err := svc.UpdateStock(ctx, id, 2); err != nil {
+		t.Fatalf("failed to update stock: %v", err)
+	}
+
+	if err := svc.CommitReservation(ctx, reservation); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("expected the commit to fail for lack of stock, got %v", err)
+	}
+	if got := repo.products[id].Stock; got != 2 {
+		t.Errorf("expected a failed commit to leave stock at 2, got %d", got)
+	}
+
+	if _, err := svc.RestockProduct(ctx, id, 1, ""); err != nil {
+		t.Fatalf("failed to restock: %v", err)
+	}
+	if err := svc.CommitReservation(ctx, reservation); err != nil {
+		t.Errorf("expected the kept reservation to be committed once stock is back, got %v", err)
+	}
+}
+
+func TestProductUpdateChecksExistence(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(repo, nil, nil)
+	ctx := context.Background()
+
+	updates := &model.Product{Name: "Mechanical keyboard", Price: 89.99, Stock: 5, Category: "electronics"}
+	if err := svc.Update(ctx, stored.ID.Hex(), updates); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := repo.products[stored.ID.Hex()]; got.Name != updates.Name || got.Stock != 5 {
+		t.Errorf("expected product to be updated, got %+v", got)
+	}
+
+	for _, id := range []string{primitive.NewObjectID().Hex(), "not-an-id"} {
+		if err := svc.Update(ctx, id, updates); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("Update(%q): expected ErrProductNotFound, got %v", id, err)
+		}
+	}
+}
+
+func TestProductUpdateKeepsOmittedFields(t *testing.T) {
+	stored := newTestProduct()
+	stored.OwnerID = primitive.NewObjectID()
+	stored.Metadata = model.Metadata{"color": "black"}
+	repo := newFakeProductRepo(stored)
+	svc := NewProductService(fieldsOnlyProductRepo{repo, t}, nil, nil)
+	ctx := context.Background()
+
+	if err := svc.Update(ctx, stored.ID.Hex(), &model.Product{Name: "Mechanical keyboard"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := repo.products[stored.ID.Hex()]
+	if got.Name != "Mechanical keyboard" {
+		t.Errorf("expected the name to be updated, got %q", got.Name)
+	}
+	if got.Stock != stored.Stock || got.Price != stored.Price || got.Description != stored.Description || got.Category != stored.Category {
+		t.Errorf("expected omitted fields to keep their values, got %+v", got)
+	}
+	if got.OwnerID != stored.OwnerID || got.Metadata["color"] != "black" {
+		t.Errorf("expected the owner and metadata to be kept, got %v and %v", got.OwnerID, got.Metadata)
+	}
+
+	if err := svc.Update(ctx, stored.ID.Hex(), &model.Product{Stock: -1}); !errors.Is(err, ErrInvalidStock) {
+		t.Errorf("expected ErrInvalidStock for negative stock, got %v", err)
+	}
+}
+
+// fakeRevisionRepo is an in-memory ProductRevisionRepository; unimplemented methods panic
+type fakeRevisionRepo struct {
+	repository.ProductRevisionRepository
+	revisions []*model.ProductRevision
+}
+
+func (r *fakeRevisionRepo) Create(ctx context.Context, revision *model.ProductRevision) error {
+	revision.ID = primitive.NewObjectID()
+	revision.CreatedAt = time.Now().UTC()
+	r.revisions = append(r.revisions, revision)
+	return nil
+}
+
+func (r *fakeRevisionRepo) Delete(ctx context.Context, id string) error {
+	for i, revision := range r.revisions {
+		if revision.ID.Hex() == id {
+			r.revisions = append(r.revisions[:i], r.revisions[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+// FindPaginated only supports the product_id filter and returns revisions newest first
+func (r *fakeRevisionRepo) FindPaginated(ctx context.Context, filter interface{}, sort Sort, page, itemsPerPage int64) ([]*model.ProductRevision, int64, error) {
+	productID := filter.(bson.M)["product_id"]
+	var matched []*model.ProductRevision
+	for i := len(r.revisions) - 1; i >= 0; i-- {
+		if r.revisions[i].ProductID == productID {
+			matched = append(matched, r.revisions[i])
+		}
+	}
+	start := min((page-1)*itemsPerPage, int64(len(matched)))
+	end := min(start+itemsPerPage, int64(len(matched)))
+	return matched[start:end], int64(len(matched)), nil
+}
+
+func TestProductUpdatesAppendRevisions(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	revisions := &fakeRevisionRepo{}
+	svc := NewProductService(repo, nil, revisions)
+	ctx := WithEditor(context.Background(), "editor-1")
+	id := stored.ID.Hex()
+
+	updates := *stored
+	updates.Name = "Mechanical keyboard"
+	if err := svc.Update(ctx, id, &updates); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	if _, err := svc.PatchProduct(ctx, id, map[string]interface{}{"price": 59.99}); err != nil {
+		t.Fatalf("PatchProduct: unexpected error: %v", err)
+	}
+	if err := svc.UpdateStock(context.Background(), id, 3); err != nil {
+		t.Fatalf("UpdateStock: unexpected error: %v", err)
+	}
+
+	if len(revisions.revisions) != 3 {
+		t.Fatalf("expected one revision per update, got %d", len(revisions.revisions))
+	}
+	// Each revision holds the state before its update
+	wantNames := []string{"Keyboard", "Mechanical keyboard", "Mechanical keyboard"}
+	wantPrices := []float64{49.99, 49.99, 59.99}
+	wantEditors := []string{"editor-1", "editor-1", ""}
+	for i, revision := range revisions.revisions {
+		if revision.ProductID != stored.ID || revision.Snapshot.Name != wantNames[i] || revision.Snapshot.Price != wantPrices[i] {
+			t.Errorf("revision %d: expected %q at %v, got %+v", i, wantNames[i], wantPrices[i], revision.Snapshot)
+		}
+		if revision.EditedBy != wantEditors[i] {
+			t.Errorf("revision %d: expected editor %q, got %q", i, wantEditors[i], revision.EditedBy)
+		}
+	}
+
+	history, total, err := svc.GetProductHistory(ctx, id, 1, 2)
+	if err != nil {
+		t.Fatalf("GetProductHistory: unexpected error: %v", err)
+	}
+	if total != 3 || len(history) != 2 || history[0].Snapshot.Stock != stored.Stock || history[0].Snapshot.Price != 59.99 {
+		t.Errorf("expected the newest of 3 revisions first, got %d total and %+v", total, history)
+	}
+
+	// A rejected update leaves the history alone
+	if err := svc.Update(ctx, primitive.NewObjectID().Hex(), &updates); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+	if len(revisions.revisions) != 3 {
+		t.Errorf("expected no revision for a failed update, got %d", len(revisions.revisions))
+	}
+	if _, _, err := svc.GetProductHistory(ctx, "not-an-id", 1, 10); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound for a malformed ID, got %v", err)
+	}
+}
+
+func TestStockChangesAppendRevisions(t *testing.T) {
+	stored := newTestProduct()
+	repo := newFakeProductRepo(stored)
+	revisions := &fakeRevisionRepo{}
+	svc := NewProductService(repo, nil, revisions)
+	ctx := WithEditor(context.Background(), "editor-1")
+	id := stored.ID.Hex()
+
+	if _, err := svc.RestockProduct(ctx, id, 8, "manager-1"); err != nil {
+		t.Fatalf("RestockProduct: unexpected error: %v", err)
+	}
+	if err := svc.DecrementStock(ctx, id, 5); err != nil {
+		t.Fatalf("DecrementStock: unexpected error: %v", err)
+	}
+	reservationID, err := svc.Reserve(ctx, id, 4, time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve: unexpected error: %v", err)
+	}
+	if err := svc.CommitReservation(ctx, reservationID); err != nil {
+		t.Fatalf("CommitReservation: unexpected error: %v", err)
+	}
+
+	// Each stock change records the stock before it; a restock is attributed to whoever restocked
+	wantStocks := []int32{stored.Stock, stored.Stock + 8, stored.Stock + 3}
+	wantEditors := []string{"manager-1", "editor-1", "editor-1"}
+	if len(revisions.revisions) != len(wantStocks) {
+		t.Fatalf("expected one revision per stock change, got %d", len(revisions.revisions))
+	}
+	for i, revision := range revisions.revisions {
+		if revision.ProductID != stored.ID || revision.Snapshot.Stock != wantStocks[i] || revision.EditedBy != wantEditors[i] {
+			t.Errorf("revision %d: expected stock %d by %q, got %d by %q", i, wantStocks[i], wantEditors[i], revision.Snapshot.Stock, revision.EditedBy)
+		}
+	}
+
+	// Rejected stock changes leave the history alone
+	if err := svc.DecrementStock(ctx, id, 100); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("expected ErrInsufficientStock, got %v", err)
+	}
+	if len(revisions.revisions) != len(wantStocks) {
+		t.Errorf("expected no revision for a rejected decrement, got %d", len(revisions.revisions))
+	}
+}
+
+func TestGetProductHistoryOfUnknownProduct(t *testing.T) {
+	for _, revisions := range []repository.ProductRevisionRepository{&fakeRevisionRepo{}, nil} {
+		svc := NewProductService(newFakeProductRepo(newTestProduct()), nil, revisions)
+		if _, _, err := svc.GetProductHistory(context.Background(), primitive.NewObjectID().Hex(), 1, 10); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("expected ErrProductNotFound for an unknown product, got %v", err)
+		}
+	}
+}
+
+// fieldsOnlyProductRepo fails the test if a product is replaced as a whole
+type fieldsOnlyProductRepo struct {
+	*fakeProductRepo
+	t *testing.T
+}
+
+func (r fieldsOnlyProductRepo) Update(ctx context.Context, id string, product *model.Product) error {
+	r.t.Errorf("expected only fields to be written, got a full replace of %s", id)
+	return r.fakeProductRepo.Update(ctx, id, product)
+}
+
+func TestProductUpdateMetadataWritesOnlyMetadata(t *testing.T) {
+	product := newTestProduct()
+	product.Metadata = model.Metadata{"color": "black", "size": "large"}
+	repo := newFakeProductRepo(product)
+	svc := NewProductService(fieldsOnlyProductRepo{repo, t}, nil, nil)
+	ctx := context.Background()
+
+	merged, err := svc.UpdateMetadata(ctx, product.ID.Hex(), model.Metadata{"color": "white", "size": nil})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged) != 1 || merged["color"] != "white" {
+		t.Errorf("expected the merged metadata {color: white}, got %v", merged)
+	}
+
+	stored := repo.products[product.ID.Hex()]
+	if len(stored.Metadata) != 1 || stored.Metadata["color"] != "white" {
+		t.Errorf("expected the merged metadata to be stored, got %v", stored.Metadata)
+	}
+}
+
+func TestProductMetadataOfUnknownProduct(t *testing.T) {
+	svc := NewProductService(newFakeProductRepo(newTestProduct()), nil, nil)
+	ctx := context.Background()
+
+	for _, id := range []string{primitive.NewObjectID().Hex(), "not-an-id"} {
+		if _, err := svc.GetMetadata(ctx, id); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("GetMetadata(%q): expected ErrProductNotFound, got %v", id, err)
+		}
+		if _, err := svc.UpdateMetadata(ctx, id, model.Metadata{"color": "black"}); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("UpdateMetadata(%q): expected ErrProductNotFound, got %v", id, err)
+		}
+	}
+}
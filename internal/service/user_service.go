@@ -2,36 +2,55 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"go-echo-mongo/internal/model"
 	"go-echo-mongo/internal/repository"
 	"go-echo-mongo/internal/repository/redisrepo"
 	"go-echo-mongo/pkg/secutil"
 	"go-echo-mongo/pkg/strutil"
+	"go-echo-mongo/pkg/web/mwutil"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // UserService defines the interface for user-related business logic
 type UserService interface {
 	BaseService[*model.User]
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	GetByApiKey(ctx context.Context, apiKey string) (*model.User, error)
-	ValidateCredentials(ctx context.Context, email, password string) (*model.User, error)
+	ValidateCredentials(ctx context.Context, identifier, password string) (*model.User, error)
+	VerifyTOTP(ctx context.Context, user *model.User, code string) error
+	ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error
+	RegenerateApiKey(ctx context.Context, id string) (string, error)
+	Impersonate(ctx context.Context, actorID, targetID, remoteIP string) (*model.User, error)
+	FindOrCreate(ctx context.Context, user *model.User) (created bool, result *model.User, err error)
 
 	// Role management
 	AddRoles(ctx context.Context, id string, roles []string) error
 	RemoveRoles(ctx context.Context, id string, roles []string) error
+	SetRoles(ctx context.Context, id string, roles []string) error
 	GetUsersByRole(ctx context.Context, role string) ([]*model.User, error)
 
+	// Scope management
+	AddScopes(ctx context.Context, id string, scopes []string) error
+	RemoveScopes(ctx context.Context, id string, scopes []string) error
+	SetScopes(ctx context.Context, id string, scopes []string) error
+
 	// Batch operations
 	CreateUsers(ctx context.Context, users []*model.User) error
 	FindUsersByFilter(ctx context.Context, filter map[string]interface{}, limit, skip int64) ([]*model.User, error)
+	FindUsersByFilterPaginated(ctx context.Context, filter map[string]interface{}, sort Sort, page, itemsPerPage int64) ([]*model.User, int64, error)
+	CountUsersByFilter(ctx context.Context, filter map[string]interface{}) (int64, error)
 	UpdateUsersByFilter(ctx context.Context, filter interface{}, updates interface{}) (int64, error)
 	DeleteUsersByIDs(ctx context.Context, ids []string) (int64, error)
 }
@@ -40,10 +59,14 @@ type userService struct {
 	BaseService[*model.User]
 	repo  repository.UserRepository
 	redis redisrepo.Repository
+	cache redisrepo.CacheRepository
+	audit repository.AuditLogRepository
 }
 
-// NewUserService creates a new UserService instance
-func NewUserService(repo repository.UserRepository, redis redisrepo.Repository) UserService {
+// NewUserService creates a new UserService instance.
+// cache may be nil; when set, cached API key lookups are invalidated on role changes.
+// audit may be nil, which disables impersonation since it could not be recorded.
+func NewUserService(repo repository.UserRepository, redis redisrepo.Repository, cache redisrepo.CacheRepository, audit repository.AuditLogRepository) UserService {
 	if repo == nil {
 		log.Fatal(ErrNilRepository)
 	}
@@ -51,6 +74,8 @@ func NewUserService(repo repository.UserRepository, redis redisrepo.Repository)
 		BaseService: newBaseService(repo),
 		repo:        repo,
 		redis:       redis,
+		cache:       cache,
+		audit:       audit,
 	}
 }
 
@@ -60,24 +85,34 @@ func (s *userService) Create(ctx context.Context, user *model.User) error {
 		return err
 	}
 
+	if err := validateRoles(user.Roles); err != nil {
+		return err
+	}
+
 	// Check for existing email
+	user.Email = model.NormalizeEmail(user.Email)
 	if existingUser, _ := s.GetByEmail(ctx, user.Email); existingUser != nil {
 		return ErrEmailExists
 	}
 
+	if err := s.checkUsername(ctx, user.Username, primitive.NilObjectID); err != nil {
+		return err
+	}
+
 	// Hash password
-	hashedPassword, err := secutil.HashPassword(user.Password)
+	hashedPassword, err := hashPassword(user.Password)
 	if err != nil {
 		return err
 	}
 	user.Password = hashedPassword
 
 	// Generate API key
-	apiKey, err := strutil.GenerateRandom(32, false, true, true, false)
+	apiKey, err := generateApiKey()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to generate API key: %w", err)
 	}
-	user.ApiKey = apiKey
+	user.ApiKey = repository.HashApiKey(apiKey)
+	user.IssuedApiKey = apiKey
 
 	// Ensure user has at least the basic user role if no roles are specified
 	if len(user.Roles) == 0 {
@@ -87,7 +122,62 @@ func (s *userService) Create(ctx context.Context, user *model.User) error {
 	return s.BaseService.Create(ctx, user)
 }
 
-// Update overrides base Update to handle email uniqueness and password hashing
+// FindOrCreate returns the user with user's email, creating it like Create if there is none.
+// The insert is an atomic upsert keyed on email, so concurrent syncs of the same user create
+// it once; created reports whether this call did. An existing user is returned unchanged.
+func (s *userService) FindOrCreate(ctx context.Context, user *model.User) (bool, *model.User, error) {
+	if err := validateContext(ctx); err != nil {
+		return false, nil, err
+	}
+
+	user.Email = model.NormalizeEmail(user.Email)
+	if existingUser, _ := s.GetByEmail(ctx, user.Email); existingUser != nil {
+		return false, existingUser, nil
+	}
+
+	if err := validateRoles(user.Roles); err != nil {
+		return false, nil, err
+	}
+	if err := s.checkUsername(ctx, user.Username, primitive.NilObjectID); err != nil {
+		return false, nil, err
+	}
+
+	hashedPassword, err := hashPassword(user.Password)
+	if err != nil {
+		return false, nil, err
+	}
+	user.Password = hashedPassword
+
+	apiKey, err := generateApiKey()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	user.ApiKey = repository.HashApiKey(apiKey)
+
+	if len(user.Roles) == 0 {
+		user.Roles = []string{model.RoleUser}
+	}
+
+	created, result, err := s.repo.FindOrCreate(ctx, user)
+	if err != nil {
+		// The email was checked above, so a conflict here is a username taken concurrently
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil, ErrUsernameExists
+		}
+		return false, nil, err
+	}
+	// The upsert matches soft-deleted users too; their email stays taken but they are not handed back
+	if !created && result.IsDeleted() {
+		return false, nil, ErrEmailExists
+	}
+	if created {
+		result.IssuedApiKey = apiKey
+	}
+	return created, result, nil
+}
+
+// Update overrides base Update to handle email and username uniqueness.
+// updates carries the stored password hash unchanged; ChangePassword is the only way to set a new one.
 func (s *userService) Update(ctx context.Context, id string, updates *model.User) error {
 	if err := validateContext(ctx); err != nil {
 		return err
@@ -99,19 +189,18 @@ func (s *userService) Update(ctx context.Context, id string, updates *model.User
 	}
 
 	// Check email uniqueness if it's being updated
+	updates.Email = model.NormalizeEmail(updates.Email)
 	if updates.Email != "" && updates.Email != existingUser.Email {
-		if emailUser, _ := s.GetByEmail(ctx, updates.Email); emailUser != nil {
+		if emailUser, _ := s.GetByEmail(ctx, updates.Email); emailUser != nil && emailUser.ID != existingUser.ID {
 			return ErrEmailExists
 		}
 	}
 
-	// Hash new password if provided
-	if updates.Password != "" {
-		hashedPassword, err := secutil.HashPassword(updates.Password)
-		if err != nil {
+	// Check username uniqueness if it's being updated
+	if updates.Username != "" && !strings.EqualFold(updates.Username, existingUser.Username) {
+		if err := s.checkUsername(ctx, updates.Username, existingUser.ID); err != nil {
 			return err
 		}
-		updates.Password = hashedPassword
 	}
 
 	return s.BaseService.Update(ctx, id, updates)
@@ -125,21 +214,58 @@ func (s *userService) GetByEmail(ctx context.Context, email string) (*model.User
 	return s.repo.FindByEmail(ctx, email)
 }
 
-// GetByApiKey retrieves a user by their API key
+// GetByUsername retrieves a user by their username, ignoring case
+func (s *userService) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByUsername(ctx, username)
+}
+
+// checkUsername validates an optional username and makes sure no other user holds it, ignoring case.
+// self is the user the username is for, or primitive.NilObjectID for a new user.
+func (s *userService) checkUsername(ctx context.Context, username string, self primitive.ObjectID) error {
+	if username == "" {
+		return nil
+	}
+	if !strutil.IsUsername(username) {
+		return ErrInvalidUsername
+	}
+	if existing, _ := s.GetByUsername(ctx, username); existing != nil && existing.ID != self {
+		return ErrUsernameExists
+	}
+	return nil
+}
+
+// GetByApiKey retrieves a user by their API key. Keys issued for another environment
+// are rejected without a lookup; keys without an environment prefix are looked up as is.
 func (s *userService) GetByApiKey(ctx context.Context, apiKey string) (*model.User, error) {
 	if err := validateContext(ctx); err != nil {
 		return nil, err
 	}
+
+	apiKey = strings.TrimSpace(apiKey)
+	if env, ok := ApiKeyEnvironment(apiKey); ok && env != apiKeyEnv.Load().(string) {
+		return nil, ErrApiKeyEnvironment
+	}
 	return s.repo.FindByApiKey(ctx, apiKey)
 }
 
-// ValidateCredentials validates user credentials and returns the user if valid
-func (s *userService) ValidateCredentials(ctx context.Context, email, password string) (*model.User, error) {
+// ValidateCredentials validates user credentials and returns the user if valid.
+// The identifier is an email if it contains "@", which usernames cannot, and a username otherwise.
+func (s *userService) ValidateCredentials(ctx context.Context, identifier, password string) (*model.User, error) {
 	if err := validateContext(ctx); err != nil {
 		return nil, err
 	}
 
-	user, err := s.GetByEmail(ctx, email)
+	var user *model.User
+	var err error
+	identifier = strings.TrimSpace(identifier)
+	if strings.Contains(identifier, "@") {
+		user, err = s.GetByEmail(ctx, identifier)
+	} else {
+		user, err = s.GetByUsername(ctx, identifier)
+	}
 	if err != nil {
 		return nil, ErrInvalidCredentials
 	}
@@ -147,23 +273,192 @@ func (s *userService) ValidateCredentials(ctx context.Context, email, password s
 	if err := secutil.VerifyPassword(user.Password, password); err != nil {
 		return nil, ErrInvalidCredentials
 	}
+	s.upgradePasswordHash(ctx, user, password)
 
 	return user, nil
 }
 
-// CreateUsers creates multiple users with email uniqueness check and password hashing
+// totpSkew is how many 30 second steps a TOTP code may be off by to allow for clock drift
+const totpSkew = 1
+
+// VerifyTOTP checks the second factor of a user whose credentials were validated.
+// Users without TOTP enabled pass with any code. When Redis is available each code is
+// accepted only once, so a code seen by an attacker cannot be replayed while it is valid.
+func (s *userService) VerifyTOTP(ctx context.Context, user *model.User, code string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return nil
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrTOTPRequired
+	}
+	if !secutil.ValidateTOTP(user.TOTPSecret, code, totpSkew) {
+		return ErrInvalidTOTP
+	}
+
+	if s.redis != nil {
+		key := fmt.Sprintf("totp:used:%s:%s", user.ID.Hex(), code)
+		ttl := time.Duration(2*totpSkew+1) * secutil.TOTPPeriod
+		fresh, err := s.redis.SetNX(ctx, key, "1", ttl)
+		if err != nil {
+			slog.Warn("Failed to record used TOTP code", "user_id", user.ID.Hex(), "error", err)
+		} else if !fresh {
+			return ErrInvalidTOTP
+		}
+	}
+
+	return nil
+}
+
+// upgradePasswordHash re-hashes a verified password whose stored hash uses a lower cost
+// than the configured one. Failures are logged and never fail the login; the upgrade is
+// retried on the next one. The hash is only replaced while it is still the one that was
+// verified, so a password changed during the login is kept.
+func (s *userService) upgradePasswordHash(ctx context.Context, user *model.User, password string) {
+	if !secutil.NeedsRehash(user.Password, int(passwordCost.Load())) {
+		return
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		slog.Warn("Failed to re-hash password", "user_id", user.ID.Hex(), "error", err)
+		return
+	}
+	replaced, err := s.repo.ReplacePasswordHash(ctx, user.ID.Hex(), user.Password, hashedPassword)
+	if err != nil {
+		slog.Warn("Failed to store re-hashed password", "user_id", user.ID.Hex(), "error", err)
+		return
+	}
+	if replaced {
+		user.Password = hashedPassword
+	}
+}
+
+// ChangePassword replaces a user's password after verifying their current one
+func (s *userService) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	if !strutil.IsStrongPassword(newPassword) {
+		return ErrWeakPassword
+	}
+
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := secutil.VerifyPassword(user.Password, oldPassword); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.repo.UpdateFields(ctx, id, bson.M{"password": hashedPassword}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RegenerateApiKey replaces a user's API key with a new random one and returns it in plaintext;
+// only its hash is stored. The old key stops authenticating immediately.
+func (s *userService) RegenerateApiKey(ctx context.Context, id string) (string, error) {
+	if err := validateContext(ctx); err != nil {
+		return "", err
+	}
+
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+
+	apiKey, err := generateApiKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	if err := s.repo.UpdateFields(ctx, id, bson.M{"api_key": repository.HashApiKey(apiKey)}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+
+	// Drop the cached lookup of the old key so it cannot be used until the cache expires
+	s.invalidateAPIKeyCache(ctx, user)
+
+	return apiKey, nil
+}
+
+// Impersonate returns the user identified by targetID so actorID can act as them,
+// after recording the impersonation in the audit log. Nothing is returned if the
+// audit record cannot be written. Admins cannot be impersonated.
+func (s *userService) Impersonate(ctx context.Context, actorID, targetID, remoteIP string) (*model.User, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+	if s.audit == nil {
+		return nil, ErrImpersonationUnavailable
+	}
+	if actorID == targetID {
+		return nil, ErrImpersonateSelf
+	}
+
+	target, err := s.GetByID(ctx, targetID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	// An impersonation token must never carry admin rights
+	if target.IsAdmin() {
+		return nil, ErrImpersonateAdmin
+	}
+
+	if err := s.audit.Create(ctx, &model.AuditLog{
+		Action:   model.AuditActionImpersonate,
+		ActorID:  actorID,
+		TargetID: targetID,
+		RemoteIP: remoteIP,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record impersonation: %w", err)
+	}
+	slog.Info("User impersonated", "actor_id", actorID, "target_id", targetID, "remote_ip", remoteIP)
+
+	return target, nil
+}
+
+// CreateUsers creates multiple users with email uniqueness check and password hashing.
+// Either all users are created or none are: in a transaction where the server supports them,
+// otherwise by deleting the users already inserted when the batch fails.
 func (s *userService) CreateUsers(ctx context.Context, users []*model.User) error {
 	if err := validateContext(ctx); err != nil {
 		return err
 	}
 
-	if len(users) == 0 {
-		return ErrEmptyBatch
+	if err := validateBatchSize(len(users)); err != nil {
+		return err
 	}
 
-	// Check for duplicate emails within the batch
+	// Check for duplicate emails and usernames within the batch
 	emails := make(map[string]bool)
+	usernames := make(map[string]bool)
 	for _, user := range users {
+		if err := validateRoles(user.Roles); err != nil {
+			return err
+		}
+
+		user.Email = model.NormalizeEmail(user.Email)
 		if emails[user.Email] {
 			return ErrEmailExists
 		}
@@ -174,19 +469,30 @@ func (s *userService) CreateUsers(ctx context.Context, users []*model.User) erro
 			return ErrEmailExists
 		}
 
+		if user.Username != "" {
+			if usernames[strings.ToLower(user.Username)] {
+				return ErrUsernameExists
+			}
+			usernames[strings.ToLower(user.Username)] = true
+		}
+		if err := s.checkUsername(ctx, user.Username, primitive.NilObjectID); err != nil {
+			return err
+		}
+
 		// Hash password
-		hashedPassword, err := secutil.HashPassword(user.Password)
+		hashedPassword, err := hashPassword(user.Password)
 		if err != nil {
 			return err
 		}
 		user.Password = hashedPassword
 
 		// Generate API key
-		apiKey, err := strutil.GenerateRandom(32, false, true, true, false)
+		apiKey, err := generateApiKey()
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to generate API key: %w", err)
 		}
-		user.ApiKey = apiKey
+		user.ApiKey = repository.HashApiKey(apiKey)
+		user.IssuedApiKey = apiKey
 
 		// Ensure user has at least the basic user role if no roles are specified
 		if len(user.Roles) == 0 {
@@ -194,33 +500,136 @@ func (s *userService) CreateUsers(ctx context.Context, users []*model.User) erro
 		}
 	}
 
-	return s.BaseService.CreateMany(ctx, users)
+	supported, err := s.repo.SupportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return s.createUsersCompensated(ctx, users)
+	}
+
+	// Insert in a transaction so a failure part way through the batch persists no users
+	return s.repo.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		return s.BaseService.CreateMany(sessCtx, users)
+	})
+}
+
+// createUsersCompensated inserts users without a transaction, for standalone servers. The insert is
+// ordered and the IDs are assigned up front, so if it fails part way through the users already
+// written are known and deleted again.
+func (s *userService) createUsersCompensated(ctx context.Context, users []*model.User) error {
+	ids := make([]primitive.ObjectID, len(users))
+	for i, user := range users {
+		user.ID = primitive.NewObjectID()
+		ids[i] = user.ID
+	}
+
+	insertErr := s.BaseService.CreateMany(ctx, users)
+	if insertErr == nil {
+		return nil
+	}
+	if _, err := s.repo.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		slog.Error("Failed to remove partially created users", "error", err)
+		return errors.Join(insertErr, fmt.Errorf("failed to remove partially created users: %w", err))
+	}
+	for _, user := range users {
+		user.ID = primitive.NilObjectID
+	}
+	return insertErr
+}
+
+// UpsertMany overrides the base UpsertMany so upserted users are prepared like Create prepares them:
+// emails are normalized, roles validated, passwords hashed and new users get an API key and the basic
+// user role. A matched user only has its name, email, username and password updated; its API key,
+// roles and scopes are left alone. IssuedApiKey is set on inserted users only.
+func (s *userService) UpsertMany(ctx context.Context, users []*model.User, keyFields []string) (*UpsertResult, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+	if err := validateBatchSize(len(users)); err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if err := validateRoles(user.Roles); err != nil {
+			return nil, err
+		}
+		// IDs come from the store: set for inserted users, left zero for matched ones
+		user.ID = primitive.NilObjectID
+		user.Email = model.NormalizeEmail(user.Email)
+		if user.Username != "" && !strutil.IsUsername(user.Username) {
+			return nil, ErrInvalidUsername
+		}
+
+		if user.Password != "" {
+			hashedPassword, err := hashPassword(user.Password)
+			if err != nil {
+				return nil, err
+			}
+			user.Password = hashedPassword
+		}
+
+		apiKey, err := generateApiKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate API key: %w", err)
+		}
+		user.ApiKey = repository.HashApiKey(apiKey)
+		user.IssuedApiKey = apiKey
+
+		if len(user.Roles) == 0 {
+			user.Roles = []string{model.RoleUser}
+		}
+	}
+
+	result, err := s.repo.UpsertUsers(ctx, users, keyFields)
+	if err != nil {
+		return nil, err
+	}
+	// Matched users keep their stored key, so the one generated for them was never issued
+	for _, user := range users {
+		if user.ID.IsZero() {
+			user.ApiKey = ""
+			user.IssuedApiKey = ""
+		}
+	}
+	return result, nil
 }
 
 // FindUsersByFilter finds users by filter criteria
+// Without a limit at most the default find limit is returned
 func (s *userService) FindUsersByFilter(ctx context.Context, filter map[string]interface{}, limit, skip int64) ([]*model.User, error) {
 	if err := validateContext(ctx); err != nil {
 		return nil, err
 	}
 
-	// Convert map to BSON filter
+	return s.BaseService.FindMany(ctx, buildUserFilter(filter), filterFindOptions(limit, skip))
+}
+
+// FindUsersByFilterPaginated finds a sorted page of users by filter criteria along with the total match count
+func (s *userService) FindUsersByFilterPaginated(ctx context.Context, filter map[string]interface{}, sort Sort, page, itemsPerPage int64) ([]*model.User, int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.BaseService.GetPaginated(ctx, buildUserFilter(filter), sort, page, itemsPerPage)
+}
+
+// CountUsersByFilter counts users matching the filter criteria
+func (s *userService) CountUsersByFilter(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return 0, err
+	}
+	return s.BaseService.Count(ctx, buildUserFilter(filter))
+}
+
+// buildUserFilter converts a filter map to a BSON filter, skipping empty values
+func buildUserFilter(filter map[string]interface{}) bson.M {
 	bsonFilter := bson.M{}
 	for k, v := range filter {
 		if v != "" {
 			bsonFilter[k] = v
 		}
 	}
-
-	// Set options
-	opts := options.Find()
-	if limit > 0 {
-		opts.SetLimit(limit)
-	}
-	if skip > 0 {
-		opts.SetSkip(skip)
-	}
-
-	return s.BaseService.FindMany(ctx, bsonFilter, opts)
+	return bsonFilter
 }
 
 // UpdateUsersByFilter updates users based on filter and updates criteria
@@ -285,8 +694,12 @@ func (s *userService) UpdateUsersByFilter(ctx context.Context, filter interface{
 		if len(userUpdates) == 0 {
 			return 0, nil
 		}
+		if err := validateBatchSize(len(userUpdates)); err != nil {
+			return 0, err
+		}
 
 		// Process each user update
+		usernames := make(map[string]bool)
 		for id, userUpdates := range userUpdates {
 			// Convert string ID to ObjectID
 			objID, err := primitive.ObjectIDFromHex(id)
@@ -294,6 +707,19 @@ func (s *userService) UpdateUsersByFilter(ctx context.Context, filter interface{
 				return 0, fmt.Errorf("invalid ID format: %s", id)
 			}
 
+			normalizeEmailUpdate(userUpdates)
+
+			// Check usernames like Update does, and against the rest of the batch
+			if username, ok := userUpdates["username"].(string); ok {
+				if usernames[strings.ToLower(username)] {
+					return 0, ErrUsernameExists
+				}
+				usernames[strings.ToLower(username)] = true
+				if err := s.checkUsername(ctx, username, objID); err != nil {
+					return 0, err
+				}
+			}
+
 			// Create an update model for this user
 			updateModel := mongo.NewUpdateOneModel().
 				SetFilter(bson.M{"_id": objID}).
@@ -349,6 +775,8 @@ func (s *userService) UpdateUsersByFilter(ctx context.Context, filter interface{
 			}
 		}
 
+		normalizeEmailUpdate(generalUpdates)
+
 		// Create update model
 		updateModel := mongo.NewUpdateManyModel().
 			SetFilter(bsonFilter).
@@ -369,14 +797,21 @@ func (s *userService) UpdateUsersByFilter(ctx context.Context, filter interface{
 	return s.BaseService.UpdateMany(ctx, nil, writeModels)
 }
 
+// normalizeEmailUpdate lowercases an email being set by a raw update so it is stored like created users' emails
+func normalizeEmailUpdate(updates map[string]interface{}) {
+	if email, ok := updates["email"].(string); ok {
+		updates["email"] = model.NormalizeEmail(email)
+	}
+}
+
 // DeleteUsersByIDs deletes multiple users by their IDs
 func (s *userService) DeleteUsersByIDs(ctx context.Context, ids []string) (int64, error) {
 	if err := validateContext(ctx); err != nil {
 		return 0, err
 	}
 
-	if len(ids) == 0 {
-		return 0, ErrEmptyBatch
+	if err := validateBatchSize(len(ids)); err != nil {
+		return 0, err
 	}
 
 	// Convert string IDs to ObjectIDs
@@ -395,7 +830,60 @@ func (s *userService) DeleteUsersByIDs(ctx context.Context, ids []string) (int64
 
 	filter := bson.M{"_id": bson.M{"$in": objectIDs}}
 
-	return s.BaseService.DeleteMany(ctx, filter)
+	// Read the users first so their cached API keys can be dropped once they are gone
+	users, err := s.BaseService.FindMany(repository.IncludeDeleted(ctx), filter, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := s.BaseService.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for _, user := range users {
+		s.invalidateAPIKeyCache(ctx, user)
+	}
+	return deleted, nil
+}
+
+// Delete overrides base Delete to report a missing user as ErrUserNotFound and to drop the
+// user's cached API key, which would otherwise keep authenticating until the cache entry expires
+func (s *userService) Delete(ctx context.Context, id string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	user, err := s.BaseService.DeleteAndReturn(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	s.invalidateAPIKeyCache(ctx, user)
+	return nil
+}
+
+// SoftDelete overrides base SoftDelete to report a missing user as ErrUserNotFound and to drop
+// the user's cached API key
+func (s *userService) SoftDelete(ctx context.Context, id string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	if err := s.BaseService.SoftDelete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	user, err := s.BaseService.GetByID(repository.IncludeDeleted(ctx), id)
+	if err != nil {
+		slog.Warn("Failed to read soft-deleted user to invalidate its API key", "user_id", id, "error", err)
+		return nil
+	}
+	s.invalidateAPIKeyCache(ctx, user)
+	return nil
 }
 
 // AddRoles adds roles to a user
@@ -408,6 +896,10 @@ func (s *userService) AddRoles(ctx context.Context, id string, roles []string) e
 		return nil
 	}
 
+	if err := validateRoles(roles); err != nil {
+		return err
+	}
+
 	user, err := s.GetByID(ctx, id)
 	if err != nil {
 		return ErrUserNotFound
@@ -430,12 +922,49 @@ func (s *userService) AddRoles(ctx context.Context, id string, roles []string) e
 
 	// Only update if there are new roles
 	if hasNewRoles {
-		return s.BaseService.Update(ctx, id, user)
+		if err := s.BaseService.Update(ctx, id, user); err != nil {
+			return err
+		}
+		s.invalidateAPIKeyCache(ctx, user)
+	}
+
+	return nil
+}
+
+// validateRoles rejects roles that are not in the known role set
+func validateRoles(roles []string) error {
+	for _, role := range roles {
+		if !model.IsKnownRole(role) {
+			return fmt.Errorf("%w: %q", ErrUnknownRole, role)
+		}
 	}
+	return nil
+}
 
+// validateScopes rejects scopes that are not "*", "resource:*" or "resource:action"
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !model.IsValidScope(scope) {
+			return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
 	return nil
 }
 
+// invalidateAPIKeyCache drops the cached API key lookup for a user so role and scope changes apply immediately
+func (s *userService) invalidateAPIKeyCache(ctx context.Context, user *model.User) {
+	if s.cache == nil || user.ApiKey == "" {
+		return
+	}
+	hashed := user.ApiKey
+	if !repository.IsHashedApiKey(hashed) {
+		hashed = repository.HashApiKey(hashed)
+	}
+	if err := s.cache.Invalidate(ctx, mwutil.HashedAPIKeyCacheKey(hashed)); err != nil {
+		slog.Warn("Failed to invalidate API key cache", "user_id", user.ID.Hex(), "error", err)
+	}
+}
+
 // RemoveRoles removes roles from a user
 func (s *userService) RemoveRoles(ctx context.Context, id string, roles []string) error {
 	if err := validateContext(ctx); err != nil {
@@ -465,15 +994,109 @@ func (s *userService) RemoveRoles(ctx context.Context, id string, roles []string
 		}
 	}
 
+	// Refuse to remove the admin role from the last remaining admin
+	if removeMap[model.RoleAdmin] && user.HasRole(model.RoleAdmin) {
+		if err := s.ensureOtherAdmin(ctx, user); err != nil {
+			return err
+		}
+	}
+
 	// Only update if roles were actually removed
 	if len(newRoles) != len(user.Roles) {
-		user.Roles = newRoles
-		return s.BaseService.Update(ctx, id, user)
+		if err := s.writeRoles(ctx, user, newRoles); err != nil {
+			return err
+		}
+		s.invalidateAPIKeyCache(ctx, user)
 	}
 
 	return nil
 }
 
+// SetRoles replaces a user's roles with exactly the given set
+func (s *userService) SetRoles(ctx context.Context, id string, roles []string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	if len(roles) == 0 {
+		return ErrNoRoles
+	}
+
+	if err := validateRoles(roles); err != nil {
+		return err
+	}
+
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	// Drop duplicates while keeping the requested order
+	seen := make(map[string]bool, len(roles))
+	newRoles := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !seen[role] {
+			seen[role] = true
+			newRoles = append(newRoles, role)
+		}
+	}
+
+	// Refuse to take the admin role away from the last remaining admin
+	if user.HasRole(model.RoleAdmin) && !seen[model.RoleAdmin] {
+		if err := s.ensureOtherAdmin(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeRoles(ctx, user, newRoles); err != nil {
+		return err
+	}
+	s.invalidateAPIKeyCache(ctx, user)
+
+	return nil
+}
+
+// writeRoles sets a user's roles in a single update so concurrent role changes cannot interleave.
+// The callers' ensureOtherAdmin check can race with another admin being demoted at the same time,
+// so when the admin role is taken away the check runs again after the write. If no other admin is
+// left the user's previous roles are put back and ErrLastAdmin is returned.
+func (s *userService) writeRoles(ctx context.Context, user *model.User, roles []string) error {
+	if err := s.repo.UpdateFields(ctx, user.ID.Hex(), bson.M{"roles": roles}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if !user.HasRole(model.RoleAdmin) || slices.Contains(roles, model.RoleAdmin) {
+		return nil
+	}
+	checkErr := s.ensureOtherAdmin(ctx, user)
+	if checkErr == nil {
+		return nil
+	}
+	if err := s.repo.UpdateFields(ctx, user.ID.Hex(), bson.M{"roles": user.Roles}); err != nil {
+		slog.Error("Failed to restore the roles of the last admin", "user_id", user.ID.Hex(), "error", err)
+		return errors.Join(checkErr, fmt.Errorf("failed to restore roles: %w", err))
+	}
+	return checkErr
+}
+
+// ensureOtherAdmin returns ErrLastAdmin if user is the only admin
+func (s *userService) ensureOtherAdmin(ctx context.Context, user *model.User) error {
+	admins, err := s.GetUsersByRole(ctx, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	for _, admin := range admins {
+		if admin.ID != user.ID {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
 // GetUsersByRole retrieves all users with a specific role
 func (s *userService) GetUsersByRole(ctx context.Context, role string) ([]*model.User, error) {
 	if err := validateContext(ctx); err != nil {
@@ -483,3 +1106,79 @@ func (s *userService) GetUsersByRole(ctx context.Context, role string) ([]*model
 	filter := bson.M{"roles": bson.M{"$in": []string{role}}}
 	return s.BaseService.FindMany(ctx, filter, nil)
 }
+
+// AddScopes grants scopes to a user
+func (s *userService) AddScopes(ctx context.Context, id string, scopes []string) error {
+	return s.updateScopes(ctx, id, scopes, func(current []string) []string {
+		return appendMissing(current, scopes)
+	})
+}
+
+// RemoveScopes revokes scopes from a user. Only exact matches are removed, so revoking
+// "products:read" leaves a "products:*" grant in place.
+func (s *userService) RemoveScopes(ctx context.Context, id string, scopes []string) error {
+	return s.updateScopes(ctx, id, scopes, func(current []string) []string {
+		remove := make(map[string]bool, len(scopes))
+		for _, scope := range scopes {
+			remove[scope] = true
+		}
+		kept := make([]string, 0, len(current))
+		for _, scope := range current {
+			if !remove[scope] {
+				kept = append(kept, scope)
+			}
+		}
+		return kept
+	})
+}
+
+// SetScopes replaces all of a user's scopes; an empty list revokes every scope
+func (s *userService) SetScopes(ctx context.Context, id string, scopes []string) error {
+	return s.updateScopes(ctx, id, scopes, func([]string) []string {
+		return appendMissing(nil, scopes)
+	})
+}
+
+// updateScopes validates scopes, applies change to the user's current scopes and stores the result
+func (s *userService) updateScopes(ctx context.Context, id string, scopes []string, change func(current []string) []string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	if err := validateScopes(scopes); err != nil {
+		return err
+	}
+
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	newScopes := change(user.Scopes)
+	if slices.Equal(newScopes, user.Scopes) {
+		return nil
+	}
+
+	if err := s.repo.UpdateFields(ctx, id, bson.M{"scopes": newScopes}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	s.invalidateAPIKeyCache(ctx, user)
+
+	return nil
+}
+
+// appendMissing appends the values not already in list, keeping their order and dropping duplicates
+func appendMissing(list, values []string) []string {
+	seen := make(map[string]bool, len(list)+len(values))
+	result := make([]string, 0, len(list)+len(values))
+	for _, v := range append(append([]string(nil), list...), values...) {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
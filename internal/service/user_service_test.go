@@ -0,0 +1,1267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/dto"
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/repository"
+	"go-echo-mongo/internal/repository/redisrepo"
+	"go-echo-mongo/pkg/secutil"
+	"go-echo-mongo/pkg/web/mwutil"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeUserRepo is an in-memory UserRepository; unimplemented methods panic
+type fakeUserRepo struct {
+	repository.UserRepository
+	users      map[string]*model.User
+	failEmails map[string]bool
+	// standalone makes the repository report no transaction support, like a standalone mongod
+	standalone bool
+}
+
+func newFakeUserRepo(users ...*model.User) *fakeUserRepo {
+	r := &fakeUserRepo{users: make(map[string]*model.User)}
+	for _, u := range users {
+		clone := *u
+		clone.Roles = append([]string(nil), u.Roles...)
+		r.users[u.ID.Hex()] = &clone
+	}
+	return r
+}
+
+func (r *fakeUserRepo) FindByID(ctx context.Context, id string) (*model.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	clone := *u
+	clone.Roles = append([]string(nil), u.Roles...)
+	return &clone, nil
+}
+
+func (r *fakeUserRepo) Update(ctx context.Context, id string, user *model.User) error {
+	if _, ok := r.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	clone := *user
+	r.users[id] = &clone
+	return nil
+}
+
+// UpdateFields only supports the roles, scopes, password and api_key fields
+func (r *fakeUserRepo) UpdateFields(ctx context.Context, id string, fields bson.M) error {
+	u, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if roles, ok := fields["roles"].([]string); ok {
+		u.Roles = append([]string(nil), roles...)
+	}
+	if scopes, ok := fields["scopes"].([]string); ok {
+		u.Scopes = append([]string(nil), scopes...)
+	}
+	if password, ok := fields["password"].(string); ok {
+		u.Password = password
+	}
+	if apiKey, ok := fields["api_key"].(string); ok {
+		u.ApiKey = apiKey
+	}
+	return nil
+}
+
+// ReplacePasswordHash swaps a user's password hash only while it is still oldHash, like the real repository
+func (r *fakeUserRepo) ReplacePasswordHash(ctx context.Context, id, oldHash, newHash string) (bool, error) {
+	u, ok := r.users[id]
+	if !ok || u.Password != oldHash {
+		return false, nil
+	}
+	u.Password = newHash
+	return true, nil
+}
+
+// FindByApiKey finds a user by the hash of a raw API key, skipping soft-deleted users like the real repository
+func (r *fakeUserRepo) FindByApiKey(ctx context.Context, apiKey string) (*model.User, error) {
+	for _, u := range r.users {
+		if u.ApiKey == repository.HashApiKey(apiKey) && !u.IsDeleted() {
+			return u, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// FindByUsername finds a user by username, ignoring case and soft-deleted users like the real repository
+func (r *fakeUserRepo) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	for _, u := range r.users {
+		if u.Username != "" && strings.EqualFold(u.Username, username) && !u.IsDeleted() {
+			return u, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// FindByEmail finds a user by email, ignoring case and soft-deleted users like the real repository
+func (r *fakeUserRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range r.users {
+		if model.NormalizeEmail(u.Email) == model.NormalizeEmail(email) && !u.IsDeleted() {
+			return u, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// WithTransaction discards every write made by fn when it fails
+func (r *fakeUserRepo) WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	snapshot := make(map[string]*model.User, len(r.users))
+	for id, u := range r.users {
+		snapshot[id] = u
+	}
+	if err := fn(mongo.NewSessionContext(ctx, nil)); err != nil {
+		r.users = snapshot
+		return err
+	}
+	return nil
+}
+
+// Create stores a user under a new ID
+func (r *fakeUserRepo) Create(ctx context.Context, user *model.User) error {
+	user.ID = primitive.NewObjectID()
+	clone := *user
+	r.users[user.ID.Hex()] = &clone
+	return nil
+}
+
+// SupportsTransactions reports transaction support unless the repository is standalone
+func (r *fakeUserRepo) SupportsTransactions(ctx context.Context) (bool, error) {
+	return !r.standalone, nil
+}
+
+// InsertMany stores users one at a time, failing at the first email in failEmails.
+// Like the real repository it keeps IDs that are already set.
+func (r *fakeUserRepo) InsertMany(ctx context.Context, users []*model.User) error {
+	for _, u := range users {
+		if r.failEmails[u.Email] {
+			return errors.New("insert failed")
+		}
+		if u.ID.IsZero() {
+			u.ID = primitive.NewObjectID()
+		}
+		clone := *u
+		r.users[u.ID.Hex()] = &clone
+	}
+	return nil
+}
+
+// UpsertUsers matches users on email only and, like the real repository, updates a match's
+// name, username and password but never its API key, roles or scopes
+func (r *fakeUserRepo) UpsertUsers(ctx context.Context, users []*model.User, keyFields []string) (*repository.UpsertResult, error) {
+	result := &repository.UpsertResult{}
+	for _, u := range users {
+		if existing, _ := r.FindByEmail(ctx, u.Email); existing != nil {
+			existing.Name = u.Name
+			if u.Username != "" {
+				existing.Username = u.Username
+			}
+			if u.Password != "" {
+				existing.Password = u.Password
+			}
+			result.Updated++
+			continue
+		}
+		u.ID = primitive.NewObjectID()
+		clone := *u
+		r.users[u.ID.Hex()] = &clone
+		result.Inserted++
+	}
+	return result, nil
+}
+
+// filterIDs returns the IDs of a {"_id": {"$in": ids}} filter, or false for any other filter
+func filterIDs(filter interface{}) ([]primitive.ObjectID, bool) {
+	m, ok := filter.(bson.M)
+	if !ok {
+		return nil, false
+	}
+	in, ok := m["_id"].(bson.M)
+	if !ok {
+		return nil, false
+	}
+	switch ids := in["$in"].(type) {
+	case []primitive.ObjectID:
+		return ids, true
+	case []interface{}:
+		objectIDs := make([]primitive.ObjectID, len(ids))
+		for i, id := range ids {
+			objectIDs[i] = id.(primitive.ObjectID)
+		}
+		return objectIDs, true
+	}
+	return nil, false
+}
+
+// DeleteMany only supports the {"_id": {"$in": ids}} filter
+func (r *fakeUserRepo) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	ids, _ := filterIDs(filter)
+	var deleted int64
+	for _, id := range ids {
+		if _, ok := r.users[id.Hex()]; ok {
+			delete(r.users, id.Hex())
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *fakeUserRepo) DeleteAndReturn(ctx context.Context, id string) (*model.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(r.users, id)
+	return u, nil
+}
+
+func (r *fakeUserRepo) SoftDelete(ctx context.Context, id string) error {
+	u, ok := r.users[id]
+	if !ok || u.IsDeleted() {
+		return repository.ErrNotFound
+	}
+	now := time.Now().UTC()
+	u.DeletedAt = &now
+	return nil
+}
+
+// UpdateMany reports every write model as a modified user without applying it
+func (r *fakeUserRepo) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (int64, error) {
+	return int64(len(update.([]mongo.WriteModel))), nil
+}
+
+// FindMany supports the {"_id": {"$in": ids}} filter; any other filter is taken to be the
+// role filter used by GetUsersByRole
+func (r *fakeUserRepo) FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]*model.User, error) {
+	var users []*model.User
+	if ids, ok := filterIDs(filter); ok {
+		for _, id := range ids {
+			if u, ok := r.users[id.Hex()]; ok {
+				users = append(users, u)
+			}
+		}
+		return users, nil
+	}
+	for _, u := range r.users {
+		if u.HasRole(model.RoleAdmin) {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+// fakeCache records invalidated keys; unimplemented methods panic
+type fakeCache struct {
+	redisrepo.CacheRepository
+	invalidated []string
+}
+
+func (c *fakeCache) Invalidate(ctx context.Context, keys ...string) error {
+	c.invalidated = append(c.invalidated, keys...)
+	return nil
+}
+
+func newTestUser(roles ...string) *model.User {
+	u := &model.User{Name: "Test User", Email: "test@example.com", Roles: roles}
+	u.ID = primitive.NewObjectID()
+	return u
+}
+
+func TestRemoveRolesLastAdmin(t *testing.T) {
+	admin := newTestUser(model.RoleAdmin, model.RoleUser)
+	repo := newFakeUserRepo(admin, newTestUser(model.RoleUser))
+	svc := NewUserService(repo, nil, nil, nil)
+
+	err := svc.RemoveRoles(context.Background(), admin.ID.Hex(), []string{model.RoleAdmin})
+	if !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected ErrLastAdmin, got %v", err)
+	}
+	if !repo.users[admin.ID.Hex()].HasRole(model.RoleAdmin) {
+		t.Error("expected the last admin to keep the admin role")
+	}
+
+	// Removing other roles from the last admin is still allowed
+	if err := svc.RemoveRoles(context.Background(), admin.ID.Hex(), []string{model.RoleUser}); err != nil {
+		t.Errorf("unexpected error removing a non-admin role: %v", err)
+	}
+}
+
+func TestRemoveRolesWithMultipleAdmins(t *testing.T) {
+	first := newTestUser(model.RoleAdmin)
+	second := newTestUser(model.RoleAdmin)
+	repo := newFakeUserRepo(first, second)
+	svc := NewUserService(repo, nil, nil, nil)
+
+	if err := svc.RemoveRoles(context.Background(), first.ID.Hex(), []string{model.RoleAdmin}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.users[first.ID.Hex()].HasRole(model.RoleAdmin) {
+		t.Error("expected admin role to be removed")
+	}
+
+	// The remaining admin is now the last one
+	err := svc.RemoveRoles(context.Background(), second.ID.Hex(), []string{model.RoleAdmin})
+	if !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin for the remaining admin, got %v", err)
+	}
+}
+
+// racingUserRepo runs race once, right after the first admin lookup has been read, so the caller
+// acts on a list of admins that is already out of date
+type racingUserRepo struct {
+	*fakeUserRepo
+	race func()
+}
+
+func (r *racingUserRepo) FindMany(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]*model.User, error) {
+	users, err := r.fakeUserRepo.FindMany(ctx, filter, opts)
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+	return users, err
+}
+
+func TestConcurrentAdminDemotionsKeepOneAdmin(t *testing.T) {
+	demotions := map[string]func(svc UserService, id string) error{
+		"RemoveRoles": func(svc UserService, id string) error {
+			return svc.RemoveRoles(context.Background(), id, []string{model.RoleAdmin})
+		},
+		"SetRoles": func(svc UserService, id string) error {
+			return svc.SetRoles(context.Background(), id, []string{model.RoleUser})
+		},
+	}
+
+	for name, demote := range demotions {
+		t.Run(name, func(t *testing.T) {
+			first := newTestUser(model.RoleAdmin, model.RoleUser)
+			second := newTestUser(model.RoleAdmin, model.RoleUser)
+			repo := &racingUserRepo{fakeUserRepo: newFakeUserRepo(first, second)}
+			svc := NewUserService(repo, nil, nil, nil)
+
+			// The second admin is demoted while the first demotion is between its check and its write
+			var secondErr error
+			repo.race = func() { secondErr = demote(svc, second.ID.Hex()) }
+			firstErr := demote(svc, first.ID.Hex())
+
+			if secondErr != nil {
+				t.Fatalf("expected the demotion that finished first to succeed, got %v", secondErr)
+			}
+			if !errors.Is(firstErr, ErrLastAdmin) {
+				t.Fatalf("expected ErrLastAdmin for the demotion that lost the race, got %v", firstErr)
+			}
+			if !repo.users[first.ID.Hex()].HasRole(model.RoleAdmin) || !repo.users[first.ID.Hex()].HasRole(model.RoleUser) {
+				t.Errorf("expected the losing admin's roles to be restored, got %v", repo.users[first.ID.Hex()].Roles)
+			}
+			if repo.users[second.ID.Hex()].HasRole(model.RoleAdmin) {
+				t.Error("expected the winning demotion to be kept")
+			}
+		})
+	}
+}
+
+func TestRoleChangesInvalidateAPIKeyCache(t *testing.T) {
+	user := newTestUser(model.RoleUser)
+	user.ApiKey = repository.HashApiKey("user-api-key")
+	repo := newFakeUserRepo(user, newTestUser(model.RoleAdmin))
+	cache := &fakeCache{}
+	svc := NewUserService(repo, nil, cache, nil)
+
+	if err := svc.AddRoles(context.Background(), user.ID.Hex(), []string{model.RoleEditor}); err != nil {
+		t.Fatalf("unexpected error adding roles: %v", err)
+	}
+	if err := svc.RemoveRoles(context.Background(), user.ID.Hex(), []string{model.RoleEditor}); err != nil {
+		t.Fatalf("unexpected error removing roles: %v", err)
+	}
+	// No-op role changes leave the cache alone
+	if err := svc.AddRoles(context.Background(), user.ID.Hex(), []string{model.RoleUser}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := mwutil.APIKeyCacheKey("user-api-key")
+	if len(cache.invalidated) != 2 || cache.invalidated[0] != key || cache.invalidated[1] != key {
+		t.Errorf("expected %q to be invalidated twice, got %v", key, cache.invalidated)
+	}
+}
+
+func TestDeletingUsersInvalidatesAPIKeyCache(t *testing.T) {
+	deletions := map[string]func(svc UserService, id string) error{
+		"Delete":     func(svc UserService, id string) error { return svc.Delete(context.Background(), id) },
+		"SoftDelete": func(svc UserService, id string) error { return svc.SoftDelete(context.Background(), id) },
+		"DeleteUsersByIDs": func(svc UserService, id string) error {
+			_, err := svc.DeleteUsersByIDs(context.Background(), []string{id})
+			return err
+		},
+	}
+
+	for name, remove := range deletions {
+		t.Run(name, func(t *testing.T) {
+			user := newTestUser(model.RoleUser)
+			user.ApiKey = repository.HashApiKey("user-api-key")
+			cache := &fakeCache{}
+			svc := NewUserService(newFakeUserRepo(user), nil, cache, nil)
+
+			if err := remove(svc, user.ID.Hex()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			key := mwutil.APIKeyCacheKey("user-api-key")
+			if len(cache.invalidated) != 1 || cache.invalidated[0] != key {
+				t.Errorf("expected %q to be invalidated, got %v", key, cache.invalidated)
+			}
+		})
+	}
+
+	svc := NewUserService(newFakeUserRepo(), nil, &fakeCache{}, nil)
+	id := primitive.NewObjectID().Hex()
+	if err := svc.Delete(context.Background(), id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Delete: expected ErrUserNotFound, got %v", err)
+	}
+	if err := svc.SoftDelete(context.Background(), id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SoftDelete: expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestScopeManagement(t *testing.T) {
+	user := newTestUser(model.RoleUser)
+	user.ApiKey = repository.HashApiKey("user-api-key")
+	repo := newFakeUserRepo(user)
+	cache := &fakeCache{}
+	svc := NewUserService(repo, nil, cache, nil)
+	ctx := context.Background()
+	id := user.ID.Hex()
+	scopes := func() []string { return repo.users[id].Scopes }
+
+	if err := svc.AddScopes(ctx, id, []string{"products:read", "users:read", "products:read"}); err != nil {
+		t.Fatalf("unexpected error adding scopes: %v", err)
+	}
+	if got := scopes(); !slices.Equal(got, []string{"products:read", "users:read"}) {
+		t.Errorf("expected deduplicated scopes, got %v", got)
+	}
+
+	if err := svc.RemoveScopes(ctx, id, []string{"products:read"}); err != nil {
+		t.Fatalf("unexpected error removing scopes: %v", err)
+	}
+	if got := scopes(); !slices.Equal(got, []string{"users:read"}) {
+		t.Errorf("expected products:read to be revoked, got %v", got)
+	}
+
+	if err := svc.SetScopes(ctx, id, []string{"products:*"}); err != nil {
+		t.Fatalf("unexpected error setting scopes: %v", err)
+	}
+	if got := scopes(); !slices.Equal(got, []string{"products:*"}) {
+		t.Errorf("expected scopes to be replaced, got %v", got)
+	}
+	// No-op changes leave the cache alone
+	if err := svc.AddScopes(ctx, id, []string{"products:*"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cache.invalidated) != 3 {
+		t.Errorf("expected each scope change to invalidate the API key cache, got %v", cache.invalidated)
+	}
+
+	if err := svc.AddScopes(ctx, id, []string{"products"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("expected ErrInvalidScope, got %v", err)
+	}
+	if err := svc.SetScopes(ctx, primitive.NewObjectID().Hex(), nil); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := svc.SetScopes(ctx, id, []string{}); err != nil {
+		t.Fatalf("unexpected error revoking all scopes: %v", err)
+	}
+	if got := scopes(); len(got) != 0 {
+		t.Errorf("expected every scope to be revoked, got %v", got)
+	}
+}
+
+func TestAddRolesRejectsUnknownRole(t *testing.T) {
+	user := newTestUser(model.RoleUser)
+	repo := newFakeUserRepo(user)
+	svc := NewUserService(repo, nil, nil, nil)
+
+	err := svc.AddRoles(context.Background(), user.ID.Hex(), []string{model.RoleEditor, "admn"})
+	if !errors.Is(err, ErrUnknownRole) {
+		t.Fatalf("expected ErrUnknownRole, got %v", err)
+	}
+	if roles := repo.users[user.ID.Hex()].Roles; len(roles) != 1 || roles[0] != model.RoleUser {
+		t.Errorf("expected roles to be unchanged, got %v", roles)
+	}
+
+	// Roles added to the known set are accepted
+	model.SetKnownRoles(model.RoleUser, "auditor")
+	t.Cleanup(func() {
+		model.SetKnownRoles(model.RoleAdmin, model.RoleUser, model.RoleEditor, model.RoleViewer, model.RoleManager, model.RoleModerator)
+	})
+	if err := svc.AddRoles(context.Background(), user.ID.Hex(), []string{"auditor"}); err != nil {
+		t.Errorf("expected configured role to be accepted, got %v", err)
+	}
+}
+
+func TestSetRolesReplacesRoles(t *testing.T) {
+	user := newTestUser(model.RoleUser, model.RoleEditor)
+	user.ApiKey = "user-api-key"
+	repo := newFakeUserRepo(user, newTestUser(model.RoleAdmin))
+	cache := &fakeCache{}
+	svc := NewUserService(repo, nil, cache, nil)
+
+	if err := svc.SetRoles(context.Background(), user.ID.Hex(), []string{model.RoleViewer, model.RoleViewer}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roles := repo.users[user.ID.Hex()].Roles
+	if len(roles) != 1 || roles[0] != model.RoleViewer {
+		t.Errorf("expected roles [%s], got %v", model.RoleViewer, roles)
+	}
+	if len(cache.invalidated) != 1 {
+		t.Errorf("expected the API key cache to be invalidated, got %v", cache.invalidated)
+	}
+
+	tests := []struct {
+		roles []string
+		want  error
+	}{
+		{nil, ErrNoRoles},
+		{[]string{"admn"}, ErrUnknownRole},
+	}
+	for _, tt := range tests {
+		if err := svc.SetRoles(context.Background(), user.ID.Hex(), tt.roles); !errors.Is(err, tt.want) {
+			t.Errorf("SetRoles(%v): expected %v, got %v", tt.roles, tt.want, err)
+		}
+	}
+	if err := svc.SetRoles(context.Background(), primitive.NewObjectID().Hex(), []string{model.RoleUser}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSetRolesLastAdmin(t *testing.T) {
+	admin := newTestUser(model.RoleAdmin)
+	repo := newFakeUserRepo(admin)
+	svc := NewUserService(repo, nil, nil, nil)
+
+	if err := svc.SetRoles(context.Background(), admin.ID.Hex(), []string{model.RoleUser}); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected ErrLastAdmin, got %v", err)
+	}
+	if err := svc.SetRoles(context.Background(), admin.ID.Hex(), []string{model.RoleAdmin, model.RoleUser}); err != nil {
+		t.Errorf("expected the last admin to keep admin alongside other roles, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user := newTestUser(model.RoleUser)
+	hashed, err := secutil.HashPassword("Old-passw0rd")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user.Password = hashed
+	repo := newFakeUserRepo(user)
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+	id := user.ID.Hex()
+
+	tests := []struct {
+		id, old, new string
+		want         error
+	}{
+		{id, "wrong-passw0rD", "New-passw0rd", ErrInvalidCredentials},
+		{id, "Old-passw0rd", "weak", ErrWeakPassword},
+		{id, "Old-passw0rd", "nouppercase1!", ErrWeakPassword},
+		{primitive.NewObjectID().Hex(), "Old-passw0rd", "New-passw0rd", ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		if err := svc.ChangePassword(ctx, tt.id, tt.old, tt.new); !errors.Is(err, tt.want) {
+			t.Errorf("ChangePassword(%q, %q): expected %v, got %v", tt.old, tt.new, tt.want, err)
+		}
+	}
+	if repo.users[id].Password != hashed {
+		t.Fatal("expected failed changes to keep the old password")
+	}
+
+	if err := svc.ChangePassword(ctx, id, "Old-passw0rd", "New-passw0rd"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := repo.users[id].Password
+	if stored == "New-passw0rd" {
+		t.Fatal("expected the new password to be stored hashed")
+	}
+	if err := secutil.VerifyPassword(stored, "New-passw0rd"); err != nil {
+		t.Errorf("expected the new password to verify, got %v", err)
+	}
+	if err := secutil.VerifyPassword(stored, "Old-passw0rd"); err == nil {
+		t.Error("expected the old password to stop working")
+	}
+}
+
+func TestRegenerateApiKey(t *testing.T) {
+	user := newTestUser(model.RoleUser)
+	user.ApiKey = repository.HashApiKey("old-api-key")
+	repo := newFakeUserRepo(user)
+	cache := &fakeCache{}
+	svc := NewUserService(repo, nil, cache, nil)
+
+	newKey, err := svc.RegenerateApiKey(context.Background(), user.ID.Hex())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(newKey, "sk_live_") || len(newKey) != len("sk_live_")+32 {
+		t.Errorf("expected a new sk_live_ key with 32 random characters, got %q", newKey)
+	}
+	if len(cache.invalidated) != 1 || cache.invalidated[0] != mwutil.APIKeyCacheKey("old-api-key") {
+		t.Errorf("expected the old key's cache entry to be invalidated, got %v", cache.invalidated)
+	}
+	if stored := repo.users[user.ID.Hex()].ApiKey; stored != repository.HashApiKey(newKey) {
+		t.Errorf("expected the new key to be stored hashed, got %q", stored)
+	}
+
+	e := echo.New()
+	h := mwutil.NewAPIKeyAuthWithConfig(mwutil.APIKeyAuthConfig{Validator: svc, RequiredRoles: []string{model.RoleUser}})(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	authenticate := func(apiKey string) error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		return h(e.NewContext(req, httptest.NewRecorder()))
+	}
+	if err := authenticate("old-api-key"); err == nil {
+		t.Error("expected the old key to be rejected")
+	}
+	if err := authenticate(newKey); err != nil {
+		t.Errorf("expected the new key to authenticate, got %v", err)
+	}
+
+	if _, err := svc.RegenerateApiKey(context.Background(), primitive.NewObjectID().Hex()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestCreateStoresHashedApiKey(t *testing.T) {
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	user := &model.User{Name: "Jane", Email: "jane@example.com", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if user.IssuedApiKey == "" || user.ApiKey == user.IssuedApiKey {
+		t.Fatalf("expected the plaintext key to be issued and only its hash stored, got issued %q stored %q", user.IssuedApiKey, user.ApiKey)
+	}
+	if !repository.IsHashedApiKey(user.ApiKey) || user.ApiKey != repository.HashApiKey(user.IssuedApiKey) {
+		t.Errorf("expected the stored key to be the SHA-256 of the issued key, got %q", user.ApiKey)
+	}
+
+	found, err := svc.GetByApiKey(ctx, user.IssuedApiKey)
+	if err != nil || found.ID != user.ID {
+		t.Errorf("expected the raw key to authenticate, got %v, %v", found, err)
+	}
+	if _, err := svc.GetByApiKey(ctx, user.ApiKey); err == nil {
+		t.Error("expected the stored hash to be rejected as a key")
+	}
+}
+
+func TestUpsertManyPreparesUsers(t *testing.T) {
+	existing := &model.User{
+		BaseModel: model.BaseModel{ID: primitive.NewObjectID()},
+		Name:      "Admin",
+		Email:     "admin@example.com",
+		ApiKey:    repository.HashApiKey("admin-key"),
+		Roles:     []string{model.RoleAdmin},
+	}
+	repo := newFakeUserRepo(existing)
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	users := []*model.User{
+		{Name: "Renamed", Email: " Admin@Example.com ", Password: "N3w-passw0rd!"},
+		{Name: "Jane", Email: "Jane@Example.com", Password: "Str0ng-passw0rd"},
+	}
+	result, err := svc.UpsertMany(ctx, users, []string{"email"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *result != (UpsertResult{Inserted: 1, Updated: 1}) {
+		t.Errorf("expected one insert and one update, got %+v", *result)
+	}
+
+	stored := repo.users[existing.ID.Hex()]
+	if stored.Name != "Renamed" || secutil.VerifyPassword(stored.Password, "N3w-passw0rd!") != nil {
+		t.Errorf("expected the match's name and hashed password to be updated, got %+v", stored)
+	}
+	if stored.ApiKey != existing.ApiKey || !stored.IsAdmin() {
+		t.Errorf("expected the match's API key and roles to be kept, got key %q roles %v", stored.ApiKey, stored.Roles)
+	}
+	if users[0].IssuedApiKey != "" {
+		t.Error("expected no API key to be issued for a matched user")
+	}
+
+	inserted, err := svc.GetByApiKey(ctx, users[1].IssuedApiKey)
+	if err != nil || inserted.Email != "jane@example.com" {
+		t.Fatalf("expected the inserted user to get a working API key and a normalized email, got %v, %v", inserted, err)
+	}
+	if !inserted.HasRole(model.RoleUser) || secutil.VerifyPassword(inserted.Password, "Str0ng-passw0rd") != nil {
+		t.Errorf("expected the inserted user to get the user role and a hashed password, got %+v", inserted)
+	}
+}
+
+func TestSoftDeletedUserIsRejected(t *testing.T) {
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	user := &model.User{Name: "Gone", Email: "gone@example.com", Username: "gone", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deletedAt := time.Now()
+	repo.users[user.ID.Hex()].DeletedAt = &deletedAt
+
+	if _, err := svc.GetByApiKey(ctx, user.IssuedApiKey); err == nil {
+		t.Error("expected the soft-deleted user's API key to be rejected")
+	}
+	for _, identifier := range []string{"gone@example.com", "gone"} {
+		if _, err := svc.ValidateCredentials(ctx, identifier, "Str0ng-passw0rd"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected the soft-deleted user's password to be rejected for %q, got %v", identifier, err)
+		}
+	}
+}
+
+func TestEmailUniquenessIgnoresCase(t *testing.T) {
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	john := &model.User{Name: "John", Email: "  John@Example.com ", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, john); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored := repo.users[john.ID.Hex()].Email; stored != "john@example.com" {
+		t.Errorf("expected the email to be stored lowercased, got %q", stored)
+	}
+
+	if err := svc.Create(ctx, &model.User{Name: "Other", Email: "john@example.com", Password: "Str0ng-passw0rd"}); !errors.Is(err, ErrEmailExists) {
+		t.Errorf("expected ErrEmailExists for a differently-cased email, got %v", err)
+	}
+
+	batch := []*model.User{
+		{Name: "Ann", Email: "ann@example.com", Password: "Str0ng-passw0rd"},
+		{Name: "Ann", Email: "ANN@example.com", Password: "Str0ng-passw0rd"},
+	}
+	if err := svc.CreateUsers(ctx, batch); !errors.Is(err, ErrEmailExists) {
+		t.Errorf("expected ErrEmailExists for case variants within a batch, got %v", err)
+	}
+
+	jane := &model.User{Name: "Jane", Email: "jane@example.com", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, jane); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Update(ctx, jane.ID.Hex(), &model.User{Email: "JOHN@example.com"}); !errors.Is(err, ErrEmailExists) {
+		t.Errorf("expected ErrEmailExists when updating to a case variant of another user's email, got %v", err)
+	}
+	// Changing only the case of one's own email is not a conflict
+	if err := svc.Update(ctx, jane.ID.Hex(), &model.User{Email: "Jane@Example.com"}); err != nil {
+		t.Errorf("unexpected error re-casing own email: %v", err)
+	}
+
+	if _, err := svc.ValidateCredentials(ctx, "JOHN@EXAMPLE.COM", "Str0ng-passw0rd"); err != nil {
+		t.Errorf("expected login to ignore email case, got %v", err)
+	}
+}
+
+func TestCreateUsersMidBatchFailurePersistsNothing(t *testing.T) {
+	for _, standalone := range []bool{false, true} {
+		t.Run(fmt.Sprintf("standalone=%t", standalone), func(t *testing.T) {
+			repo := newFakeUserRepo()
+			repo.failEmails = map[string]bool{"c@example.com": true}
+			repo.standalone = standalone
+			svc := NewUserService(repo, nil, nil, nil)
+
+			users := []*model.User{
+				{Name: "A", Email: "a@example.com", Password: "password123"},
+				{Name: "B", Email: "b@example.com", Password: "password123"},
+				{Name: "C", Email: "c@example.com", Password: "password123"},
+			}
+			if err := svc.CreateUsers(context.Background(), users); err == nil {
+				t.Fatal("expected the batch to fail")
+			}
+			if len(repo.users) != 0 {
+				t.Errorf("expected no users to be persisted, got %d", len(repo.users))
+			}
+			if standalone && !users[0].ID.IsZero() {
+				t.Errorf("expected the removed users to have no ID, got %v", users[0].ID)
+			}
+		})
+	}
+}
+
+func TestApiKeyPrefixes(t *testing.T) {
+	defer SetApiKeyEnvironment(ApiKeyEnvLive)
+
+	for _, env := range []string{ApiKeyEnvLive, ApiKeyEnvTest} {
+		SetApiKeyEnvironment(env)
+		key, err := generateApiKey()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", env, err)
+		}
+		if !strings.HasPrefix(key, "sk_"+env+"_") || len(key) != len(ApiKeyPrefix(env))+32 {
+			t.Errorf("%s: expected an sk_%s_ key with 32 random characters, got %q", env, env, key)
+		}
+		if got, ok := ApiKeyEnvironment(key); !ok || got != env {
+			t.Errorf("%s: expected the key to be identified as %s, got %q", env, env, got)
+		}
+	}
+
+	// Unknown environments are ignored
+	SetApiKeyEnvironment("staging")
+	if key, _ := generateApiKey(); !strings.HasPrefix(key, "sk_test_") {
+		t.Errorf("expected the environment to stay test, got %q", key)
+	}
+
+	if _, ok := ApiKeyEnvironment("0123456789abcdef0123456789abcdef"); ok {
+		t.Error("expected a key without a prefix to have no environment")
+	}
+}
+
+func TestGetByApiKeySeparatesEnvironments(t *testing.T) {
+	defer SetApiKeyEnvironment(ApiKeyEnvLive)
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	SetApiKeyEnvironment(ApiKeyEnvTest)
+	testUser := &model.User{Name: "Tester", Email: "tester@example.com", Password: "Str0ng!Passw0rd"}
+	if err := svc.Create(ctx, testUser); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	testKey := testUser.IssuedApiKey
+
+	SetApiKeyEnvironment(ApiKeyEnvLive)
+	liveUser := &model.User{Name: "Live", Email: "live@example.com", Password: "Str0ng!Passw0rd"}
+	if err := svc.Create(ctx, liveUser); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	legacy := newTestUser(model.RoleUser)
+	legacy.ApiKey = repository.HashApiKey("0123456789abcdef0123456789abcdef")
+	repo.users[legacy.ID.Hex()] = legacy
+
+	if _, err := svc.GetByApiKey(ctx, testKey); !errors.Is(err, ErrApiKeyEnvironment) {
+		t.Errorf("expected a test key to be rejected in live, got %v", err)
+	}
+	if found, err := svc.GetByApiKey(ctx, " "+liveUser.IssuedApiKey+" "); err != nil || found.ID != liveUser.ID {
+		t.Errorf("expected the live key to find its user, got %v, %v", found, err)
+	}
+	if found, err := svc.GetByApiKey(ctx, "0123456789abcdef0123456789abcdef"); err != nil || found.ID != legacy.ID {
+		t.Errorf("expected an unprefixed legacy key to still authenticate, got %v, %v", found, err)
+	}
+
+	SetApiKeyEnvironment(ApiKeyEnvTest)
+	if _, err := svc.GetByApiKey(ctx, liveUser.IssuedApiKey); !errors.Is(err, ErrApiKeyEnvironment) {
+		t.Errorf("expected a live key to be rejected in test, got %v", err)
+	}
+	if found, err := svc.GetByApiKey(ctx, testKey); err != nil || found.ID != testUser.ID {
+		t.Errorf("expected the test key to find its user, got %v, %v", found, err)
+	}
+}
+
+func TestLoginByEmailOrUsername(t *testing.T) {
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	jane := &model.User{Name: "Jane", Email: "jane@example.com", Username: "jane_doe", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, jane); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	noUsername := &model.User{Name: "John", Email: "john@example.com", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, noUsername); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, identifier := range []string{"jane@example.com", "jane_doe", "Jane_Doe", " jane_doe "} {
+		user, err := svc.ValidateCredentials(ctx, identifier, "Str0ng-passw0rd")
+		if err != nil || user.ID != jane.ID {
+			t.Errorf("%q: expected to log in as jane, got %v, %v", identifier, user, err)
+		}
+	}
+	if user, err := svc.ValidateCredentials(ctx, "john@example.com", "Str0ng-passw0rd"); err != nil || user.ID != noUsername.ID {
+		t.Errorf("expected users without a username to log in by email, got %v, %v", user, err)
+	}
+	for _, identifier := range []string{"jane_doe", "nobody", ""} {
+		if _, err := svc.ValidateCredentials(ctx, identifier, "wrong-passw0rd"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%q: expected ErrInvalidCredentials, got %v", identifier, err)
+		}
+	}
+}
+
+func TestUpdateKeepsPassword(t *testing.T) {
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	jane := &model.User{Name: "Jane", Email: "jane@example.com", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, jane); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Update the user the way PUT /users/:id does, merging the request into the stored user
+	existing, err := svc.GetByID(ctx, jane.ID.Hex())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := &dto.UpdateUserRequest{Name: "Jane Doe"}
+	if err := svc.Update(ctx, jane.ID.Hex(), req.ToModel(existing)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	user, err := svc.ValidateCredentials(ctx, "jane@example.com", "Str0ng-passw0rd")
+	if err != nil {
+		t.Fatalf("expected the password to still log in after an update, got %v", err)
+	}
+	if user.Name != "Jane Doe" {
+		t.Errorf("expected the name to be updated, got %q", user.Name)
+	}
+}
+
+func TestUsernameValidationAndUniqueness(t *testing.T) {
+	repo := newFakeUserRepo()
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	jane := &model.User{Name: "Jane", Email: "jane@example.com", Username: "jane_doe", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, jane); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	taken := &model.User{Name: "Other", Email: "other@example.com", Username: "JANE_DOE", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, taken); !errors.Is(err, ErrUsernameExists) {
+		t.Errorf("expected ErrUsernameExists for a case variant, got %v", err)
+	}
+	for _, username := range []string{"ab", "has space", "at@sign", strings.Repeat("a", 31)} {
+		u := &model.User{Name: "Other", Email: "other@example.com", Username: username, Password: "Str0ng-passw0rd"}
+		if err := svc.Create(ctx, u); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("%q: expected ErrInvalidUsername, got %v", username, err)
+		}
+	}
+
+	batch := []*model.User{
+		{Name: "A", Email: "a@example.com", Username: "same", Password: "Str0ng-passw0rd"},
+		{Name: "B", Email: "b@example.com", Username: "Same", Password: "Str0ng-passw0rd"},
+	}
+	if err := svc.CreateUsers(ctx, batch); !errors.Is(err, ErrUsernameExists) {
+		t.Errorf("expected ErrUsernameExists for duplicates within a batch, got %v", err)
+	}
+
+	john := &model.User{Name: "John", Email: "john@example.com", Password: "Str0ng-passw0rd"}
+	if err := svc.Create(ctx, john); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Update(ctx, john.ID.Hex(), &model.User{Username: "Jane_Doe"}); !errors.Is(err, ErrUsernameExists) {
+		t.Errorf("expected ErrUsernameExists when taking another user's username, got %v", err)
+	}
+	// Changing only the case of one's own username is not a conflict
+	if err := svc.Update(ctx, jane.ID.Hex(), &model.User{Username: "Jane_Doe"}); err != nil {
+		t.Errorf("unexpected error re-casing own username: %v", err)
+	}
+
+	other := newTestUser(model.RoleUser)
+	other.Username = "other_user"
+	repo.users[other.ID.Hex()] = other
+	batches := []struct {
+		updates map[string]map[string]interface{}
+		want    error
+	}{
+		{map[string]map[string]interface{}{john.ID.Hex(): {"username": "JANE_DOE"}}, ErrUsernameExists},
+		{map[string]map[string]interface{}{john.ID.Hex(): {"username": "has space"}}, ErrInvalidUsername},
+		{map[string]map[string]interface{}{john.ID.Hex(): {"username": "twin"}, other.ID.Hex(): {"username": "Twin"}}, ErrUsernameExists},
+		{map[string]map[string]interface{}{john.ID.Hex(): {"username": "john_doe"}, other.ID.Hex(): {"username": "OTHER_user"}}, nil},
+	}
+	for _, tt := range batches {
+		if _, err := svc.UpdateUsersByFilter(ctx, tt.updates, nil); !errors.Is(err, tt.want) {
+			t.Errorf("UpdateUsersByFilter(%v): expected %v, got %v", tt.updates, tt.want, err)
+		}
+	}
+}
+
+// syncedUserRepo guards fakeUserRepo with a mutex and implements FindOrCreate as an atomic upsert
+type syncedUserRepo struct {
+	*fakeUserRepo
+	mu      sync.Mutex
+	inserts int
+}
+
+func (r *syncedUserRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fakeUserRepo.FindByEmail(ctx, email)
+}
+
+func (r *syncedUserRepo) FindOrCreate(ctx context.Context, user *model.User) (bool, *model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, err := r.fakeUserRepo.FindByEmail(ctx, user.Email); err == nil {
+		return false, existing, nil
+	}
+	r.inserts++
+	r.fakeUserRepo.Create(ctx, user)
+	return true, user, nil
+}
+
+func TestFindOrCreateCreatesOnce(t *testing.T) {
+	repo := &syncedUserRepo{fakeUserRepo: newFakeUserRepo()}
+	svc := NewUserService(repo, nil, nil, nil)
+	ctx := context.Background()
+
+	const callers = 8
+	type result struct {
+		created bool
+		user    *model.User
+		err     error
+	}
+	results := make(chan result, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, user, err := svc.FindOrCreate(ctx, &model.User{Name: "Jane", Email: "Jane@Example.com", Password: "secret123"})
+			results <- result{created, user, err}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	created := 0
+	var id primitive.ObjectID
+	for r := range results {
+		if r.err != nil {
+			t.Fatalf("unexpected error: %v", r.err)
+		}
+		if id.IsZero() {
+			id = r.user.ID
+		} else if r.user.ID != id {
+			t.Errorf("expected every caller to get user %v, got %v", id, r.user.ID)
+		}
+		if r.created {
+			created++
+			if r.user.IssuedApiKey == "" || secutil.VerifyPassword(r.user.Password, "secret123") != nil {
+				t.Errorf("expected the created user to be set up like Create, got %+v", r.user)
+			}
+		} else if r.user.IssuedApiKey != "" {
+			t.Error("expected no API key to be issued for an existing user")
+		}
+	}
+	if created != 1 || repo.inserts != 1 {
+		t.Errorf("expected exactly one creation, got %d reported and %d inserted", created, repo.inserts)
+	}
+
+	// Later syncs return the stored user without touching it
+	again, user, err := svc.FindOrCreate(ctx, &model.User{Name: "Renamed", Email: "jane@example.com", Password: "other-password"})
+	if err != nil || again || user.ID != id || user.Name != "Jane" {
+		t.Errorf("expected the existing user back unchanged, got %v %+v %v", again, user, err)
+	}
+}
+
+// fakeAuditRepo collects audit logs in memory, optionally failing every write
+type fakeAuditRepo struct {
+	repository.AuditLogRepository
+	logs []*model.AuditLog
+	err  error
+}
+
+func (r *fakeAuditRepo) Create(ctx context.Context, log *model.AuditLog) error {
+	if r.err != nil {
+		return r.err
+	}
+	log.ID = primitive.NewObjectID()
+	r.logs = append(r.logs, log)
+	return nil
+}
+
+func TestImpersonateRecordsAudit(t *testing.T) {
+	admin := newTestUser(model.RoleAdmin)
+	target := newTestUser(model.RoleUser)
+	audit := &fakeAuditRepo{}
+	svc := NewUserService(newFakeUserRepo(admin, target), nil, nil, audit)
+
+	user, err := svc.Impersonate(context.Background(), admin.ID.Hex(), target.ID.Hex(), "203.0.113.7")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != target.ID {
+		t.Errorf("expected the target user, got %s", user.ID.Hex())
+	}
+
+	if len(audit.logs) != 1 {
+		t.Fatalf("expected one audit record, got %d", len(audit.logs))
+	}
+	got := audit.logs[0]
+	if got.Action != model.AuditActionImpersonate || got.ActorID != admin.ID.Hex() || got.TargetID != target.ID.Hex() || got.RemoteIP != "203.0.113.7" {
+		t.Errorf("unexpected audit record %+v", got)
+	}
+}
+
+func TestImpersonateRejections(t *testing.T) {
+	admin := newTestUser(model.RoleAdmin)
+	otherAdmin := newTestUser(model.RoleAdmin)
+	target := newTestUser(model.RoleUser)
+
+	tests := []struct {
+		name   string
+		audit  repository.AuditLogRepository
+		target string
+		want   error
+	}{
+		{"no audit log", nil, target.ID.Hex(), ErrImpersonationUnavailable},
+		{"self", &fakeAuditRepo{}, admin.ID.Hex(), ErrImpersonateSelf},
+		{"admin target", &fakeAuditRepo{}, otherAdmin.ID.Hex(), ErrImpersonateAdmin},
+		{"unknown user", &fakeAuditRepo{}, primitive.NewObjectID().Hex(), ErrUserNotFound},
+		{"audit write fails", &fakeAuditRepo{err: errors.New("write failed")}, target.ID.Hex(), nil},
+	}
+	for _, tt := range tests {
+		svc := NewUserService(newFakeUserRepo(admin, otherAdmin, target), nil, nil, tt.audit)
+		user, err := svc.Impersonate(context.Background(), admin.ID.Hex(), tt.target, "")
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.want, err)
+		}
+		if user != nil {
+			t.Errorf("%s: expected no user without an audit record, got %s", tt.name, user.ID.Hex())
+		}
+	}
+}
+
+func TestValidateCredentialsUpgradesOutdatedHash(t *testing.T) {
+	defer SetPasswordCost(secutil.DefaultCost)
+	SetPasswordCost(secutil.MinCost + 1)
+
+	user := newTestUser(model.RoleUser)
+	oldHash, err := secutil.HashPasswordWithCost("Str0ng-passw0rd", secutil.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user.Password = oldHash
+	repo := newFakeUserRepo(user)
+	svc := NewUserService(repo, nil, nil, nil)
+
+	if _, err := svc.ValidateCredentials(context.Background(), user.Email, "Wrong-passw0rd"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if repo.users[user.ID.Hex()].Password != oldHash {
+		t.Fatal("expected a failed login to leave the hash alone")
+	}
+
+	if _, err := svc.ValidateCredentials(context.Background(), user.Email, "Str0ng-passw0rd"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	upgraded := repo.users[user.ID.Hex()].Password
+	if upgraded == oldHash || secutil.NeedsRehash(upgraded, secutil.MinCost+1) {
+		t.Fatalf("expected the hash to be upgraded to cost %d", secutil.MinCost+1)
+	}
+	if err := secutil.VerifyPassword(upgraded, "Str0ng-passw0rd"); err != nil {
+		t.Errorf("expected the upgraded hash to verify, got %v", err)
+	}
+
+	// A current hash is not rewritten on later logins
+	if _, err := svc.ValidateCredentials(context.Background(), user.Email, "Str0ng-passw0rd"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.users[user.ID.Hex()].Password != upgraded {
+		t.Error("expected a current hash to be kept")
+	}
+}
+
+func TestUpgradePasswordHashKeepsChangedPassword(t *testing.T) {
+	defer SetPasswordCost(secutil.DefaultCost)
+	SetPasswordCost(secutil.MinCost + 1)
+
+	oldHash, err := secutil.HashPasswordWithCost("Str0ng-passw0rd", secutil.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	changedHash, err := secutil.HashPasswordWithCost("N3w-passw0rd!", secutil.MinCost+1)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	stored := newTestUser(model.RoleUser)
+	stored.Password = changedHash
+	repo := newFakeUserRepo(stored)
+	svc := NewUserService(repo, nil, nil, nil).(*userService)
+
+	// The login verified the old hash, but the password was changed before the upgrade was stored
+	verified := *stored
+	verified.Password = oldHash
+	svc.upgradePasswordHash(context.Background(), &verified, "Str0ng-passw0rd")
+
+	if repo.users[stored.ID.Hex()].Password != changedHash {
+		t.Error("expected the changed password to be kept")
+	}
+	if verified.Password != oldHash {
+		t.Error("expected the user to keep the hash that was not replaced")
+	}
+}
+
+// fakeSetNX records keys set with SetNX; other Redis methods panic
+type fakeSetNX struct {
+	redisrepo.Repository
+	keys map[string]bool
+}
+
+func (r *fakeSetNX) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if r.keys == nil {
+		r.keys = make(map[string]bool)
+	}
+	if r.keys[key] {
+		return false, nil
+	}
+	r.keys[key] = true
+	return true, nil
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := secutil.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	enrolled := newTestUser(model.RoleUser)
+	enrolled.TOTPSecret = secret
+	enrolled.TOTPEnabled = true
+	plain := newTestUser(model.RoleUser)
+	svc := NewUserService(newFakeUserRepo(enrolled, plain), &fakeSetNX{}, nil, nil)
+	ctx := context.Background()
+
+	if err := svc.VerifyTOTP(ctx, plain, ""); err != nil {
+		t.Errorf("expected users without TOTP to pass, got %v", err)
+	}
+	if err := svc.VerifyTOTP(ctx, enrolled, ""); !errors.Is(err, ErrTOTPRequired) {
+		t.Errorf("expected ErrTOTPRequired, got %v", err)
+	}
+	if err := svc.VerifyTOTP(ctx, enrolled, "12345"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("expected ErrInvalidTOTP for a malformed code, got %v", err)
+	}
+
+	code := secutil.TOTPCode(secret, time.Now())
+	if err := svc.VerifyTOTP(ctx, enrolled, code); err != nil {
+		t.Fatalf("expected the current code to verify, got %v", err)
+	}
+	if err := svc.VerifyTOTP(ctx, enrolled, code); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("expected a replayed code to be rejected, got %v", err)
+	}
+}
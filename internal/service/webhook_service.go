@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/repository"
+	"go-echo-mongo/pkg/secutil"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	// Webhook service errors
+	ErrWebhookDeliveryFailed = errors.New("webhook delivery failed")
+	ErrDeadLetterNotFound    = errors.New("dead letter not found")
+)
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookConfig configures webhook delivery
+type WebhookConfig struct {
+	// URL receives every delivered event
+	URL string
+	// Secret signs request bodies with secutil.SignPayload; if empty, requests are unsigned
+	Secret string
+	// MaxAttempts is how many times a delivery is tried before it is dead-lettered
+	MaxAttempts int
+	// RetryWait is the delay before the first retry; it doubles after each failed attempt
+	RetryWait time.Duration
+	// Client sends the requests
+	Client *http.Client
+}
+
+// DefaultWebhookConfig is the default webhook delivery config
+var DefaultWebhookConfig = WebhookConfig{
+	MaxAttempts: 3,
+	RetryWait:   time.Second,
+	Client:      &http.Client{Timeout: 10 * time.Second},
+}
+
+// WebhookService delivers events to a webhook endpoint and keeps exhausted deliveries
+// in a dead-letter store so they can be inspected and replayed
+type WebhookService interface {
+	Deliver(ctx context.Context, eventType string, payload []byte) error
+	GetDeadLetters(ctx context.Context, page, itemsPerPage int64) ([]*model.DeadLetter, int64, error)
+	ReplayDeadLetter(ctx context.Context, id string) error
+}
+
+type webhookService struct {
+	deadLetters repository.DeadLetterRepository
+	config      WebhookConfig
+}
+
+// NewWebhookService creates a new WebhookService instance
+func NewWebhookService(deadLetters repository.DeadLetterRepository, config WebhookConfig) WebhookService {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = DefaultWebhookConfig.MaxAttempts
+	}
+	if config.RetryWait <= 0 {
+		config.RetryWait = DefaultWebhookConfig.RetryWait
+	}
+	if config.Client == nil {
+		config.Client = DefaultWebhookConfig.Client
+	}
+	return &webhookService{deadLetters: deadLetters, config: config}
+}
+
+// Deliver posts payload to the webhook URL, retrying failed attempts.
+// When every attempt fails the delivery is recorded as a dead letter and ErrWebhookDeliveryFailed is returned.
+func (s *webhookService) Deliver(ctx context.Context, eventType string, payload []byte) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	attempts, err := s.send(ctx, s.config.URL, eventType, payload)
+	if err == nil {
+		return nil
+	}
+
+	deadLetter := &model.DeadLetter{
+		URL:       s.config.URL,
+		EventType: eventType,
+		Payload:   string(payload),
+		Attempts:  attempts,
+		LastError: err.Error(),
+	}
+	// Record the dead letter even if the caller's context was cancelled mid-retry
+	if storeErr := s.deadLetters.Create(context.WithoutCancel(ctx), deadLetter); storeErr != nil {
+		slog.Error("Failed to store webhook dead letter", "event", eventType, "error", storeErr)
+	}
+
+	return fmt.Errorf("%w after %d attempts: %w", ErrWebhookDeliveryFailed, attempts, err)
+}
+
+// GetDeadLetters retrieves dead letters, newest first, with the total count
+func (s *webhookService) GetDeadLetters(ctx context.Context, page, itemsPerPage int64) ([]*model.DeadLetter, int64, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.deadLetters.FindPaginated(ctx, nil, Sort{Field: "created_at", Order: SortDesc}, page, itemsPerPage)
+}
+
+// ReplayDeadLetter delivers a dead letter again. It is removed from the store on success;
+// otherwise its attempts and last error are updated.
+func (s *webhookService) ReplayDeadLetter(ctx context.Context, id string) error {
+	if err := validateContext(ctx); err != nil {
+		return err
+	}
+
+	deadLetter, err := s.deadLetters.FindByID(ctx, id)
+	if err != nil {
+		return ErrDeadLetterNotFound
+	}
+
+	attempts, sendErr := s.send(ctx, deadLetter.URL, deadLetter.EventType, []byte(deadLetter.Payload))
+	if sendErr == nil {
+		return s.deadLetters.Delete(ctx, id)
+	}
+
+	update := bson.M{
+		"attempts":         deadLetter.Attempts + attempts,
+		"last_error":       sendErr.Error(),
+		"last_replayed_at": time.Now().UTC(),
+	}
+	if err := s.deadLetters.UpdateFields(context.WithoutCancel(ctx), id, update); err != nil {
+		slog.Error("Failed to update webhook dead letter", "id", id, "error", err)
+	}
+
+	return fmt.Errorf("%w after %d attempts: %w", ErrWebhookDeliveryFailed, attempts, sendErr)
+}
+
+// send tries to deliver payload up to MaxAttempts times and returns the number of attempts made
+// along with the last error, or nil once an attempt succeeds
+func (s *webhookService) send(ctx context.Context, url, eventType string, payload []byte) (int, error) {
+	var lastErr error
+	wait := s.config.RetryWait
+	for attempt := 1; attempt <= s.config.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return attempt - 1, lastErr
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+
+		if lastErr = s.post(ctx, url, eventType, payload); lastErr == nil {
+			return attempt, nil
+		}
+		slog.Warn("Webhook delivery attempt failed", "event", eventType, "attempt", attempt, "error", lastErr)
+	}
+	return s.config.MaxAttempts, lastErr
+}
+
+// post makes a single delivery attempt; any non-2xx response is a failure
+func (s *webhookService) post(ctx context.Context, url, eventType string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, eventType)
+	if s.config.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, secutil.SignPayload(payload, s.config.Secret))
+	}
+
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookEvent is an event waiting in a WebhookDispatcher queue
+type webhookEvent struct {
+	eventType string
+	payload   []byte
+}
+
+// WebhookDispatcher queues events and delivers them on a background worker,
+// so neither request handlers nor the event subscriber wait on the webhook endpoint
+type WebhookDispatcher struct {
+	webhooks WebhookService
+	queue    chan webhookEvent
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewWebhookDispatcher starts a worker that delivers queued events through webhooks.
+// queueSize bounds how many events can wait for delivery; values below 1 mean 1.
+func NewWebhookDispatcher(webhooks WebhookService, queueSize int) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		webhooks: webhooks,
+		queue:    make(chan webhookEvent, max(queueSize, 1)),
+		done:     make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue queues an event for delivery without blocking. It returns false, and the
+// event is dropped, when the queue is full or the dispatcher is closed.
+func (d *WebhookDispatcher) Enqueue(eventType string, payload []byte) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return false
+	}
+
+	select {
+	case d.queue <- webhookEvent{eventType: eventType, payload: payload}:
+		return true
+	default:
+		slog.Error("Webhook queue is full, dropping event", "event", eventType)
+		return false
+	}
+}
+
+// Close stops accepting events and waits for the ones already queued to be delivered
+func (d *WebhookDispatcher) Close() error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	<-d.done
+	return nil
+}
+
+func (d *WebhookDispatcher) run() {
+	defer close(d.done)
+	for event := range d.queue {
+		// Failed deliveries are dead-lettered by the service
+		_ = d.webhooks.Deliver(context.Background(), event.eventType, event.payload)
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/internal/repository"
+	"go-echo-mongo/pkg/secutil"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeDeadLetterRepo is an in-memory DeadLetterRepository; unimplemented methods panic
+type fakeDeadLetterRepo struct {
+	repository.DeadLetterRepository
+	deadLetters map[string]*model.DeadLetter
+}
+
+func newFakeDeadLetterRepo() *fakeDeadLetterRepo {
+	return &fakeDeadLetterRepo{deadLetters: make(map[string]*model.DeadLetter)}
+}
+
+func (r *fakeDeadLetterRepo) Create(ctx context.Context, deadLetter *model.DeadLetter) error {
+	deadLetter.ID = primitive.NewObjectID()
+	clone := *deadLetter
+	r.deadLetters[deadLetter.ID.Hex()] = &clone
+	return nil
+}
+
+func (r *fakeDeadLetterRepo) FindByID(ctx context.Context, id string) (*model.DeadLetter, error) {
+	d, ok := r.deadLetters[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	clone := *d
+	return &clone, nil
+}
+
+// UpdateFields only supports the fields changed by a failed replay
+func (r *fakeDeadLetterRepo) UpdateFields(ctx context.Context, id string, fields bson.M) error {
+	d, ok := r.deadLetters[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	d.Attempts = fields["attempts"].(int)
+	d.LastError = fields["last_error"].(string)
+	replayedAt := fields["last_replayed_at"].(time.Time)
+	d.LastReplayedAt = &replayedAt
+	return nil
+}
+
+func (r *fakeDeadLetterRepo) Delete(ctx context.Context, id string) error {
+	if _, ok := r.deadLetters[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.deadLetters, id)
+	return nil
+}
+
+// webhookEndpoint counts requests and answers with status until it is changed
+type webhookEndpoint struct {
+	*httptest.Server
+	status   atomic.Int32
+	requests atomic.Int32
+}
+
+func newWebhookEndpoint(t *testing.T, status int, secret string) *webhookEndpoint {
+	ep := &webhookEndpoint{}
+	ep.status.Store(int32(status))
+	ep.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ep.requests.Add(1)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+		if err := secutil.VerifyPayload(body, r.Header.Get(WebhookSignatureHeader), secret, time.Minute); err != nil {
+			t.Errorf("expected a valid signature, got %v", err)
+		}
+		w.WriteHeader(int(ep.status.Load()))
+	}))
+	t.Cleanup(ep.Close)
+	return ep
+}
+
+func TestWebhookDeliverDeadLettersAfterRetries(t *testing.T) {
+	ep := newWebhookEndpoint(t, http.StatusInternalServerError, "hook-secret")
+	repo := newFakeDeadLetterRepo()
+	svc := NewWebhookService(repo, WebhookConfig{URL: ep.URL, Secret: "hook-secret", MaxAttempts: 3, RetryWait: time.Millisecond})
+	payload := []byte(`{"type":"product.restocked","data":{"quantity":5}}`)
+
+	err := svc.Deliver(context.Background(), EventProductRestocked, payload)
+	if !errors.Is(err, ErrWebhookDeliveryFailed) {
+		t.Fatalf("expected ErrWebhookDeliveryFailed, got %v", err)
+	}
+	if got := ep.requests.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+
+	if len(repo.deadLetters) != 1 {
+		t.Fatalf("expected one dead letter, got %d", len(repo.deadLetters))
+	}
+	var deadLetter *model.DeadLetter
+	for _, d := range repo.deadLetters {
+		deadLetter = d
+	}
+	if deadLetter.URL != ep.URL || deadLetter.EventType != EventProductRestocked || deadLetter.Payload != string(payload) {
+		t.Errorf("expected the delivery to be recorded as sent, got %+v", deadLetter)
+	}
+	if deadLetter.Attempts != 3 || !strings.Contains(deadLetter.LastError, "500") {
+		t.Errorf("expected 3 attempts ending in a 500, got %d attempts and %q", deadLetter.Attempts, deadLetter.LastError)
+	}
+
+	// A replay against the still-failing endpoint keeps the dead letter with more attempts
+	id := deadLetter.ID.Hex()
+	if err := svc.ReplayDeadLetter(context.Background(), id); !errors.Is(err, ErrWebhookDeliveryFailed) {
+		t.Fatalf("expected the replay to fail, got %v", err)
+	}
+	if d := repo.deadLetters[id]; d.Attempts != 6 || d.LastReplayedAt == nil {
+		t.Errorf("expected 6 attempts and a replay time, got %+v", d)
+	}
+
+	// Once the endpoint recovers the replay succeeds and the dead letter is removed
+	ep.status.Store(http.StatusNoContent)
+	if err := svc.ReplayDeadLetter(context.Background(), id); err != nil {
+		t.Fatalf("unexpected replay error: %v", err)
+	}
+	if len(repo.deadLetters) != 0 {
+		t.Errorf("expected the dead letter to be removed, got %v", repo.deadLetters)
+	}
+
+	if err := svc.ReplayDeadLetter(context.Background(), id); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("expected ErrDeadLetterNotFound, got %v", err)
+	}
+}
+
+func TestWebhookDeliverSucceedsWithoutDeadLetter(t *testing.T) {
+	ep := newWebhookEndpoint(t, http.StatusOK, "hook-secret")
+	repo := newFakeDeadLetterRepo()
+	svc := NewWebhookService(repo, WebhookConfig{URL: ep.URL, Secret: "hook-secret", RetryWait: time.Millisecond})
+
+	if err := svc.Deliver(context.Background(), EventProductDeleted, []byte(`{}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ep.requests.Load() != 1 || len(repo.deadLetters) != 0 {
+		t.Errorf("expected a single attempt and no dead letter, got %d attempts and %d dead letters", ep.requests.Load(), len(repo.deadLetters))
+	}
+}
+
+// blockingWebhooks records delivered events, holding each delivery until release is closed
+type blockingWebhooks struct {
+	WebhookService
+	release   chan struct{}
+	delivered chan string
+}
+
+func (w *blockingWebhooks) Deliver(ctx context.Context, eventType string, payload []byte) error {
+	<-w.release
+	w.delivered <- eventType
+	return nil
+}
+
+func TestWebhookDispatcherDeliversInBackground(t *testing.T) {
+	webhooks := &blockingWebhooks{release: make(chan struct{}), delivered: make(chan string, 3)}
+	d := NewWebhookDispatcher(webhooks, 1)
+
+	// The first event is picked up by the worker, which blocks on it; the second fills the queue
+	if !d.Enqueue(EventProductRestocked, []byte(`{}`)) {
+		t.Fatal("expected the first event to be queued")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !d.Enqueue(EventProductDeleted, []byte(`{}`)) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the worker to take the first event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if d.Enqueue(EventProductRestocked, []byte(`{}`)) {
+		t.Error("expected an event to be dropped while the queue is full")
+	}
+
+	close(webhooks.release)
+	if err := d.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := len(webhooks.delivered); got != 2 {
+		t.Errorf("expected Close to wait for 2 queued deliveries, got %d", got)
+	}
+	if d.Enqueue(EventProductRestocked, []byte(`{}`)) {
+		t.Error("expected Enqueue to refuse events after Close")
+	}
+}
@@ -2,27 +2,49 @@ package httpclient
 
 import (
 	"net/http"
+	"os"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 // Client is a wrapper around http.Client with additional functionality
 type Client struct {
-	client     *http.Client
-	baseURL    string
-	headers    map[string]string
-	timeout    time.Duration
-	maxRetries int
-	retryDelay time.Duration
+	client          *http.Client
+	baseURL         string
+	headers         map[string]string
+	timeout         time.Duration
+	maxRetries      int
+	retryDelay      time.Duration
+	retryMaxDelay   time.Duration
+	retryMultiplier float64
+	retryPolicy     RetryPolicy
+	// disableCompression stops the client from asking for compressed responses
+	disableCompression bool
+	// requestInterceptors and responseInterceptors run in order around every request
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+	// limiter paces outbound attempts when set by WithRateLimit
+	limiter *rate.Limiter
+	// jitter picks the actual wait for a backoff delay; defaults to fullJitter
+	jitter func(time.Duration) time.Duration
+	// fileMode is the exact mode DownloadToFile gives files when set by WithFileMode;
+	// otherwise they get 0644 less the umask
+	fileMode os.FileMode
 }
 
 // NewClient creates a new HTTP client with the given options
 func NewClient(options ...ClientOption) *Client {
 	c := &Client{
-		client:     &http.Client{},
-		headers:    make(map[string]string),
-		timeout:    30 * time.Second,
-		maxRetries: 3,
-		retryDelay: 1 * time.Second,
+		client:          &http.Client{},
+		headers:         make(map[string]string),
+		timeout:         30 * time.Second,
+		maxRetries:      3,
+		retryDelay:      1 * time.Second,
+		retryMaxDelay:   30 * time.Second,
+		retryMultiplier: 2,
+		retryPolicy:     DefaultRetryPolicy,
+		jitter:          fullJitter,
 	}
 
 	for _, option := range options {
@@ -55,6 +77,9 @@ type Request struct {
 	Headers map[string]string
 	Body    interface{}
 	Query   map[string]string
+	// Timeout bounds the whole request, including retries and reading the response.
+	// It applies on top of the caller's context, so whichever deadline comes first wins.
+	Timeout time.Duration
 }
 
 // Helper function to check if a URL is absolute
@@ -0,0 +1,90 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is advertised on every request unless compression is disabled
+const acceptEncoding = "gzip, deflate"
+
+// setAcceptEncoding asks for a compressed response unless compression is disabled or the
+// caller chose an encoding. Setting the header ourselves means the transport leaves the
+// body alone, so decodeBody handles every encoding the same way.
+func (c *Client) setAcceptEncoding(httpReq *http.Request) {
+	if httpReq.Header.Get("Accept-Encoding") != "" {
+		return
+	}
+	if c.disableCompression {
+		httpReq.Header.Set("Accept-Encoding", "identity")
+		return
+	}
+	httpReq.Header.Set("Accept-Encoding", acceptEncoding)
+}
+
+// decodeBody wraps resp.Body in a decompressing reader matching its Content-Encoding and
+// drops the encoding headers, which no longer describe the body that is read. Responses
+// without a body are returned as they are, since their headers describe a body not sent.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	if !hasBody(resp) {
+		return resp.Body, nil
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+
+	var reader io.ReadCloser
+	switch encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip response: %w", err)
+		}
+		reader = gz
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send a raw deflate stream
+		buffered := bufio.NewReader(resp.Body)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress deflate response: %w", err)
+			}
+			reader = zr
+		} else {
+			reader = flate.NewReader(buffered)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported response content encoding %q", encoding)
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return reader, nil
+}
+
+// hasBody reports whether resp can carry a body: HEAD responses, 1xx, 204 and 304 responses
+// have none, and neither does a response that declares a Content-Length of zero
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch {
+	case resp.StatusCode >= 100 && resp.StatusCode < 200,
+		resp.StatusCode == http.StatusNoContent,
+		resp.StatusCode == http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+// isZlibHeader reports whether b starts with a zlib stream header (RFC 1950)
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// compressingServer responds with a JSON body compressed by encode under the given
+// Content-Encoding and records the Accept-Encoding header it received
+func compressingServer(t *testing.T, encoding string, encode func(io.Writer) io.WriteCloser) (*httptest.Server, *string) {
+	var accepted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+
+		var buf bytes.Buffer
+		zw := encode(&buf)
+		zw.Write([]byte(`{"name":"Keyboard","price":49.5}`))
+		zw.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	return server, &accepted
+}
+
+func TestGetJSONDecompressesResponses(t *testing.T) {
+	tests := []struct {
+		encoding string
+		encode   func(io.Writer) io.WriteCloser
+	}{
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+		{"deflate", func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}},
+	}
+
+	for _, tt := range tests {
+		server, accepted := compressingServer(t, tt.encoding, tt.encode)
+		c := NewClient(WithRetryCount(0))
+
+		var product struct {
+			Name  string  `json:"name"`
+			Price float64 `json:"price"`
+		}
+		if err := c.GetJSON(context.Background(), server.URL, nil, &product); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.encoding, err)
+		}
+		if product.Name != "Keyboard" || product.Price != 49.5 {
+			t.Errorf("%s: expected the decoded product, got %+v", tt.encoding, product)
+		}
+		if *accepted != "gzip, deflate" {
+			t.Errorf("%s: expected compressed responses to be requested, got %q", tt.encoding, *accepted)
+		}
+	}
+}
+
+func TestDisableCompression(t *testing.T) {
+	server, accepted := compressingServer(t, "gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	c := NewClient(WithRetryCount(0), WithDisableCompression(true))
+
+	resp, err := c.Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *accepted != "identity" {
+		t.Errorf("expected an uncompressed response to be requested, got %q", *accepted)
+	}
+	// A server that compresses anyway is still decoded
+	if string(resp.Body) != `{"name":"Keyboard","price":49.5}` || resp.Headers.Get("Content-Encoding") != "" {
+		t.Errorf("expected the decoded body without Content-Encoding, got %q (%v)", resp.Body, resp.Headers)
+	}
+}
+
+func TestBodylessResponsesAreNotDecompressed(t *testing.T) {
+	// The server claims gzip, as it would for the body a GET returns, but sends none
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		switch r.URL.Path {
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		case "/empty":
+			w.Header().Set("Content-Length", "0")
+		}
+	}))
+	t.Cleanup(server.Close)
+	c := NewClient(WithRetryCount(0))
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodHead, "/", http.StatusOK},
+		{http.MethodGet, "/no-content", http.StatusNoContent},
+		{http.MethodGet, "/not-modified", http.StatusNotModified},
+		{http.MethodGet, "/empty", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		resp, err := c.Do(context.Background(), Request{Method: tt.method, URL: server.URL + tt.path})
+		if err != nil {
+			t.Fatalf("%s %s: unexpected error: %v", tt.method, tt.path, err)
+		}
+		if resp.StatusCode != tt.want || len(resp.Body) != 0 {
+			t.Errorf("%s %s: expected an empty %d, got %d with %q", tt.method, tt.path, tt.want, resp.StatusCode, resp.Body)
+		}
+		if resp.Headers.Get("Content-Encoding") != "gzip" {
+			t.Errorf("%s %s: expected the encoding header to be kept, got %q", tt.method, tt.path, resp.Headers.Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Download sends a GET request and streams the response body to dest without buffering it,
+// returning the number of bytes written. Non-2xx responses are returned as errors and
+// nothing is written. Response interceptors see the status and headers but no body.
+func (c *Client) Download(ctx context.Context, url string, dest io.Writer, headers map[string]string) (int64, error) {
+	fullURL := url
+	if c.baseURL != "" && !isAbsoluteURL(url) {
+		fullURL = fmt.Sprintf("%s/%s", c.baseURL, url)
+	}
+
+	httpReq, err := newRequest(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Add client-level headers
+	for key, value := range c.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	// Add request-specific headers
+	for key, value := range headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := c.send(ctx, httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Include the start of the body; error pages are small but a wrong URL may not be
+		snippet, _ := io.ReadAll(io.LimitReader(reader, 4<<10))
+		return 0, fmt.Errorf("request failed with status code %d: %s", resp.StatusCode, string(snippet))
+	}
+	if err := c.interceptResponse(&Response{StatusCode: resp.StatusCode, Headers: resp.Header}); err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(dest, reader)
+	if err != nil {
+		return written, fmt.Errorf("failed to download response body: %w", err)
+	}
+	return written, nil
+}
+
+// DownloadToFile downloads url into the file at path, returning the number of bytes written.
+// The body is written to a temporary file next to path that replaces it only once the
+// download completes, so a failed download never leaves a partial file behind.
+// The file gets the mode set by WithFileMode, or 0644 less the umask.
+func (c *Client) DownloadToFile(ctx context.Context, url, path string, headers map[string]string) (int64, error) {
+	tmp, err := createPartFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := c.Download(ctx, url, tmp, headers)
+	if err == nil && c.fileMode != 0 {
+		if chmodErr := tmp.Chmod(c.fileMode); chmodErr != nil {
+			err = fmt.Errorf("failed to set file mode: %w", chmodErr)
+		}
+	}
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file: %w", closeErr)
+	}
+	if err != nil {
+		return written, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return written, fmt.Errorf("failed to move download into place: %w", err)
+	}
+	return written, nil
+}
+
+// createPartFile creates a uniquely named temporary file next to path with mode 0644 less
+// the umask. os.CreateTemp is not used because it always creates files 0600.
+func createPartFile(path string) (*os.File, error) {
+	dir, base := filepath.Split(path)
+	for range 100 {
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(rand.Uint64(), 36)+".part")
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if !errors.Is(err, fs.ErrExist) {
+			return f, err
+		}
+	}
+	return nil, fmt.Errorf("no unused temporary name for %s", path)
+}
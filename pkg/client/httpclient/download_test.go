@@ -0,0 +1,169 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// payloadServer serves payload at /file and a 404 everywhere else
+func payloadServer(t *testing.T, payload []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			http.Error(w, "no such file", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.Copy(w, bytes.NewReader(payload))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func randomPayload(size int) []byte {
+	rng := rand.New(rand.NewPCG(1, 2))
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(rng.UintN(256))
+	}
+	return payload
+}
+
+// hashWriter hashes what is written to it and records the largest single write
+type hashWriter struct {
+	hash.Hash
+	maxWrite int
+}
+
+func (w *hashWriter) Write(p []byte) (int, error) {
+	if len(p) > w.maxWrite {
+		w.maxWrite = len(p)
+	}
+	return w.Hash.Write(p)
+}
+
+func TestDownloadStreamsLargeBodies(t *testing.T) {
+	payload := randomPayload(8 << 20)
+	want := sha256.Sum256(payload)
+	server := payloadServer(t, payload)
+	c := NewClient(WithRetryCount(0))
+
+	dest := &hashWriter{Hash: sha256.New()}
+	written, err := c.Download(context.Background(), server.URL+"/file", dest, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written != int64(len(payload)) {
+		t.Errorf("expected %d bytes, got %d", len(payload), written)
+	}
+	if !bytes.Equal(dest.Sum(nil), want[:]) {
+		t.Error("expected the downloaded content to match the payload")
+	}
+	// The body is copied through in chunks rather than buffered and written at once
+	if dest.maxWrite >= len(payload) {
+		t.Errorf("expected the body to be streamed, got a single %d byte write", dest.maxWrite)
+	}
+}
+
+func TestDownloadFailsOnErrorStatus(t *testing.T) {
+	server := payloadServer(t, nil)
+	c := NewClient(WithRetryCount(0))
+
+	var dest bytes.Buffer
+	written, err := c.Download(context.Background(), server.URL+"/missing", &dest, nil)
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("expected a 404 error with the response body, got %v", err)
+	}
+	if written != 0 || dest.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %d bytes", dest.Len())
+	}
+}
+
+func TestDownloadToFile(t *testing.T) {
+	payload := randomPayload(3 << 20)
+	server := payloadServer(t, payload)
+	c := NewClient(WithRetryCount(0))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "payload.bin")
+
+	written, err := c.DownloadToFile(context.Background(), server.URL+"/file", path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the downloaded file: %v", err)
+	}
+	if written != int64(len(payload)) || sha256.Sum256(got) != sha256.Sum256(payload) {
+		t.Errorf("expected the file to hold the %d byte payload, got %d bytes", len(payload), len(got))
+	}
+
+	// A failed download leaves the existing file and no partial files behind
+	if _, err := c.DownloadToFile(context.Background(), server.URL+"/missing", path, nil); err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the original file to remain, got %v", entries)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, payload) {
+		t.Error("expected a failed download not to overwrite the existing file")
+	}
+}
+
+func TestDownloadToFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not enforced on Windows")
+	}
+	server := payloadServer(t, randomPayload(1<<10))
+	dir := t.TempDir()
+
+	// A file created 0777 reveals the umask the download has to respect
+	probe := filepath.Join(dir, "probe")
+	f, err := os.OpenFile(probe, os.O_CREATE|os.O_WRONLY, 0o777)
+	if err != nil {
+		t.Fatalf("failed to create probe file: %v", err)
+	}
+	f.Close()
+	info, err := os.Stat(probe)
+	if err != nil {
+		t.Fatalf("failed to stat probe file: %v", err)
+	}
+	umask := 0o777 &^ info.Mode().Perm()
+
+	tests := []struct {
+		name    string
+		options []ClientOption
+		want    os.FileMode
+	}{
+		{"default", nil, 0o644 &^ umask},
+		{"private", []ClientOption{WithFileMode(0o600)}, 0o600},
+		{"group writable", []ClientOption{WithFileMode(0o664)}, 0o664},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(append(tt.options, WithRetryCount(0))...)
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".bin")
+			if _, err := c.DownloadToFile(context.Background(), server.URL+"/file", path, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("failed to stat the downloaded file: %v", err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Errorf("expected mode %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequestInterceptor runs before a request is sent, e.g. to add auth headers or log it.
+// Returning an error aborts the request.
+type RequestInterceptor func(*http.Request) error
+
+// ResponseInterceptor runs on the final response, after retries and decompression, e.g. to
+// collect metrics. Returning an error makes the call fail with it.
+type ResponseInterceptor func(*Response) error
+
+// interceptRequest runs the request interceptors in the order they were added
+func (c *Client) interceptRequest(httpReq *http.Request) error {
+	for _, intercept := range c.requestInterceptors {
+		if err := intercept(httpReq); err != nil {
+			return fmt.Errorf("request interceptor failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// interceptResponse runs the response interceptors in the order they were added
+func (c *Client) interceptResponse(resp *Response) error {
+	for _, intercept := range c.responseInterceptors {
+		if err := intercept(resp); err != nil {
+			return fmt.Errorf("response interceptor failed: %w", err)
+		}
+	}
+	return nil
+}
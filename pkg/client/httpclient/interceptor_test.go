@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInterceptorsRunOnEveryMethod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Trace") != "first,second" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var statuses []int
+	c := NewClient(
+		WithRetryCount(0),
+		WithRequestInterceptor(
+			func(r *http.Request) error {
+				r.Header.Set("Authorization", "Bearer token")
+				r.Header.Set("X-Trace", "first")
+				return nil
+			},
+			func(r *http.Request) error {
+				r.Header.Set("X-Trace", r.Header.Get("X-Trace")+",second")
+				return nil
+			},
+		),
+		WithResponseInterceptor(func(resp *Response) error {
+			statuses = append(statuses, resp.StatusCode)
+			return nil
+		}),
+	)
+
+	ctx := context.Background()
+	calls := map[string]func() (*Response, error){
+		"Get":    func() (*Response, error) { return c.Get(ctx, server.URL, nil, nil) },
+		"Post":   func() (*Response, error) { return c.Post(ctx, server.URL, map[string]string{"a": "b"}, nil) },
+		"Delete": func() (*Response, error) { return c.Delete(ctx, server.URL, nil) },
+		"PostForm": func() (*Response, error) {
+			return c.PostForm(ctx, server.URL, map[string]string{"a": "b"}, nil)
+		},
+		"PostMultipartForm": func() (*Response, error) {
+			return c.PostMultipartForm(ctx, server.URL, nil, []FormFile{{FieldName: "file", FileName: "a.txt", FileData: []byte("a")}}, nil)
+		},
+	}
+	for name, call := range calls {
+		statuses = nil
+		resp, err := call()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Errorf("%s: expected the interceptor headers to be sent, got %d", name, resp.StatusCode)
+		}
+		if len(statuses) != 1 || statuses[0] != http.StatusAccepted {
+			t.Errorf("%s: expected the response interceptor to see 202, got %v", name, statuses)
+		}
+	}
+}
+
+func TestInterceptorErrorsAbortTheCall(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	denied := errors.New("denied")
+	c := NewClient(WithRetryCount(0), WithRequestInterceptor(func(r *http.Request) error { return denied }))
+	if _, err := c.Get(context.Background(), server.URL, nil, nil); !errors.Is(err, denied) {
+		t.Errorf("expected the request interceptor error, got %v", err)
+	}
+	if hits != 0 {
+		t.Errorf("expected the request not to be sent, got %d hits", hits)
+	}
+
+	serverError := errors.New("server error")
+	c = NewClient(WithRetryCount(0), WithResponseInterceptor(func(resp *Response) error {
+		if resp.StatusCode >= 500 {
+			return serverError
+		}
+		return nil
+	}))
+	if resp, err := c.Get(context.Background(), server.URL, nil, nil); !errors.Is(err, serverError) || resp != nil {
+		t.Errorf("expected the response interceptor error, got %v, %v", resp, err)
+	}
+}
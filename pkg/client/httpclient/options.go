@@ -1,7 +1,10 @@
 package httpclient
 
 import (
+	"os"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 // ClientOption is a function that configures a Client
@@ -35,19 +38,78 @@ func WithRetryCount(maxRetries int) ClientOption {
 	}
 }
 
-// WithRetryWaitTime sets the delay between retry attempts
+// WithRetryWaitTime sets the base delay before the first retry; later retries back off
+// exponentially from it
 func WithRetryWaitTime(retryDelay time.Duration) ClientOption {
 	return func(c *Client) {
 		c.retryDelay = retryDelay
 	}
 }
 
-// WithRetryMaxWaitTime sets both retry count and delay
+// WithRetryMaxWaitTime caps the delay between retry attempts
 func WithRetryMaxWaitTime(maxWaitTime time.Duration) ClientOption {
 	return func(c *Client) {
-		// This is a placeholder - in a real implementation, you might
-		// calculate an appropriate retry delay based on the max wait time
-		// For now, we'll just set a reasonable default
-		c.retryDelay = maxWaitTime / 5
+		c.retryMaxDelay = maxWaitTime
+	}
+}
+
+// WithRetryMultiplier sets the factor the retry delay grows by after each attempt.
+// A multiplier of 1 keeps the delay constant.
+func WithRetryMultiplier(multiplier float64) ClientOption {
+	return func(c *Client) {
+		c.retryMultiplier = multiplier
+	}
+}
+
+// WithDisableCompression stops the client from asking for gzip or deflate responses.
+// Responses that are compressed anyway are still decompressed.
+func WithDisableCompression(disable bool) ClientOption {
+	return func(c *Client) {
+		c.disableCompression = disable
+	}
+}
+
+// WithRequestInterceptor adds interceptors that run, in order, before every request is sent
+func WithRequestInterceptor(interceptors ...RequestInterceptor) ClientOption {
+	return func(c *Client) {
+		c.requestInterceptors = append(c.requestInterceptors, interceptors...)
+	}
+}
+
+// WithResponseInterceptor adds interceptors that run, in order, on every response
+func WithResponseInterceptor(interceptors ...ResponseInterceptor) ClientOption {
+	return func(c *Client) {
+		c.responseInterceptors = append(c.responseInterceptors, interceptors...)
+	}
+}
+
+// WithRetryPolicy sets which methods and response status codes are retried
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithRateLimit paces outbound requests to perSecond on average, allowing bursts of up to burst.
+// Every attempt, retries included, waits for a slot; the wait ends early if the context is done.
+// A non-positive perSecond disables the limit.
+func WithRateLimit(perSecond float64, burst int) ClientOption {
+	return func(c *Client) {
+		if perSecond <= 0 {
+			c.limiter = nil
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		c.limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+}
+
+// WithFileMode sets the permissions of files written by DownloadToFile, regardless of the umask.
+// By default they are created 0644 less the umask, like os.WriteFile.
+func WithFileMode(mode os.FileMode) ClientOption {
+	return func(c *Client) {
+		c.fileMode = mode
 	}
 }
@@ -6,12 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"math/rand/v2"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
@@ -85,7 +86,7 @@ func (c *Client) PostForm(ctx context.Context, urlStr string, formData map[strin
 	}
 
 	// Create the HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, strings.NewReader(encodedData))
+	httpReq, err := newRequest(ctx, http.MethodPost, fullURL, []byte(encodedData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -169,7 +170,7 @@ func (c *Client) PostMultipartForm(ctx context.Context, url string, formData map
 	}
 
 	// Create the HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, &buf)
+	httpReq, err := newRequest(ctx, http.MethodPost, fullURL, buf.Bytes())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -193,13 +194,13 @@ func (c *Client) PostMultipartForm(ctx context.Context, url string, formData map
 
 // Do sends an HTTP request and returns the response
 func (c *Client) Do(ctx context.Context, req Request) (*Response, error) {
-	var bodyReader io.Reader
+	var bodyBytes []byte
 	if req.Body != nil {
-		bodyBytes, err := json.Marshal(req.Body)
+		var err error
+		bodyBytes, err = json.Marshal(req.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewReader(bodyBytes)
 	}
 
 	// Construct the full URL
@@ -208,8 +209,14 @@ func (c *Client) Do(ctx context.Context, req Request) (*Response, error) {
 		fullURL = fmt.Sprintf("%s/%s", c.baseURL, req.URL)
 	}
 
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
 	// Create the HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, req.Method, fullURL, bodyReader)
+	httpReq, err := newRequest(ctx, req.Method, fullURL, bodyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -240,43 +247,178 @@ func (c *Client) Do(ctx context.Context, req Request) (*Response, error) {
 	return c.executeRequest(ctx, httpReq)
 }
 
-// executeRequest executes an HTTP request with retries
+// newRequest creates a request whose body is buffered, so GetBody can replay it for retries.
+// A nil body sends no body.
+func newRequest(ctx context.Context, method, url string, body []byte) (*http.Request, error) {
+	if body == nil {
+		return http.NewRequestWithContext(ctx, method, url, nil)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return httpReq, nil
+}
+
+// bufferBody reads the request body into memory and sets GetBody so it can be replayed for retries
+func bufferBody(httpReq *http.Request) error {
+	body, err := io.ReadAll(httpReq.Body)
+	httpReq.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to buffer request body: %w", err)
+	}
+
+	httpReq.ContentLength = int64(len(body))
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	httpReq.Body, _ = httpReq.GetBody()
+	return nil
+}
+
+// executeRequest executes an HTTP request and reads the whole response body
 func (c *Client) executeRequest(ctx context.Context, httpReq *http.Request) (*Response, error) {
-	// Execute the request with retries
+	resp, err := c.send(ctx, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the response body, decompressing it if needed
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	result := &Response{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       body,
+	}
+	if err := c.interceptResponse(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// send runs the request interceptors and sends the request, retrying transport errors and
+// retryable status codes as allowed by the retry policy. Once retries run out the last
+// response is returned; the caller must close its body.
+func (c *Client) send(ctx context.Context, httpReq *http.Request) (*http.Response, error) {
 	var resp *http.Response
 	var lastErr error
+	var wait time.Duration
 
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
-		if attempt > 0 {
+	if err := c.interceptRequest(httpReq); err != nil {
+		return nil, err
+	}
+	c.setAcceptEncoding(httpReq)
+	retryable := c.retryPolicy.allowsMethod(httpReq)
+	if retryable && c.maxRetries > 0 && httpReq.GetBody == nil && httpReq.Body != nil && httpReq.Body != http.NoBody {
+		// An interceptor replaced the body without a way to replay it
+		if err := bufferBody(httpReq); err != nil {
+			return nil, err
+		}
+	}
+	attempts := 0
+	for {
+		if attempts > 0 {
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
-			case <-time.After(c.retryDelay):
+			case <-time.After(wait):
 				// Wait before retrying
 			}
+
+			// The previous attempt consumed the body, so send a fresh copy
+			if httpReq.GetBody != nil {
+				body, err := httpReq.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("failed to rewind request body: %w", err)
+				}
+				httpReq.Body = body
+			}
 		}
 
+		if err := c.waitForSlot(ctx); err != nil {
+			return nil, err
+		}
 		resp, lastErr = c.client.Do(httpReq)
-		if lastErr == nil {
+		attempts++
+		if !retryable || attempts > c.maxRetries {
+			break
+		}
+		if lastErr != nil {
+			wait = c.backoff(attempts)
+			continue
+		}
+		if !c.retryPolicy.retriesStatus(resp.StatusCode) {
 			break
 		}
+
+		// A server that says when to come back knows better than our backoff,
+		// but is never waited on for longer than retryMaxDelay
+		var ok bool
+		if wait, ok = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); !ok {
+			wait = c.backoff(attempts)
+		} else if c.retryMaxDelay > 0 && wait > c.retryMaxDelay {
+			wait = c.retryMaxDelay
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
 	}
 
 	if lastErr != nil {
-		return nil, fmt.Errorf("request failed after %d attempts: %w", c.maxRetries+1, lastErr)
+		return nil, fmt.Errorf("request failed after %d attempts: %w", attempts, lastErr)
 	}
+	return resp, nil
+}
 
-	defer resp.Body.Close()
+// waitForSlot blocks until the rate limiter allows another attempt, if one is configured
+func (c *Client) waitForSlot(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		// Wait fails early when the deadline is too close for a slot, before ctx itself is done
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("rate limit wait exceeds context deadline: %w", context.DeadlineExceeded)
+	}
+	return nil
+}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+// backoff returns how long to wait before the given retry attempt (1 for the first retry).
+// The delay grows exponentially from retryDelay by retryMultiplier up to retryMaxDelay,
+// and jitter spreads retries from many clients so they do not arrive in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := float64(c.retryDelay)
+	if c.retryMultiplier > 1 {
+		delay *= math.Pow(c.retryMultiplier, float64(attempt-1))
 	}
+	if c.retryMaxDelay > 0 && delay > float64(c.retryMaxDelay) {
+		delay = float64(c.retryMaxDelay)
+	}
+	if delay >= math.MaxInt64 {
+		return c.jitter(time.Duration(math.MaxInt64))
+	}
+	return c.jitter(time.Duration(delay))
+}
 
-	return &Response{
-		StatusCode: resp.StatusCode,
-		Headers:    resp.Header,
-		Body:       body,
-	}, nil
+// fullJitter returns a random duration in [0, d]
+func fullJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d) + 1))
 }
@@ -0,0 +1,448 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer drops the connection for the first failures requests, then responds 200,
+// recording when each attempt arrived and the body it carried
+type flakyServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	failures int
+	arrivals []time.Time
+	bodies   []string
+}
+
+func newFlakyServer(t *testing.T, failures int) *flakyServer {
+	s := &flakyServer{failures: failures}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.arrivals = append(s.arrivals, time.Now())
+		s.bodies = append(s.bodies, string(body))
+		fail := len(s.arrivals) <= s.failures
+		s.mu.Unlock()
+
+		if fail {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("failed to hijack connection: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Arrivals returns when each attempt arrived so far
+func (s *flakyServer) Arrivals() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.arrivals)
+}
+
+// Bodies returns the body each attempt carried so far
+func (s *flakyServer) Bodies() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.bodies)
+}
+
+func TestRetriesBackOffExponentially(t *testing.T) {
+	server := newFlakyServer(t, 3)
+	c := NewClient(WithRetryCount(3), WithRetryWaitTime(20*time.Millisecond), WithRetryMultiplier(2), WithRetryMaxWaitTime(time.Second))
+	// Disable jitter so the delays are deterministic
+	c.jitter = func(d time.Duration) time.Duration { return d }
+
+	resp, err := c.Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("expected the request to eventually succeed, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if len(server.Arrivals()) != 4 {
+		t.Fatalf("expected 4 attempts, got %d", len(server.Arrivals()))
+	}
+	var previous time.Duration
+	for i, want := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond} {
+		gap := server.Arrivals()[i+1].Sub(server.Arrivals()[i])
+		if gap < want {
+			t.Errorf("retry %d: expected to wait at least %v, waited %v", i+1, want, gap)
+		}
+		if gap <= previous {
+			t.Errorf("retry %d: expected the delay to grow, waited %v after %v", i+1, gap, previous)
+		}
+		previous = gap
+	}
+}
+
+func TestRetriesReplayRequestBody(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(c *Client, url string) (*Response, error)
+		want string
+	}{
+		{"json", func(c *Client, url string) (*Response, error) {
+			return c.Post(context.Background(), url, map[string]string{"name": "Keyboard"}, nil)
+		}, `{"name":"Keyboard"}`},
+		{"form", func(c *Client, url string) (*Response, error) {
+			return c.PostForm(context.Background(), url, map[string]string{"name": "Keyboard"}, nil)
+		}, "name=Keyboard"},
+		{"multipart", func(c *Client, url string) (*Response, error) {
+			files := []FormFile{{FieldName: "file", FileName: "notes.txt", FileData: []byte("file contents")}}
+			return c.PostMultipartForm(context.Background(), url, nil, files, nil)
+		}, "file contents"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFlakyServer(t, 1)
+			c := NewClient(
+				WithRetryCount(1),
+				WithRetryWaitTime(time.Millisecond),
+				WithRetryPolicy(RetryPolicy{RetryNonIdempotent: true}),
+			)
+
+			if _, err := tt.send(c, server.URL); err != nil {
+				t.Fatalf("expected the retry to succeed, got %v", err)
+			}
+			if len(server.Bodies()) != 2 {
+				t.Fatalf("expected 2 attempts, got %d", len(server.Bodies()))
+			}
+			if got := server.Bodies()[1]; !strings.Contains(got, tt.want) || got != server.Bodies()[0] {
+				t.Errorf("expected the retry to resend the full body %q, got %q (first attempt %q)", tt.want, got, server.Bodies()[0])
+			}
+		})
+	}
+}
+
+func TestRetriesReplayBodyAfterRetryableStatus(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		first := len(bodies) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	// The interceptor swaps in a body that cannot be replayed on its own
+	sign := func(r *http.Request) error {
+		r.Body = io.NopCloser(strings.NewReader(`{"name":"Keyboard","signed":true}`))
+		r.GetBody = nil
+		return nil
+	}
+	c := NewClient(WithRetryCount(1), WithRetryWaitTime(time.Millisecond), WithRequestInterceptor(sign))
+
+	resp, err := c.Put(context.Background(), server.URL, map[string]string{"name": "Keyboard"}, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the retry to succeed, got %v %v", resp, err)
+	}
+	want := `{"name":"Keyboard","signed":true}`
+	if len(bodies) != 2 || bodies[0] != want || bodies[1] != want {
+		t.Errorf("expected both attempts to carry %q, got %q", want, bodies)
+	}
+}
+
+func TestBackoffIsCappedAndJittered(t *testing.T) {
+	c := NewClient(WithRetryWaitTime(100*time.Millisecond), WithRetryMultiplier(3), WithRetryMaxWaitTime(time.Second))
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		limit := min(time.Duration(float64(100*time.Millisecond)*math.Pow(3, float64(attempt-1))), time.Second)
+		for i := 0; i < 50; i++ {
+			if d := c.backoff(attempt); d < 0 || d > limit {
+				t.Fatalf("attempt %d: expected a delay in [0, %v], got %v", attempt, limit, d)
+			}
+		}
+	}
+
+	// Without a cap the delay still cannot overflow
+	c = NewClient(WithRetryWaitTime(time.Second), WithRetryMaxWaitTime(0))
+	c.jitter = func(d time.Duration) time.Duration { return d }
+	if d := c.backoff(200); d <= 0 {
+		t.Errorf("expected a positive delay for a late attempt, got %v", d)
+	}
+}
+
+func TestRetriesStopWhenContextIsCancelled(t *testing.T) {
+	server := newFlakyServer(t, 10)
+	c := NewClient(WithRetryCount(5), WithRetryWaitTime(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.Get(ctx, server.URL, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected cancellation to interrupt the backoff, took %v", elapsed)
+	}
+}
+
+// statusServer answers with each status in turn (then 200), setting Retry-After when given,
+// and records when each attempt arrived
+type statusServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	arrivals []time.Time
+}
+
+func newStatusServer(t *testing.T, retryAfter string, statuses ...int) *statusServer {
+	s := &statusServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.arrivals = append(s.arrivals, time.Now())
+		attempt := len(s.arrivals)
+		s.mu.Unlock()
+
+		if attempt > len(statuses) {
+			w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(statuses[attempt-1])
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Arrivals returns when each attempt arrived so far
+func (s *statusServer) Arrivals() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.arrivals)
+}
+
+func TestRetriesHonorRetryAfter(t *testing.T) {
+	server := newStatusServer(t, "2", http.StatusTooManyRequests)
+	c := NewClient(WithRetryCount(1), WithRetryWaitTime(time.Millisecond))
+
+	resp, err := c.Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(server.Arrivals()) != 2 {
+		t.Fatalf("expected 200 after 2 attempts, got %d after %d", resp.StatusCode, len(server.Arrivals()))
+	}
+	if gap := server.Arrivals()[1].Sub(server.Arrivals()[0]); gap < 2*time.Second {
+		t.Errorf("expected to wait the 2s asked for by Retry-After, waited %v", gap)
+	}
+}
+
+func TestRetryAfterIsCappedAtMaxWaitTime(t *testing.T) {
+	server := newStatusServer(t, "3600", http.StatusServiceUnavailable)
+	c := NewClient(WithRetryCount(1), WithRetryWaitTime(time.Millisecond), WithRetryMaxWaitTime(50*time.Millisecond))
+
+	resp, err := c.Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	arrivals := server.Arrivals()
+	if resp.StatusCode != http.StatusOK || len(arrivals) != 2 {
+		t.Fatalf("expected 200 after 2 attempts, got %d after %d", resp.StatusCode, len(arrivals))
+	}
+	if gap := arrivals[1].Sub(arrivals[0]); gap > time.Second {
+		t.Errorf("expected the hour-long Retry-After to be capped at 50ms, waited %v", gap)
+	}
+}
+
+func TestRetriesOnlyRetryableResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		statuses []int
+		want     int
+		attempts int
+	}{
+		{"5xx is retried", http.MethodGet, nil, []int{502, 503, 504}, http.StatusOK, 4},
+		{"last response is returned", http.MethodDelete, nil, []int{503, 503, 503, 503}, http.StatusServiceUnavailable, 4},
+		{"client errors are not retried", http.MethodGet, nil, []int{404}, http.StatusNotFound, 1},
+		{"internal errors are not retried", http.MethodPut, nil, []int{500}, http.StatusInternalServerError, 1},
+		{"POST is not retried", http.MethodPost, nil, []int{503}, http.StatusServiceUnavailable, 1},
+		{"POST with an idempotency key is retried", http.MethodPost, map[string]string{"Idempotency-Key": "abc"}, []int{503}, http.StatusOK, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStatusServer(t, "", tt.statuses...)
+			c := NewClient(WithRetryCount(3), WithRetryWaitTime(time.Millisecond))
+
+			resp, err := c.Do(context.Background(), Request{Method: tt.method, URL: server.URL, Headers: tt.headers})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.want || len(server.Arrivals()) != tt.attempts {
+				t.Errorf("expected %d after %d attempts, got %d after %d", tt.want, tt.attempts, resp.StatusCode, len(server.Arrivals()))
+			}
+		})
+	}
+}
+
+func TestRetriesSkipNonIdempotentTransportErrors(t *testing.T) {
+	server := newFlakyServer(t, 1)
+	c := NewClient(WithRetryCount(3), WithRetryWaitTime(time.Millisecond))
+
+	if _, err := c.Post(context.Background(), server.URL, map[string]string{"name": "Keyboard"}, nil); err == nil {
+		t.Fatal("expected the failed POST to be returned without a retry")
+	}
+	if len(server.Arrivals()) != 1 {
+		t.Errorf("expected a single attempt, got %d", len(server.Arrivals()))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"2", 2 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; expected %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// slowServer waits for delay before responding 200, or gives up when the client goes away
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(`{"ok":true}`))
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := slowServer(t, 5*time.Second)
+	c := NewClient(WithRetryCount(0))
+
+	// The per-request timeout fires while the parent context still has plenty of time
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.Do(ctx, Request{Method: http.MethodGet, URL: server.URL, Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the timeout to cut the request short, took %v", elapsed)
+	}
+	if ctx.Err() != nil {
+		t.Error("expected the parent context to be left alone")
+	}
+}
+
+func TestRequestTimeoutComposesWithContext(t *testing.T) {
+	server := slowServer(t, 200*time.Millisecond)
+	c := NewClient(WithRetryCount(0))
+
+	// A shorter parent deadline wins over a longer per-request timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Do(ctx, Request{Method: http.MethodGet, URL: server.URL, Timeout: time.Minute}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the parent deadline to apply, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the parent deadline to cut the request short, took %v", elapsed)
+	}
+
+	// A request that finishes within its timeout succeeds
+	resp, err := c.Do(context.Background(), Request{Method: http.MethodGet, URL: server.URL, Timeout: 5 * time.Second})
+	if err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != `{"ok":true}` {
+		t.Errorf("expected a successful response, got %v %v", resp, err)
+	}
+}
+
+func TestRateLimitPacesRequests(t *testing.T) {
+	server := newStatusServer(t, "")
+	c := NewClient(WithRetryCount(0), WithRateLimit(20, 2))
+
+	// The burst goes out at once, then requests are spaced 50ms apart
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := c.Get(context.Background(), server.URL, nil, nil); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("expected 6 requests at 20/s with a burst of 2 to take at least 200ms, took %v", elapsed)
+	}
+
+	if gap := server.Arrivals()[1].Sub(server.Arrivals()[0]); gap > 40*time.Millisecond {
+		t.Errorf("expected the burst to be sent without waiting, waited %v", gap)
+	}
+	for i := 2; i < len(server.Arrivals()); i++ {
+		if gap := server.Arrivals()[i].Sub(server.Arrivals()[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d: expected to be paced about 50ms after the previous one, came %v after", i+1, gap)
+		}
+	}
+}
+
+func TestRateLimitWaitRespectsContext(t *testing.T) {
+	server := newStatusServer(t, "")
+	c := NewClient(WithRetryCount(0), WithRateLimit(0.5, 1))
+
+	if _, err := c.Get(context.Background(), server.URL, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The next slot is 2s away, past the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Get(ctx, server.URL, nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to fail with the context deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait to give up early, took %v", elapsed)
+	}
+	if len(server.Arrivals()) != 1 {
+		t.Errorf("expected the throttled request not to be sent, got %d requests", len(server.Arrivals()))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 )
 
 // GetJSON sends a GET request and unmarshals the JSON response into the provided target
@@ -75,3 +76,68 @@ func (c *Client) PatchJSON(ctx context.Context, url string, body interface{}, ta
 
 	return json.Unmarshal(resp.Body, target)
 }
+
+// StatusError is returned by the typed JSON helpers when the server answers with a non-2xx status
+type StatusError struct {
+	StatusCode int
+	// Message is the "message" or "error" field of a JSON error body, if the body has one
+	Message string
+	// Body is the decoded JSON error body, or nil if the body is not a JSON object
+	Body map[string]interface{}
+	// Response is the full response, for inspecting headers or the raw body
+	Response *Response
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("request failed with status code %d: %s", e.StatusCode, e.Message)
+	}
+	body := e.Response.Body
+	if len(body) > 512 {
+		body = body[:512]
+	}
+	return fmt.Sprintf("request failed with status code %d: %s", e.StatusCode, string(body))
+}
+
+// newStatusError builds a StatusError, parsing the body when it is a JSON object
+func newStatusError(resp *Response) *StatusError {
+	statusErr := &StatusError{StatusCode: resp.StatusCode, Response: resp}
+	var body map[string]interface{}
+	if json.Unmarshal(resp.Body, &body) == nil {
+		statusErr.Body = body
+		for _, key := range []string{"message", "error"} {
+			if message, ok := body[key].(string); ok && message != "" {
+				statusErr.Message = message
+				break
+			}
+		}
+	}
+	return statusErr
+}
+
+// DoJSON sends req and unmarshals a 2xx JSON response into a T. The response is returned
+// whenever one was received, so callers can inspect the status and headers. Non-2xx responses
+// return a *StatusError; an empty 2xx body (e.g. 204 No Content) leaves the result as T's zero value.
+func DoJSON[T any](ctx context.Context, c *Client, req Request) (T, *Response, error) {
+	var result T
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return result, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, resp, newStatusError(resp)
+	}
+	if len(resp.Body) == 0 {
+		return result, resp, nil
+	}
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return result, resp, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return result, resp, nil
+}
+
+// GetTyped sends a GET request and unmarshals the JSON response into a T, like DoJSON
+func GetTyped[T any](ctx context.Context, c *Client, url string, query map[string]string) (T, *Response, error) {
+	return DoJSON[T](ctx, c, Request{Method: http.MethodGet, URL: url, Query: query})
+}
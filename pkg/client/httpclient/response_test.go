@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type typedUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func typedServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users/1":
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`{"id":"1","name":"Jane"}`))
+		case "/users/2":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status_code":404,"message":"User not found"}`))
+		case "/broken":
+			w.Write([]byte(`{"id":"1","name":`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream unavailable"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDoJSONDecodesSuccess(t *testing.T) {
+	c := NewClient(WithBaseURL(typedServer(t).URL))
+
+	user, resp, err := DoJSON[typedUser](context.Background(), c, Request{Method: http.MethodGet, URL: "users/1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != "1" || user.Name != "Jane" {
+		t.Errorf("expected the decoded user, got %+v", user)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Headers.Get("ETag") != `"v1"` {
+		t.Errorf("expected the full response, got %d %v", resp.StatusCode, resp.Headers)
+	}
+
+	users, _, err := GetTyped[*typedUser](context.Background(), c, "users/1", nil)
+	if err != nil || users.Name != "Jane" {
+		t.Errorf("expected GetTyped to decode into a pointer, got %+v %v", users, err)
+	}
+
+	empty, resp, err := DoJSON[typedUser](context.Background(), c, Request{Method: http.MethodDelete, URL: "empty"})
+	if err != nil || resp.StatusCode != http.StatusNoContent || empty != (typedUser{}) {
+		t.Errorf("expected an empty body to give the zero value, got %+v %v", empty, err)
+	}
+}
+
+func TestDoJSONReturnsStatusErrors(t *testing.T) {
+	c := NewClient(WithBaseURL(typedServer(t).URL), WithRetryCount(0))
+
+	_, resp, err := GetTyped[typedUser](context.Background(), c, "users/2", nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *StatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound || statusErr.Message != "User not found" {
+		t.Errorf("expected 404 with the parsed message, got %d %q", statusErr.StatusCode, statusErr.Message)
+	}
+	if statusErr.Body["status_code"] != float64(404) {
+		t.Errorf("expected the parsed error body, got %v", statusErr.Body)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound || statusErr.Response != resp {
+		t.Errorf("expected the response to be returned alongside the error, got %+v", resp)
+	}
+	if !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "User not found") {
+		t.Errorf("expected the error to describe the failure, got %q", err)
+	}
+
+	// Non-JSON error bodies are kept raw
+	_, _, err = GetTyped[typedUser](context.Background(), c, "other", nil)
+	if !errors.As(err, &statusErr) || statusErr.Body != nil || !strings.Contains(err.Error(), "upstream unavailable") {
+		t.Errorf("expected a StatusError carrying the raw body, got %v", err)
+	}
+}
+
+func TestDoJSONReportsMalformedBodies(t *testing.T) {
+	c := NewClient(WithBaseURL(typedServer(t).URL))
+
+	user, resp, err := GetTyped[typedUser](context.Background(), c, "broken", nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to decode response body") {
+		t.Fatalf("expected a decode error, got %v", err)
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		t.Error("expected a decode error, not a status error")
+	}
+	if resp == nil || resp.StatusCode != http.StatusOK || user != (typedUser{}) {
+		t.Errorf("expected the response and a zero value, got %+v %+v", resp, user)
+	}
+}
@@ -0,0 +1,66 @@
+package httpclient
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides which failed requests are retried. Transport errors are always
+// retryable; responses are retried only when their status code is listed.
+type RetryPolicy struct {
+	// StatusCodes lists the response status codes that are retried
+	StatusCodes []int
+	// RetryNonIdempotent also retries methods such as POST and PATCH, which may not be
+	// safe to send twice. Without it, only requests carrying an Idempotency-Key header are.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy retries idempotent requests on transport errors, 429 and 502/503/504
+var DefaultRetryPolicy = RetryPolicy{
+	StatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// allowsMethod reports whether req may be sent more than once
+func (p RetryPolicy) allowsMethod(req *http.Request) bool {
+	if p.RetryNonIdempotent {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retriesStatus reports whether a response with the given status code is retried
+func (p RetryPolicy) retriesStatus(code int) bool {
+	return slices.Contains(p.StatusCodes, code)
+}
+
+// parseRetryAfter reads a Retry-After header given either as delay seconds or an HTTP-date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Default delays between connection attempts
+const (
+	DefaultConnectRetryBase = time.Second
+	DefaultConnectRetryMax  = 30 * time.Second
+)
+
+// Backoff computes jittered exponential delays between connection attempts, so many
+// instances restarting at once do not reconnect in lockstep
+type Backoff struct {
+	// Base is the delay before the first retry; it doubles after each failed attempt
+	Base time.Duration
+	// Max caps the delay
+	Max time.Duration
+	// rand returns a number in [0, n); defaults to rand.Int64N
+	rand func(n int64) int64
+}
+
+// Delay returns the wait before the given retry attempt (1 for the first retry): a random
+// duration between half and all of Base×2^(attempt-1), capped at Max
+func (b Backoff) Delay(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = DefaultConnectRetryBase
+	}
+	limit := b.Max
+	if limit <= 0 {
+		limit = DefaultConnectRetryMax
+	}
+
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+
+	random := b.rand
+	if random == nil {
+		random = rand.Int64N
+	}
+	half := delay / 2
+	return half + time.Duration(random(int64(delay-half)+1))
+}
+
+// wait sleeps for d, returning early with the context's error if it is done first
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
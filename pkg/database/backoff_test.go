@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffGrowsWithJitter(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	// Each delay lies between half and all of the exponential step, capped at Max
+	steps := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, step := range steps {
+		step *= time.Millisecond
+		seen := make(map[time.Duration]bool)
+		for n := 0; n < 200; n++ {
+			d := b.Delay(i + 1)
+			if d < step/2 || d > step {
+				t.Fatalf("attempt %d: expected a delay in [%v, %v], got %v", i+1, step/2, step, d)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("attempt %d: expected randomised delays, always got %v", i+1, b.Delay(i+1))
+		}
+	}
+
+	// With the randomness pinned the bounds are hit exactly and grow up to the cap
+	low := Backoff{Base: 100 * time.Millisecond, Max: time.Second, rand: func(n int64) int64 { return 0 }}
+	high := Backoff{Base: 100 * time.Millisecond, Max: time.Second, rand: func(n int64) int64 { return n - 1 }}
+	var previous time.Duration
+	for attempt := 1; attempt <= 4; attempt++ {
+		if d := low.Delay(attempt); d <= previous {
+			t.Errorf("attempt %d: expected the minimum delay to grow past %v, got %v", attempt, previous, d)
+		}
+		previous = low.Delay(attempt)
+	}
+	if d := high.Delay(50); d != time.Second {
+		t.Errorf("expected the delay to be capped at 1s, got %v", d)
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	b := Backoff{rand: func(n int64) int64 { return n - 1 }}
+	if d := b.Delay(1); d != DefaultConnectRetryBase {
+		t.Errorf("expected the default base delay, got %v", d)
+	}
+	if d := b.Delay(100); d != DefaultConnectRetryMax {
+		t.Errorf("expected the default max delay, got %v", d)
+	}
+}
+
+func TestWaitStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := wait(ctx, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected wait to return when the context expired, took %v", elapsed)
+	}
+}
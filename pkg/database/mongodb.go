@@ -23,6 +23,9 @@ type Config struct {
 	RetryWrites     bool
 	RetryReads      bool
 	MaxRetries      uint64
+	// ConnectRetryBase and ConnectRetryMax bound the jittered exponential backoff between connection attempts
+	ConnectRetryBase time.Duration
+	ConnectRetryMax  time.Duration
 }
 
 // DefaultConfig returns a default MongoDB configuration
@@ -35,6 +38,9 @@ func DefaultConfig() Config {
 		RetryWrites:     true,
 		RetryReads:      true,
 		MaxRetries:      3,
+
+		ConnectRetryBase: DefaultConnectRetryBase,
+		ConnectRetryMax:  DefaultConnectRetryMax,
 	}
 }
 
@@ -44,6 +50,8 @@ type MongoDBService interface {
 	GetDatabase() *mongo.Database
 	IsConnected(ctx context.Context) bool
 	Disconnect(ctx context.Context) error
+	// WithTransaction runs fn inside a transaction, committing only if it returns nil
+	WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error
 	// Add other methods as needed
 }
 
@@ -76,6 +84,11 @@ func (s *mongoDBService) GetDatabase() *mongo.Database {
 	return s.database
 }
 
+// WithTransaction runs fn inside a transaction, committing only if it returns nil
+func (s *mongoDBService) WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	return WithTransaction(ctx, s.client, fn)
+}
+
 // Connect establishes connection to MongoDB and returns the database instance
 func connect(config Config) (*mongo.Database, *mongo.Client, error) {
 	slog.Info("Attempting to connect to MongoDB")
@@ -92,7 +105,11 @@ func connect(config Config) (*mongo.Database, *mongo.Client, error) {
 		SetMinPoolSize(config.MinPoolSize).
 		SetRetryWrites(config.RetryWrites).
 		SetRetryReads(config.RetryReads).
-		SetMaxConnecting(config.MaxRetries)
+		SetMaxConnecting(config.MaxRetries).
+		// Decode nested documents into bson.M so free-form fields marshal to JSON objects
+		SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+
+	backoff := Backoff{Base: config.ConnectRetryBase, Max: config.ConnectRetryMax}
 
 	// Connect to MongoDB with retry logic
 	var client *mongo.Client
@@ -104,7 +121,9 @@ func connect(config Config) (*mongo.Database, *mongo.Client, error) {
 		}
 		if i < config.MaxRetries {
 			slog.Warn("Failed to connect to MongoDB", "attempt", i+1, "maxRetries", config.MaxRetries, "error", err)
-			time.Sleep(time.Second * time.Duration(i+1)) // Exponential backoff
+			if wait(ctx, backoff.Delay(int(i)+1)) != nil {
+				break
+			}
 		}
 	}
 	if err != nil {
@@ -119,7 +138,9 @@ func connect(config Config) (*mongo.Database, *mongo.Client, error) {
 		}
 		if i < config.MaxRetries {
 			slog.Warn("Failed to ping MongoDB", "attempt", i+1, "maxRetries", config.MaxRetries, "error", err)
-			time.Sleep(time.Second * time.Duration(i+1)) // Exponential backoff
+			if wait(ctx, backoff.Delay(int(i)+1)) != nil {
+				break
+			}
 		}
 	}
 	if err != nil {
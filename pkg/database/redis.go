@@ -29,6 +29,9 @@ type RedisConfig struct {
 	PoolTimeout     time.Duration
 	ConnMaxIdleTime time.Duration
 	ConnMaxLifetime time.Duration
+	// ConnectRetryBase and ConnectRetryMax bound the jittered exponential backoff between connection attempts
+	ConnectRetryBase time.Duration
+	ConnectRetryMax  time.Duration
 }
 
 // DefaultRedisConfig returns a default Redis configuration
@@ -48,6 +51,9 @@ func DefaultRedisConfig() RedisConfig {
 		PoolTimeout:     4 * time.Second,
 		ConnMaxIdleTime: 5 * time.Minute,
 		ConnMaxLifetime: 30 * time.Minute,
+
+		ConnectRetryBase: DefaultConnectRetryBase,
+		ConnectRetryMax:  DefaultConnectRetryMax,
 	}
 }
 
@@ -133,6 +139,7 @@ func connectRedis(config RedisConfig) (*redis.Client, error) {
 	client := redis.NewClient(options)
 
 	// Ping Redis with retry logic
+	backoff := Backoff{Base: config.ConnectRetryBase, Max: config.ConnectRetryMax}
 	var err error
 	for i := 0; i <= config.MaxRetries; i++ {
 		_, err = client.Ping(ctx).Result()
@@ -141,7 +148,9 @@ func connectRedis(config RedisConfig) (*redis.Client, error) {
 		}
 		if i < config.MaxRetries {
 			slog.Warn("Failed to ping Redis", "attempt", i+1, "maxRetries", config.MaxRetries, "error", err)
-			time.Sleep(time.Duration(i+1) * time.Second) // Exponential backoff
+			if wait(ctx, backoff.Delay(i+1)) != nil {
+				break
+			}
 		}
 	}
 	if err != nil {
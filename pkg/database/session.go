@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithCausalSession runs fn with a context carrying a causally consistent session of client.
+// Every operation given that context, or one derived from it, uses the session, so reads
+// observe the writes made before them (read-your-writes) without a transaction.
+// If ctx already carries a session it is reused and fn runs on ctx unchanged.
+// A session must not be used by several goroutines at once.
+func WithCausalSession(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := client.StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	return fn(mongo.NewSessionContext(ctx, session))
+}
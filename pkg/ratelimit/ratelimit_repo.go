@@ -11,6 +11,9 @@ type RateLimitRepo interface {
 	// If the key doesn't exist, it sets the provided expiration
 	IncrementPreserveTTL(ctx context.Context, key string, defaultExpiration time.Duration) (int, error)
 
+	// IncrAndExpire atomically increments the counter and sets the TTL if the key has none
+	IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int, error)
+
 	// Check returns the current count for the given key without incrementing
 	Check(ctx context.Context, key string) (int, error)
 
@@ -22,15 +25,47 @@ type RateLimitRepo interface {
 
 	// GetState gets the bucket state of a rate limit
 	GetState(ctx context.Context, key string) (string, error)
+
+	// LogRequest drops logged requests older than window, then logs one at now if fewer than limit remain.
+	// It returns whether the request was logged and how many requests the window holds.
+	LogRequest(ctx context.Context, key string, now time.Time, window time.Duration, limit int) (bool, int, error)
+
+	// CountLog drops logged requests older than window and returns how many remain
+	// and when the oldest of them was logged
+	CountLog(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error)
+
+	// AllowGCRA checks a request against the theoretical arrival time stored at key, spacing requests
+	// interval apart with up to burst at once. It returns whether the request is allowed and the
+	// theoretical arrival time after the call. The stored value is readable with GetState as Unix microseconds.
+	AllowGCRA(ctx context.Context, key string, now time.Time, interval time.Duration, burst int) (bool, time.Time, error)
 }
 
 // RateLimitResponse represents the rate limit information returned in headers
 type RateLimitResponse struct {
 	Limit     int   `json:"limit"`
+	Used      int   `json:"used"`
 	Remaining int   `json:"remaining"`
 	Reset     int64 `json:"reset"`
 }
 
+// NewRateLimitResponse builds a RateLimitResponse from the amount of the limit consumed.
+// Used is clamped to [0, limit] so that Used + Remaining always equals Limit,
+// even when denied requests push a counter past the limit.
+func NewRateLimitResponse(limit, used int, reset int64) *RateLimitResponse {
+	if used < 0 {
+		used = 0
+	}
+	if used > limit {
+		used = limit
+	}
+	return &RateLimitResponse{
+		Limit:     limit,
+		Used:      used,
+		Remaining: limit - used,
+		Reset:     reset,
+	}
+}
+
 var repo RateLimitRepo
 
 // SetRateLimitRepo sets the rate limit repository implementation
@@ -0,0 +1,132 @@
+package strategy
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// LoadSignal reports how stressed the system is, from 0 (idle) to 1 (saturated)
+type LoadSignal interface {
+	Load() float64
+}
+
+// LoadFunc adapts a function to the LoadSignal interface
+type LoadFunc func() float64
+
+// Load calls f
+func (f LoadFunc) Load() float64 {
+	return f()
+}
+
+// AdaptiveConfig holds the thresholds used to tighten a limit under load
+type AdaptiveConfig struct {
+	// StressThreshold is the load at or above which the limit is tightened
+	StressThreshold float64
+	// StressedFactor scales the limit while stressed, e.g. 0.5 halves it
+	StressedFactor float64
+}
+
+// DefaultAdaptiveConfig halves the limit once load reaches 80%
+func DefaultAdaptiveConfig() AdaptiveConfig {
+	return AdaptiveConfig{
+		StressThreshold: 0.8,
+		StressedFactor:  0.5,
+	}
+}
+
+// adaptiveLimit lowers a base limit while its load signal is above the stress threshold
+type adaptiveLimit struct {
+	signal LoadSignal
+	config AdaptiveConfig
+}
+
+// apply returns the effective limit for the current load, never less than 1
+func (a *adaptiveLimit) apply(limit int) int {
+	if a.signal.Load() < a.config.StressThreshold {
+		return limit
+	}
+	return max(1, int(float64(limit)*a.config.StressedFactor))
+}
+
+// NewAdaptiveFixedWindowStore creates a fixed window rate limiter whose limit is scaled down
+// while signal reports load at or above config.StressThreshold, and restored once it drops.
+// Zero config values fall back to DefaultAdaptiveConfig.
+func NewAdaptiveFixedWindowStore(repo ratelimit.RateLimitRepo, limit int, windowSize time.Duration, signal LoadSignal, config AdaptiveConfig) *FixedWindowStore {
+	defaults := DefaultAdaptiveConfig()
+	if config.StressThreshold <= 0 {
+		config.StressThreshold = defaults.StressThreshold
+	}
+	if config.StressedFactor <= 0 || config.StressedFactor > 1 {
+		config.StressedFactor = defaults.StressedFactor
+	}
+
+	store := NewFixedWindowStore(repo, limit, windowSize)
+	store.keyPrefix = "rate_limit_adaptive_window"
+	store.adaptive = &adaptiveLimit{signal: signal, config: config}
+	return store
+}
+
+// NewAdaptiveFixedWindowMiddleware creates a fixed window rate limiting middleware that tightens under load
+func NewAdaptiveFixedWindowMiddleware(limit int, windowSize time.Duration, signal LoadSignal, config AdaptiveConfig) echo.MiddlewareFunc {
+	store := NewAdaptiveFixedWindowStore(ratelimit.GetRateLimitRepo(), limit, windowSize, signal, config)
+
+	return newRateLimiter(store, middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			// Try to get API key first
+			apiKey := c.Request().Header.Get("X-API-Key")
+			if apiKey != "" {
+				return fmt.Sprintf("api:%s", apiKey), nil
+			}
+			// Fall back to IP address
+			return fmt.Sprintf("ip:%s", c.RealIP()), nil
+		},
+		ErrorHandler: store.ErrorHandler,
+		DenyHandler:  store.DenyHandler,
+	})
+}
+
+// InflightLoad is a LoadSignal measuring in-flight requests against a capacity
+type InflightLoad struct {
+	capacity int64
+	inflight atomic.Int64
+}
+
+// NewInflightLoad creates an InflightLoad that reports full load at capacity concurrent requests
+func NewInflightLoad(capacity int) *InflightLoad {
+	return &InflightLoad{capacity: int64(max(capacity, 1))}
+}
+
+// Middleware counts requests while they are being handled
+func (l *InflightLoad) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			l.inflight.Add(1)
+			defer l.inflight.Add(-1)
+			return next(c)
+		}
+	}
+}
+
+// Load returns the in-flight request count as a fraction of capacity
+func (l *InflightLoad) Load() float64 {
+	return float64(l.inflight.Load()) / float64(l.capacity)
+}
+
+// RedisMemoryLoad returns Redis memory usage as a fraction of maxmemory from the stats
+// reported by the Redis service health check, or 0 when no memory limit is configured
+func RedisMemoryLoad(stats map[string]string) float64 {
+	used, _ := strconv.ParseInt(stats["redis_used_memory"], 10, 64)
+	maxMemory, _ := strconv.ParseInt(stats["redis_max_memory"], 10, 64)
+	if maxMemory <= 0 {
+		return 0
+	}
+	return float64(used) / float64(maxMemory)
+}
@@ -0,0 +1,83 @@
+package strategy
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveFixedWindowTightensUnderLoad(t *testing.T) {
+	var load atomic.Value
+	load.Store(0.1)
+	signal := LoadFunc(func() float64 { return load.Load().(float64) })
+
+	store := NewAdaptiveFixedWindowStore(newMemoryRepo(), 10, time.Hour, signal, AdaptiveConfig{StressThreshold: 0.75, StressedFactor: 0.3})
+
+	if got := store.EffectiveLimit(); got != 10 {
+		t.Fatalf("expected full limit while healthy, got %d", got)
+	}
+
+	load.Store(0.9)
+	if got := store.EffectiveLimit(); got != 3 {
+		t.Fatalf("expected limit 3 under load, got %d", got)
+	}
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		ok, err := store.Allow("client")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected 3 requests allowed under load, got %d", allowed)
+	}
+
+	info, err := store.GetRateLimitInfo("client")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Limit != 3 || info.Remaining != 0 {
+		t.Errorf("expected reported limit 3 with none remaining, got %+v", info)
+	}
+
+	load.Store(0.2)
+	if got := store.EffectiveLimit(); got != 10 {
+		t.Errorf("expected limit restored once healthy, got %d", got)
+	}
+	if ok, _ := store.Allow("client"); !ok {
+		t.Error("expected requests to be allowed again once healthy")
+	}
+}
+
+func TestAdaptiveConfigDefaults(t *testing.T) {
+	store := NewAdaptiveFixedWindowStore(newMemoryRepo(), 10, time.Hour, LoadFunc(func() float64 { return 0.8 }), AdaptiveConfig{})
+	if got := store.EffectiveLimit(); got != 5 {
+		t.Errorf("expected the default config to halve the limit at 80%% load, got %d", got)
+	}
+
+	tiny := NewAdaptiveFixedWindowStore(newMemoryRepo(), 1, time.Hour, LoadFunc(func() float64 { return 1 }), AdaptiveConfig{})
+	if got := tiny.EffectiveLimit(); got != 1 {
+		t.Errorf("expected the effective limit to stay at least 1, got %d", got)
+	}
+}
+
+func TestInflightLoad(t *testing.T) {
+	l := NewInflightLoad(4)
+	l.inflight.Add(3)
+	if got := l.Load(); got != 0.75 {
+		t.Errorf("expected load 0.75, got %v", got)
+	}
+}
+
+func TestRedisMemoryLoad(t *testing.T) {
+	if got := RedisMemoryLoad(map[string]string{"redis_used_memory": "900", "redis_max_memory": "1000"}); got != 0.9 {
+		t.Errorf("expected load 0.9, got %v", got)
+	}
+	if got := RedisMemoryLoad(map[string]string{"redis_used_memory": "900", "redis_max_memory": "0"}); got != 0 {
+		t.Errorf("expected no load without maxmemory, got %v", got)
+	}
+}
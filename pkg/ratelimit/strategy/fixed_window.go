@@ -14,9 +14,10 @@ import (
 // FixedWindowStore implements a simple fixed-window rate limiter
 type FixedWindowStore struct {
 	repo       ratelimit.RateLimitRepo
-	limit      int           // Maximum requests per window
-	windowSize time.Duration // Time window size
-	keyPrefix  string        // Key prefix for rate limit
+	limit      int            // Maximum requests per window
+	windowSize time.Duration  // Time window size
+	keyPrefix  string         // Key prefix for rate limit
+	adaptive   *adaptiveLimit // Optional load-based limit adjustment
 }
 
 // NewFixedWindowStore creates a new fixed window rate limiter
@@ -29,6 +30,15 @@ func NewFixedWindowStore(repo ratelimit.RateLimitRepo, limit int, windowSize tim
 	}
 }
 
+// EffectiveLimit returns the limit currently enforced, which is lower than the configured
+// limit while an adaptive store is under load
+func (s *FixedWindowStore) EffectiveLimit() int {
+	if s.adaptive == nil {
+		return s.limit
+	}
+	return s.adaptive.apply(s.limit)
+}
+
 // Allow implements the RateLimiterStore interface
 func (s *FixedWindowStore) Allow(identifier string) (bool, error) {
 	ctx := context.Background()
@@ -37,13 +47,13 @@ func (s *FixedWindowStore) Allow(identifier string) (bool, error) {
 	windowNum := time.Now().Unix() / int64(s.windowSize.Seconds())
 	key := fmt.Sprintf("%s:%s:%d", s.keyPrefix, identifier, windowNum)
 
-	// Increment the counter for this window
-	count, err := s.repo.IncrementPreserveTTL(ctx, key, s.windowSize)
+	// Atomically increment the counter for this window so concurrent requests can't undercount
+	count, err := s.repo.IncrAndExpire(ctx, key, s.windowSize)
 	if err != nil {
 		return false, fmt.Errorf("failed to increment rate limit counter: %w", err)
 	}
 
-	return count <= s.limit, nil
+	return count <= s.EffectiveLimit(), nil
 }
 
 // GetRateLimitInfo returns information about the current rate limit state
@@ -59,21 +69,25 @@ func (s *FixedWindowStore) GetRateLimitInfo(identifier string) (*ratelimit.RateL
 	}
 
 	nextWindow := (windowNum + 1) * int64(s.windowSize.Seconds())
-	remaining := s.limit - count
-	if remaining < 0 {
-		remaining = 0
-	}
 
-	return &ratelimit.RateLimitResponse{
-		Limit:     s.limit,
-		Remaining: remaining,
-		Reset:     nextWindow,
-	}, nil
+	// Denied requests still increment the counter, so count may exceed the limit
+	return ratelimit.NewRateLimitResponse(s.EffectiveLimit(), count, nextWindow), nil
+}
+
+// ResetLimit deletes identifier's counter for the current window so its next request gets the full allowance
+func (s *FixedWindowStore) ResetLimit(identifier string) error {
+	windowNum := time.Now().Unix() / int64(s.windowSize.Seconds())
+	key := fmt.Sprintf("%s:%s:%d", s.keyPrefix, identifier, windowNum)
+	if err := s.repo.Reset(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
 }
 
 // SetRateLimitHeaders sets the rate limit headers in the response
 func (s *FixedWindowStore) SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse) {
 	c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
+	c.Response().Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", info.Used))
 	c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
 	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset))
 }
@@ -125,7 +139,7 @@ func NewFixedWindowMiddleware(limit int, windowSize time.Duration) echo.Middlewa
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
 
 // NewFixedWindowMiddlewarePerPath creates a new fixed window rate limiting middleware that's path-specific
@@ -148,5 +162,5 @@ func NewFixedWindowMiddlewarePerPath(limit int, windowSize time.Duration) echo.M
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
@@ -0,0 +1,142 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryRepo is an in-memory ratelimit.RateLimitRepo with atomic increments
+type memoryRepo struct {
+	mu     sync.Mutex
+	counts map[string]int
+	states map[string]string
+	logs   map[string][]time.Time
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{counts: make(map[string]int), states: make(map[string]string), logs: make(map[string][]time.Time)}
+}
+
+func (r *memoryRepo) IncrementPreserveTTL(ctx context.Context, key string, defaultExpiration time.Duration) (int, error) {
+	return r.IncrAndExpire(ctx, key, defaultExpiration)
+}
+
+func (r *memoryRepo) IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[key]++
+	return r.counts[key], nil
+}
+
+func (r *memoryRepo) Check(ctx context.Context, key string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[key], nil
+}
+
+func (r *memoryRepo) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.counts, key)
+	delete(r.states, key)
+	delete(r.logs, key)
+	return nil
+}
+
+func (r *memoryRepo) SetState(ctx context.Context, key string, state interface{}, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[key] = state.(string)
+	return nil
+}
+
+func (r *memoryRepo) GetState(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.states[key]
+	if !ok {
+		return "", errors.New("key " + key + " not found")
+	}
+	return state, nil
+}
+
+// trimLog drops entries logged at or before now minus window; callers hold r.mu
+func (r *memoryRepo) trimLog(key string, now time.Time, window time.Duration) []time.Time {
+	cutoff := now.Add(-window)
+	var kept []time.Time
+	for _, at := range r.logs[key] {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	r.logs[key] = kept
+	return kept
+}
+
+func (r *memoryRepo) LogRequest(ctx context.Context, key string, now time.Time, window time.Duration, limit int) (bool, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.trimLog(key, now, window)
+	if len(entries) >= limit {
+		return false, len(entries), nil
+	}
+	r.logs[key] = append(entries, now)
+	return true, len(entries) + 1, nil
+}
+
+func (r *memoryRepo) CountLog(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.trimLog(key, now, window)
+	if len(entries) == 0 {
+		return 0, time.Time{}, nil
+	}
+	return len(entries), entries[0], nil
+}
+
+// AllowGCRA mirrors the Redis script, storing the TAT as Unix microseconds so GetState can read it
+func (r *memoryRepo) AllowGCRA(ctx context.Context, key string, now time.Time, interval time.Duration, burst int) (bool, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tat := now
+	if micros, err := strconv.ParseInt(r.states[key], 10, 64); err == nil && time.UnixMicro(micros).After(now) {
+		tat = time.UnixMicro(micros)
+	}
+	next := tat.Add(interval)
+	if next.Sub(now) > interval*time.Duration(burst) {
+		return false, tat, nil
+	}
+	r.states[key] = strconv.FormatInt(next.UnixMicro(), 10)
+	return true, next, nil
+}
+
+func TestFixedWindowConcurrentAllowsExactlyLimit(t *testing.T) {
+	store := NewFixedWindowStore(newMemoryRepo(), 100, time.Hour)
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := store.Allow("ip:10.0.0.1")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if ok {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 100 {
+		t.Errorf("expected exactly 100 requests allowed, got %d", allowed)
+	}
+}
@@ -0,0 +1,189 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// GCRAStore implements the generic cell rate algorithm, a leaky bucket used as a meter.
+// Each client has a theoretical arrival time (TAT): the point at which it would be back to an
+// idle bucket. Every allowed request pushes the TAT one emission interval (window / rate) further,
+// and a request is denied when it would put the TAT more than burst intervals ahead of now.
+// Requests are spread evenly instead of resetting at window boundaries, and the only state
+// per client is a single timestamp.
+type GCRAStore struct {
+	repo      ratelimit.RateLimitRepo
+	interval  time.Duration // Emission interval between two requests at the steady rate
+	burst     int           // Requests that may arrive at once
+	keyPrefix string        // Key prefix for rate limit
+	now       func() time.Time
+}
+
+// NewGCRAStore creates a new GCRA rate limiter allowing rate requests per window on average,
+// with up to burst of them back to back. A burst below 1 is treated as 1.
+func NewGCRAStore(repo ratelimit.RateLimitRepo, rate float64, burst int, window time.Duration) *GCRAStore {
+	if burst < 1 {
+		burst = 1
+	}
+	interval := window
+	if rate > 0 {
+		interval = time.Duration(float64(window) / rate)
+	}
+	return &GCRAStore{
+		repo:      repo,
+		interval:  interval,
+		burst:     burst,
+		keyPrefix: "rate_limit_gcra",
+		now:       time.Now,
+	}
+}
+
+// Allow implements the RateLimiterStore interface
+func (s *GCRAStore) Allow(identifier string) (bool, error) {
+	ctx := context.Background()
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+
+	// The TAT is read and advanced in one step, so concurrent requests can't both take the last slot
+	allowed, _, err := s.repo.AllowGCRA(ctx, key, s.now(), s.interval, s.burst)
+	if err != nil {
+		return false, fmt.Errorf("failed to apply GCRA: %w", err)
+	}
+	return allowed, nil
+}
+
+// getTAT returns the stored theoretical arrival time, or now when the client has none
+func (s *GCRAStore) getTAT(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	state, err := s.repo.GetState(ctx, key)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return now, nil
+		}
+		return time.Time{}, err
+	}
+
+	micros, err := strconv.ParseInt(state, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid GCRA state: %w", err)
+	}
+	if tat := time.UnixMicro(micros); tat.After(now) {
+		return tat, nil
+	}
+	return now, nil
+}
+
+// GetRateLimitInfo returns information about the current rate limit state.
+// Used is how many intervals the TAT is ahead of now, and Reset is when it catches up.
+func (s *GCRAStore) GetRateLimitInfo(identifier string) (*ratelimit.RateLimitResponse, error) {
+	ctx := context.Background()
+	now := s.now()
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+
+	tat, err := s.getTAT(ctx, key, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// A partially elapsed interval has not freed its slot yet
+	used := int(math.Ceil(float64(tat.Sub(now)) / float64(s.interval)))
+	reset := int64(math.Ceil(float64(tat.UnixMicro()) / 1e6))
+	return ratelimit.NewRateLimitResponse(s.burst, used, reset), nil
+}
+
+// ResetLimit deletes identifier's theoretical arrival time so its next request gets the full allowance
+func (s *GCRAStore) ResetLimit(identifier string) error {
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+	if err := s.repo.Reset(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
+}
+
+// SetRateLimitHeaders sets the rate limit headers in the response
+func (s *GCRAStore) SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse) {
+	c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
+	c.Response().Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", info.Used))
+	c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
+	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset))
+}
+
+// ErrorHandler handles internal errors
+func (s *GCRAStore) ErrorHandler(c echo.Context, err error) error {
+	return c.JSON(500, map[string]string{
+		"error": "Internal rate limit error",
+	})
+}
+
+// DenyHandler handles rate limit exceeded errors
+func (s *GCRAStore) DenyHandler(c echo.Context, identifier string, err error) error {
+	if err != nil {
+		return c.JSON(500, map[string]string{
+			"error": "Internal rate limit error",
+		})
+	}
+
+	info, err := s.GetRateLimitInfo(identifier)
+	if err != nil {
+		return c.JSON(500, map[string]string{
+			"error": "Failed to get rate limit info",
+		})
+	}
+
+	s.SetRateLimitHeaders(c, info)
+	return c.JSON(429, map[string]string{
+		"error": "Rate limit exceeded",
+	})
+}
+
+// NewGCRAMiddleware creates a new GCRA rate limiting middleware
+func NewGCRAMiddleware(rate float64, burst int, window time.Duration) echo.MiddlewareFunc {
+	store := NewGCRAStore(ratelimit.GetRateLimitRepo(), rate, burst, window)
+
+	config := middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			// Try to get API key first
+			apiKey := c.Request().Header.Get("X-API-Key")
+			if apiKey != "" {
+				return fmt.Sprintf("api:%s", apiKey), nil
+			}
+			// Fall back to IP address
+			return fmt.Sprintf("ip:%s", c.RealIP()), nil
+		},
+		ErrorHandler: store.ErrorHandler,
+		DenyHandler:  store.DenyHandler,
+	}
+
+	return newRateLimiter(store, config)
+}
+
+// NewGCRAMiddlewarePerPath creates a new GCRA rate limiting middleware that's path-specific
+func NewGCRAMiddlewarePerPath(rate float64, burst int, window time.Duration) echo.MiddlewareFunc {
+	store := NewGCRAStore(ratelimit.GetRateLimitRepo(), rate, burst, window)
+
+	config := middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			path := c.Request().URL.Path
+			// Try to get API key first
+			apiKey := c.Request().Header.Get("X-API-Key")
+			if apiKey != "" {
+				return fmt.Sprintf("api:%s:%s", apiKey, path), nil
+			}
+			// Fall back to IP address
+			return fmt.Sprintf("ip:%s:%s", c.RealIP(), path), nil
+		},
+		ErrorHandler: store.ErrorHandler,
+		DenyHandler:  store.DenyHandler,
+	}
+
+	return newRateLimiter(store, config)
+}
@@ -0,0 +1,97 @@
+package strategy
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newGCRATestStore(rate float64, burst int, window time.Duration, clock *fakeClock) *GCRAStore {
+	store := NewGCRAStore(newMemoryRepo(), rate, burst, window)
+	store.now = clock.Now
+	return store
+}
+
+func TestGCRASteadyState(t *testing.T) {
+	// 10 requests per second, no bursting: one request every 100ms
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	store := newGCRATestStore(10, 1, time.Second, clock)
+
+	for i := 0; i < 50; i++ {
+		if got := allowN(t, store, 1); got != 1 {
+			t.Fatalf("request %d at the steady rate: expected it to be allowed", i+1)
+		}
+		// A second request before the interval has passed is too early
+		clock.now = clock.now.Add(50 * time.Millisecond)
+		if got := allowN(t, store, 1); got != 0 {
+			t.Fatalf("request %d: expected a request halfway through the interval to be denied", i+1)
+		}
+		clock.now = clock.now.Add(50 * time.Millisecond)
+	}
+
+	// Denied requests don't push the schedule back
+	if got := allowN(t, store, 1); got != 1 {
+		t.Error("expected the next request on schedule to be allowed after denials")
+	}
+}
+
+func TestGCRABurst(t *testing.T) {
+	// One request per second on average, with bursts of up to 5
+	start := time.Unix(1_700_000_000, 0)
+	clock := &fakeClock{now: start}
+	store := newGCRATestStore(60, 5, time.Minute, clock)
+
+	if got := allowN(t, store, 8); got != 5 {
+		t.Fatalf("expected a burst of 5 to be allowed at once, got %d", got)
+	}
+	info, err := store.GetRateLimitInfo("ip:10.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Limit != 5 || info.Used != 5 || info.Remaining != 0 || info.Reset != start.Add(5*time.Second).Unix() {
+		t.Errorf("expected a full bucket resetting in 5s, got %+v", info)
+	}
+
+	// Capacity comes back one interval at a time
+	clock.now = start.Add(1500 * time.Millisecond)
+	info, _ = store.GetRateLimitInfo("ip:10.0.0.1")
+	if info.Used != 4 || info.Remaining != 1 {
+		t.Errorf("expected one slot back after 1.5s, got %+v", info)
+	}
+	if got := allowN(t, store, 3); got != 1 {
+		t.Errorf("expected exactly one request after 1.5s, got %d", got)
+	}
+
+	// After a long idle period the full burst is available again, but never more
+	clock.now = start.Add(time.Hour)
+	if got := allowN(t, store, 10); got != 5 {
+		t.Errorf("expected the burst capacity to be restored to 5 after idling, got %d", got)
+	}
+}
+
+func TestGCRAConcurrentAllowsExactlyBurst(t *testing.T) {
+	store := NewGCRAStore(newMemoryRepo(), 1, 100, time.Hour)
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := store.Allow("ip:10.0.0.1")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if ok {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 100 {
+		t.Errorf("expected exactly 100 requests allowed, got %d", allowed)
+	}
+}
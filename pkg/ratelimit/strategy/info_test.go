@@ -0,0 +1,71 @@
+package strategy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// infoStore is the part of each strategy store exercised by the invariant test
+type infoStore interface {
+	Allow(identifier string) (bool, error)
+	GetRateLimitInfo(identifier string) (*ratelimit.RateLimitResponse, error)
+	SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse)
+}
+
+func TestRateLimitInfoUsedPlusRemainingEqualsLimit(t *testing.T) {
+	const limit = 5
+	stores := map[string]infoStore{
+		"fixed_window":   NewFixedWindowStore(newMemoryRepo(), limit, time.Hour),
+		"sliding_window": NewSlidingWindowStore(newMemoryRepo(), limit, time.Hour),
+		"sliding_log":    NewSlidingWindowLogStore(newMemoryRepo(), limit, time.Hour),
+		"token_bucket":   NewTokenBucketStore(newMemoryRepo(), 0.001, limit, time.Hour),
+		"leaky_bucket":   NewLeakyBucketStore(newMemoryRepo(), limit, 0.001, time.Hour),
+		"gcra":           NewGCRAStore(newMemoryRepo(), 1, limit, time.Hour),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			// Go past the limit so denied requests are covered too
+			for i := 0; i <= limit+2; i++ {
+				info, err := store.GetRateLimitInfo("ip:10.0.0.1")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if info.Limit != limit {
+					t.Fatalf("expected limit %d, got %d", limit, info.Limit)
+				}
+				if info.Used+info.Remaining != info.Limit {
+					t.Errorf("after %d requests: used %d + remaining %d != limit %d", i, info.Used, info.Remaining, info.Limit)
+				}
+				if want := min(i, limit); info.Used != want {
+					t.Errorf("after %d requests: expected used %d, got %d", i, want, info.Used)
+				}
+
+				if _, err := store.Allow("ip:10.0.0.1"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetRateLimitHeadersIncludesUsed(t *testing.T) {
+	store := NewFixedWindowStore(newMemoryRepo(), 10, time.Hour)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+	store.SetRateLimitHeaders(c, ratelimit.NewRateLimitResponse(10, 3, 0))
+
+	if got := rec.Header().Get("X-RateLimit-Used"); got != "3" {
+		t.Errorf("expected X-RateLimit-Used 3, got %q", got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "7" {
+		t.Errorf("expected X-RateLimit-Remaining 7, got %q", got)
+	}
+}
@@ -121,11 +121,6 @@ func (s *LeakyBucketStore) GetRateLimitInfo(identifier string) (*ratelimit.RateL
 	leaked := int(elapsed * s.leakRate)
 	currentWater := max(0, state.Water-leaked)
 
-	remaining := s.capacity - currentWater
-	if remaining < 0 {
-		remaining = 0
-	}
-
 	// Calculate when the bucket will have space again
 	var reset int64
 	if currentWater > 0 {
@@ -135,16 +130,22 @@ func (s *LeakyBucketStore) GetRateLimitInfo(identifier string) (*ratelimit.RateL
 		reset = now.Unix()
 	}
 
-	return &ratelimit.RateLimitResponse{
-		Limit:     s.capacity,
-		Remaining: remaining,
-		Reset:     reset,
-	}, nil
+	return ratelimit.NewRateLimitResponse(s.capacity, currentWater, reset), nil
+}
+
+// ResetLimit deletes identifier's bucket state so its next request gets the full allowance
+func (s *LeakyBucketStore) ResetLimit(identifier string) error {
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+	if err := s.repo.Reset(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
 }
 
 // SetRateLimitHeaders sets the rate limit headers in the response
 func (s *LeakyBucketStore) SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse) {
 	c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
+	c.Response().Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", info.Used))
 	c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
 	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset))
 }
@@ -196,7 +197,7 @@ func NewLeakyBucketMiddleware(capacity int, leakRate float64, expiresIn time.Dur
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
 
 // NewLeakyBucketMiddlewarePerPath creates a new leaky bucket rate limiting middleware that's path-specific
@@ -219,5 +220,5 @@ func NewLeakyBucketMiddlewarePerPath(capacity int, leakRate float64, expiresIn t
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
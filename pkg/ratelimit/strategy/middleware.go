@@ -0,0 +1,76 @@
+package strategy
+
+import (
+	"errors"
+	"sync"
+
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// middlewareStore is a rate limiter store that can report its state in response headers
+// and be reset per identifier
+type middlewareStore interface {
+	middleware.RateLimiterStore
+	GetRateLimitInfo(identifier string) (*ratelimit.RateLimitResponse, error)
+	SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse)
+	ResetLimit(identifier string) error
+}
+
+var (
+	storesMu sync.Mutex
+	stores   []middlewareStore
+)
+
+// newRateLimiter builds an echo rate limiter from config that also sets the store's
+// rate limit headers on permitted requests, so clients see how much of the limit
+// is left before they are denied. The store is registered for ResetLimit.
+func newRateLimiter(store middlewareStore, config middleware.RateLimiterConfig) echo.MiddlewareFunc {
+	storesMu.Lock()
+	stores = append(stores, store)
+	storesMu.Unlock()
+
+	config.Store = store
+	limiter := middleware.RateLimiterWithConfig(config)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return limiter(func(c echo.Context) error {
+			setAllowedHeaders(store, c, config.IdentifierExtractor)
+			return next(c)
+		})
+	}
+}
+
+// setAllowedHeaders sets the rate limit headers for a request store has just permitted.
+// Headers are left unset if the identifier or the store state cannot be read;
+// the request itself is never failed.
+func setAllowedHeaders(store middlewareStore, c echo.Context, extractor middleware.Extractor) {
+	identifier, err := extractor(c)
+	if err != nil {
+		return
+	}
+	info, err := store.GetRateLimitInfo(identifier)
+	if err != nil {
+		return
+	}
+	store.SetRateLimitHeaders(c, info)
+}
+
+// ResetLimit clears identifier's rate limit in every store built by the New*Middleware
+// constructors, so the client gets its full allowance back. Identifiers are "api:<key>"
+// or "ip:<address>"; path-specific limiters append the request path, e.g. "ip:10.0.0.1:/api/v1/users".
+func ResetLimit(identifier string) error {
+	storesMu.Lock()
+	registered := append([]middlewareStore(nil), stores...)
+	storesMu.Unlock()
+
+	var errs []error
+	for _, store := range registered {
+		if err := store.ResetLimit(identifier); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
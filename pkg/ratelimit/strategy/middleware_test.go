@@ -0,0 +1,130 @@
+package strategy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddlewareSetsHeadersOnAllowedResponses(t *testing.T) {
+	defer ratelimit.SetRateLimitRepo(ratelimit.GetRateLimitRepo())
+
+	const limit = 3
+	middlewares := map[string]func() echo.MiddlewareFunc{
+		"fixed_window":   func() echo.MiddlewareFunc { return NewFixedWindowMiddleware(limit, time.Hour) },
+		"sliding_window": func() echo.MiddlewareFunc { return NewSlidingWindowMiddleware(limit, time.Hour) },
+		"sliding_log":    func() echo.MiddlewareFunc { return NewSlidingWindowLogMiddleware(limit, time.Hour) },
+		"token_bucket":   func() echo.MiddlewareFunc { return NewTokenBucketMiddleware(0.001, limit, time.Hour) },
+		"leaky_bucket":   func() echo.MiddlewareFunc { return NewLeakyBucketMiddleware(limit, 0.001, time.Hour) },
+		"gcra":           func() echo.MiddlewareFunc { return NewGCRAMiddleware(1, limit, time.Hour) },
+	}
+
+	for name, newMiddleware := range middlewares {
+		t.Run(name, func(t *testing.T) {
+			ratelimit.SetRateLimitRepo(newMemoryRepo())
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, newMiddleware())
+
+			for i := 1; i <= limit; i++ {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+				}
+				if got := rec.Header().Get("X-RateLimit-Limit"); got != "3" {
+					t.Errorf("request %d: expected X-RateLimit-Limit 3, got %q", i, got)
+				}
+				if got, want := rec.Header().Get("X-RateLimit-Remaining"), strconv.Itoa(limit-i); got != want {
+					t.Errorf("request %d: expected X-RateLimit-Remaining %s, got %q", i, want, got)
+				}
+				if rec.Header().Get("X-RateLimit-Reset") == "" {
+					t.Errorf("request %d: expected X-RateLimit-Reset to be set", i)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != http.StatusTooManyRequests || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+				t.Errorf("expected a denied request with 0 remaining, got %d %q", rec.Code, rec.Header().Get("X-RateLimit-Remaining"))
+			}
+		})
+	}
+}
+
+func TestStoreResetLimitRestoresFullAllowance(t *testing.T) {
+	const limit = 3
+	stores := map[string]middlewareStore{
+		"fixed_window":   NewFixedWindowStore(newMemoryRepo(), limit, time.Hour),
+		"sliding_window": NewSlidingWindowStore(newMemoryRepo(), limit, time.Hour),
+		"sliding_log":    NewSlidingWindowLogStore(newMemoryRepo(), limit, time.Hour),
+		"token_bucket":   NewTokenBucketStore(newMemoryRepo(), 0.001, limit, time.Hour),
+		"leaky_bucket":   NewLeakyBucketStore(newMemoryRepo(), limit, 0.001, time.Hour),
+		"gcra":           NewGCRAStore(newMemoryRepo(), 1, limit, time.Hour),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < limit; i++ {
+				store.Allow("ip:10.0.0.1")
+			}
+			store.Allow("ip:10.0.0.2")
+			if allowed, _ := store.Allow("ip:10.0.0.1"); allowed {
+				t.Fatal("expected the client to be limited before the reset")
+			}
+
+			if err := store.ResetLimit("ip:10.0.0.1"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for i := 1; i <= limit; i++ {
+				if allowed, err := store.Allow("ip:10.0.0.1"); !allowed || err != nil {
+					t.Fatalf("request %d after reset: expected allowed, got %v, %v", i, allowed, err)
+				}
+			}
+			info, err := store.GetRateLimitInfo("ip:10.0.0.2")
+			if err != nil || info.Used != 1 {
+				t.Errorf("expected other clients to keep their usage, got %+v, %v", info, err)
+			}
+		})
+	}
+}
+
+func TestResetLimitResetsEveryMiddlewareStore(t *testing.T) {
+	defer ratelimit.SetRateLimitRepo(ratelimit.GetRateLimitRepo())
+	ratelimit.SetRateLimitRepo(newMemoryRepo())
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/fixed", ok, NewFixedWindowMiddleware(1, time.Hour))
+	e.GET("/bucket", ok, NewTokenBucketMiddleware(0.001, 1, time.Hour))
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	for _, path := range []string{"/fixed", "/bucket"} {
+		get(path)
+		if code := get(path); code != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected 429 before the reset, got %d", path, code)
+		}
+	}
+
+	// httptest requests come from 192.0.2.1
+	if err := ResetLimit("ip:192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, path := range []string{"/fixed", "/bucket"} {
+		if code := get(path); code != http.StatusOK {
+			t.Errorf("%s: expected 200 after the reset, got %d", path, code)
+		}
+	}
+}
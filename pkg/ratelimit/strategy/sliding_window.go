@@ -17,6 +17,7 @@ type SlidingWindowStore struct {
 	limit      int           // Maximum requests per window
 	windowSize time.Duration // Time window size
 	keyPrefix  string        // Key prefix for rate limit
+	now        func() time.Time
 }
 
 // NewSlidingWindowStore creates a new sliding window rate limiter
@@ -26,13 +27,14 @@ func NewSlidingWindowStore(repo ratelimit.RateLimitRepo, limit int, windowSize t
 		limit:      limit,
 		windowSize: windowSize,
 		keyPrefix:  "rate_limit_sliding_window",
+		now:        time.Now,
 	}
 }
 
 // Allow implements the RateLimiterStore interface
 func (s *SlidingWindowStore) Allow(identifier string) (bool, error) {
 	ctx := context.Background()
-	now := time.Now()
+	now := s.now()
 
 	// Get the current and previous window numbers
 	currentWindow := now.Unix() / int64(s.windowSize.Seconds())
@@ -78,7 +80,7 @@ func (s *SlidingWindowStore) Allow(identifier string) (bool, error) {
 // GetRateLimitInfo returns information about the current rate limit state
 func (s *SlidingWindowStore) GetRateLimitInfo(identifier string) (*ratelimit.RateLimitResponse, error) {
 	ctx := context.Background()
-	now := time.Now()
+	now := s.now()
 
 	currentWindow := now.Unix() / int64(s.windowSize.Seconds())
 	previousWindow := currentWindow - 1
@@ -100,24 +102,32 @@ func (s *SlidingWindowStore) GetRateLimitInfo(identifier string) (*ratelimit.Rat
 	previousWeight := 1 - offset
 
 	weightedCount := int(float64(previousCount)*previousWeight) + currentCount
-	remaining := s.limit - weightedCount
-	if remaining < 0 {
-		remaining = 0
-	}
 
 	// Calculate when the current window ends
 	nextReset := (currentWindow + 1) * int64(s.windowSize.Seconds())
 
-	return &ratelimit.RateLimitResponse{
-		Limit:     s.limit,
-		Remaining: remaining,
-		Reset:     nextReset,
-	}, nil
+	return ratelimit.NewRateLimitResponse(s.limit, weightedCount, nextReset), nil
+}
+
+// ResetLimit deletes identifier's counters for the current and previous windows, since both
+// are weighed by Allow, so its next request gets the full allowance
+func (s *SlidingWindowStore) ResetLimit(identifier string) error {
+	ctx := context.Background()
+	currentWindow := s.now().Unix() / int64(s.windowSize.Seconds())
+
+	for _, window := range []int64{currentWindow, currentWindow - 1} {
+		key := fmt.Sprintf("%s:%s:%d", s.keyPrefix, identifier, window)
+		if err := s.repo.Reset(ctx, key); err != nil {
+			return fmt.Errorf("failed to reset rate limit: %w", err)
+		}
+	}
+	return nil
 }
 
 // SetRateLimitHeaders sets the rate limit headers in the response
 func (s *SlidingWindowStore) SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse) {
 	c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
+	c.Response().Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", info.Used))
 	c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
 	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset))
 }
@@ -169,7 +179,7 @@ func NewSlidingWindowMiddleware(limit int, windowSize time.Duration) echo.Middle
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
 
 // NewSlidingWindowMiddlewarePerPath creates a new sliding window rate limiting middleware that's path-specific
@@ -192,5 +202,5 @@ func NewSlidingWindowMiddlewarePerPath(limit int, windowSize time.Duration) echo
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
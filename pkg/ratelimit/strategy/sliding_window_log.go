@@ -0,0 +1,158 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// SlidingWindowLogStore implements an exact sliding window rate limiter.
+// Every allowed request is logged with its timestamp, and a request is allowed only while
+// fewer than limit requests were logged in the preceding window. Unlike SlidingWindowStore,
+// which weights two fixed windows, the count is exact at any point in time, at the cost of
+// storing one entry per allowed request.
+type SlidingWindowLogStore struct {
+	repo       ratelimit.RateLimitRepo
+	limit      int           // Maximum requests per window
+	windowSize time.Duration // Time window size
+	keyPrefix  string        // Key prefix for rate limit
+	now        func() time.Time
+}
+
+// NewSlidingWindowLogStore creates a new sliding window log rate limiter
+func NewSlidingWindowLogStore(repo ratelimit.RateLimitRepo, limit int, windowSize time.Duration) *SlidingWindowLogStore {
+	return &SlidingWindowLogStore{
+		repo:       repo,
+		limit:      limit,
+		windowSize: windowSize,
+		keyPrefix:  "rate_limit_sliding_log",
+		now:        time.Now,
+	}
+}
+
+// Allow implements the RateLimiterStore interface
+func (s *SlidingWindowLogStore) Allow(identifier string) (bool, error) {
+	ctx := context.Background()
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+
+	// Trimming, counting and logging happen atomically, so concurrent requests can't overshoot the limit
+	allowed, _, err := s.repo.LogRequest(ctx, key, s.now(), s.windowSize, s.limit)
+	if err != nil {
+		return false, fmt.Errorf("failed to log request: %w", err)
+	}
+	return allowed, nil
+}
+
+// GetRateLimitInfo returns information about the current rate limit state.
+// Reset is when the oldest logged request leaves the window and frees a slot.
+func (s *SlidingWindowLogStore) GetRateLimitInfo(identifier string) (*ratelimit.RateLimitResponse, error) {
+	ctx := context.Background()
+	now := s.now()
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+
+	count, oldest, err := s.repo.CountLog(ctx, key, now, s.windowSize)
+	if err != nil {
+		return nil, err
+	}
+
+	reset := now.Unix()
+	if count > 0 {
+		reset = oldest.Add(s.windowSize).Unix()
+	}
+	return ratelimit.NewRateLimitResponse(s.limit, count, reset), nil
+}
+
+// ResetLimit deletes identifier's request log so its next request gets the full allowance
+func (s *SlidingWindowLogStore) ResetLimit(identifier string) error {
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+	if err := s.repo.Reset(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
+}
+
+// SetRateLimitHeaders sets the rate limit headers in the response
+func (s *SlidingWindowLogStore) SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse) {
+	c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
+	c.Response().Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", info.Used))
+	c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
+	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset))
+}
+
+// ErrorHandler handles internal errors
+func (s *SlidingWindowLogStore) ErrorHandler(c echo.Context, err error) error {
+	return c.JSON(500, map[string]string{
+		"error": "Internal rate limit error",
+	})
+}
+
+// DenyHandler handles rate limit exceeded errors
+func (s *SlidingWindowLogStore) DenyHandler(c echo.Context, identifier string, err error) error {
+	if err != nil {
+		return c.JSON(500, map[string]string{
+			"error": "Internal rate limit error",
+		})
+	}
+
+	info, err := s.GetRateLimitInfo(identifier)
+	if err != nil {
+		return c.JSON(500, map[string]string{
+			"error": "Failed to get rate limit info",
+		})
+	}
+
+	s.SetRateLimitHeaders(c, info)
+	return c.JSON(429, map[string]string{
+		"error": "Rate limit exceeded",
+	})
+}
+
+// NewSlidingWindowLogMiddleware creates a new sliding window log rate limiting middleware
+func NewSlidingWindowLogMiddleware(limit int, windowSize time.Duration) echo.MiddlewareFunc {
+	store := NewSlidingWindowLogStore(ratelimit.GetRateLimitRepo(), limit, windowSize)
+
+	config := middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			// Try to get API key first
+			apiKey := c.Request().Header.Get("X-API-Key")
+			if apiKey != "" {
+				return fmt.Sprintf("api:%s", apiKey), nil
+			}
+			// Fall back to IP address
+			return fmt.Sprintf("ip:%s", c.RealIP()), nil
+		},
+		ErrorHandler: store.ErrorHandler,
+		DenyHandler:  store.DenyHandler,
+	}
+
+	return newRateLimiter(store, config)
+}
+
+// NewSlidingWindowLogMiddlewarePerPath creates a new sliding window log rate limiting middleware that's path-specific
+func NewSlidingWindowLogMiddlewarePerPath(limit int, windowSize time.Duration) echo.MiddlewareFunc {
+	store := NewSlidingWindowLogStore(ratelimit.GetRateLimitRepo(), limit, windowSize)
+
+	config := middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			path := c.Request().URL.Path
+			// Try to get API key first
+			apiKey := c.Request().Header.Get("X-API-Key")
+			if apiKey != "" {
+				return fmt.Sprintf("api:%s:%s", apiKey, path), nil
+			}
+			// Fall back to IP address
+			return fmt.Sprintf("ip:%s:%s", c.RealIP(), path), nil
+		},
+		ErrorHandler: store.ErrorHandler,
+		DenyHandler:  store.DenyHandler,
+	}
+
+	return newRateLimiter(store, config)
+}
@@ -0,0 +1,133 @@
+package strategy
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source shared by the stores under comparison
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// allowN sends n requests and returns how many were allowed
+func allowN(t *testing.T, store interface{ Allow(string) (bool, error) }, n int) int {
+	t.Helper()
+	allowed := 0
+	for i := 0; i < n; i++ {
+		ok, err := store.Allow("ip:10.0.0.1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func newComparedStores(limit int, window time.Duration, clock *fakeClock) (*SlidingWindowStore, *SlidingWindowLogStore) {
+	approx := NewSlidingWindowStore(newMemoryRepo(), limit, window)
+	approx.now = clock.Now
+	exact := NewSlidingWindowLogStore(newMemoryRepo(), limit, window)
+	exact.now = clock.Now
+	return approx, exact
+}
+
+func TestSlidingWindowLogIsExactAcrossBoundaries(t *testing.T) {
+	const limit = 10
+	window := time.Minute
+	// The approximation counts in windows aligned to Unix time, so start on a window boundary
+	start := time.Unix(1_700_000_040, 0)
+
+	t.Run("burst just before a boundary", func(t *testing.T) {
+		clock := &fakeClock{now: start.Add(59 * time.Second)}
+		approx, exact := newComparedStores(limit, window, clock)
+		allowN(t, approx, limit)
+		allowN(t, exact, limit)
+
+		// Two seconds later all ten requests are still within the last minute
+		clock.now = clock.now.Add(2 * time.Second)
+		if got := allowN(t, exact, 1); got != 0 {
+			t.Errorf("log: expected the 11th request within a minute to be denied, got %d allowed", got)
+		}
+		// The previous window is weighted down to 59/60, so the approximation undercounts
+		if got := allowN(t, approx, 1); got != 1 {
+			t.Errorf("approximation: expected the boundary undercount to allow a request, got %d allowed", got)
+		}
+	})
+
+	t.Run("burst at the start of a window", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		approx, exact := newComparedStores(limit, window, clock)
+		allowN(t, approx, limit)
+		allowN(t, exact, limit)
+
+		// 90 seconds later none of the burst is within the last minute
+		clock.now = clock.now.Add(90 * time.Second)
+		if got := allowN(t, exact, limit); got != limit {
+			t.Errorf("log: expected the full limit to be available again, got %d allowed", got)
+		}
+		// Half of the previous window still counts, so the approximation overcounts
+		if got := allowN(t, approx, limit); got != limit/2 {
+			t.Errorf("approximation: expected the boundary overcount to allow %d, got %d", limit/2, got)
+		}
+	})
+
+	t.Run("slots free up one at a time", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		_, exact := newComparedStores(limit, window, clock)
+		for i := 0; i < limit; i++ {
+			allowN(t, exact, 1)
+			clock.now = clock.now.Add(time.Second)
+		}
+
+		// The first request leaves the window exactly a minute after it was logged
+		clock.now = start.Add(window - time.Millisecond)
+		if got := allowN(t, exact, 1); got != 0 {
+			t.Errorf("expected no slot before the oldest request expires, got %d allowed", got)
+		}
+		info, err := exact.GetRateLimitInfo("ip:10.0.0.1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Used != limit || info.Reset != start.Add(window).Unix() {
+			t.Errorf("expected %d used resetting at %d, got %+v", limit, start.Add(window).Unix(), info)
+		}
+
+		clock.now = start.Add(window)
+		if got := allowN(t, exact, 2); got != 1 {
+			t.Errorf("expected exactly one slot once the oldest request expires, got %d allowed", got)
+		}
+	})
+}
+
+func TestSlidingWindowLogConcurrentAllowsExactlyLimit(t *testing.T) {
+	store := NewSlidingWindowLogStore(newMemoryRepo(), 100, time.Hour)
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := store.Allow("ip:10.0.0.1")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if ok {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 100 {
+		t.Errorf("expected exactly 100 requests allowed, got %d", allowed)
+	}
+}
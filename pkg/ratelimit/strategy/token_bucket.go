@@ -125,16 +125,23 @@ func (s *TokenBucketStore) GetRateLimitInfo(identifier string) (*ratelimit.RateL
 	tokensNeeded := float64(s.burst) - currentTokens
 	timeToFull := time.Duration(tokensNeeded/s.rate) * time.Second
 
-	return &ratelimit.RateLimitResponse{
-		Limit:     s.burst,
-		Remaining: int(currentTokens),
-		Reset:     now.Add(timeToFull).Unix(),
-	}, nil
+	// Only whole tokens can be spent, so a partially refilled token counts as used
+	return ratelimit.NewRateLimitResponse(s.burst, s.burst-int(currentTokens), now.Add(timeToFull).Unix()), nil
+}
+
+// ResetLimit deletes identifier's bucket state so its next request gets the full allowance
+func (s *TokenBucketStore) ResetLimit(identifier string) error {
+	key := fmt.Sprintf("%s:%s", s.keyPrefix, identifier)
+	if err := s.repo.Reset(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
 }
 
 // SetRateLimitHeaders sets the rate limit headers in the response
 func (s *TokenBucketStore) SetRateLimitHeaders(c echo.Context, info *ratelimit.RateLimitResponse) {
 	c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
+	c.Response().Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", info.Used))
 	c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
 	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset))
 }
@@ -186,7 +193,7 @@ func NewTokenBucketMiddleware(rate float64, burst int, expiresIn time.Duration)
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
 
 // NewTokenBucketMiddlewarePerPath creates a new token bucket rate limiting middleware that's path-specific
@@ -209,5 +216,5 @@ func NewTokenBucketMiddlewarePerPath(rate float64, burst int, expiresIn time.Dur
 		DenyHandler:  store.DenyHandler,
 	}
 
-	return middleware.RateLimiterWithConfig(config)
+	return newRateLimiter(store, config)
 }
@@ -0,0 +1,142 @@
+package secutil
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2idPrefix starts every hash produced by HashPasswordArgon2
+const argon2idPrefix = "$argon2id$"
+
+// maxArgon2Memory caps the memory a stored hash may ask for, in KiB, so a tampered
+// hash cannot make verification allocate without bound
+const maxArgon2Memory = 1024 * 1024
+
+// maxArgon2Iterations caps the passes a stored hash may ask for, so a tampered hash
+// cannot make verification run for a practically unbounded time
+const maxArgon2Iterations = 64
+
+var (
+	// ErrPasswordMismatch is returned when a password does not match its hash, for either scheme
+	ErrPasswordMismatch = bcrypt.ErrMismatchedHashAndPassword
+	// ErrInvalidArgon2Hash is returned when an encoded Argon2id hash is malformed or unsupported
+	ErrInvalidArgon2Hash = errors.New("invalid argon2id hash")
+)
+
+// Argon2Params are the Argon2id cost parameters
+type Argon2Params struct {
+	// Memory is the memory used in KiB
+	Memory uint32
+	// Iterations is the number of passes over the memory
+	Iterations uint32
+	// Parallelism is the number of threads used
+	Parallelism uint8
+	// SaltLength is the length of the random salt in bytes
+	SaltLength uint32
+	// KeyLength is the length of the derived key in bytes
+	KeyLength uint32
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106, with less parallelism
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// validate checks the parameters can produce a usable hash
+func (p Argon2Params) validate() error {
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || p.SaltLength == 0 || p.KeyLength == 0 {
+		return fmt.Errorf("argon2 parameters must all be positive")
+	}
+	if p.Memory > maxArgon2Memory {
+		return fmt.Errorf("argon2 memory must be at most %d KiB", maxArgon2Memory)
+	}
+	if p.Iterations > maxArgon2Iterations {
+		return fmt.Errorf("argon2 iterations must be at most %d", maxArgon2Iterations)
+	}
+	return nil
+}
+
+// HashPasswordArgon2 creates an Argon2id hash of a password, encoded with its salt and
+// parameters as "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>"
+func HashPasswordArgon2(password string, params Argon2Params) (string, error) {
+	if err := params.validate(); err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	salt := make([]byte, params.SaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPasswordArgon2 checks if a password matches an encoded Argon2id hash.
+// It returns ErrPasswordMismatch if it does not, and ErrInvalidArgon2Hash if the hash cannot be read.
+func VerifyPasswordArgon2(encodedHash, password string) error {
+	params, salt, key, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// decodeArgon2Hash parses the parameters, salt and key out of an encoded Argon2id hash
+func decodeArgon2Hash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	// Sscanf stops at the last verb, so make sure nothing trails the parameters
+	if parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Iterations, params.Parallelism) {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+
+	salt, err := base64.RawStdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	key, err := base64.RawStdEncoding.Strict().DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	if err := params.validate(); err != nil {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	return params, salt, key, nil
+}
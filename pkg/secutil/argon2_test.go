@@ -0,0 +1,109 @@
+package secutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keep the tests fast; production hashes use DefaultArgon2Params
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2RoundTrip(t *testing.T) {
+	encoded, err := HashPasswordArgon2("correct horse battery staple", testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("expected the salt and parameters to be encoded, got %q", encoded)
+	}
+
+	if err := VerifyPasswordArgon2(encoded, "correct horse battery staple"); err != nil {
+		t.Errorf("expected the password to verify, got %v", err)
+	}
+	if err := VerifyPasswordArgon2(encoded, "wrong password"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected ErrPasswordMismatch, got %v", err)
+	}
+
+	other, _ := HashPasswordArgon2("correct horse battery staple", testArgon2Params)
+	if other == encoded {
+		t.Error("expected hashes of the same password to use different salts")
+	}
+}
+
+func TestArgon2RejectsTamperedHashes(t *testing.T) {
+	encoded, err := HashPasswordArgon2("s3cret-Passw0rd", testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	parts := strings.Split(encoded, "$")
+	with := func(i int, value string) string {
+		tampered := append([]string(nil), parts...)
+		tampered[i] = value
+		return strings.Join(tampered, "$")
+	}
+	flip := func(s string) string {
+		if s[0] == 'A' {
+			return "B" + s[1:]
+		}
+		return "A" + s[1:]
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    error
+	}{
+		{"changed key", with(5, flip(parts[5])), ErrPasswordMismatch},
+		{"changed salt", with(4, flip(parts[4])), ErrPasswordMismatch},
+		{"changed iterations", with(3, "m=1024,t=2,p=1"), ErrPasswordMismatch},
+		{"wrong variant", with(1, "argon2i"), ErrInvalidArgon2Hash},
+		{"wrong version", with(2, "v=16"), ErrInvalidArgon2Hash},
+		{"trailing parameters", with(3, "m=1024,t=1,p=1,x=9"), ErrInvalidArgon2Hash},
+		{"zero memory", with(3, "m=0,t=1,p=1"), ErrInvalidArgon2Hash},
+		{"huge memory", with(3, "m=4294967295,t=1,p=1"), ErrInvalidArgon2Hash},
+		{"huge iterations", with(3, "m=1024,t=4294967295,p=1"), ErrInvalidArgon2Hash},
+		{"bad base64", with(5, "not*base64"), ErrInvalidArgon2Hash},
+		{"missing key", strings.Join(parts[:5], "$"), ErrInvalidArgon2Hash},
+		{"empty", "", ErrInvalidArgon2Hash},
+	}
+	for _, tt := range tests {
+		if err := VerifyPasswordArgon2(tt.encoded, "s3cret-Passw0rd"); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestHashPasswordArgon2RejectsInvalidParams(t *testing.T) {
+	params := testArgon2Params
+	params.KeyLength = 0
+	if _, err := HashPasswordArgon2("s3cret-Passw0rd", params); err == nil {
+		t.Error("expected an error for a zero key length")
+	}
+
+	params = testArgon2Params
+	params.Iterations = maxArgon2Iterations + 1
+	if _, err := HashPasswordArgon2("s3cret-Passw0rd", params); err == nil {
+		t.Errorf("expected an error for more than %d iterations", maxArgon2Iterations)
+	}
+}
+
+func TestVerifyPasswordDetectsScheme(t *testing.T) {
+	bcryptHash, err := HashPassword("s3cret-Passw0rd")
+	if err != nil {
+		t.Fatalf("failed to hash with bcrypt: %v", err)
+	}
+	argonHash, err := HashPasswordArgon2("s3cret-Passw0rd", testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to hash with argon2id: %v", err)
+	}
+
+	for name, hash := range map[string]string{"bcrypt": bcryptHash, "argon2id": argonHash} {
+		if err := VerifyPassword(hash, "s3cret-Passw0rd"); err != nil {
+			t.Errorf("%s: expected the password to verify, got %v", name, err)
+		}
+		if err := VerifyPassword(hash, "wrong"); !errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("%s: expected ErrPasswordMismatch, got %v", name, err)
+		}
+	}
+}
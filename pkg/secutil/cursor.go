@@ -0,0 +1,53 @@
+package secutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or has been tampered with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor encodes v as an opaque, HMAC-protected pagination cursor.
+// The cursor is URL-safe base64 of the JSON payload followed by its signature.
+func EncodeCursor(v interface{}, secret string) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	mac, err := CreateHMAC(string(payload), secret, "sha256")
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(string(payload) + "." + mac)), nil
+}
+
+// DecodeCursor verifies a cursor produced by EncodeCursor and decodes its payload into v.
+// It returns ErrInvalidCursor if the cursor is malformed or its signature does not match.
+func DecodeCursor(cursor, secret string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	sep := strings.LastIndexByte(string(raw), '.')
+	if sep < 0 {
+		return ErrInvalidCursor
+	}
+	payload, mac := string(raw[:sep]), string(raw[sep+1:])
+
+	valid, err := VerifyHMAC(payload, secret, mac, "sha256")
+	if err != nil || !valid {
+		return ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal([]byte(payload), v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
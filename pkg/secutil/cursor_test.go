@@ -0,0 +1,61 @@
+package secutil
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testCursor struct {
+	LastID string `json:"last_id"`
+	Sort   string `json:"sort"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	in := testCursor{LastID: "65a1f0c2e4b0a1b2c3d4e5f6", Sort: "created_at"}
+
+	cursor, err := EncodeCursor(in, "cursor-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(cursor, in.LastID) {
+		t.Error("expected cursor to be opaque")
+	}
+
+	var out testCursor
+	if err := DecodeCursor(cursor, "cursor-secret", &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != in {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestCursorTamperRejected(t *testing.T) {
+	cursor, err := EncodeCursor(testCursor{LastID: "65a1f0c2e4b0a1b2c3d4e5f6"}, "cursor-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, _ := base64.RawURLEncoding.DecodeString(cursor)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), "65a1", "0000", 1)))
+
+	tests := map[string]struct {
+		cursor string
+		secret string
+	}{
+		"modified payload": {forged, "cursor-secret"},
+		"wrong secret":     {cursor, "other-secret"},
+		"not base64":       {"%%%", "cursor-secret"},
+		"unsigned":         {base64.RawURLEncoding.EncodeToString([]byte(`{"last_id":"x"}`)), "cursor-secret"},
+		"empty":            {"", "cursor-secret"},
+	}
+
+	for name, tt := range tests {
+		var out testCursor
+		if err := DecodeCursor(tt.cursor, tt.secret, &out); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package secutil
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMalformedSecret is returned when a sealed secret cannot be parsed
+	ErrMalformedSecret = errors.New("malformed sealed secret")
+
+	// ErrUnknownKeyVersion is returned when a sealed secret was wrapped by a master key the keyring does not hold
+	ErrUnknownKeyVersion = errors.New("unknown master key version")
+)
+
+// Keyring holds versioned master keys. New secrets are sealed with the highest version;
+// older versions are kept so secrets sealed before a rotation can still be opened.
+type Keyring struct {
+	keys    map[int][]byte
+	current int
+}
+
+// NewKeyring creates a keyring from master keys indexed by version.
+// Every key must be 16, 24, or 32 bytes long and versions must be positive.
+func NewKeyring(keys map[int][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one master key is required")
+	}
+
+	ring := &Keyring{keys: make(map[int][]byte, len(keys))}
+	for version, key := range keys {
+		if version < 1 {
+			return nil, fmt.Errorf("invalid master key version %d: must be positive", version)
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return nil, fmt.Errorf("invalid master key %d length: must be 16, 24, or 32", version)
+		}
+		ring.keys[version] = append([]byte(nil), key...)
+		if version > ring.current {
+			ring.current = version
+		}
+	}
+	return ring, nil
+}
+
+// ParseKeyring parses master keys from a comma-separated list of version:base64key pairs,
+// e.g. "2:q8N...,1:Zm9...", as read from an environment variable
+func ParseKeyring(spec string) (*Keyring, error) {
+	keys := make(map[int][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionStr, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid master key entry %d: expected version:base64key", len(keys)+1)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key version %q", versionStr)
+		}
+		if _, exists := keys[version]; exists {
+			return nil, fmt.Errorf("duplicate master key version %d", version)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %d: %w", version, err)
+		}
+		keys[version] = key
+	}
+	return NewKeyring(keys)
+}
+
+// CurrentVersion returns the version new secrets are sealed with
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// SealSecret encrypts plaintext with a fresh data key and wraps that key with the current master key.
+// The result has the form "v<version>.<wrapped data key>.<ciphertext>" and is safe to store as a string.
+func SealSecret(plaintext []byte, ring *Keyring) (string, error) {
+	dataKey, err := GenerateKey(32)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := Encrypt(plaintext, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	wrapped, err := Encrypt(dataKey, ring.keys[ring.current])
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return formatSealed(ring.current, wrapped, ciphertext), nil
+}
+
+// OpenSecret decrypts a secret produced by SealSecret using whichever master key version wrapped it
+func OpenSecret(sealed string, ring *Keyring) ([]byte, error) {
+	version, wrapped, ciphertext, err := parseSealed(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := unwrapDataKey(ring, version, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := Decrypt(ciphertext, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+// RewrapSecret re-wraps a sealed secret's data key with the current master key, leaving the
+// ciphertext untouched. Run it over stored secrets after a rotation so the old key can be retired.
+func RewrapSecret(sealed string, ring *Keyring) (string, error) {
+	version, wrapped, ciphertext, err := parseSealed(sealed)
+	if err != nil {
+		return "", err
+	}
+	if version == ring.current {
+		return sealed, nil
+	}
+
+	dataKey, err := unwrapDataKey(ring, version, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	rewrapped, err := Encrypt(dataKey, ring.keys[ring.current])
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return formatSealed(ring.current, rewrapped, ciphertext), nil
+}
+
+// SealedKeyVersion returns the master key version that wrapped a sealed secret
+func SealedKeyVersion(sealed string) (int, error) {
+	version, _, _, err := parseSealed(sealed)
+	return version, err
+}
+
+// unwrapDataKey decrypts a wrapped data key with the given master key version
+func unwrapDataKey(ring *Keyring, version int, wrapped string) ([]byte, error) {
+	masterKey, ok := ring.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+
+	dataKey, err := Decrypt(wrapped, masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// formatSealed joins the parts of a sealed secret; base64 never contains the separator
+func formatSealed(version int, wrapped, ciphertext string) string {
+	return "v" + strconv.Itoa(version) + "." + wrapped + "." + ciphertext
+}
+
+// parseSealed splits a sealed secret into its master key version, wrapped data key and ciphertext
+func parseSealed(sealed string) (int, string, string, error) {
+	parts := strings.Split(sealed, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "v") {
+		return 0, "", "", ErrMalformedSecret
+	}
+
+	version, err := strconv.Atoi(parts[0][1:])
+	if err != nil || version < 1 {
+		return 0, "", "", ErrMalformedSecret
+	}
+	return version, parts[1], parts[2], nil
+}
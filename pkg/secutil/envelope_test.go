@@ -0,0 +1,135 @@
+package secutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, versions ...int) (*Keyring, map[int][]byte) {
+	t.Helper()
+	keys := make(map[int][]byte)
+	for _, version := range versions {
+		key, err := GenerateKey(32)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		keys[version] = key
+	}
+	ring, err := NewKeyring(keys)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	return ring, keys
+}
+
+func TestSealOpenSecret(t *testing.T) {
+	ring, _ := testKeyring(t, 1)
+	secret := []byte("JBSWY3DPEHPK3PXP")
+
+	sealed, err := SealSecret(secret, ring)
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if strings.Contains(sealed, string(secret)) || !strings.HasPrefix(sealed, "v1.") {
+		t.Errorf("expected an opaque secret sealed with version 1, got %q", sealed)
+	}
+
+	again, _ := SealSecret(secret, ring)
+	if again == sealed {
+		t.Error("expected every seal to use a fresh data key and nonce")
+	}
+
+	opened, err := OpenSecret(sealed, ring)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	if !bytes.Equal(opened, secret) {
+		t.Errorf("expected %q, got %q", secret, opened)
+	}
+
+	other, _ := testKeyring(t, 1)
+	if _, err := OpenSecret(sealed, other); err == nil {
+		t.Error("expected a different master key to fail to open the secret")
+	}
+
+	parts := strings.Split(sealed, ".")
+	tampered := parts[0] + "." + parts[1] + "." + parts[1]
+	if _, err := OpenSecret(tampered, ring); err == nil {
+		t.Error("expected a tampered ciphertext to fail to open")
+	}
+
+	for _, malformed := range []string{"", "plaintext", "x1.a.b", "v0.a.b", "v1.a"} {
+		if _, err := OpenSecret(malformed, ring); !errors.Is(err, ErrMalformedSecret) {
+			t.Errorf("OpenSecret(%q): expected ErrMalformedSecret, got %v", malformed, err)
+		}
+	}
+}
+
+func TestSealSecretKeyRotation(t *testing.T) {
+	oldRing, keys := testKeyring(t, 1)
+	sealed, err := SealSecret([]byte("rotate me"), oldRing)
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	// Rotate: add version 2 while keeping version 1 for existing secrets
+	newKey, _ := GenerateKey(32)
+	ring, err := NewKeyring(map[int][]byte{1: keys[1], 2: newKey})
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	if ring.CurrentVersion() != 2 {
+		t.Fatalf("expected current version 2, got %d", ring.CurrentVersion())
+	}
+
+	if opened, err := OpenSecret(sealed, ring); err != nil || string(opened) != "rotate me" {
+		t.Fatalf("expected a secret sealed before the rotation to open, got %q, %v", opened, err)
+	}
+	if fresh, _ := SealSecret([]byte("new"), ring); !strings.HasPrefix(fresh, "v2.") {
+		t.Errorf("expected new secrets to be sealed with version 2, got %q", fresh)
+	}
+
+	rewrapped, err := RewrapSecret(sealed, ring)
+	if err != nil {
+		t.Fatalf("failed to rewrap: %v", err)
+	}
+	if version, _ := SealedKeyVersion(rewrapped); version != 2 {
+		t.Errorf("expected the rewrapped secret to use version 2, got %d", version)
+	}
+	if strings.Split(rewrapped, ".")[2] != strings.Split(sealed, ".")[2] {
+		t.Error("expected rewrapping to keep the ciphertext")
+	}
+
+	// Once every secret is rewrapped the old key can be retired
+	retired, _ := NewKeyring(map[int][]byte{2: newKey})
+	if opened, err := OpenSecret(rewrapped, retired); err != nil || string(opened) != "rotate me" {
+		t.Errorf("expected the rewrapped secret to open without version 1, got %q, %v", opened, err)
+	}
+	if _, err := OpenSecret(sealed, retired); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("expected ErrUnknownKeyVersion for a retired key, got %v", err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	key1, _ := GenerateKey(32)
+	key2, _ := GenerateKey(16)
+	spec := "1:" + base64.StdEncoding.EncodeToString(key1) + ", 2:" + base64.StdEncoding.EncodeToString(key2)
+
+	ring, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ring.CurrentVersion() != 2 || !bytes.Equal(ring.keys[1], key1) || !bytes.Equal(ring.keys[2], key2) {
+		t.Errorf("expected both keys with version 2 current, got %+v", ring)
+	}
+
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	for _, invalid := range []string{"", "nokey", "a:" + short, "1:" + short, "0:" + base64.StdEncoding.EncodeToString(key1), "1:%%%", spec + ",1:" + base64.StdEncoding.EncodeToString(key1)} {
+		if _, err := ParseKeyring(invalid); err == nil {
+			t.Errorf("ParseKeyring(%q): expected an error", invalid)
+		}
+	}
+}
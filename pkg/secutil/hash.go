@@ -1,6 +1,7 @@
 package secutil
 
 import (
+	"crypto/hkdf"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
@@ -8,6 +9,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"hash"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -15,19 +17,46 @@ import (
 const (
 	// DefaultCost is the default bcrypt cost factor
 	DefaultCost = 12
+	// MinCost and MaxCost bound the bcrypt cost factor
+	MinCost = bcrypt.MinCost
+	MaxCost = bcrypt.MaxCost
 )
 
 // HashPassword creates a bcrypt hash of a password
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), DefaultCost)
+	return HashPasswordWithCost(password, DefaultCost)
+}
+
+// HashPasswordWithCost creates a bcrypt hash of a password with the given cost factor,
+// which must be between MinCost and MaxCost
+func HashPasswordWithCost(password string, cost int) (string, error) {
+	if cost < MinCost || cost > MaxCost {
+		return "", fmt.Errorf("failed to hash password: cost %d must be between %d and %d", cost, MinCost, MaxCost)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
 	return string(bytes), nil
 }
 
-// VerifyPassword checks if a password matches its hash
+// NeedsRehash reports whether a bcrypt hash was made with a cost below desiredCost and
+// should be replaced once the password is next known. Hashes are never downgraded, and
+// hashes that are not bcrypt, such as Argon2id ones, are reported as current.
+func NeedsRehash(hashedPassword string, desiredCost int) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return false
+	}
+	return cost < desiredCost
+}
+
+// VerifyPassword checks if a password matches its hash, which may be a bcrypt hash or an
+// Argon2id hash from HashPasswordArgon2, so both schemes can coexist while passwords migrate
 func VerifyPassword(hashedPassword, password string) error {
+	if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return VerifyPasswordArgon2(hashedPassword, password)
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
@@ -49,6 +78,18 @@ func HashString(s, algorithm string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// HashAPIKey returns the hex-encoded SHA-256 digest an API key is stored as.
+// API keys are long and random, so an unsalted fast hash is safe and keeps the digest indexable for lookups.
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIKey reports whether apiKey hashes to storedHash, comparing the digests in constant time
+func VerifyAPIKey(apiKey, storedHash string) bool {
+	return CompareHashes(HashAPIKey(apiKey), storedHash)
+}
+
 // CreateHMAC creates an HMAC of a message using the specified key and hash algorithm
 func CreateHMAC(message, key string, algorithm string) (string, error) {
 	var h func() hash.Hash
@@ -79,3 +120,13 @@ func VerifyHMAC(message, key, signature, algorithm string) (bool, error) {
 func CompareHashes(hash1, hash2 string) bool {
 	return hmac.Equal([]byte(hash1), []byte(hash2))
 }
+
+// DeriveKey derives a hex-encoded 256-bit key for purpose from secret with HKDF-SHA256, so one
+// secret can key several uses without a key for one use revealing or matching another's
+func DeriveKey(secret, purpose string) (string, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, purpose, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
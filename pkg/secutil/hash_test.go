@@ -0,0 +1,103 @@
+package secutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashAPIKeyMatchesSHA256(t *testing.T) {
+	want, err := HashString("sk_live_abc123", "sha256")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := HashAPIKey("sk_live_abc123"); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	stored := HashAPIKey("sk_live_abc123")
+
+	tests := []struct {
+		name   string
+		apiKey string
+		stored string
+		want   bool
+	}{
+		{"matching key", "sk_live_abc123", stored, true},
+		{"different key", "sk_live_abc124", stored, false},
+		{"key prefix", "sk_live_abc12", stored, false},
+		{"empty key", "", stored, false},
+		{"stored hash used as key", stored, stored, false},
+		{"uppercase stored hash", "sk_live_abc123", strings.ToUpper(stored), false},
+		{"truncated stored hash", "sk_live_abc123", stored[:32], false},
+		{"empty stored hash", "sk_live_abc123", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := VerifyAPIKey(tt.apiKey, tt.stored); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHashPasswordWithCost(t *testing.T) {
+	hash, err := HashPasswordWithCost("s3cret-Passw0rd", MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if err := VerifyPassword(hash, "s3cret-Passw0rd"); err != nil {
+		t.Errorf("expected the password to verify, got %v", err)
+	}
+
+	for _, cost := range []int{MinCost - 1, MaxCost + 1} {
+		if _, err := HashPasswordWithCost("s3cret-Passw0rd", cost); err == nil {
+			t.Errorf("cost %d: expected an error", cost)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := HashPasswordWithCost("s3cret-Passw0rd", MinCost+1)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	argonHash, err := HashPasswordArgon2("s3cret-Passw0rd", testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hash   string
+		desire int
+		want   bool
+	}{
+		{"lower cost", hash, MinCost + 2, true},
+		{"same cost", hash, MinCost + 1, false},
+		{"higher cost is not downgraded", hash, MinCost, false},
+		{"argon2id", argonHash, MaxCost, false},
+		{"not a hash", "plaintext", MaxCost, false},
+	}
+	for _, tt := range tests {
+		if got := NeedsRehash(tt.hash, tt.desire); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	key, err := DeriveKey("f3b1c2d4e5a6978812ab34cd56ef7890", "pagination cursors")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(key) != 64 || key == "f3b1c2d4e5a6978812ab34cd56ef7890" {
+		t.Errorf("expected a 256-bit hex key distinct from the secret, got %q", key)
+	}
+	if again, _ := DeriveKey("f3b1c2d4e5a6978812ab34cd56ef7890", "pagination cursors"); again != key {
+		t.Error("expected the same secret and purpose to derive the same key")
+	}
+	if other, _ := DeriveKey("f3b1c2d4e5a6978812ab34cd56ef7890", "sessions"); other == key {
+		t.Error("expected another purpose to derive another key")
+	}
+}
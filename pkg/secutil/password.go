@@ -0,0 +1,154 @@
+package secutil
+
+import (
+	_ "embed"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords is the set of well-known passwords, lowercased
+var commonPasswords = func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, p := range strings.Split(commonPasswordList, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			set[strings.ToLower(p)] = struct{}{}
+		}
+	}
+	return set
+}()
+
+// leetReplacer undoes common character substitutions, e.g. "p@ssw0rd" -> "password"
+var leetReplacer = strings.NewReplacer(
+	"@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t",
+)
+
+const (
+	// MaxPasswordScore is the highest score returned by ScorePassword
+	MaxPasswordScore = 4
+	// minPasswordLength is the length below which a password is always weak
+	minPasswordLength = 8
+)
+
+// ScorePassword rates a password from 0 (very weak) to 4 (strong) and explains its weaknesses.
+// It considers length, character classes, common passwords and predictable patterns.
+func ScorePassword(password string) (score int, reasons []string) {
+	length := utf8.RuneCountInString(password)
+
+	switch {
+	case length >= 16:
+		score = 3
+	case length >= 12:
+		score = 2
+	case length >= minPasswordLength:
+		score = 1
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	classes := 0
+	for _, has := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes >= 3 {
+		score++
+	}
+	if classes == 4 {
+		score++
+	}
+
+	if !hasLower || !hasUpper {
+		reasons = append(reasons, "use both uppercase and lowercase letters")
+	}
+	if !hasDigit {
+		reasons = append(reasons, "add a number")
+	}
+	if !hasSymbol {
+		reasons = append(reasons, "add a symbol")
+	}
+
+	if hasPredictablePattern(password) {
+		score--
+		reasons = append(reasons, "avoid repeated characters and sequences like \"aaa\" or \"123\"")
+	}
+
+	if length < minPasswordLength {
+		score = min(score, 1)
+		reasons = append([]string{"use at least 8 characters"}, reasons...)
+	}
+
+	if isCommonPassword(password) {
+		score = 0
+		reasons = append([]string{"this is a commonly used password"}, reasons...)
+	} else if containsCommonPassword(password) {
+		score -= 2
+		reasons = append(reasons, "avoid common words and passwords")
+	}
+
+	return max(0, min(score, MaxPasswordScore)), reasons
+}
+
+// isCommonPassword reports whether the password, ignoring case, trailing digits and
+// character substitutions, is a well-known password
+func isCommonPassword(password string) bool {
+	lower := strings.ToLower(password)
+	candidates := []string{
+		lower,
+		strings.TrimRightFunc(lower, func(r rune) bool { return unicode.IsDigit(r) || unicode.IsPunct(r) }),
+		leetReplacer.Replace(lower),
+	}
+	for _, c := range candidates {
+		if _, ok := commonPasswords[c]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// containsCommonPassword reports whether a common password of five or more characters appears in the password
+func containsCommonPassword(password string) bool {
+	lower := strings.ToLower(password)
+	normalized := leetReplacer.Replace(lower)
+	for common := range commonPasswords {
+		if len(common) < 5 {
+			continue
+		}
+		if strings.Contains(lower, common) || strings.Contains(normalized, common) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPredictablePattern reports whether the password contains three identical
+// characters in a row or a run of three ascending or descending characters
+func hasPredictablePattern(password string) bool {
+	runes := []rune(strings.ToLower(password))
+	for i := 2; i < len(runes); i++ {
+		a, b, c := runes[i-2], runes[i-1], runes[i]
+		if a == b && b == c {
+			return true
+		}
+		if b-a == c-b && (b-a == 1 || b-a == -1) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package secutil
+
+import "testing"
+
+func TestScorePasswordCommonPasswordsScoreLow(t *testing.T) {
+	for _, password := range []string{"password", "Password1", "P@ssw0rd", "qwerty123", "letmein", "123456789", "iloveyou!"} {
+		score, reasons := ScorePassword(password)
+		if score > 1 {
+			t.Errorf("%q: expected score <= 1, got %d", password, score)
+		}
+		if len(reasons) == 0 {
+			t.Errorf("%q: expected reasons for a weak password", password)
+		}
+	}
+}
+
+func TestScorePasswordComplexPasswordsScoreHigh(t *testing.T) {
+	for _, password := range []string{"Tr0ub4dor&3xK!9q", "v9#Lq2!mZp$8Wt", "Gx7$kR2@wQ9z"} {
+		score, reasons := ScorePassword(password)
+		if score < 3 {
+			t.Errorf("%q: expected score >= 3, got %d (%v)", password, score, reasons)
+		}
+	}
+}
+
+func TestScorePasswordReasons(t *testing.T) {
+	tests := []struct {
+		password string
+		reason   string
+	}{
+		{"Ab1!", "use at least 8 characters"},
+		{"lowercaseonly", "use both uppercase and lowercase letters"},
+		{"NoDigitsHere!", "add a number"},
+		{"NoSymbols123x", "add a symbol"},
+		{"Zaaa!9Kq#tmw", "avoid repeated characters and sequences like \"aaa\" or \"123\""},
+		{"xQ9#monkey!Lp", "avoid common words and passwords"},
+	}
+
+	for _, tt := range tests {
+		score, reasons := ScorePassword(tt.password)
+		found := false
+		for _, r := range reasons {
+			if r == tt.reason {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: expected reason %q, got %v", tt.password, tt.reason, reasons)
+		}
+		if score < 0 || score > MaxPasswordScore {
+			t.Errorf("%q: score %d out of range", tt.password, score)
+		}
+	}
+}
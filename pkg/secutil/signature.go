@@ -0,0 +1,100 @@
+package secutil
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformedSignature is returned when a signature header cannot be parsed
+	ErrMalformedSignature = errors.New("malformed signature header")
+
+	// ErrInvalidSignature is returned when no signature in the header matches the payload
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrSignatureExpired is returned when the signature timestamp is outside the tolerance
+	ErrSignatureExpired = errors.New("signature timestamp outside tolerance")
+)
+
+// SignPayload signs a webhook payload with HMAC-SHA256 and returns a header value
+// in the form "t=<unix timestamp>,v1=<hex signature>".
+// The timestamp is part of the signed content to prevent replay attacks.
+func SignPayload(body []byte, secret string) string {
+	return signPayloadAt(body, secret, time.Now())
+}
+
+// VerifyPayload verifies a signature header produced by SignPayload.
+// The header may contain several v1 entries (e.g. during secret rotation); any match is accepted.
+// A tolerance of zero disables the timestamp check.
+func VerifyPayload(body []byte, header, secret string, tolerance time.Duration) error {
+	timestamp, signatures, err := parseSignatureHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+
+	expected := computeSignature(body, secret, timestamp)
+	for _, signature := range signatures {
+		if CompareHashes(signature, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// signPayloadAt signs a payload using the given time as the timestamp
+func signPayloadAt(body []byte, secret string, t time.Time) string {
+	timestamp := t.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, computeSignature(body, secret, timestamp))
+}
+
+// computeSignature returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func computeSignature(body []byte, secret string, timestamp int64) string {
+	// sha256 is always supported by CreateHMAC
+	signature, _ := CreateHMAC(strconv.FormatInt(timestamp, 10)+"."+string(body), secret, "sha256")
+	return signature
+}
+
+// parseSignatureHeader extracts the timestamp and v1 signatures from a signature header
+func parseSignatureHeader(header string) (int64, []string, error) {
+	var (
+		timestamp  int64
+		hasTime    bool
+		signatures []string
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return 0, nil, ErrMalformedSignature
+		}
+
+		switch key {
+		case "t":
+			ts, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, nil, ErrMalformedSignature
+			}
+			timestamp = ts
+			hasTime = true
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if !hasTime || len(signatures) == 0 {
+		return 0, nil, ErrMalformedSignature
+	}
+
+	return timestamp, signatures, nil
+}
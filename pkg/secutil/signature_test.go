@@ -0,0 +1,35 @@
+package secutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyPayload(t *testing.T) {
+	body := []byte(`{"event":"product.restocked","id":"42"}`)
+	secret := "whsec_test"
+
+	tests := []struct {
+		name   string
+		body   []byte
+		header string
+		secret string
+		want   error
+	}{
+		{"valid", body, SignPayload(body, secret), secret, nil},
+		{"tampered body", []byte(`{"event":"product.restocked","id":"43"}`), SignPayload(body, secret), secret, ErrInvalidSignature},
+		{"wrong secret", body, SignPayload(body, secret), "other", ErrInvalidSignature},
+		{"expired", body, signPayloadAt(body, secret, time.Now().Add(-10*time.Minute)), secret, ErrSignatureExpired},
+		{"malformed", body, "v1=deadbeef", secret, ErrMalformedSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyPayload(tt.body, tt.header, tt.secret, 5*time.Minute)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyPayload() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package secutil
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key from RFC 6238 Appendix B, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("at %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestTOTPCodeRejectsInvalidSecrets(t *testing.T) {
+	for _, secret := range []string{"", "not base32!", "1"} {
+		if got := TOTPCode(secret, time.Unix(59, 0)); got != "" {
+			t.Errorf("%q: expected no code, got %q", secret, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("expected a 32 character base32 secret, got %q", secret)
+	}
+
+	now := time.Unix(1111111111, 0)
+	current := TOTPCode(rfc6238Secret, now)
+	previous := TOTPCode(rfc6238Secret, now.Add(-TOTPPeriod))
+	next := TOTPCode(rfc6238Secret, now.Add(TOTPPeriod))
+	stale := TOTPCode(rfc6238Secret, now.Add(-3*TOTPPeriod))
+
+	tests := []struct {
+		name string
+		code string
+		skew int
+		want bool
+	}{
+		{"current code", current, 0, true},
+		{"previous code within skew", previous, 1, true},
+		{"previous code without skew", previous, 0, false},
+		{"next code within skew", next, 1, true},
+		{"stale code", stale, 1, false},
+		{"stale code within a wider skew", stale, 3, true},
+		{"wrong length", current[:5], 1, false},
+		{"empty", "", 1, false},
+		{"negative skew", current, -1, false},
+	}
+	for _, tt := range tests {
+		if got := validateTOTPAt(rfc6238Secret, tt.code, tt.skew, now); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if validateTOTPAt("not base32!", current, 1, now) {
+		t.Error("expected an invalid secret to reject every code")
+	}
+	if !ValidateTOTP(secret, TOTPCode(secret, time.Now()), 1) {
+		t.Error("expected the current code for a generated secret to be valid")
+	}
+}
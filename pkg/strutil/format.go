@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -12,20 +13,43 @@ import (
 
 var (
 	numberRegex = regexp.MustCompile("[0-9]+")
-	upperRegex  = regexp.MustCompile("[A-Z]")
 	titleCaser  = cases.Title(language.English)
 )
 
-// ToSnake converts a string to snake_case
+// ToSnake converts a string to snake_case.
+// A word starts at a lowercase-to-uppercase change, at the last capital of an acronym run
+// that is followed by a lowercase letter, and where a run of digits follows a letter.
+// Letters after digits stay with them unless a capitalized word starts, so "HTTPServer"
+// becomes "http_server", "User2FA" becomes "user_2fa" and "HTTP2Server" becomes "http_2_server".
 func ToSnake(s string) string {
+	runes := []rune(s)
 	var result strings.Builder
-	for i, r := range s {
-		if i > 0 && upperRegex.MatchString(string(r)) {
+	for i, r := range runes {
+		if i > 0 && runes[i-1] != '_' && startsWord(runes, i) {
 			result.WriteRune('_')
 		}
-		result.WriteRune(r)
+		result.WriteRune(unicode.ToLower(r))
+	}
+	return result.String()
+}
+
+// startsWord reports whether runes[i] begins a new word, given the rune before it
+func startsWord(runes []rune, i int) bool {
+	prev, r := runes[i-1], runes[i]
+	nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+	switch {
+	case unicode.IsDigit(r):
+		return unicode.IsLetter(prev)
+	case unicode.IsUpper(r):
+		switch {
+		case unicode.IsLower(prev):
+			return true
+		case unicode.IsUpper(prev), unicode.IsDigit(prev):
+			// "HTTPServer" splits before the S, "2FA" stays together but "2Server" splits
+			return nextIsLower
+		}
 	}
-	return strings.ToLower(result.String())
+	return false
 }
 
 // ToCamel converts a string to camelCase
@@ -0,0 +1,57 @@
+package strutil
+
+import "testing"
+
+func TestToSnake(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		// Plain words
+		{"", ""},
+		{"name", "name"},
+		{"MyVariableName", "my_variable_name"},
+		{"myVariableName", "my_variable_name"},
+		{"already_snake", "already_snake"},
+		{"Already_Snake", "already_snake"},
+
+		// Acronyms
+		{"HTTPServer", "http_server"},
+		{"ID", "id"},
+		{"UserID", "user_id"},
+		{"APIKeyTTL", "api_key_ttl"},
+		{"parseURLQuery", "parse_url_query"},
+
+		// Digits
+		{"User2FA", "user_2fa"},
+		{"user2fa", "user_2fa"},
+		{"Base64", "base_64"},
+		{"HTTP2Server", "http_2_server"},
+		{"Sha256Sum", "sha_256_sum"},
+		{"2FAEnabled", "2fa_enabled"},
+	}
+
+	for _, tt := range tests {
+		if got := ToSnake(tt.in); got != tt.want {
+			t.Errorf("ToSnake(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToKebabMatchesToSnake(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"MyVariableName", "my-variable-name"},
+		{"HTTPServer", "http-server"},
+		{"User2FA", "user-2fa"},
+		{"APIKeyTTL", "api-key-ttl"},
+	}
+
+	for _, tt := range tests {
+		if got := ToKebab(tt.in); got != tt.want {
+			t.Errorf("ToKebab(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,13 @@ package strutil
 
 import (
 	"crypto/rand"
+	"errors"
 	"math/big"
 )
 
+// ErrInvalidLength is returned when a random string is requested with a length that is not positive
+var ErrInvalidLength = errors.New("length must be positive")
+
 const (
 	upperChars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	lowerChars   = "abcdefghijklmnopqrstuvwxyz"
@@ -12,10 +16,11 @@ const (
 	specialChars = "!@#$%^&*()-_=+[]{}|;:,.<>?"
 )
 
-// GenerateRandom generates a random string with specified parameters
+// GenerateRandom generates a random string with specified parameters.
+// It returns ErrInvalidLength if length is not positive, rather than an empty string.
 func GenerateRandom(length int, useUpper, useLower, useNumbers, useSpecial bool) (string, error) {
 	if length <= 0 {
-		return "", nil
+		return "", ErrInvalidLength
 	}
 
 	// Create character set based on parameters
@@ -53,15 +58,12 @@ func GenerateRandom(length int, useUpper, useLower, useNumbers, useSpecial bool)
 	return string(result), nil
 }
 
-// GenerateKey generates a random key (for API keys, tokens etc) with optional prefix
+// GenerateKey generates a random key (for API keys, tokens etc) with optional prefix.
+// It returns ErrInvalidLength if length leaves no room for random characters after the prefix.
 func GenerateKey(length int, prefix string) (string, error) {
 	// Generate random part (subtracting prefix length to maintain desired total length)
+	// using only URL-safe characters
 	randomLength := length - len(prefix)
-	if randomLength <= 0 {
-		return prefix, nil
-	}
-
-	// Generate random string using only URL-safe characters
 	random, err := GenerateRandom(randomLength, true, true, true, false)
 	if err != nil {
 		return "", err
@@ -0,0 +1,38 @@
+package strutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRandomRejectsNonPositiveLengths(t *testing.T) {
+	for _, length := range []int{0, -1, -32} {
+		s, err := GenerateRandom(length, true, true, true, false)
+		if !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("length %d: expected ErrInvalidLength, got %v", length, err)
+		}
+		if s != "" {
+			t.Errorf("length %d: expected no string, got %q", length, s)
+		}
+	}
+
+	s, err := GenerateRandom(24, true, true, true, false)
+	if err != nil || len(s) != 24 {
+		t.Errorf("expected a 24 character string, got %q %v", s, err)
+	}
+}
+
+func TestGenerateKeyRequiresRandomPart(t *testing.T) {
+	// A key made of only its prefix would be guessable
+	for _, length := range []int{0, 3, 4} {
+		if key, err := GenerateKey(length, "api-"); !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("length %d: expected ErrInvalidLength, got %q %v", length, key, err)
+		}
+	}
+
+	key, err := GenerateKey(36, "api-")
+	if err != nil || len(key) != 36 || !strings.HasPrefix(key, "api-") {
+		t.Errorf("expected a 36 character key with the prefix, got %q %v", key, err)
+	}
+}
@@ -2,11 +2,14 @@ package mwutil
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"go-echo-mongo/internal/model"
-	"log"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // APIKeyValidator is an interface for validating API keys and retrieving associated users
@@ -27,6 +30,47 @@ func GetAPIKeyValidator() APIKeyValidator {
 	return validator
 }
 
+// APIKeyCache is the subset of a cache repository used to cache API key lookups
+type APIKeyCache interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+}
+
+// DefaultAPIKeyCacheTTL is how long a validated API key is cached when no TTL is configured
+const DefaultAPIKeyCacheTTL = time.Minute
+
+// Global cache instance used by NewAPIKeyAuth
+var (
+	apiKeyCache    APIKeyCache
+	apiKeyCacheTTL time.Duration
+)
+
+// SetAPIKeyCache sets the cache used by NewAPIKeyAuth to avoid a validator call per request
+func SetAPIKeyCache(cache APIKeyCache, ttl time.Duration) {
+	apiKeyCache = cache
+	apiKeyCacheTTL = ttl
+}
+
+// APIKeyCacheKey returns the cache key for an API key. The key is hashed so raw
+// API keys never appear in the cache keyspace.
+func APIKeyCacheKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return HashedAPIKeyCacheKey(hex.EncodeToString(sum[:]))
+}
+
+// HashedAPIKeyCacheKey returns the cache key for an API key from its hex-encoded SHA-256
+// digest, as stored on the user, so the entry can be invalidated without the raw key
+func HashedAPIKeyCacheKey(hashedKey string) string {
+	return "apikey:" + hashedKey
+}
+
+// cachedAPIKeyUser is the minimal user view stored in the API key cache
+type cachedAPIKeyUser struct {
+	ID     string   `json:"id"`
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
 // APIKeyAuthConfig defines the config for API key middleware
 type APIKeyAuthConfig struct {
 	// Skipper defines a function to skip middleware
@@ -43,6 +87,15 @@ type APIKeyAuthConfig struct {
 	// Validator is the interface for validating API keys
 	Validator APIKeyValidator
 
+	// CacheRepository optionally caches validated users by API key.
+	// Only the user ID, roles and scopes are cached, so handlers behind a cached
+	// lookup should not rely on other user fields.
+	CacheRepository APIKeyCache
+
+	// CacheTTL is how long a validated user is cached
+	// Default is DefaultAPIKeyCacheTTL
+	CacheTTL time.Duration
+
 	// ErrorHandler is a function to handle API key validation errors
 	// If not set, default error handler is used
 	ErrorHandler func(c echo.Context, err error) error
@@ -54,6 +107,10 @@ type APIKeyAuthConfig struct {
 	// RequiredRoles specifies which roles are required to access the route
 	// If empty, DefaultRole will be used
 	RequiredRoles []string
+
+	// RequiredScopes specifies scopes the user must all hold, directly or through
+	// a wildcard such as "products:*", in addition to the required roles
+	RequiredScopes []string
 }
 
 // DefaultAPIKeyAuthConfig is the default API key middleware config
@@ -64,13 +121,13 @@ var DefaultAPIKeyAuthConfig = APIKeyAuthConfig{
 	RequiredRoles: []string{model.RoleUser},
 }
 
-// NewAPIKeyAuth returns a middleware that validates API keys using the global validator
+// NewAPIKeyAuth returns a middleware that validates API keys using the global validator.
+// It panics with a ConfigError if the validator is not set.
 func NewAPIKeyAuth(roles ...string) echo.MiddlewareFunc {
-	if GetAPIKeyValidator() == nil {
-		log.Fatal("echo: API key validator is not set")
-	}
 	c := DefaultAPIKeyAuthConfig
 	c.Validator = GetAPIKeyValidator()
+	c.CacheRepository = apiKeyCache
+	c.CacheTTL = apiKeyCacheTTL
 	if len(roles) > 0 {
 		c.RequiredRoles = roles
 	} else {
@@ -79,6 +136,17 @@ func NewAPIKeyAuth(roles ...string) echo.MiddlewareFunc {
 	return NewAPIKeyAuthWithConfig(c)
 }
 
+// NewAPIKeyAuthWithScopes returns a middleware like NewAPIKeyAuth that also requires
+// the user to hold every one of the given scopes
+func NewAPIKeyAuthWithScopes(scopes ...string) echo.MiddlewareFunc {
+	c := DefaultAPIKeyAuthConfig
+	c.Validator = GetAPIKeyValidator()
+	c.CacheRepository = apiKeyCache
+	c.CacheTTL = apiKeyCacheTTL
+	c.RequiredScopes = scopes
+	return NewAPIKeyAuthWithConfig(c)
+}
+
 // NewAPIKeyAuthWithConfig returns an API key middleware with config
 func NewAPIKeyAuthWithConfig(config APIKeyAuthConfig) echo.MiddlewareFunc {
 	// Defaults
@@ -92,7 +160,10 @@ func NewAPIKeyAuthWithConfig(config APIKeyAuthConfig) echo.MiddlewareFunc {
 		config.ContextKey = DefaultAPIKeyAuthConfig.ContextKey
 	}
 	if config.Validator == nil {
-		log.Fatal("echo: API key validator is required")
+		panic(&ConfigError{Middleware: "api key auth", Err: ErrAPIKeyValidatorNotSet})
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DefaultAPIKeyCacheTTL
 	}
 
 	// Initialize
@@ -124,23 +195,31 @@ func NewAPIKeyAuthWithConfig(config APIKeyAuthConfig) echo.MiddlewareFunc {
 			}
 
 			// Validate API key and get user
-			user, err := config.Validator.GetByApiKey(c.Request().Context(), key)
+			user, err := lookupAPIKeyUser(c.Request().Context(), config, key)
 			if err != nil {
 				if config.ErrorHandler != nil {
 					return config.ErrorHandler(c, err)
 				}
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid api key")
 			}
-			// Check if user has any of the required roles
+			// Check if user has any of the required roles, defaulting to at least the basic user role
+			hasRole := user.HasRole(model.RoleUser)
 			if len(config.RequiredRoles) > 0 {
-				if !user.HasAnyRole(config.RequiredRoles...) {
+				hasRole = user.HasAnyRole(config.RequiredRoles...)
+			}
+			if !hasRole {
+				if config.ErrorHandler != nil {
 					return config.ErrorHandler(c, echo.ErrForbidden)
 				}
-			} else {
-				// Default to requiring at least the basic user role
-				if !user.HasRole(model.RoleUser) {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient role")
+			}
+
+			// Check if user has every required scope
+			if !user.HasAllScopes(config.RequiredScopes...) {
+				if config.ErrorHandler != nil {
 					return config.ErrorHandler(c, echo.ErrForbidden)
 				}
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient scope")
 			}
 
 			// Store user in context
@@ -153,6 +232,33 @@ func NewAPIKeyAuthWithConfig(config APIKeyAuthConfig) echo.MiddlewareFunc {
 
 // Helper functions
 
+// lookupAPIKeyUser resolves the user for an API key, serving from the cache when configured.
+// Cache failures fall back to the validator so an unavailable cache never blocks requests.
+func lookupAPIKeyUser(ctx context.Context, config APIKeyAuthConfig, key string) (*model.User, error) {
+	if config.CacheRepository == nil {
+		return config.Validator.GetByApiKey(ctx, key)
+	}
+
+	cacheKey := APIKeyCacheKey(key)
+	var cached cachedAPIKeyUser
+	if err := config.CacheRepository.Get(ctx, cacheKey, &cached); err == nil {
+		if id, err := primitive.ObjectIDFromHex(cached.ID); err == nil {
+			user := &model.User{Roles: cached.Roles, Scopes: cached.Scopes}
+			user.ID = id
+			return user, nil
+		}
+	}
+
+	user, err := config.Validator.GetByApiKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = config.CacheRepository.Set(ctx, cacheKey, cachedAPIKeyUser{ID: user.ID.Hex(), Roles: user.Roles, Scopes: user.Scopes}, config.CacheTTL)
+
+	return user, nil
+}
+
 func splitKeyLookup(lookup string) []string {
 	parts := make([]string, 2)
 	i := 0
@@ -0,0 +1,227 @@
+package mwutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countingValidator returns a fixed user and counts lookups
+type countingValidator struct {
+	user  *model.User
+	calls int
+}
+
+func (v *countingValidator) GetByApiKey(ctx context.Context, apiKey string) (*model.User, error) {
+	v.calls++
+	if apiKey != v.user.ApiKey {
+		return nil, errors.New("not found")
+	}
+	return v.user, nil
+}
+
+// memoryCache is an in-memory APIKeyCache that ignores expiration
+type memoryCache struct {
+	items map[string][]byte
+	ttls  map[string]time.Duration
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{items: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, ok := m.items[key]
+	if !ok {
+		return errors.New("cache miss")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.items[key] = data
+	m.ttls[key] = expiration
+	return nil
+}
+
+func TestAPIKeyAuthCachesLookups(t *testing.T) {
+	user := &model.User{Name: "Jane", Email: "jane@example.com", ApiKey: "secret-key", Password: "hashed", Roles: []string{model.RoleUser}}
+	user.ID = primitive.NewObjectID()
+	v := &countingValidator{user: user}
+	cache := newMemoryCache()
+
+	e := echo.New()
+	var got *model.User
+	h := NewAPIKeyAuthWithConfig(APIKeyAuthConfig{
+		Validator:       v,
+		CacheRepository: cache,
+		CacheTTL:        30 * time.Second,
+		RequiredRoles:   []string{model.RoleUser},
+	})(func(c echo.Context) error {
+		got = c.Get("user").(*model.User)
+		return c.NoContent(http.StatusOK)
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "secret-key")
+		rec := httptest.NewRecorder()
+		if err := h(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+		if got.ID != user.ID || !got.HasRole(model.RoleUser) {
+			t.Errorf("request %d: expected user %v with roles, got %+v", i+1, user.ID, got)
+		}
+	}
+
+	if v.calls != 1 {
+		t.Errorf("expected the validator to be called once, got %d", v.calls)
+	}
+
+	key := APIKeyCacheKey("secret-key")
+	if cache.ttls[key] != 30*time.Second {
+		t.Errorf("expected cache TTL 30s, got %v", cache.ttls[key])
+	}
+	var cached map[string]interface{}
+	if err := json.Unmarshal(cache.items[key], &cached); err != nil {
+		t.Fatalf("failed to decode cached entry: %v", err)
+	}
+	if len(cached) != 2 || cached["id"] != user.ID.Hex() {
+		t.Errorf("expected only id and roles to be cached, got %v", cached)
+	}
+}
+
+func TestAPIKeyAuthCacheMissFallsBackToValidator(t *testing.T) {
+	user := &model.User{ApiKey: "secret-key", Roles: []string{model.RoleUser}}
+	user.ID = primitive.NewObjectID()
+	v := &countingValidator{user: user}
+	cache := newMemoryCache()
+
+	e := echo.New()
+	h := NewAPIKeyAuthWithConfig(APIKeyAuthConfig{Validator: v, CacheRepository: cache})(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	do := func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "secret-key")
+		if err := h(e.NewContext(req, httptest.NewRecorder())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	do()
+	delete(cache.items, APIKeyCacheKey("secret-key"))
+	do()
+
+	if v.calls != 2 {
+		t.Errorf("expected a cache miss to call the validator again, got %d calls", v.calls)
+	}
+	if _, ok := cache.items[APIKeyCacheKey("secret-key")]; !ok {
+		t.Error("expected the cache to be repopulated after a miss")
+	}
+	if cache.ttls[APIKeyCacheKey("secret-key")] != DefaultAPIKeyCacheTTL {
+		t.Errorf("expected default TTL, got %v", cache.ttls[APIKeyCacheKey("secret-key")])
+	}
+}
+
+func TestAPIKeyAuthRequiredScopes(t *testing.T) {
+	user := &model.User{ApiKey: "secret-key", Roles: []string{model.RoleUser}, Scopes: []string{"products:*", "users:read"}}
+	user.ID = primitive.NewObjectID()
+	cache := newMemoryCache()
+
+	tests := []struct {
+		scopes []string
+		want   int
+	}{
+		{nil, http.StatusOK},
+		{[]string{"users:read"}, http.StatusOK},
+		{[]string{"products:write"}, http.StatusOK},
+		{[]string{"products:read", "users:read"}, http.StatusOK},
+		{[]string{"users:write"}, http.StatusForbidden},
+		{[]string{"products:read", "users:write"}, http.StatusForbidden},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		// Run each case twice so the second request is served from the cache
+		for i := 0; i < 2; i++ {
+			h := NewAPIKeyAuthWithConfig(APIKeyAuthConfig{
+				Validator:       &countingValidator{user: user},
+				CacheRepository: cache,
+				RequiredScopes:  tt.scopes,
+			})(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-API-Key", "secret-key")
+			rec := httptest.NewRecorder()
+			err := h(e.NewContext(req, rec))
+
+			code := rec.Code
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				code = he.Code
+			}
+			if code != tt.want {
+				t.Errorf("scopes %v, request %d: expected %d, got %d", tt.scopes, i+1, tt.want, code)
+			}
+		}
+	}
+}
+
+func TestAPIKeyAuthRequiredRoles(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		need  []string
+		want  int
+	}{
+		{"has a required role", []string{model.RoleManager}, []string{model.RoleAdmin, model.RoleManager}, http.StatusOK},
+		{"lacks the required roles", []string{model.RoleUser}, []string{model.RoleAdmin}, http.StatusForbidden},
+		{"has the default user role", []string{model.RoleUser}, nil, http.StatusOK},
+		{"lacks the default user role", []string{model.RoleAdmin}, nil, http.StatusForbidden},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &model.User{ApiKey: "secret-key", Roles: tt.roles}
+			user.ID = primitive.NewObjectID()
+			h := NewAPIKeyAuthWithConfig(APIKeyAuthConfig{
+				Validator:     &countingValidator{user: user},
+				RequiredRoles: tt.need,
+			})(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-API-Key", "secret-key")
+			rec := httptest.NewRecorder()
+			err := h(e.NewContext(req, rec))
+
+			code := rec.Code
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				code = he.Code
+			}
+			if code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, code)
+			}
+		})
+	}
+}
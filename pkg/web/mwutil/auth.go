@@ -0,0 +1,280 @@
+package mwutil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-echo-mongo/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// AuthMethodAPIKey identifies a principal authenticated with an API key
+	AuthMethodAPIKey = "api_key"
+	// AuthMethodJWT identifies a principal authenticated with a JWT
+	AuthMethodJWT = "jwt"
+)
+
+// Principal is the authenticated caller, regardless of how it authenticated
+type Principal struct {
+	// ID is the user ID (API key) or the subject claim (JWT)
+	ID string
+	// Email is the user's email, if known
+	Email string
+	// Roles are the roles granted to the caller
+	Roles []string
+	// Method is the authentication method that succeeded
+	Method string
+	// User is the resolved user when authenticated with an API key
+	User *model.User
+	// Claims are the token claims when authenticated with a JWT
+	Claims map[string]interface{}
+}
+
+// Impersonated reports whether the principal authenticated with an impersonation token
+func (p *Principal) Impersonated() bool {
+	return IsImpersonated(p.Claims)
+}
+
+// HasAnyRole checks if the principal has any of the specified roles
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		for _, r := range p.Roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MultiAuthConfig defines the config for the combined API key or JWT middleware
+type MultiAuthConfig struct {
+	// Skipper defines a function to skip middleware
+	Skipper func(c echo.Context) bool
+
+	// APIKey configures API key lookup and validation
+	// If APIKey.Validator is nil, the global validator is used
+	APIKey APIKeyAuthConfig
+
+	// JWT configures token lookup and validation
+	JWT JWTConfig
+
+	// RequiredRoles specifies which roles are required to access the route
+	// Roles are checked against whichever method succeeded
+	RequiredRoles []string
+
+	// ContextKey is the key used to store the Principal in the context
+	// Default is "principal"
+	ContextKey string
+}
+
+// DefaultMultiAuthConfig is the default combined auth middleware config
+var DefaultMultiAuthConfig = MultiAuthConfig{
+	Skipper:       func(c echo.Context) bool { return false },
+	APIKey:        DefaultAPIKeyAuthConfig,
+	JWT:           DefaultJWTConfig,
+	RequiredRoles: []string{model.RoleUser},
+	ContextKey:    "principal",
+}
+
+// Global JWT settings used by NewAuth
+var (
+	authJWTSecret             string
+	authJWTRejectImpersonated bool
+)
+
+// SetAuthJWT sets the secret NewAuth validates bearer tokens with and whether it refuses
+// impersonation tokens. With an empty secret NewAuth accepts API keys only.
+func SetAuthJWT(secret string, rejectImpersonated bool) {
+	authJWTSecret = secret
+	authJWTRejectImpersonated = rejectImpersonated
+}
+
+// NewAuth returns the middleware protecting routes: an API key, or a bearer token validated
+// with the settings from SetAuthJWT. Only API keys are accepted if no JWT secret is set.
+// Without roles, callers must have the user role.
+func NewAuth(roles ...string) echo.MiddlewareFunc {
+	if authJWTSecret == "" {
+		return NewAPIKeyAuth(roles...)
+	}
+	c := DefaultMultiAuthConfig
+	c.APIKey.CacheRepository = apiKeyCache
+	c.APIKey.CacheTTL = apiKeyCacheTTL
+	c.JWT.Secret = authJWTSecret
+	c.JWT.RejectImpersonated = authJWTRejectImpersonated
+	if len(roles) > 0 {
+		c.RequiredRoles = roles
+	}
+	return NewAPIKeyOrJWTAuthWithConfig(c)
+}
+
+// CallerID returns the ID of the authenticated caller, or "" if the request is not authenticated
+func CallerID(c echo.Context) string {
+	if p, ok := c.Get("principal").(*Principal); ok && p != nil {
+		return p.ID
+	}
+	if u, ok := c.Get("user").(*model.User); ok && u != nil {
+		return u.ID.Hex()
+	}
+	return ""
+}
+
+// NewAPIKeyOrJWTAuth returns a middleware that accepts either an API key or a JWT signed with secret
+func NewAPIKeyOrJWTAuth(secret string, roles ...string) echo.MiddlewareFunc {
+	c := DefaultMultiAuthConfig
+	c.JWT.Secret = secret
+	if len(roles) > 0 {
+		c.RequiredRoles = roles
+	}
+	return NewAPIKeyOrJWTAuthWithConfig(c)
+}
+
+// NewAPIKeyOrJWTAuthWithConfig returns a combined API key or JWT middleware with config.
+// The API key is tried first; if it is missing or invalid the JWT is tried.
+// Requests are rejected with 401 only if both methods fail.
+// It panics with a ConfigError if no API key validator is available.
+func NewAPIKeyOrJWTAuthWithConfig(config MultiAuthConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultMultiAuthConfig.Skipper
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultMultiAuthConfig.ContextKey
+	}
+	if config.APIKey.KeyLookup == "" {
+		config.APIKey.KeyLookup = DefaultAPIKeyAuthConfig.KeyLookup
+	}
+	if config.APIKey.Validator == nil {
+		config.APIKey.Validator = GetAPIKeyValidator()
+	}
+	if config.APIKey.Validator == nil {
+		panic(&ConfigError{Middleware: "api key or jwt auth", Err: ErrAPIKeyValidatorNotSet})
+	}
+	if config.APIKey.CacheRepository == nil {
+		config.APIKey.CacheRepository = apiKeyCache
+		config.APIKey.CacheTTL = apiKeyCacheTTL
+	}
+	if config.APIKey.CacheTTL <= 0 {
+		config.APIKey.CacheTTL = DefaultAPIKeyCacheTTL
+	}
+	if config.JWT.TokenLookup == "" {
+		config.JWT.TokenLookup = DefaultJWTConfig.TokenLookup
+	}
+	config.JWT = applyJWTDefaults(config.JWT, "api key or jwt auth")
+
+	parts := splitKeyLookup(config.APIKey.KeyLookup)
+	extractKey := extractKeyFromHeader
+	switch parts[0] {
+	case "header":
+		extractKey = extractKeyFromHeader
+	case "query":
+		extractKey = extractKeyFromQuery
+	default:
+		panic("echo: invalid API key lookup")
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			principal := authenticateAPIKey(c, config, parts[1], extractKey)
+			if principal == nil {
+				var err error
+				principal, err = authenticateJWT(c, config)
+				// A valid token that is refused, such as a rejected impersonation token, is not retried as missing
+				var he *echo.HTTPError
+				if errors.As(err, &he) && he.Code == http.StatusForbidden {
+					return err
+				}
+			}
+			if principal == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing credentials")
+			}
+
+			if len(config.RequiredRoles) > 0 && !principal.HasAnyRole(config.RequiredRoles...) {
+				return echo.ErrForbidden
+			}
+
+			c.Set(config.ContextKey, principal)
+			if principal.User != nil {
+				c.Set(DefaultAPIKeyAuthConfig.ContextKey, principal.User)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// authenticateAPIKey returns a principal for a valid API key, or nil
+func authenticateAPIKey(c echo.Context, config MultiAuthConfig, name string, extract func(echo.Context, string) (string, error)) *Principal {
+	key, err := extract(c, name)
+	if err != nil {
+		return nil
+	}
+
+	user, err := lookupAPIKeyUser(c.Request().Context(), config.APIKey, key)
+	if err != nil || user == nil {
+		return nil
+	}
+
+	return &Principal{
+		ID:     user.ID.Hex(),
+		Email:  user.Email,
+		Roles:  user.Roles,
+		Method: AuthMethodAPIKey,
+		User:   user,
+	}
+}
+
+// authenticateJWT returns a principal for a valid JWT, or the error it was refused with
+func authenticateJWT(c echo.Context, config MultiAuthConfig) (*Principal, error) {
+	token, err := extractToken(c, config.JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := validateToken(token, config.JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := &Principal{
+		Roles:  claimRoles(claims),
+		Method: AuthMethodJWT,
+		Claims: claims,
+	}
+	if sub, ok := claims["sub"]; ok {
+		principal.ID = fmt.Sprint(sub)
+	} else if id, ok := claims["id"]; ok {
+		principal.ID = fmt.Sprint(id)
+	}
+	if email, ok := claims["email"].(string); ok {
+		principal.Email = email
+	}
+
+	return principal, nil
+}
+
+// claimRoles reads the roles claim, which decodes as []interface{} from JSON
+func claimRoles(claims map[string]interface{}) []string {
+	switch roles := claims["roles"].(type) {
+	case []string:
+		return roles
+	case []interface{}:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if r, ok := role.(string); ok {
+				result = append(result, r)
+			}
+		}
+		return result
+	case string:
+		return []string{roles}
+	}
+	return nil
+}
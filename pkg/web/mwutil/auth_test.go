@@ -0,0 +1,198 @@
+package mwutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewAuthAcceptsTokens(t *testing.T) {
+	user := &model.User{ApiKey: "user-key", Roles: []string{model.RoleUser}}
+	user.ID = primitive.NewObjectID()
+	SetAPIKeyValidator(&countingValidator{user: user})
+	defer SetAPIKeyValidator(nil)
+	defer SetAuthJWT("", false)
+
+	token := signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+		"sub":   "user-1",
+		"roles": []string{model.RoleUser},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	impersonated := signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+		"sub":             "user-1",
+		"roles":           []string{model.RoleUser},
+		ClaimImpersonated: true,
+		"exp":             time.Now().Add(time.Hour).Unix(),
+	})
+
+	tests := []struct {
+		name   string
+		secret string
+		reject bool
+		apiKey string
+		token  string
+		want   int
+		caller string
+	}{
+		{"api key without jwt config", "", false, "user-key", "", http.StatusOK, user.ID.Hex()},
+		{"token without jwt config", "", false, "", token, http.StatusUnauthorized, ""},
+		{"token", testJWTSecret, false, "", token, http.StatusOK, "user-1"},
+		{"api key with jwt config", testJWTSecret, false, "user-key", "", http.StatusOK, user.ID.Hex()},
+		{"impersonated token", testJWTSecret, false, "", impersonated, http.StatusOK, "user-1"},
+		{"rejected impersonated token", testJWTSecret, true, "", impersonated, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetAuthJWT(tt.secret, tt.reject)
+			e := echo.New()
+			var caller string
+			e.GET("/", func(c echo.Context) error {
+				caller = CallerID(c)
+				return c.NoContent(http.StatusOK)
+			}, NewAuth())
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if caller != tt.caller {
+				t.Errorf("expected caller %q, got %q", tt.caller, caller)
+			}
+		})
+	}
+}
+
+func TestNewAPIKeyOrJWTAuth(t *testing.T) {
+	user := &model.User{ApiKey: "user-key", Roles: []string{model.RoleUser}}
+	user.ID = primitive.NewObjectID()
+	SetAPIKeyValidator(&countingValidator{user: user})
+	defer SetAPIKeyValidator(nil)
+
+	signToken := func(roles ...string) string {
+		return signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+			"sub":   "user-1",
+			"roles": roles,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+	}
+	userToken := signToken(model.RoleUser)
+	adminToken := signToken(model.RoleAdmin)
+
+	tests := []struct {
+		name   string
+		roles  []string
+		apiKey string
+		token  string
+		want   int
+		method string
+	}{
+		{"api key only", nil, "user-key", "", http.StatusOK, AuthMethodAPIKey},
+		{"jwt only", nil, "", userToken, http.StatusOK, AuthMethodJWT},
+		{"both prefers api key", nil, "user-key", userToken, http.StatusOK, AuthMethodAPIKey},
+		{"invalid api key falls back to jwt", nil, "wrong-key", userToken, http.StatusOK, AuthMethodJWT},
+		{"neither", nil, "", "", http.StatusUnauthorized, ""},
+		{"invalid api key without jwt", nil, "wrong-key", "", http.StatusUnauthorized, ""},
+		{"jwt signed with another secret", nil, "", signTestToken(t, jwt.SigningMethodHS256, "another-secret", jwt.MapClaims{"sub": "user-1", "roles": []string{model.RoleUser}, "exp": time.Now().Add(time.Hour).Unix()}), http.StatusUnauthorized, ""},
+		{"api key missing role", []string{model.RoleAdmin}, "user-key", "", http.StatusForbidden, ""},
+		{"jwt missing role", []string{model.RoleAdmin}, "", userToken, http.StatusForbidden, ""},
+		{"jwt with role", []string{model.RoleAdmin}, "", adminToken, http.StatusOK, AuthMethodJWT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			var method string
+			e.GET("/", func(c echo.Context) error {
+				method = c.Get("principal").(*Principal).Method
+				return c.NoContent(http.StatusOK)
+			}, NewAPIKeyOrJWTAuth(testJWTSecret, tt.roles...))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if method != tt.method {
+				t.Errorf("expected method %q, got %q", tt.method, method)
+			}
+		})
+	}
+}
+
+func TestNewAPIKeyOrJWTAuthWithConfig(t *testing.T) {
+	user := &model.User{ApiKey: "user-key", Roles: []string{model.RoleUser}}
+	user.ID = primitive.NewObjectID()
+	token := signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	jwtConfig := DefaultJWTConfig
+	jwtConfig.Secret = testJWTSecret
+
+	e := echo.New()
+	var principal *Principal
+	e.GET("/", func(c echo.Context) error {
+		principal, _ = c.Get("caller").(*Principal)
+		return c.NoContent(http.StatusOK)
+	}, NewAPIKeyOrJWTAuthWithConfig(MultiAuthConfig{
+		APIKey:     APIKeyAuthConfig{KeyLookup: "query:api_key", Validator: &countingValidator{user: user}},
+		JWT:        jwtConfig,
+		ContextKey: "caller",
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		want   int
+		id     string
+	}{
+		{"api key from query", "/?api_key=user-key", "", http.StatusOK, user.ID.Hex()},
+		{"api key header is ignored", "/", "", http.StatusUnauthorized, ""},
+		// Without RequiredRoles a token carrying no roles is accepted
+		{"jwt without roles", "/", token, http.StatusOK, "user-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = nil
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("X-API-Key", "user-key")
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if tt.id != "" && (principal == nil || principal.ID != tt.id) {
+				t.Errorf("expected principal %q under the configured context key, got %+v", tt.id, principal)
+			}
+		})
+	}
+}
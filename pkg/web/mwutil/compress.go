@@ -0,0 +1,50 @@
+package mwutil
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// CompressConfig defines the config for Compress middleware.
+type CompressConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Level is the gzip compression level (-1 to 9).
+	// Default is -1 (gzip.DefaultCompression).
+	Level int
+
+	// MinLength is the minimum response size in bytes before compression is applied.
+	// Default is 0 (always compress).
+	MinLength int
+}
+
+// DefaultCompressConfig is the default Compress middleware config.
+var DefaultCompressConfig = CompressConfig{
+	Skipper:   middleware.DefaultSkipper,
+	Level:     -1,
+	MinLength: 0,
+}
+
+// Compress returns a middleware that gzip-compresses responses for clients
+// that advertise support via Accept-Encoding.
+func Compress() echo.MiddlewareFunc {
+	return CompressWithConfig(DefaultCompressConfig)
+}
+
+// CompressWithConfig returns a Compress middleware with config.
+func CompressWithConfig(config CompressConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultCompressConfig.Skipper
+	}
+	if config.Level == 0 {
+		config.Level = DefaultCompressConfig.Level
+	}
+
+	// Use Echo's built-in gzip middleware with our custom config
+	return middleware.GzipWithConfig(middleware.GzipConfig{
+		Skipper:   config.Skipper,
+		Level:     config.Level,
+		MinLength: config.MinLength,
+	})
+}
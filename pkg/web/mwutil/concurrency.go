@@ -0,0 +1,234 @@
+package mwutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// concurrencyPollInterval is how often a queued request retries a store that cannot wait for a slot
+const concurrencyPollInterval = 10 * time.Millisecond
+
+// ConcurrencyStore counts in-flight requests per identifier
+type ConcurrencyStore interface {
+	// Acquire takes a slot for key and reports false when limit slots are already taken
+	Acquire(ctx context.Context, key string, limit int) (bool, error)
+	// Release frees a slot taken by a successful Acquire
+	Release(ctx context.Context, key string) error
+}
+
+// WaitingConcurrencyStore is a ConcurrencyStore that can block until a slot frees up.
+// Queued requests poll stores that do not implement it.
+type WaitingConcurrencyStore interface {
+	ConcurrencyStore
+	// AcquireWait takes a slot for key, waiting while limit slots are taken, and reports
+	// false if ctx is done first
+	AcquireWait(ctx context.Context, key string, limit int) (bool, error)
+}
+
+// ConcurrencyLimitConfig defines the config for ConcurrencyLimit middleware.
+type ConcurrencyLimitConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Limit is the maximum number of in-flight requests per identifier.
+	// Default is 10.
+	Limit int
+
+	// QueueTimeout is how long a request waits for a slot before it is rejected.
+	// Default is 0, rejecting requests as soon as every slot is taken.
+	QueueTimeout time.Duration
+
+	// StatusCode is sent when a request is rejected.
+	// Default is 503 Service Unavailable; use 429 Too Many Requests to blame the client.
+	StatusCode int
+
+	// Store tracks in-flight requests. Use a shared store such as Redis to
+	// enforce the limit across instances.
+	// Default is an in-memory store local to the middleware.
+	Store ConcurrencyStore
+
+	// IdentifierExtractor returns the identifier requests are counted under.
+	// Default is the client IP.
+	IdentifierExtractor middleware.Extractor
+}
+
+// DefaultConcurrencyLimitConfig is the default ConcurrencyLimit middleware config.
+var DefaultConcurrencyLimitConfig = ConcurrencyLimitConfig{
+	Skipper:    middleware.DefaultSkipper,
+	Limit:      10,
+	StatusCode: http.StatusServiceUnavailable,
+	IdentifierExtractor: func(c echo.Context) (string, error) {
+		return c.RealIP(), nil
+	},
+}
+
+// ConcurrencyLimit returns a middleware that allows at most limit in-flight
+// requests per client IP and rejects the rest with 503 Service Unavailable.
+func ConcurrencyLimit(limit int) echo.MiddlewareFunc {
+	config := DefaultConcurrencyLimitConfig
+	config.Limit = limit
+	return ConcurrencyLimitWithConfig(config)
+}
+
+// ConcurrencyLimitWithConfig returns a ConcurrencyLimit middleware with config.
+func ConcurrencyLimitWithConfig(config ConcurrencyLimitConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultConcurrencyLimitConfig.Skipper
+	}
+	if config.Limit <= 0 {
+		config.Limit = DefaultConcurrencyLimitConfig.Limit
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = DefaultConcurrencyLimitConfig.StatusCode
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryConcurrencyStore()
+	}
+	if config.IdentifierExtractor == nil {
+		config.IdentifierExtractor = DefaultConcurrencyLimitConfig.IdentifierExtractor
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			identifier, err := config.IdentifierExtractor(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, "error while extracting identifier")
+			}
+			key := fmt.Sprintf("concurrency:%s", identifier)
+
+			ctx := c.Request().Context()
+			ok, err := acquireSlot(ctx, config, key)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "internal concurrency limit error")
+			}
+			if !ok {
+				return echo.NewHTTPError(config.StatusCode, "too many concurrent requests")
+			}
+			// Release even when the client has gone away and the request context is canceled
+			defer config.Store.Release(context.WithoutCancel(ctx), key)
+
+			return next(c)
+		}
+	}
+}
+
+// acquireSlot takes a slot for key, waiting up to config.QueueTimeout for one to free up
+func acquireSlot(ctx context.Context, config ConcurrencyLimitConfig, key string) (bool, error) {
+	if config.QueueTimeout <= 0 {
+		return config.Store.Acquire(ctx, key, config.Limit)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.QueueTimeout)
+	defer cancel()
+	if store, ok := config.Store.(WaitingConcurrencyStore); ok {
+		return store.AcquireWait(ctx, key, config.Limit)
+	}
+
+	ticker := time.NewTicker(concurrencyPollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := config.Store.Acquire(ctx, key, config.Limit)
+		if ok || err != nil {
+			return ok, err
+		}
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// memoryConcurrencyStore is a WaitingConcurrencyStore local to the process,
+// holding a buffered channel semaphore per key
+type memoryConcurrencyStore struct {
+	mu    sync.Mutex
+	slots map[string]*semaphore
+}
+
+// semaphore has one buffered slot per allowed request; refs counts holders and
+// waiters so the key can be dropped once nobody uses it
+type semaphore struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewMemoryConcurrencyStore returns a ConcurrencyStore that counts requests in memory.
+// Each key keeps the limit it was first acquired with until no requests are in flight.
+func NewMemoryConcurrencyStore() WaitingConcurrencyStore {
+	return &memoryConcurrencyStore{slots: make(map[string]*semaphore)}
+}
+
+// Acquire takes a slot for key unless limit slots are already taken
+func (s *memoryConcurrencyStore) Acquire(ctx context.Context, key string, limit int) (bool, error) {
+	sem := s.ref(key, limit)
+	select {
+	case sem.ch <- struct{}{}:
+		return true, nil
+	default:
+		s.unref(key)
+		return false, nil
+	}
+}
+
+// AcquireWait takes a slot for key, waiting until one is free or ctx is done
+func (s *memoryConcurrencyStore) AcquireWait(ctx context.Context, key string, limit int) (bool, error) {
+	sem := s.ref(key, limit)
+	select {
+	case sem.ch <- struct{}{}:
+		return true, nil
+	case <-ctx.Done():
+		s.unref(key)
+		return false, nil
+	}
+}
+
+// Release frees a slot, dropping the key once no requests are in flight
+func (s *memoryConcurrencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	sem, ok := s.slots[key]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	<-sem.ch
+	s.unref(key)
+	return nil
+}
+
+// ref returns the semaphore for key, creating it with limit slots, and counts the caller as a user
+func (s *memoryConcurrencyStore) ref(key string, limit int) *semaphore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sem, ok := s.slots[key]
+	if !ok {
+		sem = &semaphore{ch: make(chan struct{}, limit)}
+		s.slots[key] = sem
+	}
+	sem.refs++
+	return sem
+}
+
+// unref stops counting a caller as a user of key's semaphore
+func (s *memoryConcurrencyStore) unref(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sem, ok := s.slots[key]
+	if !ok {
+		return
+	}
+	if sem.refs--; sem.refs <= 0 {
+		delete(s.slots, key)
+	}
+}
@@ -0,0 +1,245 @@
+package mwutil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestConcurrencyLimitRejectsExcessInflightRequests(t *testing.T) {
+	const limit = 3
+
+	e := echo.New()
+	entered := make(chan struct{}, limit+1)
+	release := make(chan struct{})
+	h := ConcurrencyLimit(limit)(func(c echo.Context) error {
+		entered <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+
+	serve := func() error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		return h(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	// Fill every slot with a slow request
+	errs := make(chan error, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- serve()
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		<-entered
+	}
+
+	// The next concurrent request is rejected
+	var he *echo.HTTPError
+	if err := serve(); !errors.As(err, &he) || he.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for request %d, got %v", limit+1, err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected in-limit requests to succeed, got %v", err)
+		}
+	}
+
+	// Completed requests release their slots
+	if err := serve(); err != nil {
+		t.Errorf("expected a request to be allowed after slots were released, got %v", err)
+	}
+	if len(entered) != 1 {
+		t.Errorf("expected the rejected request never to reach the handler")
+	}
+}
+
+func TestConcurrencyLimitIsPerIdentifier(t *testing.T) {
+	e := echo.New()
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := ConcurrencyLimit(1)(func(c echo.Context) error {
+		if c.RealIP() == "203.0.113.7" {
+			entered <- struct{}{}
+			<-release
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	serve := func(ip string) error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		return h(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	done := make(chan error)
+	go func() { done <- serve("203.0.113.7") }()
+	<-entered
+
+	if err := serve("198.51.100.1"); err != nil {
+		t.Errorf("expected another client to be unaffected, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConcurrencyLimitRejectsBurstAboveLimit(t *testing.T) {
+	const limit, total = 4, 20
+
+	e := echo.New()
+	release := make(chan struct{})
+	var inflight, peak atomic.Int32
+	h := ConcurrencyLimit(limit)(func(c echo.Context) error {
+		n := inflight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		inflight.Add(-1)
+		return c.NoContent(http.StatusOK)
+	})
+
+	var allowed, rejected atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:1234"
+			var he *echo.HTTPError
+			switch err := h(e.NewContext(req, httptest.NewRecorder())); {
+			case err == nil:
+				allowed.Add(1)
+			case errors.As(err, &he) && he.Code == http.StatusServiceUnavailable:
+				rejected.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	// Hold the admitted requests until every other one has been turned away
+	deadline := time.Now().Add(5 * time.Second)
+	for inflight.Load()+rejected.Load() < total && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if allowed.Load() != limit || rejected.Load() != total-limit {
+		t.Errorf("expected %d allowed and %d rejected, got %d and %d", limit, total-limit, allowed.Load(), rejected.Load())
+	}
+	if peak.Load() > limit {
+		t.Errorf("expected at most %d requests in flight, saw %d", limit, peak.Load())
+	}
+}
+
+// pollingStore hides AcquireWait so queued requests fall back to polling Acquire
+type pollingStore struct {
+	ConcurrencyStore
+}
+
+func TestConcurrencyLimitQueuesUntilSlotFrees(t *testing.T) {
+	stores := map[string]ConcurrencyStore{
+		"waiting": NewMemoryConcurrencyStore(),
+		"polling": pollingStore{NewMemoryConcurrencyStore()},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			entered := make(chan struct{}, 2)
+			release := make(chan struct{})
+			h := ConcurrencyLimitWithConfig(ConcurrencyLimitConfig{
+				Limit:        1,
+				QueueTimeout: 5 * time.Second,
+				Store:        store,
+			})(func(c echo.Context) error {
+				entered <- struct{}{}
+				<-release
+				return c.NoContent(http.StatusOK)
+			})
+			serve := func() error {
+				return h(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()))
+			}
+
+			first := make(chan error)
+			go func() { first <- serve() }()
+			<-entered
+
+			queued := make(chan error)
+			go func() { queued <- serve() }()
+			select {
+			case <-entered:
+				t.Fatal("expected the second request to wait for the slot")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			// Freeing the slot lets the queued request through
+			release <- struct{}{}
+			if err := <-first; err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			<-entered
+			close(release)
+			if err := <-queued; err != nil {
+				t.Errorf("expected the queued request to be served, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	e := echo.New()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := ConcurrencyLimitWithConfig(ConcurrencyLimitConfig{
+		Limit:        1,
+		QueueTimeout: 20 * time.Millisecond,
+	})(func(c echo.Context) error {
+		close(entered)
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+	serve := func() error {
+		return h(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()))
+	}
+
+	done := make(chan error)
+	go func() { done <- serve() }()
+	<-entered
+
+	start := time.Now()
+	var he *echo.HTTPError
+	if err := serve(); !errors.As(err, &he) || he.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once the queue timeout passed, got %v", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("expected the request to wait for the queue timeout, waited %v", waited)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -8,4 +8,66 @@ var (
 
 	// ErrMissingAPIKey is returned when no API key is provided
 	ErrMissingAPIKey = errors.New("missing api key")
+
+	// ErrRateLimitRepoNotSet is reported when a rate limiter is built before ratelimit.SetRateLimitRepo
+	ErrRateLimitRepoNotSet = errors.New("rate limit repository is not set")
+
+	// ErrAPIKeyValidatorNotSet is reported when API key auth is built without a validator
+	ErrAPIKeyValidatorNotSet = errors.New("API key validator is not set")
+
+	// ErrJWTSecretNotSet is reported when JWT middleware is built without a secret
+	ErrJWTSecretNotSet = errors.New("jwt secret is not set")
+
+	// ErrUnsupportedSigningMethod is reported when the JWT signing method is not an HMAC method
+	ErrUnsupportedSigningMethod = errors.New("unsupported jwt signing method")
+
+	// ErrFlagSourceNotSet is reported when the feature flag middleware is built without a source
+	ErrFlagSourceNotSet = errors.New("feature flag source is not set")
+
+	// ErrLoadSignalNotSet is reported when the adaptive rate limiter is built without a load signal
+	ErrLoadSignalNotSet = errors.New("load signal is not set")
+
+	// ErrStrictJSONTargetNotSet is reported when the strict JSON middleware is built without a target type
+	ErrStrictJSONTargetNotSet = errors.New("strict json target is not set")
+
+	// ErrMongoClientNotSet is reported when the MongoDB session middleware is built without a client
+	ErrMongoClientNotSet = errors.New("mongo client is not set")
+
+	// ErrInvalidTrustedProxy is reported when HTTPS enforcement is built with a trusted proxy that is not an IP or CIDR
+	ErrInvalidTrustedProxy = errors.New("invalid trusted proxy")
 )
+
+// ConfigError is the panic value raised by middleware constructors when a
+// required dependency is missing. Use RecoverConfigError to turn it back into an error.
+type ConfigError struct {
+	// Middleware is the name of the middleware that failed to build
+	Middleware string
+	// Err is the underlying cause
+	Err error
+}
+
+// Error implements the error interface
+func (e *ConfigError) Error() string {
+	return "echo: " + e.Middleware + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// RecoverConfigError recovers a ConfigError panic into *err. Other panics are re-raised.
+// It must be called directly with defer:
+//
+//	defer mwutil.RecoverConfigError(&err)
+func RecoverConfigError(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if cfgErr, ok := r.(*ConfigError); ok {
+		*err = cfgErr
+		return
+	}
+	panic(r)
+}
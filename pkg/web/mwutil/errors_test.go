@@ -0,0 +1,78 @@
+package mwutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-mongo/pkg/ratelimit"
+)
+
+// build calls fn and returns the ConfigError it raised, if any
+func build(fn func()) (err error) {
+	defer RecoverConfigError(&err)
+	fn()
+	return nil
+}
+
+func TestRateLimiterWithoutRepoReturnsError(t *testing.T) {
+	ratelimit.SetRateLimitRepo(nil)
+
+	constructors := map[string]func(){
+		"NewRateLimiter":      func() { NewRateLimiter(RateLimitConfig{Strategy: FixedWindow, Limit: 1, Window: time.Minute}) },
+		"NewFixedRateLimiter": func() { NewFixedRateLimiter(1, time.Minute) },
+		"NewTokenBucketLimiterPerPath": func() {
+			NewTokenBucketLimiterPerPath(1, 1, time.Minute)
+		},
+	}
+
+	for name, fn := range constructors {
+		err := build(fn)
+		if !errors.Is(err, ErrRateLimitRepoNotSet) {
+			t.Errorf("%s: expected ErrRateLimitRepoNotSet, got %v", name, err)
+		}
+		var cfgErr *ConfigError
+		if !errors.As(err, &cfgErr) {
+			t.Errorf("%s: expected *ConfigError, got %T", name, err)
+		}
+	}
+}
+
+func TestAPIKeyAuthWithoutValidatorReturnsError(t *testing.T) {
+	SetAPIKeyValidator(nil)
+
+	if err := build(func() { NewAPIKeyAuth() }); !errors.Is(err, ErrAPIKeyValidatorNotSet) {
+		t.Errorf("NewAPIKeyAuth: expected ErrAPIKeyValidatorNotSet, got %v", err)
+	}
+	if err := build(func() { NewAPIKeyAuthWithConfig(APIKeyAuthConfig{}) }); !errors.Is(err, ErrAPIKeyValidatorNotSet) {
+		t.Errorf("NewAPIKeyAuthWithConfig: expected ErrAPIKeyValidatorNotSet, got %v", err)
+	}
+	if err := build(func() { NewAPIKeyOrJWTAuth("secret") }); !errors.Is(err, ErrAPIKeyValidatorNotSet) {
+		t.Errorf("NewAPIKeyOrJWTAuth: expected ErrAPIKeyValidatorNotSet, got %v", err)
+	}
+}
+
+func TestRequireHTTPSWithInvalidProxyReturnsError(t *testing.T) {
+	for _, proxy := range []string{"not-an-ip", "10.0.0.0/33"} {
+		err := build(func() { RequireHTTPS(proxy) })
+		if !errors.Is(err, ErrInvalidTrustedProxy) {
+			t.Errorf("%q: expected ErrInvalidTrustedProxy, got %v", proxy, err)
+		}
+		var cfgErr *ConfigError
+		if !errors.As(err, &cfgErr) {
+			t.Errorf("%q: expected *ConfigError, got %T", proxy, err)
+		}
+	}
+	if err := build(func() { RequireHTTPS("10.0.0.1", "10.0.0.0/8", "::1") }); err != nil {
+		t.Errorf("expected valid proxies to be accepted, got %v", err)
+	}
+}
+
+func TestRecoverConfigErrorRepanicsOtherValues(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("expected original panic to propagate, got %v", r)
+		}
+	}()
+	_ = build(func() { panic("boom") })
+}
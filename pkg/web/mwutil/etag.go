@@ -0,0 +1,145 @@
+package mwutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// ETagConfig defines the config for ETag middleware.
+type ETagConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Weak forces weak ETags (W/"...") for every response.
+	// When false, weak ETags are only emitted for encoded (e.g. gzip) bodies.
+	Weak bool
+}
+
+// DefaultETagConfig is the default ETag middleware config.
+var DefaultETagConfig = ETagConfig{
+	Skipper: middleware.DefaultSkipper,
+	Weak:    false,
+}
+
+// ETag returns a middleware that computes an ETag for GET and HEAD responses
+// and answers matching If-None-Match requests with 304 Not Modified.
+//
+// Register ETag before Compress so the tag is computed over the bytes that are
+// actually sent. Encoded bodies always get a weak ETag and every response varies
+// by Accept-Encoding, so caches never serve a gzip body to an identity client.
+func ETag() echo.MiddlewareFunc {
+	return ETagWithConfig(DefaultETagConfig)
+}
+
+// ETagWithConfig returns an ETag middleware with config.
+func ETagWithConfig(config ETagConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultETagConfig.Skipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if config.Skipper(c) || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+				return next(c)
+			}
+
+			res := c.Response()
+			original := res.Writer
+			writer := &etagResponseWriter{ResponseWriter: original, body: new(bytes.Buffer), code: http.StatusOK}
+			res.Writer = writer
+
+			err := next(c)
+			res.Writer = original
+			if err != nil {
+				// Send anything already written; otherwise the error handler writes the response
+				if res.Committed {
+					_ = writer.flush()
+				}
+				return err
+			}
+
+			// Only successful, non-empty responses are tagged
+			if !res.Committed {
+				return nil
+			}
+			if writer.code != http.StatusOK {
+				return writer.flush()
+			}
+
+			header := res.Header()
+			sum := sha256.Sum256(writer.body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			if config.Weak || header.Get(echo.HeaderContentEncoding) != "" {
+				etag = "W/" + etag
+			}
+
+			header.Set("ETag", etag)
+			if !strings.Contains(header.Get(echo.HeaderVary), echo.HeaderAcceptEncoding) {
+				header.Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			}
+
+			if etagMatches(req.Header.Get("If-None-Match"), etag) {
+				header.Del(echo.HeaderContentLength)
+				header.Del(echo.HeaderContentType)
+				original.WriteHeader(http.StatusNotModified)
+				return nil
+			}
+
+			return writer.flush()
+		}
+	}
+}
+
+// etagResponseWriter buffers the response so the ETag can be computed before
+// any bytes reach the client.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	body *bytes.Buffer
+	code int
+}
+
+// WriteHeader records the status code without sending it
+func (w *etagResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// Write appends to the buffered body
+func (w *etagResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// Flush is a no-op while buffering; the body is sent once the ETag is known
+func (w *etagResponseWriter) Flush() {}
+
+// flush sends the buffered status and body to the underlying writer
+func (w *etagResponseWriter) flush() error {
+	w.ResponseWriter.WriteHeader(w.code)
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header matches the given ETag
+// using the weak comparison required by RFC 9110.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package mwutil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-echo-mongo/pkg/web/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newETagTestServer() *echo.Echo {
+	e := echo.New()
+	e.Use(ETag())
+	e.Use(Compress())
+	e.GET("/resource", func(c echo.Context) error {
+		return c.String(http.StatusOK, strings.Repeat("payload ", 64))
+	})
+	return e
+}
+
+func doETagRequest(e *echo.Echo, acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+	if acceptEncoding != "" {
+		req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestETagCompressedAndUncompressed(t *testing.T) {
+	e := newETagTestServer()
+
+	plain := doETagRequest(e, "", "")
+	gzipped := doETagRequest(e, "gzip", "")
+
+	plainTag := plain.Header().Get("ETag")
+	gzipTag := gzipped.Header().Get("ETag")
+
+	if plainTag == "" || strings.HasPrefix(plainTag, "W/") {
+		t.Fatalf("expected strong ETag for identity response, got %q", plainTag)
+	}
+	if gzipped.Header().Get(echo.HeaderContentEncoding) != "gzip" {
+		t.Fatalf("expected gzip response, got encoding %q", gzipped.Header().Get(echo.HeaderContentEncoding))
+	}
+	if !strings.HasPrefix(gzipTag, "W/") {
+		t.Fatalf("expected weak ETag for compressed response, got %q", gzipTag)
+	}
+	if strings.TrimPrefix(gzipTag, "W/") == plainTag {
+		t.Fatalf("compressed and identity responses must not share an ETag: %q", plainTag)
+	}
+
+	for _, rec := range []*httptest.ResponseRecorder{plain, gzipped} {
+		if !strings.Contains(rec.Header().Get(echo.HeaderVary), echo.HeaderAcceptEncoding) {
+			t.Fatalf("expected Vary: Accept-Encoding, got %q", rec.Header().Get(echo.HeaderVary))
+		}
+	}
+}
+
+func TestETagNotModified(t *testing.T) {
+	e := newETagTestServer()
+
+	plainTag := doETagRequest(e, "", "").Header().Get("ETag")
+	gzipTag := doETagRequest(e, "gzip", "").Header().Get("ETag")
+
+	if rec := doETagRequest(e, "", plainTag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected empty 304 for matching identity ETag, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if rec := doETagRequest(e, "gzip", gzipTag); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching compressed ETag, got %d", rec.Code)
+	}
+	if rec := doETagRequest(e, "", gzipTag); rec.Code != http.StatusOK {
+		t.Fatalf("compressed ETag must not validate identity response, got %d", rec.Code)
+	}
+}
+
+func TestETagAndCompressKeepNoContentEmpty(t *testing.T) {
+	e := echo.New()
+	e.Use(ETag())
+	e.Use(Compress())
+	e.GET("/resource", func(c echo.Context) error {
+		return response.NoContent(c)
+	})
+
+	// Go through a real connection so the bytes on the wire are checked, not just the recorder
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/resource", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	resp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	if len(body) != 0 || resp.ContentLength > 0 {
+		t.Errorf("expected an empty body, got %d bytes %q", resp.ContentLength, body)
+	}
+	for _, header := range []string{echo.HeaderContentEncoding, echo.HeaderContentType, "ETag"} {
+		if got := resp.Header.Get(header); got != "" {
+			t.Errorf("expected no %s header, got %q", header, got)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package mwutil
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Flag is a feature flag with a percentage rollout
+type Flag struct {
+	// Name is the flag name
+	Name string
+	// Percentage of users the flag is enabled for, from 0 (off) to 100 (on)
+	Percentage int
+}
+
+// ParseFlag parses a flag value: "on"/"true"/"1", "off"/"false"/"0", or a rollout like "25%"
+func ParseFlag(name, value string) (Flag, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "on", "true", "enabled", "1":
+		return Flag{Name: name, Percentage: 100}, nil
+	case "off", "false", "disabled", "0", "":
+		return Flag{Name: name, Percentage: 0}, nil
+	}
+
+	pct, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || pct < 0 || pct > 100 {
+		return Flag{}, fmt.Errorf("invalid value %q for feature flag %q", value, name)
+	}
+	return Flag{Name: name, Percentage: pct}, nil
+}
+
+// EnabledFor reports whether the flag is enabled for the given user ID.
+// Rollouts hash the flag name with the user ID, so a user always gets the same result.
+// Partial rollouts are disabled for anonymous requests.
+func (f Flag) EnabledFor(userID string) bool {
+	if f.Percentage >= 100 {
+		return true
+	}
+	if f.Percentage <= 0 || userID == "" {
+		return false
+	}
+	return rolloutBucket(f.Name, userID) < uint32(f.Percentage)
+}
+
+// rolloutBucket maps a flag and user to a stable bucket in [0, 100)
+func rolloutBucket(flag, userID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(flag))
+	h.Write([]byte{':'})
+	h.Write([]byte(userID))
+	return h.Sum32() % 100
+}
+
+// FlagSource loads the current set of feature flags
+type FlagSource interface {
+	Flags(ctx context.Context) (map[string]Flag, error)
+}
+
+// FlagSourceFunc adapts a function to a FlagSource
+type FlagSourceFunc func(ctx context.Context) (map[string]Flag, error)
+
+// Flags implements FlagSource
+func (f FlagSourceFunc) Flags(ctx context.Context) (map[string]Flag, error) {
+	return f(ctx)
+}
+
+// ParseFlags parses a comma-separated list of flags, e.g. "new_checkout=on,search_v2=25%"
+func ParseFlags(spec string) (map[string]Flag, error) {
+	flags := make(map[string]Flag)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			value = "on"
+		}
+		name = strings.TrimSpace(name)
+		flag, err := ParseFlag(name, value)
+		if err != nil {
+			return nil, err
+		}
+		flags[name] = flag
+	}
+	return flags, nil
+}
+
+// NewEnvFlagSource returns a FlagSource that reads flags from an environment variable
+func NewEnvFlagSource(envVar string) FlagSource {
+	return FlagSourceFunc(func(ctx context.Context) (map[string]Flag, error) {
+		return ParseFlags(os.Getenv(envVar))
+	})
+}
+
+// HashGetter reads all fields of a hash, e.g. redisrepo.Repository
+type HashGetter interface {
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+}
+
+// hashFlagSource reads flags from a hash and caches them for a TTL
+type hashFlagSource struct {
+	getter HashGetter
+	key    string
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	flags    map[string]Flag
+	loadedAt time.Time
+}
+
+// NewHashFlagSource returns a FlagSource that reads flags from a hash (field=flag name, value=flag value).
+// Flags are cached for ttl to avoid a lookup on every request.
+func NewHashFlagSource(getter HashGetter, key string, ttl time.Duration) FlagSource {
+	return &hashFlagSource{getter: getter, key: key, ttl: ttl}
+}
+
+// Flags implements FlagSource
+func (s *hashFlagSource) Flags(ctx context.Context) (map[string]Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flags != nil && time.Since(s.loadedAt) < s.ttl {
+		return s.flags, nil
+	}
+
+	values, err := s.getter.HGetAll(ctx, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make(map[string]Flag, len(values))
+	for name, value := range values {
+		flag, err := ParseFlag(name, value)
+		if err != nil {
+			return nil, err
+		}
+		flags[name] = flag
+	}
+
+	s.flags = flags
+	s.loadedAt = time.Now()
+	return flags, nil
+}
+
+// FeatureFlags holds the flags for a single request.
+// The user ID is resolved on each check, so users authenticated later in the chain are targeted.
+type FeatureFlags struct {
+	flags  map[string]Flag
+	userID func() string
+}
+
+// NewFeatureFlagSet creates the flag set for a user
+func NewFeatureFlagSet(flags map[string]Flag, userID string) *FeatureFlags {
+	return &FeatureFlags{flags: flags, userID: func() string { return userID }}
+}
+
+// Enabled reports whether the named flag is enabled for this request's user
+func (f *FeatureFlags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	flag, ok := f.flags[name]
+	return ok && flag.EnabledFor(f.userID())
+}
+
+type featureFlagsKey struct{}
+
+// FeatureFlagConfig defines the config for the feature flag middleware
+type FeatureFlagConfig struct {
+	// Skipper defines a function to skip middleware
+	Skipper func(c echo.Context) bool
+
+	// Source loads the flags
+	Source FlagSource
+
+	// UserIDFunc returns the user ID used for percentage rollouts
+	// Default reads the authenticated principal or API key user from the context
+	UserIDFunc func(c echo.Context) string
+
+	// ContextKey is the key used to store the flags in the echo.Context
+	// Default is "feature_flags"
+	ContextKey string
+}
+
+// DefaultFeatureFlagConfig is the default feature flag middleware config
+var DefaultFeatureFlagConfig = FeatureFlagConfig{
+	Skipper:    func(c echo.Context) bool { return false },
+	UserIDFunc: CallerID,
+	ContextKey: "feature_flags",
+}
+
+// NewFeatureFlags returns a middleware that loads flags from source into the request context
+func NewFeatureFlags(source FlagSource) echo.MiddlewareFunc {
+	c := DefaultFeatureFlagConfig
+	c.Source = source
+	return NewFeatureFlagsWithConfig(c)
+}
+
+// NewFeatureFlagsWithConfig returns a feature flag middleware with config.
+// If the source fails, all flags are treated as off.
+func NewFeatureFlagsWithConfig(config FeatureFlagConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultFeatureFlagConfig.Skipper
+	}
+	if config.UserIDFunc == nil {
+		config.UserIDFunc = DefaultFeatureFlagConfig.UserIDFunc
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultFeatureFlagConfig.ContextKey
+	}
+	if config.Source == nil {
+		panic(&ConfigError{Middleware: "feature flags", Err: ErrFlagSourceNotSet})
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			flags, err := config.Source.Flags(req.Context())
+			if err != nil {
+				slog.Warn("Failed to load feature flags", "error", err)
+				flags = nil
+			}
+
+			set := &FeatureFlags{flags: flags, userID: func() string { return config.UserIDFunc(c) }}
+			c.Set(config.ContextKey, set)
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), featureFlagsKey{}, set)))
+
+			return next(c)
+		}
+	}
+}
+
+// FeatureEnabled reports whether a feature flag is enabled for the current request
+func FeatureEnabled(c echo.Context, name string) bool {
+	return FeatureEnabledContext(c.Request().Context(), name)
+}
+
+// FeatureEnabledContext reports whether a feature flag is enabled, for use in services
+func FeatureEnabledContext(ctx context.Context, name string) bool {
+	if ctx == nil {
+		return false
+	}
+	flags, _ := ctx.Value(featureFlagsKey{}).(*FeatureFlags)
+	return flags.Enabled(name)
+}
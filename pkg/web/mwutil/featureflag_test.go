@@ -0,0 +1,142 @@
+package mwutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func staticFlags(spec string) FlagSource {
+	return FlagSourceFunc(func(ctx context.Context) (map[string]Flag, error) {
+		return ParseFlags(spec)
+	})
+}
+
+func TestFeatureFlagsOnOff(t *testing.T) {
+	e := echo.New()
+	mw := NewFeatureFlags(staticFlags("enabled_flag=on,disabled_flag=off"))
+
+	var enabled, disabled, unknown bool
+	h := mw(func(c echo.Context) error {
+		enabled = FeatureEnabled(c, "enabled_flag")
+		disabled = FeatureEnabled(c, "disabled_flag")
+		unknown = FeatureEnabledContext(c.Request().Context(), "unknown_flag")
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := h(e.NewContext(req, httptest.NewRecorder())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !enabled {
+		t.Error("expected enabled_flag to be on")
+	}
+	if disabled {
+		t.Error("expected disabled_flag to be off")
+	}
+	if unknown {
+		t.Error("expected unknown flags to be off")
+	}
+}
+
+func TestFeatureEnabledWithoutMiddleware(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if FeatureEnabled(c, "anything") {
+		t.Error("expected flags to be off without the middleware")
+	}
+}
+
+func TestFlagRolloutIsDeterministic(t *testing.T) {
+	flag := Flag{Name: "search_v2", Percentage: 30}
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		first := flag.EnabledFor(userID)
+		for j := 0; j < 3; j++ {
+			if flag.EnabledFor(userID) != first {
+				t.Fatalf("expected stable result for %s", userID)
+			}
+		}
+		if first {
+			enabled++
+		}
+	}
+
+	// Roughly 30% of users should be in the rollout
+	if enabled < 250 || enabled > 350 {
+		t.Errorf("expected about 300 of 1000 users enabled, got %d", enabled)
+	}
+
+	if flag.EnabledFor("") {
+		t.Error("expected partial rollouts to be off for anonymous users")
+	}
+}
+
+func TestFlagRolloutTargetsUserFromContext(t *testing.T) {
+	e := echo.New()
+	flag := Flag{Name: "beta", Percentage: 50}
+
+	// Find one user inside and one outside the rollout
+	var in, out string
+	for i := 0; in == "" || out == ""; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		if flag.EnabledFor(id) {
+			in = id
+		} else {
+			out = id
+		}
+	}
+
+	for userID, want := range map[string]bool{in: true, out: false} {
+		config := DefaultFeatureFlagConfig
+		config.Source = staticFlags("beta=50%")
+		config.UserIDFunc = func(c echo.Context) string { return c.Request().Header.Get("X-User-ID") }
+
+		var got bool
+		h := NewFeatureFlagsWithConfig(config)(func(c echo.Context) error {
+			got = FeatureEnabled(c, "beta")
+			return nil
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User-ID", userID)
+		_ = h(e.NewContext(req, httptest.NewRecorder()))
+
+		if got != want {
+			t.Errorf("user %s: expected %v, got %v", userID, want, got)
+		}
+	}
+}
+
+func TestParseFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"on", 100, false},
+		{"true", 100, false},
+		{"off", 0, false},
+		{"25%", 25, false},
+		{"75", 75, false},
+		{"150%", 0, true},
+		{"maybe", 0, true},
+	}
+
+	for _, tt := range tests {
+		flag, err := ParseFlag("f", tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error %v", tt.value, err)
+			continue
+		}
+		if !tt.wantErr && flag.Percentage != tt.want {
+			t.Errorf("%q: expected %d%%, got %d%%", tt.value, tt.want, flag.Percentage)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package mwutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// HTTPSConfig defines the config for HTTPS enforcement middleware.
+type HTTPSConfig struct {
+	// Skipper defines a function to skip middleware.
+	// Use it to exempt health check paths that load balancers probe over plain HTTP.
+	Skipper middleware.Skipper
+
+	// Redirect sends plain HTTP requests to the HTTPS URL when true,
+	// otherwise they are rejected with 400 Bad Request.
+	// Default is true.
+	Redirect bool
+
+	// RedirectCode is the status code used for redirects.
+	// Default is 301 (Moved Permanently).
+	RedirectCode int
+
+	// TrustedProxies is a list of IPs or CIDRs whose X-Forwarded-Proto header is trusted.
+	// Requests from other addresses are judged by their own scheme only.
+	TrustedProxies []string
+}
+
+// DefaultHTTPSConfig is the default HTTPS enforcement middleware config.
+var DefaultHTTPSConfig = HTTPSConfig{
+	Skipper:      middleware.DefaultSkipper,
+	Redirect:     true,
+	RedirectCode: http.StatusMovedPermanently,
+}
+
+// RequireHTTPS returns a middleware that redirects plain HTTP requests to HTTPS.
+func RequireHTTPS(trustedProxies ...string) echo.MiddlewareFunc {
+	config := DefaultHTTPSConfig
+	config.TrustedProxies = trustedProxies
+	return RequireHTTPSWithConfig(config)
+}
+
+// RequireHTTPSWithConfig returns an HTTPS enforcement middleware with config.
+// It panics with a ConfigError if a trusted proxy is not a valid IP or CIDR.
+func RequireHTTPSWithConfig(config HTTPSConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultHTTPSConfig.Skipper
+	}
+	if config.RedirectCode == 0 {
+		config.RedirectCode = DefaultHTTPSConfig.RedirectCode
+	}
+
+	trusted := make([]*net.IPNet, 0, len(config.TrustedProxies))
+	for _, proxy := range config.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(&ConfigError{Middleware: "https", Err: fmt.Errorf("%w %q: %v", ErrInvalidTrustedProxy, proxy, err)})
+		}
+		trusted = append(trusted, ipNet)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) || isHTTPS(c.Request(), trusted) {
+				return next(c)
+			}
+
+			if !config.Redirect {
+				return echo.NewHTTPError(http.StatusBadRequest, "https is required")
+			}
+
+			req := c.Request()
+			return c.Redirect(config.RedirectCode, "https://"+req.Host+req.URL.RequestURI())
+		}
+	}
+}
+
+// isHTTPS reports whether the request arrived over TLS, either directly or
+// through a trusted proxy that terminated TLS
+func isHTTPS(req *http.Request, trusted []*net.IPNet) bool {
+	if req.TLS != nil {
+		return true
+	}
+
+	proto := req.Header.Get(echo.HeaderXForwardedProto)
+	if proto == "" || !isTrustedProxy(req.RemoteAddr, trusted) {
+		return false
+	}
+
+	// Use the first hop when proxies append to the header
+	first := strings.TrimSpace(strings.Split(proto, ",")[0])
+	return strings.EqualFold(first, "https")
+}
+
+// isTrustedProxy checks whether the remote address belongs to one of the trusted networks
+func isTrustedProxy(remoteAddr string, trusted []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package mwutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newHTTPSTestServer(config HTTPSConfig) *echo.Echo {
+	e := echo.New()
+	e.Use(RequireHTTPSWithConfig(config))
+	e.GET("/health", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/resource", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	return e
+}
+
+func TestRequireHTTPSRedirect(t *testing.T) {
+	e := newHTTPSTestServer(HTTPSConfig{Redirect: true, TrustedProxies: []string{"10.0.0.0/8"}})
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/resource?page=2", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected redirect, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get(echo.HeaderLocation); loc != "https://api.example.com/resource?page=2" {
+		t.Fatalf("unexpected redirect location %q", loc)
+	}
+
+	// X-Forwarded-Proto is honored from a trusted proxy
+	req = httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set(echo.HeaderXForwardedProto, "https")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected trusted forwarded https to pass, got %d", rec.Code)
+	}
+
+	// X-Forwarded-Proto is ignored from an untrusted client
+	req = httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.RemoteAddr = "203.0.113.7:4567"
+	req.Header.Set(echo.HeaderXForwardedProto, "https")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected untrusted forwarded header to be ignored, got %d", rec.Code)
+	}
+}
+
+func TestRequireHTTPSRejectAndSkip(t *testing.T) {
+	e := newHTTPSTestServer(HTTPSConfig{
+		Redirect: false,
+		Skipper:  func(c echo.Context) bool { return c.Path() == "/health" },
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resource", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for plain http, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected skipped health check to pass, got %d", rec.Code)
+	}
+}
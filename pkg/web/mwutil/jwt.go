@@ -1,12 +1,22 @@
 package mwutil
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// ClaimImpersonated is set to true on tokens issued for an admin acting as another user
+	ClaimImpersonated = "impersonated"
+	// ClaimImpersonator holds the ID of the admin an impersonated token was issued to
+	ClaimImpersonator = "impersonator"
+)
+
 // JWTConfig defines the config for JWT middleware.
 type JWTConfig struct {
 	// Skipper defines a function to skip middleware.
@@ -15,6 +25,11 @@ type JWTConfig struct {
 	// Secret is the key used for validating the JWT token.
 	Secret string
 
+	// SigningMethod is the HMAC algorithm tokens must be signed with.
+	// Supported values are "HS256", "HS384" and "HS512".
+	// Default is "HS256"
+	SigningMethod string
+
 	// TokenLookup is a string in the form of "<source>:<name>" that is used
 	// to extract token from the request.
 	// Default is "header:Authorization"
@@ -28,18 +43,36 @@ type JWTConfig struct {
 	// in the echo.Context.
 	// Default is "user"
 	ContextKey string
+
+	// RejectImpersonated rejects tokens carrying the impersonated claim with 403 Forbidden.
+	// Default is false, accepting them like any other token.
+	RejectImpersonated bool
 }
 
 // DefaultJWTConfig is the default JWT middleware config.
 var DefaultJWTConfig = JWTConfig{
-	Skipper:     func(c echo.Context) bool { return false },
-	TokenLookup: "header:Authorization",
-	AuthScheme:  "Bearer",
-	ContextKey:  "user",
+	Skipper:       func(c echo.Context) bool { return false },
+	SigningMethod: jwt.SigningMethodHS256.Alg(),
+	TokenLookup:   "header:Authorization",
+	AuthScheme:    "Bearer",
+	ContextKey:    "user",
 }
 
 // JWTWithConfig returns a JWT middleware with config.
+// It panics with a ConfigError if the secret or signing method is invalid.
 func JWTWithConfig(config JWTConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultJWTConfig.Skipper
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultJWTConfig.TokenLookup
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultJWTConfig.ContextKey
+	}
+	config = applyJWTDefaults(config, "jwt")
+
 	// Return a middleware handler
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -48,64 +81,156 @@ func JWTWithConfig(config JWTConfig) echo.MiddlewareFunc {
 			}
 
 			// Extract token
-			parts := strings.Split(config.TokenLookup, ":")
-			if len(parts) != 2 {
-				return echo.NewHTTPError(http.StatusInternalServerError, "invalid token lookup format")
-			}
-
-			var token string
-			switch parts[0] {
-			case "header":
-				auth := c.Request().Header.Get(parts[1])
-				if auth == "" {
-					return echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
-				}
-				if config.AuthScheme != "" {
-					l := len(config.AuthScheme)
-					if len(auth) > l+1 && auth[:l] == config.AuthScheme {
-						token = auth[l+1:]
-					} else {
-						return echo.NewHTTPError(http.StatusUnauthorized, "invalid auth scheme")
-					}
-				} else {
-					token = auth
-				}
-			case "query":
-				token = c.QueryParam(parts[1])
-			case "cookie":
-				cookie, err := c.Cookie(parts[1])
-				if err != nil {
-					return echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
-				}
-				token = cookie.Value
-			default:
-				return echo.NewHTTPError(http.StatusInternalServerError, "invalid token lookup source")
+			token, err := extractToken(c, config)
+			if err != nil {
+				return err
 			}
 
 			// Validate token
-			// This is a placeholder for actual JWT validation
-			// In a real implementation, you would use a JWT library to validate the token
-			if token == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+			claims, err := validateToken(token, config)
+			if err != nil {
+				return err
 			}
 
-			// For demonstration purposes, we're just checking if the token is not empty
-			// and setting a dummy user in the context
-			// In a real implementation, you would decode and validate the token
-			c.Set(config.ContextKey, map[string]interface{}{
-				"id":    "user-123",
-				"email": "user@example.com",
-				"roles": []string{"user"},
-			})
+			c.Set(config.ContextKey, claims)
 
 			return next(c)
 		}
 	}
 }
 
+// extractToken extracts the raw token from the request according to config.TokenLookup
+func extractToken(c echo.Context, config JWTConfig) (string, error) {
+	parts := strings.Split(config.TokenLookup, ":")
+	if len(parts) != 2 {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "invalid token lookup format")
+	}
+
+	var token string
+	switch parts[0] {
+	case "header":
+		auth := c.Request().Header.Get(parts[1])
+		if auth == "" {
+			return "", echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+		}
+		if config.AuthScheme != "" {
+			l := len(config.AuthScheme)
+			if len(auth) > l+1 && auth[:l] == config.AuthScheme {
+				token = auth[l+1:]
+			} else {
+				return "", echo.NewHTTPError(http.StatusUnauthorized, "invalid auth scheme")
+			}
+		} else {
+			token = auth
+		}
+	case "query":
+		token = c.QueryParam(parts[1])
+	case "cookie":
+		cookie, err := c.Cookie(parts[1])
+		if err != nil {
+			return "", echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+		}
+		token = cookie.Value
+	default:
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "invalid token lookup source")
+	}
+
+	if token == "" {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+	}
+
+	return token, nil
+}
+
+// applyJWTDefaults sets the default signing method and checks the secret and method are usable
+func applyJWTDefaults(config JWTConfig, middleware string) JWTConfig {
+	if config.SigningMethod == "" {
+		config.SigningMethod = DefaultJWTConfig.SigningMethod
+	}
+	if config.Secret == "" {
+		panic(&ConfigError{Middleware: middleware, Err: ErrJWTSecretNotSet})
+	}
+	if _, ok := jwt.GetSigningMethod(config.SigningMethod).(*jwt.SigningMethodHMAC); !ok {
+		panic(&ConfigError{Middleware: middleware, Err: ErrUnsupportedSigningMethod})
+	}
+	return config
+}
+
+// validateToken verifies the token signature and expiry and returns its claims
+func validateToken(token string, config JWTConfig) (map[string]interface{}, error) {
+	if token == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(config.Secret), nil
+	}, jwt.WithValidMethods([]string{config.SigningMethod}))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "jwt has expired")
+		}
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "invalid or malformed jwt")
+	}
+	if config.RejectImpersonated && IsImpersonated(claims) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "impersonated tokens are not accepted")
+	}
+
+	return claims, nil
+}
+
+// IsImpersonated reports whether claims belong to a token issued for impersonation
+func IsImpersonated(claims map[string]interface{}) bool {
+	impersonated, _ := claims[ClaimImpersonated].(bool)
+	return impersonated
+}
+
 // JWT returns a middleware that validates JWT tokens.
 func JWT(secret string) echo.MiddlewareFunc {
 	config := DefaultJWTConfig
 	config.Secret = secret
 	return JWTWithConfig(config)
 }
+
+// GenerateToken signs claims with secret using HS256.
+// It sets "iat" to now and "exp" to now+ttl (when ttl > 0), and copies "id" to "sub" if "sub" is not set.
+// The claims map passed in is not modified.
+func GenerateToken(claims jwt.MapClaims, secret string, ttl time.Duration) (string, error) {
+	if secret == "" {
+		return "", ErrJWTSecretNotSet
+	}
+
+	now := time.Now()
+	tokenClaims := make(jwt.MapClaims, len(claims)+2)
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+	tokenClaims["iat"] = now.Unix()
+	if ttl > 0 {
+		tokenClaims["exp"] = now.Add(ttl).Unix()
+	}
+	if _, ok := tokenClaims["sub"]; !ok {
+		if id, ok := tokenClaims["id"]; ok {
+			tokenClaims["sub"] = id
+		}
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims).SignedString([]byte(secret))
+}
+
+// TokenExpiry returns the time the "exp" claim of a token says it expires at.
+// The signature is not verified, so it must only be used on tokens this service issued.
+func TokenExpiry(token string) (time.Time, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return time.Time{}, err
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if exp == nil {
+		return time.Time{}, errors.New("token has no expiry")
+	}
+	return exp.UTC(), nil
+}
@@ -0,0 +1,213 @@
+package mwutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const testJWTSecret = "test-secret"
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWT(t *testing.T) {
+	valid := jwt.MapClaims{
+		"sub":   "user-1",
+		"email": "jane@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	expired := jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+	}{
+		{"valid", "Bearer " + signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, valid), http.StatusOK},
+		{"expired", "Bearer " + signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, expired), http.StatusUnauthorized},
+		{"wrong signature", "Bearer " + signTestToken(t, jwt.SigningMethodHS256, "other-secret", valid), http.StatusUnauthorized},
+		{"wrong method", "Bearer " + signTestToken(t, jwt.SigningMethodHS512, testJWTSecret, valid), http.StatusUnauthorized},
+		{"malformed", "Bearer not.a.jwt", http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			var claims map[string]interface{}
+			e.GET("/", func(c echo.Context) error {
+				claims, _ = c.Get("user").(map[string]interface{})
+				return c.NoContent(http.StatusOK)
+			}, JWT(testJWTSecret))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && (claims["sub"] != "user-1" || claims["email"] != "jane@example.com") {
+				t.Errorf("expected real claims in context, got %v", claims)
+			}
+		})
+	}
+}
+
+func TestJWTConfigErrors(t *testing.T) {
+	if err := build(func() { JWT("") }); err == nil {
+		t.Error("expected error for empty secret")
+	}
+
+	config := DefaultJWTConfig
+	config.Secret = testJWTSecret
+	config.SigningMethod = "RS256"
+	if err := build(func() { JWTWithConfig(config) }); err == nil {
+		t.Error("expected error for non-HMAC signing method")
+	}
+}
+
+func TestGenerateTokenRoundTrip(t *testing.T) {
+	input := jwt.MapClaims{"id": "user-1", "roles": []string{"admin"}}
+	token, err := GenerateToken(input, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := input["iat"]; ok {
+		t.Error("expected input claims not to be modified")
+	}
+
+	e := echo.New()
+	var claims map[string]interface{}
+	e.GET("/", func(c echo.Context) error {
+		claims, _ = c.Get("user").(map[string]interface{})
+		return c.NoContent(http.StatusOK)
+	}, JWT(testJWTSecret))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected generated token to validate, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if claims["sub"] != "user-1" {
+		t.Errorf("expected sub claim user-1, got %v", claims["sub"])
+	}
+	for _, claim := range []string{"iat", "exp"} {
+		if _, ok := claims[claim].(float64); !ok {
+			t.Errorf("expected numeric %s claim, got %v", claim, claims[claim])
+		}
+	}
+	if exp := int64(claims["exp"].(float64)); exp < time.Now().Add(59*time.Minute).Unix() {
+		t.Errorf("expected exp about one hour from now, got %d", exp)
+	}
+}
+
+func TestGenerateTokenExpiredIsRejected(t *testing.T) {
+	token, err := GenerateToken(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix(), "sub": "user-1"}, testJWTSecret, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, JWT(testJWTSecret))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for expired token, got %d", rec.Code)
+	}
+}
+
+func TestGenerateTokenRequiresSecret(t *testing.T) {
+	if _, err := GenerateToken(jwt.MapClaims{}, "", time.Hour); err != ErrJWTSecretNotSet {
+		t.Errorf("expected ErrJWTSecretNotSet, got %v", err)
+	}
+}
+
+func TestJWTRejectImpersonated(t *testing.T) {
+	impersonated := signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+		"sub":             "user-1",
+		ClaimImpersonated: true,
+		ClaimImpersonator: "admin-1",
+		"exp":             time.Now().Add(time.Hour).Unix(),
+	})
+	regular := signTestToken(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	tests := []struct {
+		name     string
+		reject   bool
+		token    string
+		wantCode int
+	}{
+		{"accepted by default", false, impersonated, http.StatusOK},
+		{"rejected when configured", true, impersonated, http.StatusForbidden},
+		{"regular token still accepted", true, regular, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultJWTConfig
+			config.Secret = testJWTSecret
+			config.RejectImpersonated = tt.reject
+
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, JWTWithConfig(config))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	token, err := GenerateToken(jwt.MapClaims{"sub": "user-1"}, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	exp, err := TokenExpiry(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if until := time.Until(exp); until > time.Hour || until < time.Hour-time.Minute {
+		t.Errorf("expected the token to expire in an hour, got %s", until)
+	}
+
+	noExpiry, _ := GenerateToken(jwt.MapClaims{"sub": "user-1"}, testJWTSecret, 0)
+	if _, err := TokenExpiry(noExpiry); err == nil {
+		t.Error("expected an error for a token without exp")
+	}
+}
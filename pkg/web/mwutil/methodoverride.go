@@ -0,0 +1,113 @@
+package mwutil
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// MethodOverrideConfig defines the config for MethodOverride middleware.
+type MethodOverrideConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Header is the request header carrying the method to use.
+	// Default is "X-HTTP-Method-Override".
+	Header string
+
+	// FormField is the form field carrying the method to use when the header is not set.
+	// Only urlencoded form bodies are read; multipart and JSON bodies are left untouched.
+	// Default is "_method".
+	FormField string
+
+	// MaxFormSize caps the urlencoded body read looking for FormField; larger bodies
+	// are rejected with 413 Request Entity Too Large.
+	// Default is 1 MB.
+	MaxFormSize int64
+
+	// AllowedMethods are the methods a POST request may be overridden to.
+	// Default is PUT, PATCH and DELETE.
+	AllowedMethods []string
+}
+
+// DefaultMethodOverrideConfig is the default MethodOverride middleware config.
+var DefaultMethodOverrideConfig = MethodOverrideConfig{
+	Skipper:        middleware.DefaultSkipper,
+	Header:         echo.HeaderXHTTPMethodOverride,
+	FormField:      "_method",
+	MaxFormSize:    1 << 20,
+	AllowedMethods: []string{http.MethodPut, http.MethodPatch, http.MethodDelete},
+}
+
+// MethodOverride returns a middleware that lets clients limited to GET and POST send
+// PUT, PATCH and DELETE requests as a POST naming the method in the X-HTTP-Method-Override
+// header or the _method form field.
+// Register it with e.Pre so the overridden method is used for routing.
+func MethodOverride() echo.MiddlewareFunc {
+	return MethodOverrideWithConfig(DefaultMethodOverrideConfig)
+}
+
+// MethodOverrideWithConfig returns a MethodOverride middleware with config.
+// Only POST requests are overridden, and overrides to methods outside AllowedMethods are
+// rejected with 400 Bad Request rather than handled as a POST.
+func MethodOverrideWithConfig(config MethodOverrideConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultMethodOverrideConfig.Skipper
+	}
+	if config.Header == "" {
+		config.Header = DefaultMethodOverrideConfig.Header
+	}
+	if config.FormField == "" {
+		config.FormField = DefaultMethodOverrideConfig.FormField
+	}
+	if config.MaxFormSize <= 0 {
+		config.MaxFormSize = DefaultMethodOverrideConfig.MaxFormSize
+	}
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = DefaultMethodOverrideConfig.AllowedMethods
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if config.Skipper(c) || req.Method != http.MethodPost {
+				return next(c)
+			}
+
+			method := req.Header.Get(config.Header)
+			if method == "" && isFormRequest(req) {
+				// Cap the body before parsing so a huge form cannot be read into memory
+				req.Body = http.MaxBytesReader(c.Response(), req.Body, config.MaxFormSize)
+				if err := req.ParseForm(); err != nil {
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+					}
+					return echo.NewHTTPError(http.StatusBadRequest, "invalid form body")
+				}
+				method = req.PostForm.Get(config.FormField)
+			}
+			if method == "" {
+				return next(c)
+			}
+
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if !slices.Contains(config.AllowedMethods, method) {
+				return echo.NewHTTPError(http.StatusBadRequest, "method override not allowed")
+			}
+			req.Method = method
+
+			return next(c)
+		}
+	}
+}
+
+// isFormRequest reports whether the request body is an urlencoded form
+func isFormRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationForm)
+}
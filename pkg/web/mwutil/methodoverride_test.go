@@ -0,0 +1,108 @@
+package mwutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newMethodOverrideTestServer(config MethodOverrideConfig) *echo.Echo {
+	e := echo.New()
+	e.Pre(MethodOverrideWithConfig(config))
+	e.POST("/items", func(c echo.Context) error { return c.String(http.StatusCreated, "created") })
+	e.DELETE("/items/:id", func(c echo.Context) error { return c.String(http.StatusOK, "deleted "+c.Param("id")) })
+	e.PUT("/items/:id", func(c echo.Context) error { return c.String(http.StatusOK, "replaced "+c.Param("id")) })
+	e.POST("/items/:id", func(c echo.Context) error { return c.String(http.StatusOK, "posted "+c.Param("id")) })
+	return e
+}
+
+func TestMethodOverridePostToDelete(t *testing.T) {
+	e := newMethodOverrideTestServer(MethodOverrideConfig{})
+
+	req := httptest.NewRequest(http.MethodPost, "/items/42", nil)
+	req.Header.Set(echo.HeaderXHTTPMethodOverride, "delete")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "deleted 42" {
+		t.Fatalf("expected the delete handler to run, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMethodOverrideFormField(t *testing.T) {
+	e := newMethodOverrideTestServer(MethodOverrideConfig{})
+
+	req := httptest.NewRequest(http.MethodPost, "/items/42", strings.NewReader("_method=PUT&name=widget"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "replaced 42" {
+		t.Fatalf("expected the put handler to run, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// JSON bodies are not read for the form field
+	req = httptest.NewRequest(http.MethodPost, "/items/42", strings.NewReader(`{"_method":"DELETE"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Body.String() != "posted 42" {
+		t.Errorf("expected a JSON body to be handled as a POST, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMethodOverrideCapsFormBody(t *testing.T) {
+	e := newMethodOverrideTestServer(MethodOverrideConfig{MaxFormSize: 64})
+
+	req := httptest.NewRequest(http.MethodPost, "/items/42", strings.NewReader("name="+strings.Repeat("x", 64)+"&_method=DELETE"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected an oversized form to be rejected, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Multipart bodies, such as uploads, are not read for the form field
+	req = httptest.NewRequest(http.MethodPost, "/items/42", strings.NewReader("--b\r\nContent-Disposition: form-data; name=\"_method\"\r\n\r\nDELETE\r\n--b--\r\n"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEMultipartForm+"; boundary=b")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Body.String() != "posted 42" {
+		t.Errorf("expected a multipart body to be handled as a POST, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMethodOverrideRestrictions(t *testing.T) {
+	e := newMethodOverrideTestServer(MethodOverrideConfig{AllowedMethods: []string{http.MethodDelete}})
+
+	tests := []struct {
+		name     string
+		method   string
+		override string
+		wantCode int
+		wantBody string
+	}{
+		{"no override", http.MethodPost, "", http.StatusOK, "posted 42"},
+		{"allowed", http.MethodPost, "DELETE", http.StatusOK, "deleted 42"},
+		{"not in allowed methods", http.MethodPost, "PUT", http.StatusBadRequest, ""},
+		{"unsafe target", http.MethodPost, "CONNECT", http.StatusBadRequest, ""},
+		{"only post is overridden", http.MethodPut, "DELETE", http.StatusOK, "replaced 42"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/items/42", nil)
+		if tt.override != "" {
+			req.Header.Set(echo.HeaderXHTTPMethodOverride, tt.override)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.wantCode, rec.Code, rec.Body.String())
+		}
+		if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.wantBody, rec.Body.String())
+		}
+	}
+}
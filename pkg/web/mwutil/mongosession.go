@@ -0,0 +1,61 @@
+package mwutil
+
+import (
+	"context"
+
+	"go-echo-mongo/pkg/database"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoSessionConfig defines the config for MongoSession middleware.
+type MongoSessionConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Client starts the sessions.
+	// Required.
+	Client *mongo.Client
+}
+
+// DefaultMongoSessionConfig is the default MongoSession middleware config.
+var DefaultMongoSessionConfig = MongoSessionConfig{
+	Skipper: middleware.DefaultSkipper,
+}
+
+// MongoSession returns a middleware that starts a causally consistent MongoDB session
+// for each request and stores it in the request context. Repository calls made with
+// the request context then share the session, so a handler reads its own writes.
+func MongoSession(client *mongo.Client) echo.MiddlewareFunc {
+	config := DefaultMongoSessionConfig
+	config.Client = client
+	return MongoSessionWithConfig(config)
+}
+
+// MongoSessionWithConfig returns a MongoSession middleware with config.
+func MongoSessionWithConfig(config MongoSessionConfig) echo.MiddlewareFunc {
+	if config.Client == nil {
+		panic(&ConfigError{Middleware: "MongoSession", Err: ErrMongoClientNotSet})
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultMongoSessionConfig.Skipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			return database.WithCausalSession(req.Context(), config.Client, func(ctx context.Context) error {
+				c.SetRequest(req.WithContext(ctx))
+				// Restore the original request so the session is not used after it has ended
+				defer c.SetRequest(req)
+				return next(c)
+			})
+		}
+	}
+}
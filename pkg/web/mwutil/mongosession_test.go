@@ -0,0 +1,52 @@
+package mwutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMongoSessionStoresSessionInRequestContext(t *testing.T) {
+	// Connecting is lazy, so no server is needed to start a session
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	var session mongo.Session
+	h := MongoSession(client)(func(c echo.Context) error {
+		session = mongo.SessionFromContext(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	if err := h(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if session == nil {
+		t.Fatal("expected the handler's request context to carry a session")
+	}
+	if mongo.SessionFromContext(c.Request().Context()) != nil {
+		t.Error("expected the original request to be restored once the session ended")
+	}
+}
+
+func TestMongoSessionRequiresClient(t *testing.T) {
+	var err error
+	func() {
+		defer RecoverConfigError(&err)
+		MongoSessionWithConfig(MongoSessionConfig{})
+	}()
+	if !errors.Is(err, ErrMongoClientNotSet) {
+		t.Fatalf("expected ErrMongoClientNotSet, got %v", err)
+	}
+}
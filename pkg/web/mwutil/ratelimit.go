@@ -1,9 +1,9 @@
 package mwutil
 
 import (
-	"log"
 	"time"
 
+	"go-echo-mongo/internal/model"
 	"go-echo-mongo/pkg/ratelimit"
 	"go-echo-mongo/pkg/ratelimit/strategy"
 
@@ -18,10 +18,14 @@ const (
 	FixedWindow RateLimitStrategy = "fixed_window"
 	// SlidingWindow represents a sliding window rate limiting strategy
 	SlidingWindow RateLimitStrategy = "sliding_window"
+	// SlidingWindowLog represents an exact sliding window strategy that logs every request
+	SlidingWindowLog RateLimitStrategy = "sliding_window_log"
 	// TokenBucket represents a token bucket rate limiting strategy
 	TokenBucket RateLimitStrategy = "token_bucket"
 	// LeakyBucket represents a leaky bucket rate limiting strategy
 	LeakyBucket RateLimitStrategy = "leaky_bucket"
+	// GCRA represents a generic cell rate algorithm strategy, spacing requests evenly with bounded bursts
+	GCRA RateLimitStrategy = "gcra"
 )
 
 // RateLimitConfig holds the configuration for rate limiting
@@ -32,26 +36,37 @@ type RateLimitConfig struct {
 	Limit int
 	// Window is the time window for rate limiting
 	Window time.Duration
-	// Burst is the maximum burst size (only used for token bucket)
+	// Burst is the maximum burst size (token bucket and GCRA)
 	Burst int
-	// Rate is the rate at which tokens are added (token bucket) or water leaks (leaky bucket)
+	// Rate is the rate at which tokens are added (token bucket) or water leaks (leaky bucket),
+	// or the number of requests allowed per Window (GCRA)
 	Rate float64
 }
 
-// NewRateLimiter creates a new rate limiting middleware based on the provided strategy
-func NewRateLimiter(config RateLimitConfig) echo.MiddlewareFunc {
+// requireRateLimitRepo panics with a ConfigError if the rate limit repository is not set
+func requireRateLimitRepo() {
 	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
+		panic(&ConfigError{Middleware: "rate limiter", Err: ErrRateLimitRepoNotSet})
 	}
+}
+
+// NewRateLimiter creates a new rate limiting middleware based on the provided strategy.
+// All rate limiter constructors panic with a ConfigError if the repository is not set.
+func NewRateLimiter(config RateLimitConfig) echo.MiddlewareFunc {
+	requireRateLimitRepo()
 	switch config.Strategy {
 	case FixedWindow:
 		return strategy.NewFixedWindowMiddleware(config.Limit, config.Window)
 	case SlidingWindow:
 		return strategy.NewSlidingWindowMiddleware(config.Limit, config.Window)
+	case SlidingWindowLog:
+		return strategy.NewSlidingWindowLogMiddleware(config.Limit, config.Window)
 	case TokenBucket:
 		return strategy.NewTokenBucketMiddleware(config.Rate, config.Burst, config.Window)
 	case LeakyBucket:
 		return strategy.NewLeakyBucketMiddleware(config.Burst, config.Rate, config.Window)
+	case GCRA:
+		return strategy.NewGCRAMiddleware(config.Rate, config.Burst, config.Window)
 	default:
 		// Default to fixed window if strategy is not recognized
 		return strategy.NewFixedWindowMiddleware(config.Limit, config.Window)
@@ -62,9 +77,7 @@ func NewRateLimiter(config RateLimitConfig) echo.MiddlewareFunc {
 // limit: maximum number of requests per window
 // window: time window for rate limiting
 func NewFixedRateLimiter(limit int, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewFixedWindowMiddleware(limit, window)
 }
 
@@ -72,20 +85,24 @@ func NewFixedRateLimiter(limit int, window time.Duration) echo.MiddlewareFunc {
 // limit: maximum number of requests per window
 // window: time window for rate limiting
 func NewSlidingRateLimiter(limit int, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewSlidingWindowMiddleware(limit, window)
 }
 
+// NewSlidingLogRateLimiter creates an exact sliding window rate limiter
+// limit: maximum number of requests in any window
+// window: time window for rate limiting
+func NewSlidingLogRateLimiter(limit int, window time.Duration) echo.MiddlewareFunc {
+	requireRateLimitRepo()
+	return strategy.NewSlidingWindowLogMiddleware(limit, window)
+}
+
 // NewTokenBucketLimiter creates a new token bucket rate limiter
 // rate: tokens per second
 // burst: maximum bucket size
 // window: expiration time for bucket state
 func NewTokenBucketLimiter(rate float64, burst int, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewTokenBucketMiddleware(rate, burst, window)
 }
 
@@ -94,19 +111,24 @@ func NewTokenBucketLimiter(rate float64, burst int, window time.Duration) echo.M
 // leakRate: requests per second that leak out
 // window: expiration time for bucket state
 func NewLeakyBucketLimiter(capacity int, leakRate float64, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewLeakyBucketMiddleware(capacity, leakRate, window)
 }
 
+// NewGCRALimiter creates a new GCRA rate limiter
+// rate: requests allowed per window on average
+// burst: requests that may arrive back to back
+// window: period the rate is measured over
+func NewGCRALimiter(rate float64, burst int, window time.Duration) echo.MiddlewareFunc {
+	requireRateLimitRepo()
+	return strategy.NewGCRAMiddleware(rate, burst, window)
+}
+
 // NewFixedRateLimiterPerPath creates a fixed window rate limiter that's path-specific
 // limit: maximum number of requests per window
 // window: time window for rate limiting
 func NewFixedRateLimiterPerPath(limit int, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewFixedWindowMiddlewarePerPath(limit, window)
 }
 
@@ -114,20 +136,24 @@ func NewFixedRateLimiterPerPath(limit int, window time.Duration) echo.Middleware
 // limit: maximum number of requests per window
 // window: time window for rate limiting
 func NewSlidingRateLimiterPerPath(limit int, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewSlidingWindowMiddlewarePerPath(limit, window)
 }
 
+// NewSlidingLogRateLimiterPerPath creates an exact sliding window rate limiter that's path-specific
+// limit: maximum number of requests in any window
+// window: time window for rate limiting
+func NewSlidingLogRateLimiterPerPath(limit int, window time.Duration) echo.MiddlewareFunc {
+	requireRateLimitRepo()
+	return strategy.NewSlidingWindowLogMiddlewarePerPath(limit, window)
+}
+
 // NewTokenBucketLimiterPerPath creates a token bucket rate limiter that's path-specific
 // rate: tokens per second
 // burst: maximum bucket size
 // window: expiration time for bucket state
 func NewTokenBucketLimiterPerPath(rate float64, burst int, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewTokenBucketMiddlewarePerPath(rate, burst, window)
 }
 
@@ -136,8 +162,86 @@ func NewTokenBucketLimiterPerPath(rate float64, burst int, window time.Duration)
 // leakRate: requests per second that leak out
 // window: expiration time for bucket state
 func NewLeakyBucketLimiterPerPath(capacity int, leakRate float64, window time.Duration) echo.MiddlewareFunc {
-	if ratelimit.GetRateLimitRepo() == nil {
-		log.Fatal("echo: rate limit repository is not set")
-	}
+	requireRateLimitRepo()
 	return strategy.NewLeakyBucketMiddlewarePerPath(capacity, leakRate, window)
 }
+
+// NewGCRALimiterPerPath creates a GCRA rate limiter that's path-specific
+// rate: requests allowed per window on average
+// burst: requests that may arrive back to back
+// window: period the rate is measured over
+func NewGCRALimiterPerPath(rate float64, burst int, window time.Duration) echo.MiddlewareFunc {
+	requireRateLimitRepo()
+	return strategy.NewGCRAMiddlewarePerPath(rate, burst, window)
+}
+
+// NewAdaptiveRateLimiter creates a fixed window rate limiter that tightens while under load
+// limit: maximum number of requests per window when healthy
+// window: time window for rate limiting
+// signal: load source, e.g. strategy.NewInflightLoad
+// config: stress threshold and limit factor; zero values use strategy.DefaultAdaptiveConfig
+func NewAdaptiveRateLimiter(limit int, window time.Duration, signal strategy.LoadSignal, config strategy.AdaptiveConfig) echo.MiddlewareFunc {
+	requireRateLimitRepo()
+	if signal == nil {
+		panic(&ConfigError{Middleware: "adaptive rate limiter", Err: ErrLoadSignalNotSet})
+	}
+	return strategy.NewAdaptiveFixedWindowMiddleware(limit, window, signal, config)
+}
+
+// NewRoleRateLimiter creates a rate limiter whose limit depends on the authenticated user's role.
+// It must run after API key auth so the context user is set; clients are still identified by API key.
+// tiers maps roles to their limits, and requests without a user or a tiered role use fallback.
+// A user holding several tiered roles gets the tier with the highest Limit, then the highest Burst.
+func NewRoleRateLimiter(tiers map[string]RateLimitConfig, fallback RateLimitConfig) echo.MiddlewareFunc {
+	requireRateLimitRepo()
+	limiters := make(map[string]echo.MiddlewareFunc, len(tiers))
+	for role, config := range tiers {
+		limiters[role] = NewRateLimiter(config)
+	}
+	fallbackLimiter := NewRateLimiter(fallback)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		handlers := make(map[string]echo.HandlerFunc, len(limiters))
+		for role, limiter := range limiters {
+			handlers[role] = limiter(next)
+		}
+		fallbackHandler := fallbackLimiter(next)
+
+		return func(c echo.Context) error {
+			if role, ok := tierRole(c, tiers); ok {
+				return handlers[role](c)
+			}
+			return fallbackHandler(c)
+		}
+	}
+}
+
+// tierRole returns the most generous of the caller's roles that has a tier
+func tierRole(c echo.Context, tiers map[string]RateLimitConfig) (string, bool) {
+	var roles []string
+	if p, ok := c.Get("principal").(*Principal); ok && p != nil {
+		roles = p.Roles
+	} else if user, ok := c.Get("user").(*model.User); ok && user != nil {
+		roles = user.Roles
+	}
+
+	best, found := "", false
+	for _, role := range roles {
+		config, ok := tiers[role]
+		if !ok {
+			continue
+		}
+		if !found || config.Limit > tiers[best].Limit ||
+			(config.Limit == tiers[best].Limit && config.Burst > tiers[best].Burst) {
+			best, found = role, true
+		}
+	}
+	return best, found
+}
+
+// ResetRateLimit clears a client's limit in every rate limiter built so far, e.g. to lift a
+// stuck limit on support request. identifier is "api:<key>" or "ip:<address>", with
+// ":<path>" appended for path-specific limiters.
+func ResetRateLimit(identifier string) error {
+	return strategy.ResetLimit(identifier)
+}
@@ -0,0 +1,99 @@
+package mwutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go-echo-mongo/internal/model"
+	"go-echo-mongo/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// memoryRateLimitRepo counts in memory; it only implements what the fixed window strategy uses
+type memoryRateLimitRepo struct {
+	ratelimit.RateLimitRepo
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *memoryRateLimitRepo) IncrAndExpire(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[key]++
+	return r.counts[key], nil
+}
+
+func (r *memoryRateLimitRepo) Check(ctx context.Context, key string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[key], nil
+}
+
+// keyValidator authenticates users by their API key
+type keyValidator map[string]*model.User
+
+func (v keyValidator) GetByApiKey(ctx context.Context, apiKey string) (*model.User, error) {
+	if user, ok := v[apiKey]; ok {
+		return user, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestRoleRateLimiterAppliesTierOfUserRole(t *testing.T) {
+	defer ratelimit.SetRateLimitRepo(ratelimit.GetRateLimitRepo())
+	ratelimit.SetRateLimitRepo(&memoryRateLimitRepo{counts: make(map[string]int)})
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+		NewAPIKeyAuthWithConfig(APIKeyAuthConfig{Validator: keyValidator{
+			"admin-key":   {Roles: []string{model.RoleUser, model.RoleAdmin}},
+			"user-key":    {Roles: []string{model.RoleUser}},
+			"viewer-key":  {Roles: []string{model.RoleViewer}},
+			"manager-key": {Roles: []string{model.RoleUser, model.RoleManager}},
+		}, RequiredRoles: []string{model.RoleUser, model.RoleViewer}}),
+		NewRoleRateLimiter(map[string]RateLimitConfig{
+			model.RoleAdmin:   {Strategy: FixedWindow, Limit: 5, Window: time.Hour},
+			model.RoleManager: {Strategy: FixedWindow, Limit: 1, Window: time.Hour},
+			model.RoleUser:    {Strategy: FixedWindow, Limit: 2, Window: time.Hour},
+		}, RateLimitConfig{Strategy: FixedWindow, Limit: 1, Window: time.Hour}),
+	)
+
+	tests := []struct {
+		apiKey string
+		limit  int
+	}{
+		{"admin-key", 5},
+		{"user-key", 2},
+		// Roles without a tier use the fallback
+		{"viewer-key", 1},
+		// The most generous tiered role wins
+		{"manager-key", 2},
+	}
+
+	for _, tt := range tests {
+		for i := 1; i <= tt.limit+1; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			want := http.StatusOK
+			if i > tt.limit {
+				want = http.StatusTooManyRequests
+			}
+			if rec.Code != want {
+				t.Errorf("%s request %d: expected %d, got %d", tt.apiKey, i, want, rec.Code)
+			}
+			if got := rec.Header().Get("X-RateLimit-Limit"); got != strconv.Itoa(tt.limit) {
+				t.Errorf("%s request %d: expected X-RateLimit-Limit %d, got %q", tt.apiKey, i, tt.limit, got)
+			}
+		}
+	}
+}
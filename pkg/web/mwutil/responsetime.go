@@ -0,0 +1,60 @@
+package mwutil
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// ResponseTimeConfig defines the config for ResponseTime middleware.
+type ResponseTimeConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Header is the response header the duration is written to.
+	// Default is "X-Response-Time".
+	Header string
+}
+
+// DefaultResponseTimeConfig is the default ResponseTime middleware config.
+var DefaultResponseTimeConfig = ResponseTimeConfig{
+	Skipper: middleware.DefaultSkipper,
+	Header:  "X-Response-Time",
+}
+
+// ResponseTime returns a middleware that reports the handler duration in
+// milliseconds in the X-Response-Time header.
+func ResponseTime() echo.MiddlewareFunc {
+	return ResponseTimeWithConfig(DefaultResponseTimeConfig)
+}
+
+// ResponseTimeWithConfig returns a ResponseTime middleware with config.
+// The header is set just before the response is written, so it is present
+// on error responses rendered by the HTTP error handler too.
+func ResponseTimeWithConfig(config ResponseTimeConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultResponseTimeConfig.Skipper
+	}
+	if config.Header == "" {
+		config.Header = DefaultResponseTimeConfig.Header
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			start := time.Now()
+			res := c.Response()
+			res.Before(func() {
+				ms := float64(time.Since(start).Microseconds()) / 1000
+				res.Header().Set(config.Header, strconv.FormatFloat(ms, 'f', 3, 64))
+			})
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mwutil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestResponseTimeHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  echo.HandlerFunc
+		wantCode int
+	}{
+		{
+			name:     "success",
+			handler:  func(c echo.Context) error { return c.String(http.StatusOK, "ok") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "handler error",
+			handler:  func(c echo.Context) error { return errors.New("boom") },
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "http error",
+			handler:  func(c echo.Context) error { return echo.ErrNotFound },
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(ResponseTime())
+			e.GET("/", tt.handler)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			value := rec.Header().Get("X-Response-Time")
+			if value == "" {
+				t.Fatal("expected X-Response-Time header")
+			}
+			ms, err := strconv.ParseFloat(value, 64)
+			if err != nil || ms < 0 {
+				t.Errorf("expected non-negative numeric header, got %q", value)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package mwutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// StrictJSONConfig defines the config for StrictJSON middleware.
+type StrictJSONConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Target is the request type the handler binds the body into, e.g. dto.CreateUserRequest{}.
+	// Its fields decide which JSON fields are known. Required.
+	Target interface{}
+
+	// MaxBodySize caps the JSON body read into memory for the check; larger bodies
+	// are rejected with 413 Request Entity Too Large.
+	// Default is 1 MB.
+	MaxBodySize int64
+}
+
+// DefaultStrictJSONConfig is the default StrictJSON middleware config.
+var DefaultStrictJSONConfig = StrictJSONConfig{
+	Skipper:     middleware.DefaultSkipper,
+	MaxBodySize: 1 << 20,
+}
+
+// StrictJSON returns a middleware that rejects JSON request bodies containing fields the
+// target type does not declare with 400 Bad Request naming the field, so typos such as
+// "emial" are not silently dropped by c.Bind. Add it to the write routes that need it.
+func StrictJSON(target interface{}) echo.MiddlewareFunc {
+	config := DefaultStrictJSONConfig
+	config.Target = target
+	return StrictJSONWithConfig(config)
+}
+
+// StrictJSONWithConfig returns a StrictJSON middleware with config.
+func StrictJSONWithConfig(config StrictJSONConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultStrictJSONConfig.Skipper
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultStrictJSONConfig.MaxBodySize
+	}
+	if config.Target == nil {
+		panic(&ConfigError{Middleware: "strict json", Err: ErrStrictJSONTargetNotSet})
+	}
+	targetType := reflect.TypeOf(config.Target)
+	for targetType.Kind() == reflect.Pointer {
+		targetType = targetType.Elem()
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if config.Skipper(c) || req.Body == nil || !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+				return next(c)
+			}
+
+			// Cap the body before buffering so a huge request cannot be read into memory
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, config.MaxBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+				}
+				return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body")
+			}
+			// The handler binds the body again, so hand it back untouched
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			if len(bytes.TrimSpace(body)) == 0 {
+				return next(c)
+			}
+
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.DisallowUnknownFields()
+			err = decoder.Decode(reflect.New(targetType).Interface())
+			// Other decoding errors are left for the handler's Bind to report as usual
+			if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+				return echo.NewHTTPError(http.StatusBadRequest, strings.TrimPrefix(err.Error(), "json: "))
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package mwutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type strictItem struct {
+	Name string `json:"name"`
+}
+
+type strictRequest struct {
+	Email string       `json:"email"`
+	Items []strictItem `json:"items"`
+}
+
+func TestStrictJSONRejectsUnknownFields(t *testing.T) {
+	e := echo.New()
+	var bound strictRequest
+	e.POST("/", func(c echo.Context) error {
+		bound = strictRequest{}
+		if err := c.Bind(&bound); err != nil {
+			return c.NoContent(http.StatusUnprocessableEntity)
+		}
+		return c.NoContent(http.StatusOK)
+	}, StrictJSON(&strictRequest{}))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		field       string
+	}{
+		{"known fields", echo.MIMEApplicationJSON, `{"email":"jane@example.com","items":[{"name":"a"}]}`, http.StatusOK, ""},
+		{"typo", echo.MIMEApplicationJSON, `{"emial":"jane@example.com"}`, http.StatusBadRequest, `"emial"`},
+		{"nested typo", echo.MIMEApplicationJSON, `{"items":[{"nmae":"a"}]}`, http.StatusBadRequest, `"nmae"`},
+		{"charset", echo.MIMEApplicationJSONCharsetUTF8, `{"emial":"x"}`, http.StatusBadRequest, `"emial"`},
+		{"empty body", echo.MIMEApplicationJSON, ``, http.StatusOK, ""},
+		{"malformed JSON is left to Bind", echo.MIMEApplicationJSON, `{"email":`, http.StatusUnprocessableEntity, ""},
+		{"form bodies are not checked", echo.MIMEApplicationForm, `emial=x`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, tt.contentType)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+		if tt.field != "" {
+			var body struct {
+				Message string `json:"message"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Message != "unknown field "+tt.field {
+				t.Errorf("%s: expected the error to name %s, got %q", tt.name, tt.field, body.Message)
+			}
+		}
+	}
+
+	// The body is still available to the handler after the check
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"jane@example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if bound.Email != "jane@example.com" {
+		t.Errorf("expected the handler to bind the body, got %+v", bound)
+	}
+}
+
+func TestStrictJSONCapsBody(t *testing.T) {
+	e := echo.New()
+	var bound strictRequest
+	e.POST("/", func(c echo.Context) error {
+		bound = strictRequest{}
+		if err := c.Bind(&bound); err != nil {
+			return c.NoContent(http.StatusUnprocessableEntity)
+		}
+		return c.NoContent(http.StatusOK)
+	}, StrictJSONWithConfig(StrictJSONConfig{Target: &strictRequest{}, MaxBodySize: 64}))
+
+	atLimit := `{"email":"` + strings.Repeat("a", 64-len(`{"email":""}`)) + `"}`
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"at the limit", atLimit, http.StatusOK},
+		{"over the limit", `{"email":"` + strings.Repeat("a", 64) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+	if len(bound.Email) != 64-len(`{"email":""}`) {
+		t.Errorf("expected a body at the limit to reach the handler, got %+v", bound)
+	}
+}
+
+func TestStrictJSONRequiresTarget(t *testing.T) {
+	var err error
+	func() {
+		defer RecoverConfigError(&err)
+		StrictJSON(nil)
+	}()
+	if !errors.Is(err, ErrStrictJSONTargetNotSet) {
+		t.Errorf("expected ErrStrictJSONTargetNotSet, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package response
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderEnvelope lets a client request the raw payload with "X-Envelope: false"
+	HeaderEnvelope = "X-Envelope"
+
+	// envelopeContextKey stores a per-route envelope override in the echo.Context
+	envelopeContextKey = "response.envelope"
+)
+
+// WithoutEnvelope returns a middleware that makes success responses on a route return the bare payload
+func WithoutEnvelope() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(envelopeContextKey, false)
+			return next(c)
+		}
+	}
+}
+
+// EnvelopeEnabled reports whether success responses should be wrapped in the standard envelope.
+// The envelope is on by default; it is disabled by WithoutEnvelope or the "X-Envelope: false" header.
+func EnvelopeEnabled(c echo.Context) bool {
+	if enabled, ok := c.Get(envelopeContextKey).(bool); ok && !enabled {
+		return false
+	}
+	return !strings.EqualFold(strings.TrimSpace(c.Request().Header.Get(HeaderEnvelope)), "false")
+}
@@ -0,0 +1,73 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type testItem struct {
+	ID string `json:"id"`
+}
+
+func serve(t *testing.T, header string, mw ...echo.MiddlewareFunc) map[string]interface{} {
+	t.Helper()
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return OK(c, "Item retrieved", testItem{ID: "1"})
+	}, mw...)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(HeaderEnvelope, header)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	return body
+}
+
+func TestEnvelopeDefault(t *testing.T) {
+	for _, header := range []string{"", "true"} {
+		body := serve(t, header)
+		data, ok := body["data"].(map[string]interface{})
+		if !ok || data["id"] != "1" {
+			t.Errorf("header %q: expected enveloped data, got %v", header, body)
+		}
+		if body["message"] != "Item retrieved" || body["status_code"] != float64(http.StatusOK) {
+			t.Errorf("header %q: expected status and message in envelope, got %v", header, body)
+		}
+	}
+}
+
+func TestEnvelopeDisabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		mw     []echo.MiddlewareFunc
+	}{
+		{name: "header", header: "false"},
+		{name: "route", mw: []echo.MiddlewareFunc{WithoutEnvelope()}},
+	}
+
+	for _, tt := range tests {
+		body := serve(t, tt.header, tt.mw...)
+		if body["id"] != "1" {
+			t.Errorf("%s: expected raw payload, got %v", tt.name, body)
+		}
+		if _, ok := body["data"]; ok {
+			t.Errorf("%s: expected no envelope, got %v", tt.name, body)
+		}
+	}
+}
@@ -8,7 +8,7 @@ import (
 
 // Error sends an error response with the given message (HTTP 4XX, 5XX)
 func Error(c echo.Context, statusCode int, message string) error {
-	return Send(c, statusCode, message, nil)
+	return c.JSON(statusCode, New(statusCode, message, nil))
 }
 
 // BadRequest sends a 400 Bad Request response with the given message
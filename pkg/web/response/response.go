@@ -11,15 +11,61 @@ type Response struct {
 	Data       interface{} `json:"data,omitempty"`
 }
 
+// SuccessEnvelope is the body of every enveloped success response.
+// Meta is only set by paginated responses.
+type SuccessEnvelope struct {
+	Status  int         `json:"status_code" example:"200"`
+	Message string      `json:"message" example:"Operation completed successfully"`
+	Data    interface{} `json:"data,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"`
+}
+
+// PageMeta describes the page of a page-number paginated response
+type PageMeta struct {
+	CurrentPage  int64 `json:"current_page" example:"1"`
+	ItemsPerPage int64 `json:"items_per_page" example:"10"`
+	TotalItems   int64 `json:"total_items" example:"100"`
+	TotalPages   int64 `json:"total_pages" example:"10"`
+}
+
+// CursorMeta describes the page of a cursor-paginated response
+type CursorMeta struct {
+	ItemsPerPage int64  `json:"items_per_page" example:"10"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	HasMore      bool   `json:"has_more"`
+}
+
 // PaginatedResponse represents a paginated API response
 type PaginatedResponse struct {
 	Data interface{} `json:"data"`
-	Meta struct {
-		CurrentPage  int64 `json:"current_page" example:"1"`
-		ItemsPerPage int64 `json:"items_per_page" example:"10"`
-		TotalItems   int64 `json:"total_items" example:"100"`
-		TotalPages   int64 `json:"total_pages" example:"10"`
-	} `json:"meta"`
+	Meta PageMeta    `json:"meta"`
+}
+
+// NewPaginated creates a new paginated response instance
+func NewPaginated(data interface{}, page, itemsPerPage, totalItems int64) *PaginatedResponse {
+	r := &PaginatedResponse{Data: data}
+	r.Meta.CurrentPage = page
+	r.Meta.ItemsPerPage = itemsPerPage
+	r.Meta.TotalItems = totalItems
+	if itemsPerPage > 0 {
+		r.Meta.TotalPages = (totalItems + itemsPerPage - 1) / itemsPerPage
+	}
+	return r
+}
+
+// CursorPaginatedResponse represents a cursor-paginated API response
+type CursorPaginatedResponse struct {
+	Data interface{} `json:"data"`
+	Meta CursorMeta  `json:"meta"`
+}
+
+// NewCursorPaginated creates a new cursor-paginated response instance
+func NewCursorPaginated(data interface{}, itemsPerPage int64, nextCursor string) *CursorPaginatedResponse {
+	r := &CursorPaginatedResponse{Data: data}
+	r.Meta.ItemsPerPage = itemsPerPage
+	r.Meta.NextCursor = nextCursor
+	r.Meta.HasMore = nextCursor != ""
+	return r
 }
 
 // New creates a new JSON response instance
@@ -31,7 +77,8 @@ func New(statusCode int, message string, data interface{}) *Response {
 	}
 }
 
-// Send sends a JSON response with the given status code, message, and data
-func Send(c echo.Context, statusCode int, message string, data interface{}) error {
-	return c.JSON(statusCode, New(statusCode, message, data))
+// Send sends an enveloped success response with the envelope's status code.
+// Every success helper that uses the envelope goes through Send.
+func Send(c echo.Context, envelope SuccessEnvelope) error {
+	return c.JSON(envelope.Status, envelope)
 }
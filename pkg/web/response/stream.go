@@ -0,0 +1,106 @@
+package response
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StreamFlushInterval is how often buffered stream output is flushed to the client
+var StreamFlushInterval = 100 * time.Millisecond
+
+// Stream sends a 200 OK response whose body is written by fn as it is produced.
+// The status line is sent with the first write, so if fn fails before writing anything
+// its error is returned and the caller can still send a regular error response.
+// Output is flushed every StreamFlushInterval and once fn returns. When the client
+// disconnects, writes fail with the request context's error so fn stops early.
+func Stream(c echo.Context, contentType string, fn func(w io.Writer) error) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+
+	ctx := c.Request().Context()
+	w := &streamWriter{ctx: ctx, res: res}
+	stop := w.flushEvery(StreamFlushInterval)
+	err := fn(w)
+	stop()
+
+	if ctx.Err() != nil {
+		// The client is gone; there is nobody left to report to
+		return nil
+	}
+	if err != nil && !res.Committed {
+		return err
+	}
+	if err != nil {
+		// Headers are already sent; the truncated stream is all we can signal
+		slog.Error("Stream interrupted", "path", c.Path(), "error", err)
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !res.Committed {
+		res.WriteHeader(http.StatusOK)
+	}
+	res.Flush()
+	return nil
+}
+
+// streamWriter commits the response on first write and tracks whether there is unflushed output
+type streamWriter struct {
+	ctx   context.Context
+	res   *echo.Response
+	mu    sync.Mutex
+	dirty bool
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.res.Committed {
+		w.res.WriteHeader(http.StatusOK)
+	}
+	w.dirty = true
+	return w.res.Write(p)
+}
+
+// flushEvery flushes pending output on every tick until the returned stop function is called
+func (w *streamWriter) flushEvery(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+				w.mu.Lock()
+				if w.dirty {
+					w.res.Flush()
+					w.dirty = false
+				}
+				w.mu.Unlock()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
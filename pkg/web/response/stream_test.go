@@ -0,0 +1,128 @@
+package response
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestStreamDeliversChunksAsTheyAreWritten(t *testing.T) {
+	defer func(interval time.Duration) { StreamFlushInterval = interval }(StreamFlushInterval)
+	StreamFlushInterval = 5 * time.Millisecond
+
+	firstRead := make(chan struct{})
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return Stream(c, "application/x-ndjson", func(w io.Writer) error {
+			if _, err := io.WriteString(w, "first\n"); err != nil {
+				return err
+			}
+			// The second line is only written once the client has received the first
+			select {
+			case <-firstRead:
+			case <-time.After(5 * time.Second):
+				return errors.New("first chunk was never delivered")
+			}
+			_, err := io.WriteString(w, "second\n")
+			return err
+		})
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(echo.HeaderContentType) != "application/x-ndjson" {
+		t.Fatalf("expected 200 application/x-ndjson, got %d %q", resp.StatusCode, resp.Header.Get(echo.HeaderContentType))
+	}
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("expected a chunked response, got %v", resp.TransferEncoding)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for i, want := range []string{"first\n", "second\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != want {
+			t.Fatalf("line %d: expected %q, got %q (%v)", i+1, want, line, err)
+		}
+		if i == 0 {
+			close(firstRead)
+		}
+	}
+}
+
+func TestStreamStopsWhenClientDisconnects(t *testing.T) {
+	defer func(interval time.Duration) { StreamFlushInterval = interval }(StreamFlushInterval)
+	StreamFlushInterval = 5 * time.Millisecond
+
+	type result struct {
+		fnErr, streamErr error
+	}
+	done := make(chan result, 1)
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		var fnErr error
+		streamErr := Stream(c, "text/plain", func(w io.Writer) error {
+			for {
+				if _, fnErr = io.WriteString(w, "tick\n"); fnErr != nil {
+					return fnErr
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+		done <- result{fnErr, streamErr}
+		return streamErr
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatalf("failed to read the first chunk: %v", err)
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case r := <-done:
+		if !errors.Is(r.fnErr, context.Canceled) {
+			t.Errorf("expected writes to fail with context.Canceled, got %v", r.fnErr)
+		}
+		if r.streamErr != nil {
+			t.Errorf("expected Stream to return nil after a disconnect, got %v", r.streamErr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to stop after the client disconnected")
+	}
+}
+
+func TestStreamReturnsErrorsBeforeFirstWrite(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	boom := errors.New("boom")
+	if err := Stream(c, "text/csv", func(w io.Writer) error { return boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected the error to be returned, got %v", err)
+	}
+	if c.Response().Committed {
+		t.Error("expected the response to be left uncommitted so an error response can be sent")
+	}
+}
@@ -7,8 +7,16 @@ import (
 )
 
 // Success sends a success response with the given message and data (HTTP 2XX)
+// If the envelope is disabled for the request, only the data is sent.
+// A 204 status is sent with NoContent, since it cannot carry a body.
 func Success(c echo.Context, statusCode int, message string, data interface{}) error {
-	return Send(c, statusCode, message, data)
+	if statusCode == http.StatusNoContent {
+		return NoContent(c)
+	}
+	if !EnvelopeEnabled(c) {
+		return c.JSON(statusCode, data)
+	}
+	return Send(c, SuccessEnvelope{Status: statusCode, Message: message, Data: data})
 }
 
 // OK sends a 200 OK response with the given message and data
@@ -26,7 +34,33 @@ func Accepted(c echo.Context, message string, data interface{}) error {
 	return Success(c, http.StatusAccepted, message, data)
 }
 
-// NoContent sends a 204 No Content response
+// Paginated sends a 200 OK paginated response with the page data and total item count.
+// If the envelope is disabled for the request, only the data and meta are sent.
+func Paginated(c echo.Context, data interface{}, page, itemsPerPage, totalItems int64) error {
+	r := NewPaginated(data, page, itemsPerPage, totalItems)
+	if !EnvelopeEnabled(c) {
+		return c.JSON(http.StatusOK, r)
+	}
+	return Send(c, SuccessEnvelope{Status: http.StatusOK, Message: http.StatusText(http.StatusOK), Data: r.Data, Meta: r.Meta})
+}
+
+// CursorPaginated sends a 200 OK cursor-paginated response with the page data and next cursor.
+// If the envelope is disabled for the request, only the data and meta are sent.
+func CursorPaginated(c echo.Context, data interface{}, itemsPerPage int64, nextCursor string) error {
+	r := NewCursorPaginated(data, itemsPerPage, nextCursor)
+	if !EnvelopeEnabled(c) {
+		return c.JSON(http.StatusOK, r)
+	}
+	return Send(c, SuccessEnvelope{Status: http.StatusOK, Message: http.StatusText(http.StatusOK), Data: r.Data, Meta: r.Meta})
+}
+
+// NoContent sends a 204 No Content response with an empty body and no envelope.
+// Content headers set earlier, e.g. by middleware, are removed since there is no content
+// for them to describe and some clients reject a 204 that has them.
 func NoContent(c echo.Context) error {
+	header := c.Response().Header()
+	header.Del(echo.HeaderContentType)
+	header.Del(echo.HeaderContentLength)
+	header.Del(echo.HeaderContentEncoding)
 	return c.NoContent(http.StatusNoContent)
 }
@@ -0,0 +1,143 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestNoContentSendsEmptyBody(t *testing.T) {
+	handlers := map[string]echo.HandlerFunc{
+		"NoContent": func(c echo.Context) error {
+			// Headers set before the handler knew there would be no content
+			c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c.Response().Header().Set(echo.HeaderContentLength, "42")
+			return NoContent(c)
+		},
+		"Success with 204": func(c echo.Context) error {
+			return Success(c, http.StatusNoContent, "Deleted", map[string]string{"id": "1"})
+		},
+	}
+
+	for name, h := range handlers {
+		e := echo.New()
+		e.DELETE("/", h)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/", nil))
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("%s: expected 204, got %d", name, rec.Code)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty body, got %q", name, rec.Body.String())
+		}
+		for _, header := range []string{echo.HeaderContentType, echo.HeaderContentLength, echo.HeaderContentEncoding} {
+			if got := rec.Header().Get(header); got != "" {
+				t.Errorf("%s: expected no %s header, got %q", name, header, got)
+			}
+		}
+	}
+}
+
+func TestSuccessHelpersShareEnvelope(t *testing.T) {
+	items := []testItem{{ID: "1"}, {ID: "2"}}
+	tests := []struct {
+		name     string
+		h        echo.HandlerFunc
+		status   int
+		message  string
+		wantMeta map[string]interface{}
+	}{
+		{
+			name:    "OK",
+			h:       func(c echo.Context) error { return OK(c, "Items retrieved", items) },
+			status:  http.StatusOK,
+			message: "Items retrieved",
+		},
+		{
+			name:    "Created",
+			h:       func(c echo.Context) error { return Created(c, "Items created", items) },
+			status:  http.StatusCreated,
+			message: "Items created",
+		},
+		{
+			name:    "Paginated",
+			h:       func(c echo.Context) error { return Paginated(c, items, 2, 2, 5) },
+			status:  http.StatusOK,
+			message: http.StatusText(http.StatusOK),
+			wantMeta: map[string]interface{}{
+				"current_page": float64(2), "items_per_page": float64(2), "total_items": float64(5), "total_pages": float64(3),
+			},
+		},
+		{
+			name:    "CursorPaginated",
+			h:       func(c echo.Context) error { return CursorPaginated(c, items, 2, "next") },
+			status:  http.StatusOK,
+			message: http.StatusText(http.StatusOK),
+			wantMeta: map[string]interface{}{
+				"items_per_page": float64(2), "next_cursor": "next", "has_more": true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		e.GET("/", tt.h)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, rec.Code)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: failed to decode body: %v", tt.name, err)
+		}
+		if body["status_code"] != float64(tt.status) || body["message"] != tt.message {
+			t.Errorf("%s: expected status %d and message %q in envelope, got %v", tt.name, tt.status, tt.message, body)
+		}
+		if data, ok := body["data"].([]interface{}); !ok || len(data) != len(items) {
+			t.Errorf("%s: expected %d items in data, got %v", tt.name, len(items), body["data"])
+		}
+
+		meta, hasMeta := body["meta"].(map[string]interface{})
+		if tt.wantMeta == nil {
+			if _, ok := body["meta"]; ok {
+				t.Errorf("%s: expected no meta, got %v", tt.name, body["meta"])
+			}
+			continue
+		}
+		if !hasMeta || len(meta) != len(tt.wantMeta) {
+			t.Errorf("%s: expected meta %v, got %v", tt.name, tt.wantMeta, body["meta"])
+			continue
+		}
+		for key, want := range tt.wantMeta {
+			if meta[key] != want {
+				t.Errorf("%s: expected meta %s = %v, got %v", tt.name, key, want, meta[key])
+			}
+		}
+	}
+}
+
+func TestPaginatedWithoutEnvelopeKeepsMeta(t *testing.T) {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return Paginated(c, []testItem{{ID: "1"}}, 1, 10, 1)
+	}, WithoutEnvelope())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if _, ok := body["status_code"]; ok {
+		t.Errorf("expected no envelope, got %v", body)
+	}
+	if _, ok := body["meta"].(map[string]interface{}); !ok {
+		t.Errorf("expected the page meta to be kept, got %v", body)
+	}
+}
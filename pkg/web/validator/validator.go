@@ -4,12 +4,40 @@ package validator
 import (
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync/atomic"
+
+	"go-echo-mongo/pkg/strutil"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// DefaultMaxBatchSize is the default number of items a batch request may hold
+const DefaultMaxBatchSize = 1000
+
+var maxBatchSize atomic.Int64
+
+func init() {
+	maxBatchSize.Store(DefaultMaxBatchSize)
+}
+
+// SetMaxBatchSize sets the number of items the maxbatch tag allows in a slice or map.
+// Values <= 0 are ignored.
+func SetMaxBatchSize(size int) {
+	if size <= 0 {
+		return
+	}
+	maxBatchSize.Store(int64(size))
+}
+
+// MaxBatchSize returns the number of items the maxbatch tag allows
+func MaxBatchSize() int64 {
+	return maxBatchSize.Load()
+}
+
 // CustomValidator is a custom validator for Echo
 type CustomValidator struct {
 	validator *validator.Validate
@@ -28,11 +56,37 @@ func New() *CustomValidator {
 		return name
 	})
 
+	// username accepts 3 to 30 letters, digits, underscores or hyphens
+	_ = v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return strutil.IsUsername(fl.Field().String())
+	})
+
+	// maxbatch limits batch request bodies to the configured maximum batch size
+	_ = v.RegisterValidation("maxbatch", func(fl validator.FieldLevel) bool {
+		return int64(fl.Field().Len()) <= maxBatchSize.Load()
+	})
+
+	// objectid accepts 24-character hex MongoDB ObjectIDs; use dive,objectid for slices
+	_ = v.RegisterValidation("objectid", func(fl validator.FieldLevel) bool {
+		return primitive.IsValidObjectID(fl.Field().String())
+	})
+
 	return &CustomValidator{
 		validator: v,
 	}
 }
 
+// InvalidObjectIDKey returns a key of m that is not a valid ObjectID hex string, if there is one.
+// Batch requests keyed by ID can't declare the objectid tag on their map keys, so handlers check them with this.
+func InvalidObjectIDKey[V any](m map[string]V) (string, bool) {
+	for key := range m {
+		if !primitive.IsValidObjectID(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
 // Validate validates the provided struct
 func (cv *CustomValidator) Validate(i interface{}) error {
 	if err := cv.validator.Struct(i); err != nil {
@@ -83,6 +137,16 @@ func getErrorMessage(e validator.FieldError) string {
 		return "Must be a valid number"
 	case "datetime":
 		return "Invalid datetime format"
+	case "role":
+		return "Unknown role"
+	case "username":
+		return "Must be 3 to 30 letters, digits, underscores or hyphens"
+	case "required_without":
+		return "This field is required"
+	case "objectid":
+		return "Must be a valid ObjectID"
+	case "maxbatch":
+		return "Must contain at most " + strconv.FormatInt(maxBatchSize.Load(), 10) + " items"
 	}
 	return "Invalid value"
 }
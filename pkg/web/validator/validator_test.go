@@ -0,0 +1,64 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestObjectIDValidation(t *testing.T) {
+	type request struct {
+		ID  string   `json:"id" validate:"objectid"`
+		IDs []string `json:"ids" validate:"dive,objectid"`
+	}
+
+	valid := primitive.NewObjectID().Hex()
+	tests := []struct {
+		name    string
+		req     request
+		invalid string
+	}{
+		{"valid", request{ID: valid, IDs: []string{valid, strings.ToUpper(valid)}}, ""},
+		{"too short", request{ID: valid[:23], IDs: []string{valid}}, "id"},
+		{"not hex", request{ID: "zzzzzzzzzzzzzzzzzzzzzzzz", IDs: []string{valid}}, "id"},
+		{"empty", request{ID: "", IDs: []string{valid}}, "id"},
+		{"invalid slice element", request{ID: valid, IDs: []string{valid, "not-an-id"}}, "ids[1]"},
+	}
+
+	v := New()
+	for _, tt := range tests {
+		err := v.Validate(&tt.req)
+		if tt.invalid == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+
+		var he *echo.HTTPError
+		if !errors.As(err, &he) {
+			t.Fatalf("%s: expected an HTTP error, got %v", tt.name, err)
+		}
+		messages := he.Message.(map[string]string)
+		if len(messages) != 1 || messages[tt.invalid] != "Must be a valid ObjectID" {
+			t.Errorf("%s: expected %s to be reported, got %v", tt.name, tt.invalid, messages)
+		}
+	}
+}
+
+func TestInvalidObjectIDKey(t *testing.T) {
+	valid := primitive.NewObjectID().Hex()
+
+	if key, invalid := InvalidObjectIDKey(map[string]int{valid: 1, primitive.NewObjectID().Hex(): 2}); invalid {
+		t.Errorf("expected every key to be valid, got %q", key)
+	}
+	if key, invalid := InvalidObjectIDKey(map[string]int{valid: 1, "123": 2}); !invalid || key != "123" {
+		t.Errorf("expected key 123 to be reported, got %q, %v", key, invalid)
+	}
+	if _, invalid := InvalidObjectIDKey(map[string]int(nil)); invalid {
+		t.Error("expected an empty map to have no invalid keys")
+	}
+}